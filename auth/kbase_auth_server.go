@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -42,76 +43,75 @@ type KBaseAuthServer struct {
 
 // constructs or retrieves a proxy to the KBase authentication server using the
 // given OAuth2 access token (corresponding to the current user), or returns a
-// non-nil error explaining any issue encountered
+// non-nil error explaining any issue encountered. Validated access tokens are
+// cached (see SetCacheTTL), so repeated calls with the same token don't
+// contact the auth server until its cache entry expires.
 func NewKBaseAuthServer(accessToken string) (*KBaseAuthServer, error) {
 
-	// check our list of KBase auth server instances for this access token
-	if instances == nil {
-		instances = make(map[string]*KBaseAuthServer)
-	}
-	if server, found := instances[accessToken]; found {
-		return server, nil
-	} else {
-		server := KBaseAuthServer{
-			URL:         fmt.Sprintf("%s/services/auth", kbaseURL),
-			ApiVersion:  2,
-			AccessToken: accessToken,
-		}
+	// check our cache of KBase auth server instances for this access token
+	if entry, found := cachedInstance(accessToken); found {
+		return entry.Server, nil
+	}
 
-		// verify that the access token works (i.e. that the client is logged in)
-		kbaseUser, err := server.kbaseUser()
-		if err != nil {
-			return nil, err
-		}
+	server := KBaseAuthServer{
+		URL:         fmt.Sprintf("%s/services/auth", kbaseURL),
+		ApiVersion:  2,
+		AccessToken: accessToken,
+	}
+
+	// verify that the access token works (i.e. that the client is logged in)
+	kbaseUser, err := server.kbaseUser()
+	if err != nil {
+		return nil, err
+	}
 
-		// register the local username under all its ORCIDs with our KBase user
-		// federation mechanism
-		for _, pid := range kbaseUser.Idents {
-			if pid.Provider == "OrcID" {
-				orcid := pid.UserName
-				err = SetKBaseLocalUsernameForOrcid(orcid, kbaseUser.Username)
-				if err != nil {
-					break
-				}
+	// register the local username under all its ORCIDs with our KBase user
+	// federation mechanism
+	for _, pid := range kbaseUser.Idents {
+		if pid.Provider == "OrcID" {
+			orcid := pid.UserName
+			err = SetKBaseLocalUsernameForOrcid(orcid, kbaseUser.Username)
+			if err != nil {
+				break
 			}
 		}
+	}
 
-		if err == nil {
-			// register this instance of the auth server
-			instances[accessToken] = &server
-		}
-		return &server, err
+	if err == nil {
+		// register this instance of the auth server
+		cacheInstance(&server, kbaseUser)
 	}
+	return &server, err
 }
 
 // returns a normalized user record for the current KBase user
 func (server KBaseAuthServer) Client() (Client, error) {
+	if entry, found := cachedInstance(server.AccessToken); found {
+		return entry.Client, nil
+	}
 	kbUser, err := server.kbaseUser()
 	if err != nil {
 		return Client{}, err
 	}
-	client := Client{
-		Name:     kbUser.Display,
-		Username: kbUser.Username,
-		Email:    kbUser.Email,
-	}
-	for _, pid := range kbUser.Idents {
-		// grab the first ORCID associated with the user
-		if pid.Provider == "OrcID" {
-			client.Orcid = pid.UserName
-			break
-		}
-	}
-	return client, nil
+	cacheInstance(&server, kbUser)
+	return clientFromKBaseUser(kbUser), nil
+}
+
+// sets the duration for which validated access tokens (and their users) are
+// cached, after which the auth server is consulted again. A non-positive
+// duration disables caching.
+func SetCacheTTL(ttl time.Duration) {
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+	cacheTTL = ttl
 }
 
 //-----------
 // Internals
 //-----------
 
-const (
-	kbaseURL = "https://kbase.us"
-)
+// base URL for KBase services (a variable so it can be set for testing)
+var kbaseURL = "https://kbase.us"
 
 // a record containing information about a user logged into the KBase Auth2
 // server
@@ -140,9 +140,78 @@ type kbaseAuthErrorResponse struct {
 	Time       time.Duration `json:"time"`
 }
 
-// here's a set of instances to the KBase auth server, mapped by OAuth2
+// a cached instance of the KBase auth server with information about its user
+type cacheEntry struct {
+	Server     *KBaseAuthServer
+	Client     Client
+	Expiration time.Time
+}
+
+// here's a cache of instances to the KBase auth server, mapped by OAuth2
 // access token
-var instances map[string]*KBaseAuthServer
+var instances = make(map[string]cacheEntry)
+var instancesMutex sync.Mutex
+
+// duration for which access tokens are cached
+var cacheTTL = 5 * time.Minute
+
+// retrieves an unexpired cache entry for the given access token, if any
+func cachedInstance(accessToken string) (cacheEntry, bool) {
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+	entry, found := instances[accessToken]
+	if found && time.Now().After(entry.Expiration) {
+		delete(instances, accessToken)
+		found = false
+	}
+	return entry, found
+}
+
+// caches the given auth server instance along with its user, expiring the
+// entry after the cache TTL or the token's expiration, whichever comes first
+func cacheInstance(server *KBaseAuthServer, user kbaseUser) {
+	// fetch the token's expiration before locking, since it's a request to
+	// the auth server
+	tokenExpiration, err := server.tokenExpiration()
+
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+	if cacheTTL > 0 {
+		expiration := time.Now().Add(cacheTTL)
+		if err == nil && tokenExpiration.Before(expiration) {
+			expiration = tokenExpiration
+		}
+		instances[server.AccessToken] = cacheEntry{
+			Server:     server,
+			Client:     clientFromKBaseUser(user),
+			Expiration: expiration,
+		}
+	}
+}
+
+// removes any cache entry for the given access token
+func uncacheInstance(accessToken string) {
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+	delete(instances, accessToken)
+}
+
+// converts a KBase user record to a normalized client record
+func clientFromKBaseUser(kbUser kbaseUser) Client {
+	client := Client{
+		Name:     kbUser.Display,
+		Username: kbUser.Username,
+		Email:    kbUser.Email,
+	}
+	for _, pid := range kbUser.Idents {
+		// grab the first ORCID associated with the user
+		if pid.Provider == "OrcID" {
+			client.Orcid = pid.UserName
+			break
+		}
+	}
+	return client
+}
 
 // emits an error representing the error in a response to the auth server
 func kbaseAuthError(response *http.Response) error {
@@ -202,6 +271,9 @@ func (server KBaseAuthServer) kbaseUser() (kbaseUser, error) {
 		return user, err
 	}
 	if resp.StatusCode != 200 {
+		if resp.StatusCode == http.StatusUnauthorized { // token no longer valid
+			uncacheInstance(server.AccessToken)
+		}
 		err = kbaseAuthError(resp)
 		if err != nil {
 			return user, err
@@ -230,3 +302,33 @@ func (server KBaseAuthServer) kbaseUser() (kbaseUser, error) {
 	}
 	return user, err
 }
+
+// returns the time at which the server's access token expires
+func (server KBaseAuthServer) tokenExpiration() (time.Time, error) {
+	resp, err := server.get("token")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		err = kbaseAuthError(resp)
+		if err == nil {
+			err = fmt.Errorf("KBase Auth error: %d", resp.StatusCode)
+		}
+		return time.Time{}, err
+	}
+	var token struct {
+		Expires int64 `json:"expires"` // milliseconds since the epoch
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &token)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if token.Expires == 0 {
+		return time.Time{}, fmt.Errorf("KBase Auth2: No expiration time for token!")
+	}
+	return time.UnixMilli(token.Expires), nil
+}
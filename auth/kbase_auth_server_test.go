@@ -28,8 +28,12 @@
 package auth
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -68,3 +72,62 @@ func TestClient(t *testing.T) {
 	assert.True(len(client.Email) > 0)
 	assert.Equal(os.Getenv("DTS_KBASE_TEST_ORCID"), client.Orcid)
 }
+
+// tests whether validated tokens are cached, using a mock auth server
+func TestCachedToken(t *testing.T) {
+	assert := assert.New(t)
+
+	// count the requests made to a mock auth server
+	numUserRequests := 0
+	valid := true
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "MOCK_TOKEN" || !valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": {"httpcode": 401, "message": "Invalid token"}}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/services/auth/api/V2/me":
+			numUserRequests++
+			fmt.Fprint(w, `{"user": "mockuser", "display": "Mock User", "email": "mock@example.com",
+"idents": [{"provider": "OrcID", "provusername": "0000-0000-0000-0000"}]}`)
+		case "/services/auth/api/V2/token":
+			fmt.Fprintf(w, `{"expires": %d}`, time.Now().Add(time.Hour).UnixMilli())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+	realURL := kbaseURL
+	kbaseURL = mockServer.URL
+	defer func() { kbaseURL = realURL }()
+
+	// the first request contacts the auth server
+	server, err := NewKBaseAuthServer("MOCK_TOKEN")
+	assert.Nil(err)
+	client, err := server.Client()
+	assert.Nil(err)
+	assert.Equal("0000-0000-0000-0000", client.Orcid)
+	assert.Equal(1, numUserRequests)
+
+	// the second doesn't
+	server, err = NewKBaseAuthServer("MOCK_TOKEN")
+	assert.Nil(err)
+	client, err = server.Client()
+	assert.Nil(err)
+	assert.Equal("mockuser", client.Username)
+	assert.Equal(1, numUserRequests)
+
+	// an expired entry sends us back to the auth server, which invalidates
+	// the token
+	instancesMutex.Lock()
+	entry := instances["MOCK_TOKEN"]
+	entry.Expiration = time.Now().Add(-time.Second)
+	instances["MOCK_TOKEN"] = entry
+	instancesMutex.Unlock()
+	valid = false
+	_, err = NewKBaseAuthServer("MOCK_TOKEN")
+	assert.NotNil(err)
+	_, found := cachedInstance("MOCK_TOKEN")
+	assert.False(found)
+}
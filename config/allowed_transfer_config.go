@@ -0,0 +1,56 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// A pair of source and destination databases between which transfers are
+// permitted, given in the config file in the form "source -> destination".
+// Either database name may contain wildcards ("*" matches any database).
+type AllowedTransfer struct {
+	// the name (or pattern) of the source database
+	Source string
+	// the name (or pattern) of the destination database
+	Destination string
+}
+
+// parses an allowed transfer from a string of the form "source -> destination",
+// returning false if the string is not of this form
+func parseAllowedTransfer(s string) (AllowedTransfer, bool) {
+	source, destination, found := strings.Cut(s, "->")
+	transfer := AllowedTransfer{
+		Source:      strings.TrimSpace(source),
+		Destination: strings.TrimSpace(destination),
+	}
+	return transfer, found && transfer.Source != "" && transfer.Destination != ""
+}
+
+// returns true if the allowed transfer permits transfers from the given source
+// database to the given destination database
+func (t AllowedTransfer) Permits(source, destination string) bool {
+	sourceMatches, _ := path.Match(t.Source, source)
+	destinationMatches, _ := path.Match(t.Destination, destination)
+	return sourceMatches && destinationMatches
+}
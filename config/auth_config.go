@@ -27,8 +27,11 @@ import (
 
 type authConfig struct {
 	// the client ID (uuid)
-	ClientId uuid.UUID `yaml:"client_id"`
+	ClientId uuid.UUID `yaml:"client_id" doc:"the client ID"`
 	// the client secret used to obtain API access tokens or make requests
 	// DO NOT STORE THIS IN A CONFIG FILE! Use an environment variable instead
-	ClientSecret string `yaml:"client_secret"`
+	ClientSecret string `yaml:"client_secret" doc:"the client secret (use an environment variable)"`
+	// the interval (seconds) before an access token expires at which it is
+	// proactively refreshed (optional; providers choose a default if 0)
+	RefreshMargin int `yaml:"refresh_margin,omitempty" doc:"seconds before an access token expires at which it is refreshed"`
 }
@@ -25,9 +25,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/kbase/dts/logging"
 )
 
 // a type with service configuration parameters
@@ -40,9 +45,29 @@ type serviceConfig struct {
 	// maximum size of requested payload for transfer, past which transfer
 	// requests are rejected (gigabytes)
 	MaxPayloadSize float64 `json:"max_payload_size,omitempy" yaml:"max_payload_size,omitempty"`
+	// if positive, the free space (gigabytes) that must remain in the
+	// directories to which a transfer writes files (the manifest and data
+	// directories and the roots of local destination endpoints) once it's
+	// done, past which transfer requests are rejected
+	MinFreeSpace float64 `json:"min_free_space,omitempty" yaml:"min_free_space,omitempty"`
+	// maximum number of files to which a path prefix given in a transfer
+	// request may expand, above which the request is rejected (0 allows any
+	// number of files)
+	// default: 10000
+	MaxPrefixFiles int `json:"max_prefix_files" yaml:"max_prefix_files"`
 	// polling interval for checking transfer statuses (milliseconds)
 	// default: 1 minute
 	PollInterval int `json:"poll_interval" yaml:"poll_interval"`
+	// polling interval for checking the progress of file staging (milliseconds)
+	// default: poll_interval
+	StagingPollInterval int `json:"staging_poll_interval,omitempty" yaml:"staging_poll_interval,omitempty"`
+	// polling interval for checking the progress of file transfers (milliseconds)
+	// default: poll_interval
+	TransferPollInterval int `json:"transfer_poll_interval,omitempty" yaml:"transfer_poll_interval,omitempty"`
+	// polling interval for checking the progress of manifest transfers that
+	// finalize a transfer (milliseconds)
+	// default: poll_interval
+	FinalizePollInterval int `json:"finalize_poll_interval,omitempty" yaml:"finalize_poll_interval,omitempty"`
 	// name of endpoint with access to local filesystem
 	// (for generating and transferring manifests)
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
@@ -56,9 +81,129 @@ type serviceConfig struct {
 	DeleteAfter int `json:"delete_after" yaml:"delete_after"`
 	// flag indicating whether debug logging and other tools are enabled
 	Debug bool `json:"debug" yaml:"debug"`
+	// names of fields (e.g. in descriptors, request headers, and URL query
+	// parameters) whose values are redacted from the service's log; email
+	// addresses are redacted wherever they appear if "email" is among them,
+	// as are bearer credentials if "authorization" is
+	// default: [email, token, access_token, authorization, client_secret, password]
+	RedactedLogFields []string `json:"redacted_log_fields" yaml:"redacted_log_fields"`
 	// flag indicating whether an endpoint double-checks that files are staged
 	// (if not set, the endpoint will trust a database for staging status)
 	DoubleCheckStaging bool `json:"double_check_staging" yaml:"double_check_staging"`
+	// format of the manifest written to a transfer's destination folder
+	// ("frictionless" for a Frictionless data package or "bagit" for a BagIt bag)
+	// default: frictionless
+	ManifestFormat string `json:"manifest_format" yaml:"manifest_format"`
+	// flag indicating whether a Frictionless manifest is gzip-compressed
+	// (manifest.json.gz) before it's sent to a transfer's destination folder
+	CompressManifest bool `json:"compress_manifest" yaml:"compress_manifest"`
+	// flag indicating whether each resource in a transfer manifest records
+	// metadata for the endpoint from which it was transferred
+	ManifestEndpointMetadata bool `json:"manifest_endpoint_metadata" yaml:"manifest_endpoint_metadata"`
+	// flag indicating whether collection-level credit metadata (titles,
+	// descriptions, related identifiers) shared by all resources in a transfer
+	// manifest is recorded once in the package descriptor instead of in each
+	// resource
+	ManifestCollectionMetadata bool `json:"manifest_collection_metadata" yaml:"manifest_collection_metadata"`
+	// flag indicating whether the MD5 checksums of transferred files are
+	// recomputed at their destination (where its endpoint can compute them),
+	// with manifests recording the computed checksum and flagging any that
+	// disagree with those reported by the source database
+	RecomputeHashes bool `json:"recompute_hashes" yaml:"recompute_hashes"`
+	// flag indicating whether resources record metadata for the instruments
+	// (sequencers, spectrometers, etc) that generated their data, where the
+	// database provides it
+	InstrumentMetadata bool `json:"instrument_metadata" yaml:"instrument_metadata"`
+	// time for which validated access tokens are cached before being checked
+	// again with the auth server (seconds; 0 disables caching)
+	// default: 5 minutes
+	AuthCacheTTL int `json:"auth_cache_ttl" yaml:"auth_cache_ttl"`
+	// time for which search results are saved so that transfers can refer to
+	// them by search ID (seconds; 0 disables saved searches)
+	// default: 1 hour
+	SearchCacheTTL int `json:"search_cache_ttl" yaml:"search_cache_ttl"`
+	// maximum number of saved searches, past which the oldest are discarded
+	// (0 disables saved searches)
+	// default: 1000
+	SearchCacheSize int `json:"search_cache_size" yaml:"search_cache_size"`
+	// time for which the descriptors of files fetched from (or found in) a
+	// database are cached, so that e.g. transferring files right after finding
+	// them doesn't fetch their descriptors again (seconds; 0 disables caching)
+	// default: 60
+	DescriptorCacheTTL int `json:"descriptor_cache_ttl" yaml:"descriptor_cache_ttl"`
+	// maximum number of cached file descriptors, past which the least recently
+	// cached are discarded (0 disables caching)
+	// default: 10000
+	DescriptorCacheSize int `json:"descriptor_cache_size" yaml:"descriptor_cache_size"`
+	// maximum age of an incomplete transfer restored when the service starts,
+	// past which the transfer is marked failed instead of being resumed
+	// (seconds; 0 resumes transfers of any age)
+	// default: 0
+	ResumeMaxAge int `json:"resume_max_age" yaml:"resume_max_age"`
+	// flag indicating whether all data must be encrypted in transit, in which
+	// case transfers involving endpoints that use unencrypted connections are
+	// rejected
+	RequireEncryption bool `json:"require_encryption" yaml:"require_encryption"`
+	// maximum time the service waits on shutdown for file transfers it performs
+	// itself to finish before saving its state (seconds; 0 disables waiting)
+	// default: 1 minute
+	DrainTimeout int `json:"drain_timeout" yaml:"drain_timeout"`
+	// maximum number of files in a single transfer, above which a requested
+	// transfer is split into sub-transfers of at most this many files each
+	// (0 disables splitting)
+	// default: 0
+	MaxFilesPerTransfer int `json:"max_files_per_transfer" yaml:"max_files_per_transfer"`
+	// maximum number of files in a single transfer request, above which the
+	// request is rejected outright (0 allows any number of files)
+	// default: 0
+	MaxFilesPerRequest int `json:"max_files_per_request" yaml:"max_files_per_request"`
+	// the number of times a failed staging request or file transfer within a
+	// transfer is retried before the transfer fails (0 disables retries)
+	// default: 0
+	MaxTransferRetries int `json:"max_transfer_retries" yaml:"max_transfer_retries"`
+	// maximum number of transfers in progress at once, past which requested
+	// transfers wait to begin in order of priority (0 allows any number)
+	// default: 0
+	MaxConcurrentTransfers int `json:"max_concurrent_transfers" yaml:"max_concurrent_transfers"`
+	// action taken when a transfer includes a resource under embargo until a
+	// future date ("reject" fails the transfer, "warn" logs a warning and
+	// transfers the resource anyway)
+	// default: reject
+	EmbargoPolicy string `json:"embargo_policy" yaml:"embargo_policy"`
+	// secret used to sign the payloads POSTed to transfer completion callback
+	// URLs (HMAC-SHA256); transfer requests can't specify callback URLs unless
+	// this is set
+	CallbackSecret string `json:"callback_secret" yaml:"callback_secret"`
+	// URL schemes permitted for transfer completion callbacks ("http" and/or
+	// "https")
+	// default: [https]
+	CallbackSchemes []string `json:"callback_schemes" yaml:"callback_schemes"`
+	// ORCIDs of the users permitted to use the administrative endpoints
+	// (e.g. to purge transfer records)
+	// default: [] (nobody)
+	Admins []string `json:"admins" yaml:"admins"`
+	// flag indicating whether transfers requested by admins are exempt from the
+	// limits on the number of files and payload size of a transfer request
+	ExemptAdminsFromLimits bool `json:"exempt_admins_from_limits" yaml:"exempt_admins_from_limits"`
+	// product token identifying the service in the User-Agent header of its
+	// requests to databases (followed by the service's version)
+	// default: kbase-dts
+	UserAgent string `json:"user_agent" yaml:"user_agent"`
+	// maximum time the service spends reading a request, including its headers
+	// and body (seconds)
+	// default: 30 seconds
+	ReadTimeout int `json:"read_timeout" yaml:"read_timeout"`
+	// maximum time the service spends handling a request and writing its
+	// response (seconds)
+	// default: 2 minutes
+	WriteTimeout int `json:"write_timeout" yaml:"write_timeout"`
+	// maximum time an idle keep-alive connection to the service stays open
+	// (seconds)
+	// default: 2 minutes
+	IdleTimeout int `json:"idle_timeout" yaml:"idle_timeout"`
+	// maximum size of the body of a search or transfer request (bytes)
+	// default: 1 MiB
+	MaxBodyBytes int64 `json:"max_body_bytes" yaml:"max_body_bytes"`
 }
 
 // global config variables
@@ -66,6 +211,12 @@ var Service serviceConfig
 var Endpoints map[string]endpointConfig
 var Databases map[string]databaseConfig
 var MessageQueues map[string]messageQueueConfig
+var SMTP smtpConfig
+var Manifest manifestConfig
+
+// permitted source/destination database pairs for transfers (nil if all pairs
+// are permitted)
+var AllowedTransfers []AllowedTransfer
 
 // This struct performs the unmarshalling from the YAML config file and then
 // copies its fields to the globals above.
@@ -74,6 +225,10 @@ type configFile struct {
 	Databases     map[string]databaseConfig     `yaml:"databases"`
 	Endpoints     map[string]endpointConfig     `yaml:"endpoints"`
 	MessageQueues map[string]messageQueueConfig `yaml:"message_queues"`
+	SMTP          smtpConfig                    `yaml:"smtp"`
+	Manifest      manifestConfig                `yaml:"manifest"`
+	// "source -> destination" pairs (nil if all transfers are allowed)
+	AllowedTransfers []string `yaml:"allowed_transfers"`
 }
 
 // This helper locates and reads a configuration file, returning an error
@@ -87,8 +242,25 @@ func readConfig(bytes []byte) error {
 	conf.Service.Port = 8080
 	conf.Service.MaxConnections = 100
 	conf.Service.MaxPayloadSize = 100.0 // gigabytes
+	conf.Service.MaxPrefixFiles = 10000
 	conf.Service.PollInterval = int(time.Minute / time.Millisecond)
 	conf.Service.DeleteAfter = 7 * 24 * 3600
+	conf.Service.ManifestFormat = "frictionless"
+	conf.Service.EmbargoPolicy = "reject"
+	conf.Service.CallbackSchemes = []string{"https"}
+	conf.Service.AuthCacheTTL = 5 * 60
+	conf.Service.SearchCacheTTL = 3600
+	conf.Service.SearchCacheSize = 1000
+	conf.Service.DescriptorCacheTTL = 60
+	conf.Service.DescriptorCacheSize = 10000
+	conf.Service.DrainTimeout = 60
+	conf.Service.UserAgent = "kbase-dts"
+	conf.Service.ReadTimeout = 30
+	conf.Service.WriteTimeout = 120
+	conf.Service.IdleTimeout = 120
+	conf.Service.MaxBodyBytes = 1024 * 1024
+	conf.Service.RedactedLogFields = slices.Clone(logging.DefaultRedactedFields)
+	conf.SMTP.Port = 25
 	err := yaml.Unmarshal(bytes, &conf)
 	if err != nil {
 		log.Printf("Couldn't parse configuration data: %s\n", err)
@@ -97,6 +269,15 @@ func readConfig(bytes []byte) error {
 
 	// copy the config data into place, performing any needed conversions
 	Service = conf.Service
+	for _, interval := range []*int{
+		&Service.StagingPollInterval,
+		&Service.TransferPollInterval,
+		&Service.FinalizePollInterval,
+	} {
+		if *interval == 0 {
+			*interval = Service.PollInterval
+		}
+	}
 
 	Endpoints = conf.Endpoints
 	for name, endpoint := range Endpoints {
@@ -108,6 +289,23 @@ func readConfig(bytes []byte) error {
 
 	Databases = conf.Databases
 	MessageQueues = conf.MessageQueues
+	SMTP = conf.SMTP
+	Manifest = conf.Manifest
+
+	AllowedTransfers = nil
+	if conf.AllowedTransfers != nil {
+		AllowedTransfers = make([]AllowedTransfer, len(conf.AllowedTransfers))
+		for i, pair := range conf.AllowedTransfers {
+			transfer, ok := parseAllowedTransfer(pair)
+			if !ok {
+				return InvalidAllowedTransferConfigError{
+					Transfer: pair,
+					Message:  "must have the form 'source -> destination'",
+				}
+			}
+			AllowedTransfers[i] = transfer
+		}
+	}
 
 	return err
 }
@@ -124,6 +322,12 @@ func validateServiceParameters(params serviceConfig) error {
 				params.MaxConnections),
 		}
 	}
+	if params.MaxPrefixFiles < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative maximum number of files per prefix specified: (%d)",
+				params.MaxPrefixFiles),
+		}
+	}
 	if params.Endpoint != "" {
 		if _, found := Endpoints[params.Endpoint]; !found {
 			return InvalidServiceConfigError{
@@ -137,12 +341,164 @@ func validateServiceParameters(params serviceConfig) error {
 				params.PollInterval),
 		}
 	}
+	if params.StagingPollInterval <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive staging poll interval specified: (%d ms)",
+				params.StagingPollInterval),
+		}
+	}
+	if params.TransferPollInterval <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive transfer poll interval specified: (%d ms)",
+				params.TransferPollInterval),
+		}
+	}
+	if params.FinalizePollInterval <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive finalize poll interval specified: (%d ms)",
+				params.FinalizePollInterval),
+		}
+	}
 	if params.DeleteAfter <= 0 {
 		return InvalidServiceConfigError{
 			Message: fmt.Sprintf("Non-positive task deletion period specified: (%d h)",
 				params.DeleteAfter),
 		}
 	}
+	if params.AuthCacheTTL < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative auth cache TTL specified: (%d s)",
+				params.AuthCacheTTL),
+		}
+	}
+	if params.SearchCacheTTL < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative search cache TTL specified: (%d s)",
+				params.SearchCacheTTL),
+		}
+	}
+	if params.SearchCacheSize < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative search cache size specified: %d",
+				params.SearchCacheSize),
+		}
+	}
+	if params.DescriptorCacheTTL < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative descriptor cache TTL specified: (%d s)",
+				params.DescriptorCacheTTL),
+		}
+	}
+	if params.DescriptorCacheSize < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative descriptor cache size specified: %d",
+				params.DescriptorCacheSize),
+		}
+	}
+	if params.ResumeMaxAge < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative maximum age for resumed transfers specified: (%d s)",
+				params.ResumeMaxAge),
+		}
+	}
+	if params.UserAgent == "" || strings.ContainsAny(params.UserAgent, " \t/") {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid user_agent: '%s' (must be a product token without spaces or slashes)",
+				params.UserAgent),
+		}
+	}
+	if params.DrainTimeout < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative drain timeout specified: (%d s)",
+				params.DrainTimeout),
+		}
+	}
+	if params.ReadTimeout <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive read timeout specified: (%d s)",
+				params.ReadTimeout),
+		}
+	}
+	if params.WriteTimeout <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive write timeout specified: (%d s)",
+				params.WriteTimeout),
+		}
+	}
+	if params.IdleTimeout <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive idle timeout specified: (%d s)",
+				params.IdleTimeout),
+		}
+	}
+	if params.MaxBodyBytes <= 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Non-positive maximum request body size specified: (%d bytes)",
+				params.MaxBodyBytes),
+		}
+	}
+	if params.MaxFilesPerTransfer < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative maximum number of files per transfer specified: (%d)",
+				params.MaxFilesPerTransfer),
+		}
+	}
+	if params.MaxFilesPerRequest < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative maximum number of files per request specified: (%d)",
+				params.MaxFilesPerRequest),
+		}
+	}
+	if params.MinFreeSpace < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative minimum free space specified: (%g)",
+				params.MinFreeSpace),
+		}
+	}
+	if params.MaxTransferRetries < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative maximum number of transfer retries specified: (%d)",
+				params.MaxTransferRetries),
+		}
+	}
+	if params.MaxConcurrentTransfers < 0 {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Negative maximum number of concurrent transfers specified: (%d)",
+				params.MaxConcurrentTransfers),
+		}
+	}
+	if params.ManifestFormat != "frictionless" && params.ManifestFormat != "bagit" {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid manifest format: %s (must be frictionless or bagit)",
+				params.ManifestFormat),
+		}
+	}
+	if params.CompressManifest && params.ManifestFormat != "frictionless" {
+		return InvalidServiceConfigError{
+			Message: "Manifest compression requires the frictionless manifest format",
+		}
+	}
+	if params.EmbargoPolicy != "reject" && params.EmbargoPolicy != "warn" {
+		return InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid embargo policy: %s (must be reject or warn)",
+				params.EmbargoPolicy),
+		}
+	}
+	for _, scheme := range params.CallbackSchemes {
+		if scheme != "http" && scheme != "https" {
+			return InvalidServiceConfigError{
+				Message: fmt.Sprintf("Invalid callback URL scheme: %s (must be http or https)",
+					scheme),
+			}
+		}
+	}
+	for _, orcid := range params.Admins {
+		if orcid == "" {
+			return InvalidServiceConfigError{
+				Message: "Invalid admin: ORCIDs of admins must not be empty",
+			}
+		}
+	}
 	return nil
 }
 
@@ -160,12 +516,74 @@ func validateEndpoints(endpoints map[string]endpointConfig) error {
 				Message:  "No provider specified",
 			}
 		}
+		if endpoint.Auth.RefreshMargin < 0 {
+			return InvalidEndpointConfigError{
+				Endpoint: name,
+				Message:  fmt.Sprintf("Invalid refresh margin: %d (must be non-negative)", endpoint.Auth.RefreshMargin),
+			}
+		}
+		if endpoint.PreferHardlink && endpoint.Provider != "local" {
+			return InvalidEndpointConfigError{
+				Endpoint: name,
+				Message:  "prefer_hardlink is only supported by local endpoints",
+			}
+		}
+		if endpoint.MaxRetries != nil && *endpoint.MaxRetries < 0 {
+			return InvalidEndpointConfigError{
+				Endpoint: name,
+				Message:  fmt.Sprintf("Invalid max_retries: %d (must be non-negative)", *endpoint.MaxRetries),
+			}
+		}
+		if endpoint.RetryInterval < 0 {
+			return InvalidEndpointConfigError{
+				Endpoint: name,
+				Message:  fmt.Sprintf("Invalid retry_interval: %d (must be non-negative)", endpoint.RetryInterval),
+			}
+		}
+		if (endpoint.MaxRetries != nil || endpoint.RetryInterval > 0) && endpoint.Provider != "globus" {
+			return InvalidEndpointConfigError{
+				Endpoint: name,
+				Message:  "max_retries and retry_interval are only supported by Globus endpoints",
+			}
+		}
 	}
 	return nil
 }
 
 func validateDatabases(databases map[string]databaseConfig) error {
 	for name, db := range databases {
+		if db.RequestTimeout < 0 {
+			return InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("Invalid request timeout for database %s: %d (must be non-negative)", name, db.RequestTimeout),
+			}
+		}
+		if db.EnrichmentConcurrency < 0 {
+			return InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("Invalid enrichment concurrency for database %s: %d (must be non-negative)", name, db.EnrichmentConcurrency),
+			}
+		}
+		if _, valid := tlsVersions[db.MinTLSVersion]; db.MinTLSVersion != "" && !valid {
+			return InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("Invalid minimum TLS version for database %s: %s (must be 1.0, 1.1, 1.2, or 1.3)", name, db.MinTLSVersion),
+			}
+		}
+		if db.Provider != "" {
+			if db.Provider != "globus" && db.Provider != "local" {
+				return InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Invalid provider for database %s: %s (must be globus or local)", name, db.Provider),
+				}
+			}
+			if db.Endpoint == "" || Endpoints[db.Endpoint].Provider != db.Provider {
+				return InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Database %s requires a single %s endpoint", name, db.Provider),
+				}
+			}
+		}
 		if db.Endpoint == "" && len(db.Endpoints) == 0 {
 			return InvalidDatabaseConfigError{
 				Database: name,
@@ -199,6 +617,59 @@ func validateDatabases(databases map[string]databaseConfig) error {
 	return nil
 }
 
+func validateSMTP(smtp smtpConfig) error {
+	if smtp.Host != "" {
+		if smtp.Port <= 0 || smtp.Port > 65535 {
+			return InvalidSMTPConfigError{
+				Message: fmt.Sprintf("Invalid port: %d (must be 1-65535)", smtp.Port),
+			}
+		}
+		if smtp.From == "" {
+			return InvalidSMTPConfigError{
+				Message: "No sender (from) address specified",
+			}
+		}
+	}
+	return nil
+}
+
+func validateManifest(manifest manifestConfig) error {
+	for _, license := range manifest.Licenses {
+		if license.Name == "" {
+			return InvalidManifestConfigError{
+				Message: "No name specified for license",
+			}
+		}
+	}
+	if manifest.Publisher != nil && manifest.Publisher.Title == "" {
+		return InvalidManifestConfigError{
+			Message: "No title specified for publisher",
+		}
+	}
+	for _, funding := range manifest.Funding {
+		if funding.FunderName == "" {
+			return InvalidManifestConfigError{
+				Message: "No funder name specified for funding acknowledgment",
+			}
+		}
+	}
+	return nil
+}
+
+func validateAllowedTransfers(transfers []AllowedTransfer) error {
+	for _, transfer := range transfers {
+		for _, pattern := range []string{transfer.Source, transfer.Destination} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return InvalidAllowedTransferConfigError{
+					Transfer: fmt.Sprintf("%s -> %s", transfer.Source, transfer.Destination),
+					Message:  fmt.Sprintf("invalid database pattern: %s", pattern),
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // This helper validates the given configfile, returning an error that indicates
 // success or failure.
 func validateConfig() error {
@@ -211,6 +682,18 @@ func validateConfig() error {
 		return err
 	}
 	err = validateDatabases(Databases)
+	if err != nil {
+		return err
+	}
+	err = validateSMTP(SMTP)
+	if err != nil {
+		return err
+	}
+	err = validateManifest(Manifest)
+	if err != nil {
+		return err
+	}
+	err = validateAllowedTransfers(AllowedTransfers)
 	return err
 }
 
@@ -24,6 +24,7 @@ package config
 // These tests verify that we can properly configure the search service with
 // YAML input.
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
 	"testing"
@@ -93,6 +94,245 @@ func TestInitRejectsInvalidEndpointType(t *testing.T) {
 	assert.NotNil(t, err, "Config with invalid endpoint didn't trigger an error.")
 }
 
+// tests whether config.Init rejects prefer_hardlink for a non-local endpoint
+func TestInitRejectsHardlinksForNonLocalEndpoint(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + "    prefer_hardlink: true\n" + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Globus endpoint preferring hard links didn't trigger an error.")
+}
+
+// tests whether config.Init sets the default redacted log fields, and whether
+// they can be replaced
+func TestInitRedactedLogFields(t *testing.T) {
+	err := Init([]byte(VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES))
+	assert.Nil(t, err)
+	assert.Contains(t, Service.RedactedLogFields, "email")
+	assert.Contains(t, Service.RedactedLogFields, "authorization")
+
+	yaml := VALID_SERVICE + "  redacted_log_fields: [api_key]\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"api_key"}, Service.RedactedLogFields)
+}
+
+// tests whether config.Init accepts retry settings for a Globus endpoint and
+// rejects invalid ones
+func TestInitGlobusRetrySettings(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + "    max_retries: 5\n    retry_interval: 500\n" + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, "Globus endpoint with retry settings triggered an error.")
+	assert.Equal(t, 5, *Endpoints["my-globus-endpoint"].MaxRetries)
+	assert.Equal(t, 500, Endpoints["my-globus-endpoint"].RetryInterval)
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + "    max_retries: 0\n" + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, "Disabling retries triggered an error.")
+	assert.Equal(t, 0, *Endpoints["my-globus-endpoint"].MaxRetries)
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + "    max_retries: -1\n" + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.NotNil(t, err, "Negative max_retries didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS +
+		"  my-local-endpoint:\n    name: Local\n    id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6\n" +
+		"    provider: local\n    max_retries: 2\n" + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.NotNil(t, err, "Local endpoint with retry settings didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative maximum number of
+// concurrent transfers
+func TestInitRejectsNegativeMaxConcurrentTransfers(t *testing.T) {
+	yaml := VALID_SERVICE + "  max_concurrent_transfers: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative maximum concurrent transfers didn't trigger an error.")
+}
+
+// tests whether per-phase poll intervals default to the service's poll interval
+func TestInitDefaultsPhasePollIntervals(t *testing.T) {
+	yaml := VALID_SERVICE + "  transfer_poll_interval: 30\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, "Config with a transfer poll interval triggered an error.")
+	assert.Equal(t, 60, Service.StagingPollInterval)
+	assert.Equal(t, 30, Service.TransferPollInterval)
+	assert.Equal(t, 60, Service.FinalizePollInterval)
+}
+
+// tests whether config.Init reports an error for a negative per-phase poll
+// interval
+func TestInitRejectsNegativePhasePollInterval(t *testing.T) {
+	for _, param := range []string{"staging", "transfer", "finalize"} {
+		yaml := VALID_SERVICE + fmt.Sprintf("  %s_poll_interval: -1\n\n", param) +
+			VALID_ENDPOINTS + VALID_DATABASES
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, "Config with negative %s poll interval didn't trigger an error.", param)
+	}
+}
+
+// tests whether config.Init reports an error for a user agent that isn't a
+// product token
+func TestInitRejectsBadUserAgent(t *testing.T) {
+	for _, userAgent := range []string{"''", "kbase dts", "kbase-dts/1.0"} {
+		yaml := VALID_SERVICE + fmt.Sprintf("  user_agent: %s\n\n", userAgent) +
+			VALID_ENDPOINTS + VALID_DATABASES
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, "Config with user agent %s didn't trigger an error.", userAgent)
+	}
+}
+
+// tests whether config.Init reports an error for an invalid manifest format
+func TestInitRejectsBadManifestFormat(t *testing.T) {
+	yaml := VALID_SERVICE + "  manifest_format: zip\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad manifest format didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error when manifest compression is
+// requested for BagIt manifests
+func TestInitRejectsCompressedBagManifest(t *testing.T) {
+	yaml := VALID_SERVICE + "  manifest_format: bagit\n  compress_manifest: true\n\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with compressed BagIt manifest didn't trigger an error.")
+}
+
+// tests whether config.Init reports errors for a negative search cache TTL or
+// size
+func TestInitRejectsNegativeSearchCacheTTL(t *testing.T) {
+	yaml := VALID_SERVICE + "  search_cache_ttl: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative search cache TTL didn't trigger an error.")
+
+	yaml = VALID_SERVICE + "  search_cache_size: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with negative search cache size didn't trigger an error.")
+}
+
+// tests whether config.Init reports errors for a negative descriptor cache TTL
+// or size
+func TestInitRejectsNegativeDescriptorCacheParameters(t *testing.T) {
+	yaml := VALID_SERVICE + "  descriptor_cache_ttl: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with negative descriptor cache TTL didn't trigger an error.")
+	yaml = VALID_SERVICE + "  descriptor_cache_size: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with negative descriptor cache size didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative maximum age for
+// resumed transfers
+func TestInitRejectsNegativeResumeMaxAge(t *testing.T) {
+	yaml := VALID_SERVICE + "  resume_max_age: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative resume max age didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative drain timeout
+func TestInitRejectsNegativeDrainTimeout(t *testing.T) {
+	yaml := VALID_SERVICE + "  drain_timeout: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative drain timeout didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative maximum number of
+// files per transfer
+func TestInitRejectsNegativeMaxFilesPerTransfer(t *testing.T) {
+	yaml := VALID_SERVICE + "  max_files_per_transfer: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative maximum files per transfer didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative maximum number of
+// files per request
+func TestInitRejectsNegativeMaxFilesPerRequest(t *testing.T) {
+	yaml := VALID_SERVICE + "  max_files_per_request: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative maximum files per request didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative number of
+// transfer retries
+func TestInitRejectsNegativeMaxTransferRetries(t *testing.T) {
+	yaml := VALID_SERVICE + "  max_transfer_retries: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with negative maximum transfer retries didn't trigger an error.")
+}
+
+// tests whether config.Init rejects an unrecognized embargo policy
+func TestInitRejectsInvalidEmbargoPolicy(t *testing.T) {
+	yaml := VALID_SERVICE + "  embargo_policy: ignore\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with invalid embargo policy didn't trigger an error.")
+}
+
+// tests whether config.Init rejects callback URL schemes other than http and
+// https
+func TestInitRejectsInvalidCallbackSchemes(t *testing.T) {
+	yaml := VALID_SERVICE + "  callback_schemes: [https, ftp]\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with invalid callback URL scheme didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for an SMTP server without a
+// tests whether config.Init rejects an empty admin ORCID
+func TestInitRejectsEmptyAdmin(t *testing.T) {
+	yaml := VALID_SERVICE + "  admins: [\"0000-0002-1825-0097\", \"\"]\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with empty admin ORCID didn't trigger an error.")
+}
+
+// sender address
+func TestInitRejectsSMTPWithoutSender(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"smtp:\n  host: smtp.example.com\n"
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "SMTP config without sender didn't trigger an error.")
+	yaml += "  from: dts@example.com\n"
+	b = []byte(yaml)
+	err = Init(b)
+	assert.Nil(t, err, fmt.Sprintf("Valid SMTP config produced an error: %s", err))
+	assert.Equal(t, 25, SMTP.Port)
+}
+
+// tests whether config.Init reports errors for manifest metadata missing
+// required names, and reads valid metadata
+func TestInitManifestMetadata(t *testing.T) {
+	for _, manifest := range []string{
+		"manifest:\n  licenses:\n    - path: https://example.com/license\n",
+		"manifest:\n  publisher:\n    email: dts@example.com\n",
+		"manifest:\n  funding:\n    - grant_id: DE-AC02-05CH11231\n",
+	} {
+		yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + manifest
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, fmt.Sprintf("Manifest config didn't trigger an error: %s", manifest))
+	}
+
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"manifest:\n" +
+		"  keywords: [kbase]\n" +
+		"  licenses:\n    - name: CC0-1.0\n" +
+		"  publisher:\n    title: Example Laboratory\n" +
+		"  funding:\n    - funder_name: Example Foundation\n      grant_id: EF-1234\n"
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid manifest config produced an error: %s", err))
+	assert.Equal(t, []string{"kbase"}, Manifest.Keywords)
+	assert.Equal(t, "CC0-1.0", Manifest.Licenses[0].Name)
+	assert.Equal(t, "Example Laboratory", Manifest.Publisher.Title)
+	assert.Equal(t, "EF-1234", Manifest.Funding[0].GrantId)
+}
+
 // Tests whether config.Init rejects a database with a bad base URL.
 func TestInitRejectsBadDatabaseBaseURL(t *testing.T) {
 	yaml := fmt.Sprintf("databases:\n  ohaicorp:\n    url: hahahahahahaha\n\n")
@@ -101,6 +341,154 @@ func TestInitRejectsBadDatabaseBaseURL(t *testing.T) {
 	assert.NotNil(t, err, "Config with bad database URL didn't trigger an error.")
 }
 
+// Tests whether config.Init checks the provider of a generic database.
+func TestInitChecksDatabaseProvider(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"  collection:\n    name: Collection\n    endpoint: my-globus-endpoint\n    provider: globus\n"
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid Globus database produced an error: %s", err))
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"  collection:\n    name: Collection\n    endpoint: my-globus-endpoint\n    provider: ftp\n"
+	err = Init([]byte(yaml))
+	assert.NotNil(t, err, "Database with invalid provider didn't trigger an error.")
+
+	// a local database needs a local endpoint
+	localEndpoint := "  my-local-endpoint:\n    name: Local endpoint\n" +
+		"    id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6\n    provider: local\n    root: /tmp\n"
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + localEndpoint + VALID_DATABASES +
+		"  files:\n    name: Files\n    endpoint: my-local-endpoint\n    provider: local\n"
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid local database produced an error: %s", err))
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"  files:\n    name: Files\n    endpoint: my-globus-endpoint\n    provider: local\n"
+	err = Init([]byte(yaml))
+	assert.NotNil(t, err, "Local database with a Globus endpoint didn't trigger an error.")
+}
+
+// Tests whether config.Init rejects a negative minimum free space.
+func TestInitRejectsNegativeMinFreeSpace(t *testing.T) {
+	yaml := VALID_SERVICE + "  min_free_space: -1\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Negative minimum free space didn't trigger an error.")
+
+	yaml = VALID_SERVICE + "  min_free_space: 2.5\n\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid minimum free space produced an error: %s", err))
+	assert.Equal(t, 2.5, Service.MinFreeSpace)
+}
+
+// Tests whether databases report their single endpoint or their functional
+// endpoints (in order of function).
+func TestDatabaseEndpointNames(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + `  my-other-globus-endpoint:
+    name: Another Globus test endpoint
+    id: ${DTS_GLOBUS_TEST_ENDPOINT}
+    provider: globus
+` + VALID_DATABASES + `  nmdc:
+    name: National Microbiome Data Collaborative
+    organization: DOE
+    endpoints:
+      nersc: my-globus-endpoint
+      emsl: my-other-globus-endpoint
+`
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Database with functional endpoints produced an error: %s", err))
+	assert.Equal(t, []string{"my-globus-endpoint"}, Databases["jdp"].EndpointNames())
+	assert.Equal(t, []string{"my-other-globus-endpoint", "my-globus-endpoint"},
+		Databases["nmdc"].EndpointNames())
+	assert.Empty(t, Databases["nonexistent"].EndpointNames())
+}
+
+// Tests whether config.Init rejects non-positive server timeouts and request
+// body sizes.
+func TestInitRejectsNonPositiveServerLimits(t *testing.T) {
+	for _, setting := range []string{"read_timeout", "write_timeout", "idle_timeout", "max_body_bytes"} {
+		yaml := VALID_SERVICE + fmt.Sprintf("  %s: 0\n\n", setting) + VALID_ENDPOINTS + VALID_DATABASES
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, fmt.Sprintf("Zero %s didn't trigger an error.", setting))
+	}
+
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Default server limits produced an error: %s", err))
+	assert.Equal(t, 30, Service.ReadTimeout)
+	assert.Equal(t, 120, Service.WriteTimeout)
+	assert.Equal(t, 120, Service.IdleTimeout)
+	assert.Equal(t, int64(1024*1024), Service.MaxBodyBytes)
+}
+
+// Tests whether config.Init rejects a negative database request timeout.
+func TestInitRejectsNegativeRequestTimeout(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    request_timeout: -1\n"
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Database with negative request timeout didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    request_timeout: 30\n"
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid request timeout produced an error: %s", err))
+	assert.Equal(t, 30, Databases["jdp"].RequestTimeout)
+}
+
+// Tests whether config.Init rejects a negative database enrichment concurrency.
+func TestInitRejectsNegativeEnrichmentConcurrency(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    enrichment_concurrency: -1\n"
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Database with negative enrichment concurrency didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    enrichment_concurrency: 4\n"
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid enrichment concurrency produced an error: %s", err))
+	assert.Equal(t, 4, Databases["jdp"].EnrichmentConcurrency)
+}
+
+// Tests whether config.Init accepts database HTTP security settings and rejects
+// unknown TLS versions.
+func TestInitDatabaseHttpSettings(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    min_tls_version: \"1.4\"\n"
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Database with unknown TLS version didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"    secure_http: true\n    min_tls_version: \"1.3\"\n"
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid HTTP settings produced an error: %s", err))
+	assert.True(t, *Databases["jdp"].SecureHttp)
+	assert.Equal(t, uint16(tls.VersionTLS13), Databases["jdp"].MinTLS())
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(yaml))
+	assert.Nil(t, err)
+	assert.Nil(t, Databases["jdp"].SecureHttp)
+	assert.Zero(t, Databases["jdp"].MinTLS())
+}
+
+// Tests whether config.Init parses allowed transfers and rejects malformed ones.
+func TestInitParsesAllowedTransfers(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid YAML input produced an error: %s", err))
+	assert.Nil(t, AllowedTransfers)
+
+	yaml += "allowed_transfers:\n  - jdp -> kbase\n  - \"* -> archive\"\n"
+	err = Init([]byte(yaml))
+	assert.Nil(t, err, fmt.Sprintf("Valid allowed transfers produced an error: %s", err))
+	assert.Equal(t, []AllowedTransfer{
+		{Source: "jdp", Destination: "kbase"},
+		{Source: "*", Destination: "archive"},
+	}, AllowedTransfers)
+	assert.True(t, AllowedTransfers[1].Permits("nmdc", "archive"))
+	assert.False(t, AllowedTransfers[1].Permits("nmdc", "kbase"))
+
+	for _, transfer := range []string{"jdp", "jdp ->", "-> kbase", "\"[ -> kbase\""} {
+		yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+			"allowed_transfers:\n  - " + transfer + "\n"
+		err = Init([]byte(yaml))
+		assert.NotNil(t, err, fmt.Sprintf("Malformed allowed transfer didn't trigger an error: %s", transfer))
+	}
+}
+
 // Tests whether config.Init returns no error for a configuration that is
 // (ostensibly) valid. NOTE: This particular configuration is consistent and
 // contains acceptible values for fields. It won't actually run a service!
@@ -121,6 +509,7 @@ func TestInitProperlySetsGlobals(t *testing.T) {
 	// Check data
 	assert.Equal(t, 8080, Service.Port)
 	assert.Equal(t, 100, Service.MaxConnections)
+	assert.Equal(t, "frictionless", Service.ManifestFormat)
 	assert.Equal(t, 1, len(Endpoints))
 	assert.Equal(t, 1, len(Databases))
 }
@@ -21,16 +21,75 @@
 
 package config
 
+import (
+	"crypto/tls"
+	"slices"
+)
+
 // A database provides files for a file transfer (at its source or destination).
 type databaseConfig struct {
 	// the full name of the database
-	Name string `yaml:"name"`
+	Name string `yaml:"name" doc:"the full name of the database"`
 	// the name of the organization hosting the database
-	Organization string `yaml:"organization"`
+	Organization string `yaml:"organization" doc:"the name of the organization hosting the database"`
 	// if set, the name of the single endpoint available to this database
 	// (only one of Endpoint and Endpoints may be set)
-	Endpoint string `yaml:"endpoint,omitempty"`
+	Endpoint string `yaml:"endpoint,omitempty" doc:"the name of the database's single endpoint"`
 	// if set, a set of endpoints assigned functional names, available to thi
 	// database (only one of Endpoint and Endpoints may be set)
-	Endpoints map[string]string `yaml:"endpoints,omitempty"`
+	Endpoints map[string]string `yaml:"endpoints,omitempty" doc:"endpoint names keyed by functional name (instead of endpoint)"`
+	// if set, the provider of a generic database whose files are listed from
+	// its endpoint instead of being served by a dedicated integration
+	// ("globus" or "local")
+	Provider string `yaml:"provider,omitempty" doc:"the provider of a generic database (globus or local)"`
+	// if positive, the interval (in seconds) after which an HTTP request to
+	// the database is abandoned
+	RequestTimeout int `yaml:"request_timeout,omitempty" doc:"seconds after which an HTTP request to the database is abandoned"`
+	// if set, files the database reports as staged are checked against their
+	// descriptors' sizes (and hashes, where the endpoint can compute them) and
+	// staged again if they don't match (currently used only by "jdp")
+	VerifyStaging bool `yaml:"verify_staging,omitempty" doc:"if true, staged files are checked against their sizes (and hashes) and staged again on mismatch"`
+	// if positive, the number of requests for the metadata with which file
+	// descriptors are enriched that may be in flight at once (currently used
+	// only by "nmdc")
+	EnrichmentConcurrency int `yaml:"enrichment_concurrency,omitempty" doc:"the number of concurrent requests for metadata that enriches file descriptors"`
+	// if set, overrides whether HTTP requests to the database use HTTP Strict
+	// Transport Security (HSTS) and refuse redirects (the default depends on
+	// the database)
+	SecureHttp *bool `yaml:"secure_http,omitempty" doc:"if true, HTTP requests to the database use HSTS and refuse redirects"`
+	// if set, the minimum TLS version ("1.0", "1.1", "1.2", or "1.3") accepted
+	// for HTTPS requests to the database
+	MinTLSVersion string `yaml:"min_tls_version,omitempty" doc:"the minimum TLS version (1.0, 1.1, 1.2, or 1.3) accepted for HTTPS requests to the database"`
+}
+
+// TLS versions accepted for min_tls_version, mapped to their crypto/tls values
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// returns the crypto/tls value of the minimum TLS version configured for the
+// database, or zero if none is configured
+func (db databaseConfig) MinTLS() uint16 {
+	return tlsVersions[db.MinTLSVersion]
+}
+
+// returns the names of the endpoints available to the database: its single
+// endpoint, or its functional endpoints in order of their functional names
+func (db databaseConfig) EndpointNames() []string {
+	endpointNames := make([]string, 0)
+	if db.Endpoint != "" {
+		endpointNames = append(endpointNames, db.Endpoint)
+	}
+	functions := make([]string, 0, len(db.Endpoints))
+	for function := range db.Endpoints {
+		functions = append(functions, function)
+	}
+	slices.Sort(functions)
+	for _, function := range functions {
+		endpointNames = append(endpointNames, db.Endpoints[function])
+	}
+	return endpointNames
 }
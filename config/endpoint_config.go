@@ -6,13 +6,23 @@ import (
 
 type endpointConfig struct {
 	// descriptive name of the endpoint
-	Name string `yaml:"name"`
+	Name string `yaml:"name" doc:"descriptive name of the endpoint"`
 	// the endpoint ID (uuid)
-	Id uuid.UUID `yaml:"id"`
+	Id uuid.UUID `yaml:"id" doc:"the endpoint ID"`
 	// the name of the provider (e.g. "globus")
-	Provider string `yaml:"provider"`
+	Provider string `yaml:"provider" doc:"the name of the endpoint's provider (e.g. globus)"`
 	// authentication/authorization data (client secret used to request access token)
-	Auth authConfig `yaml:"auth,omitempty"`
+	Auth authConfig `yaml:"auth,omitempty" doc:"credentials used to request access tokens"`
 	// root directory for filesystem access (optional)
-	Root string `yaml:"root,omitempty"`
+	Root string `yaml:"root,omitempty" doc:"root directory (or URL) for file access"`
+	// if true, a local endpoint hard-links files it transfers instead of
+	// copying them where possible
+	PreferHardlink bool `yaml:"prefer_hardlink,omitempty" doc:"hard-link transferred files instead of copying them where possible (local endpoints only)"`
+	// the number of times a Globus endpoint retries a transfer submission or
+	// status check that fails transiently (e.g. with a 5xx response); nil if
+	// unspecified, so that 0 can disable retries
+	MaxRetries *int `yaml:"max_retries,omitempty" doc:"the number of times a transiently failing transfer submission or status check is retried (Globus endpoints only, 0 disables retries)"`
+	// the interval (in milliseconds) before a Globus endpoint's first retry,
+	// which doubles with each subsequent retry
+	RetryInterval int `yaml:"retry_interval,omitempty" doc:"milliseconds before the first retry of a transiently failing request, doubling with each retry (Globus endpoints only)"`
 }
@@ -51,3 +51,33 @@ type InvalidDatabaseConfigError struct {
 func (e InvalidDatabaseConfigError) Error() string {
 	return fmt.Sprintf("Database %s is not properly configured: %s", e.Database, e.Message)
 }
+
+// indicates that the SMTP server used for notifications is not configured
+// properly
+type InvalidSMTPConfigError struct {
+	Message string
+}
+
+func (e InvalidSMTPConfigError) Error() string {
+	return fmt.Sprintf("Invalid SMTP configuration: %s", e.Message)
+}
+
+// indicates that the metadata included in transfer manifests is not
+// configured properly
+type InvalidManifestConfigError struct {
+	Message string
+}
+
+func (e InvalidManifestConfigError) Error() string {
+	return fmt.Sprintf("Invalid manifest configuration: %s", e.Message)
+}
+
+// indicates that a permitted source/destination database pair for transfers
+// is not configured properly
+type InvalidAllowedTransferConfigError struct {
+	Transfer, Message string
+}
+
+func (e InvalidAllowedTransferConfigError) Error() string {
+	return fmt.Sprintf("Invalid allowed transfer '%s': %s", e.Transfer, e.Message)
+}
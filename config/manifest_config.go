@@ -0,0 +1,73 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// Metadata written into the manifest of every transfer (e.g. an institution's
+// license and funding acknowledgments). Metadata given in a transfer request
+// takes precedence over these defaults.
+type manifestConfig struct {
+	// keywords added to those of every manifest ("dts" and "manifest")
+	Keywords []string `yaml:"keywords,omitempty"`
+	// licenses under which transferred data are managed (replaced by any
+	// given in a transfer request)
+	Licenses []manifestLicense `yaml:"licenses,omitempty"`
+	// the organization publishing transferred data, listed as a contributor
+	// with the "publisher" role (replaced by any publisher given in a transfer
+	// request)
+	Publisher *manifestPublisher `yaml:"publisher,omitempty"`
+	// funding acknowledgments (replaced by any given in a transfer request)
+	Funding []manifestFunding `yaml:"funding,omitempty"`
+}
+
+// a license included in every manifest
+type manifestLicense struct {
+	// the abbreviated name of the license (e.g. an SPDX identifier)
+	Name string `yaml:"name"`
+	// a URL at which the license text may be retrieved
+	Path string `yaml:"path,omitempty"`
+	// the descriptive title of the license
+	Title string `yaml:"title,omitempty"`
+}
+
+// the organization listed as the publisher of every manifest
+type manifestPublisher struct {
+	// the name of the organization
+	Title string `yaml:"title"`
+	// a contact email address for the organization
+	Email string `yaml:"email,omitempty"`
+	// a URL for the organization
+	Path string `yaml:"path,omitempty"`
+}
+
+// a funding acknowledgment included in every manifest
+type manifestFunding struct {
+	// the name of the funding organization
+	FunderName string `yaml:"funder_name"`
+	// a persistent identifier for the funding organization (e.g. "ROR:01bj3aw27")
+	FunderId string `yaml:"funder_id,omitempty"`
+	// the code assigned to the grant by the funder
+	GrantId string `yaml:"grant_id,omitempty"`
+	// the title of the grant
+	GrantTitle string `yaml:"grant_title,omitempty"`
+	// a URL for the grant
+	GrantUrl string `yaml:"grant_url,omitempty"`
+}
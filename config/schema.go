@@ -0,0 +1,92 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// a description of a field in the DTS configuration file
+type FieldSchema struct {
+	// the name of the field in the configuration file
+	Name string `json:"name"`
+	// the type of the field's value ("string", "integer", "boolean", "uuid",
+	// "map", or "object")
+	Type string `json:"type"`
+	// a brief description of the field
+	Description string `json:"description,omitempty"`
+	// the fields of an object-valued field
+	Fields []FieldSchema `json:"fields,omitempty"`
+}
+
+// returns a description of the fields in each entry of the configuration
+// file's endpoints section
+func EndpointSchema() []FieldSchema {
+	return schemaForType(reflect.TypeOf(endpointConfig{}))
+}
+
+// returns a description of the fields in each entry of the configuration
+// file's databases section
+func DatabaseSchema() []FieldSchema {
+	return schemaForType(reflect.TypeOf(databaseConfig{}))
+}
+
+// describes the fields of the given struct type using their yaml and doc
+// tags
+func schemaForType(t reflect.Type) []FieldSchema {
+	fields := make([]FieldSchema, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := FieldSchema{
+			Name:        name,
+			Description: field.Tag.Get("doc"),
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer { // optional field
+			fieldType = fieldType.Elem()
+		}
+		switch {
+		case fieldType == reflect.TypeOf(uuid.UUID{}):
+			schema.Type = "uuid"
+		case fieldType.Kind() == reflect.Struct:
+			schema.Type = "object"
+			schema.Fields = schemaForType(fieldType)
+		case fieldType.Kind() == reflect.Map:
+			schema.Type = "map"
+		case fieldType.Kind() == reflect.Bool:
+			schema.Type = "boolean"
+		case fieldType.Kind() == reflect.Int:
+			schema.Type = "integer"
+		default:
+			schema.Type = fieldType.Kind().String()
+		}
+		fields = append(fields, schema)
+	}
+	return fields
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// An SMTP server used to send email notifications (e.g. on transfer
+// completion). Notifications are disabled if no host is given.
+type smtpConfig struct {
+	// the hostname of the SMTP server
+	Host string `yaml:"host,omitempty"`
+	// the port on which the SMTP server listens
+	// default: 25
+	Port int `yaml:"port,omitempty"`
+	// the username used to authenticate with the SMTP server (if any)
+	Username string `yaml:"username,omitempty"`
+	// the password used to authenticate with the SMTP server (if any)
+	Password string `yaml:"password,omitempty"`
+	// the address from which notifications are sent
+	From string `yaml:"from,omitempty"`
+}
@@ -24,6 +24,7 @@ package databases
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
@@ -51,6 +52,10 @@ type Database interface {
 	StageFiles(fileIds []string) (uuid.UUID, error)
 	// returns the status of a given staging operation
 	StagingStatus(id uuid.UUID) (StagingStatus, error)
+	// discards the staging operation with the given UUID (e.g. when its transfer
+	// has failed or been canceled); databases that don't track staging
+	// operations can simply return nil
+	CancelStaging(id uuid.UUID) error
 	// returns the local username associated with the given Orcid ID
 	LocalUser(orcid string) (string, error)
 	// returns the saved state of the Database, loadable via Load
@@ -59,6 +64,97 @@ type Database interface {
 	Load(state DatabaseSaveState) error
 }
 
+// This type represents a database whose file IDs are paths, so that its files
+// can be requested by a common prefix (e.g. that of a directory).
+type PrefixDatabase interface {
+	Database
+	// returns the IDs of the database's files that begin with the given prefix
+	FileIdsWithPrefix(prefix string) ([]string, error)
+}
+
+// This type represents a database that must be notified once a transfer's
+// files (and its manifest) have arrived at its endpoint, e.g. so that it can
+// make them available to its users.
+type FinalizableDatabase interface {
+	Database
+	// notifies the database that files have been transferred to the given
+	// destination folder, with a manifest at the given path (both relative to
+	// the root of the database's endpoint). The manifest is a Frictionless
+	// data package, or a bag's manifest-md5.txt if BagIt manifests are written.
+	Finalize(folder, manifest string) error
+}
+
+// This type represents a database that can estimate the number and total
+// size of a set of files more cheaply than by describing them in full, e.g.
+// for checking a requested transfer against the service's limits.
+type EstimatingDatabase interface {
+	Database
+	// returns the number of the files with the given IDs that the database
+	// recognizes and their total size in bytes
+	EstimateTransfer(fileIds []string) (int, uint64, error)
+}
+
+// This type represents a database holding private data that it describes and
+// stages only on explicit request (and only for users entitled to it, which
+// the database checks against the ORCID ID of the user it serves).
+type PrivateDataDatabase interface {
+	Database
+	// returns true if the database includes private data in the files it
+	// describes and stages, false if not
+	IncludesPrivateData() bool
+	// returns a view of the database that includes private data in the files
+	// it describes and stages
+	WithPrivateData() Database
+}
+
+// returns a view of the given database that includes private data in the
+// files it describes and stages if include is true and the database supports
+// it, or the database itself otherwise
+func WithPrivateData(db Database, include bool) Database {
+	if privateDb, ok := db.(PrivateDataDatabase); ok && include {
+		return privateDb.WithPrivateData()
+	}
+	return db
+}
+
+// returns true if a search of the given database with the given parameters may
+// include private data in its results, either because the database includes
+// private data or because the search requests it with the include_private_data
+// database-specific parameter (any value but 0 or false counts as a request)
+func SearchIncludesPrivateData(db Database, params SearchParameters) bool {
+	if privateDb, ok := db.(PrivateDataDatabase); ok && privateDb.IncludesPrivateData() {
+		return true
+	}
+	value, found := params.Specific["include_private_data"]
+	if !found {
+		return false
+	}
+	var flag any
+	if err := json.Unmarshal(value, &flag); err != nil {
+		return true
+	}
+	return flag != false && flag != float64(0)
+}
+
+// This type represents a database that can send a correlation ID (e.g. that
+// of the transfer on whose behalf it acts) with the requests it makes, so that
+// its provider can associate them with one another.
+type CorrelatedDatabase interface {
+	Database
+	// returns a view of the database whose requests carry the given
+	// correlation ID (see WithCorrelationId)
+	WithCorrelationId(id string) Database
+}
+
+// returns a view of the given database whose requests carry the given
+// correlation ID if the database supports it, or the database itself otherwise
+func Correlated(db Database, id string) Database {
+	if correlatedDb, ok := db.(CorrelatedDatabase); ok {
+		return correlatedDb.WithCorrelationId(id)
+	}
+	return db
+}
+
 // represents a saved database state (for service restarts)
 type DatabaseSaveState struct {
 	// database name
@@ -84,11 +180,50 @@ type SearchParameters struct {
 	// database-specific search parameters with names matched to provided values
 	// (validated by database)
 	Specific map[string]json.RawMessage
+	// names of the resource fields to be included in results (all fields are
+	// included if empty; see SelectResourceFields)
+	Fields []string
+	// glob patterns that the paths of resources in results must match
+	// (Include) or must not match (Exclude), if given (see FilterSearchResults)
+	Include, Exclude string
 }
 
 // results from a file search
 type SearchResults struct {
 	Resources []frictionless.DataResource `json:"resources"`
+	// total number of resources matching the search, for databases that can
+	// report it (nil otherwise)
+	Total *int `json:"total,omitempty"`
+	// set by databases that can't report a total if more resources match the
+	// search than were returned
+	HasMore bool `json:"has_more,omitempty"`
+	// groups of resources sharing a database-specific attribute, for databases
+	// that support grouped results and searches that request them
+	Groups []SearchResultGroup `json:"groups,omitempty"`
+	// number of matching resources left out of the results (e.g. because the
+	// database's metadata for them is inconsistent, or because they were
+	// excluded by file patterns)
+	NumSkipped int `json:"num_skipped,omitempty"`
+}
+
+// a group of search results sharing a database-specific attribute (e.g. the
+// organism from which their data was sequenced)
+type SearchResultGroup struct {
+	// database-specific identifier for the group
+	Id string `json:"id"`
+	// human-readable title for the group (if any)
+	Title string `json:"title,omitempty"`
+	// IDs of the resources in the group, which appear in the search results
+	ResourceIds []string `json:"resource_ids"`
+}
+
+// sorts the given resources by ID, for databases whose results would otherwise
+// appear in an arbitrary order (identical searches should produce identical
+// results, so they can be cached and paginated reliably)
+func SortResourcesById(resources []frictionless.DataResource) {
+	slices.SortStableFunc(resources, func(a, b frictionless.DataResource) int {
+		return strings.Compare(a.Id, b.Id)
+	})
 }
 
 type SearchPaginationParameters struct {
@@ -131,6 +266,16 @@ func RegisterDatabase(dbName string, createDb func(orcid string) (Database, erro
 	}
 }
 
+// returns the names of all registered databases in sorted order
+func RegisteredDatabases() []string {
+	dbNames := make([]string, 0, len(createDatabaseFuncs_))
+	for dbName := range createDatabaseFuncs_ {
+		dbNames = append(dbNames, dbName)
+	}
+	slices.Sort(dbNames)
+	return dbNames
+}
+
 // creates a database proxy associated with the given ORCID, based on the
 // configured type, or returns an existing instance
 func NewDatabase(orcid, dbName string) (Database, error) {
@@ -1,10 +1,23 @@
 package databases
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/StalkR/hsts"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/frictionless"
 )
 
 func TestInvalidDatabase(t *testing.T) {
@@ -14,3 +27,605 @@ func TestInvalidDatabase(t *testing.T) {
 	assert.Nil(bbDb, "Invalid database should not be created")
 	assert.NotNil(err, "Invalid database creation did not report an error")
 }
+
+func TestSelectResourceFields(t *testing.T) {
+	assert := assert.New(t)
+	resources := []frictionless.DataResource{
+		{
+			Id:        "file1",
+			Name:      "file1",
+			Path:      "dir1/file1.txt",
+			Format:    "text",
+			MediaType: "text/plain",
+			Bytes:     1024,
+			Credit: credit.CreditMetadata{
+				Identifier:   "file1",
+				ResourceType: "dataset",
+			},
+		},
+	}
+
+	selected, err := SelectResourceFields("test", resources, []string{"id", "bytes"})
+	assert.Nil(err)
+	assert.Equal(1, len(selected))
+	assert.Equal(2, len(selected[0]))
+	assert.Equal(`"file1"`, string(selected[0]["id"]))
+	assert.Equal("1024", string(selected[0]["bytes"]))
+	assert.NotContains(selected[0], "credit")
+	assert.NotContains(selected[0], "media_type")
+
+	// no fields means all fields
+	selected, err = SelectResourceFields("test", resources, nil)
+	assert.Nil(err)
+	assert.Contains(selected[0], "credit")
+	assert.Contains(selected[0], "media_type")
+
+	// unknown fields are rejected
+	_, err = SelectResourceFields("test", resources, []string{"id", "Endpoint"})
+	assert.NotNil(err)
+	assert.IsType(InvalidSearchParameter{}, err)
+}
+
+func TestValidateFilePatterns(t *testing.T) {
+	assert := assert.New(t)
+
+	err := ValidateFilePatterns("test", SearchParameters{})
+	assert.Nil(err)
+	err = ValidateFilePatterns("test", SearchParameters{Include: "*.txt", Exclude: "raw/[a-c]*"})
+	assert.Nil(err)
+	err = ValidateFilePatterns("test", SearchParameters{Include: "[.txt"})
+	assert.NotNil(err)
+	assert.IsType(InvalidSearchParameter{}, err)
+	err = ValidateFilePatterns("test", SearchParameters{Exclude: "raw\\"})
+	assert.NotNil(err)
+}
+
+// filters results listing the files under a prefix
+func TestFilterSearchResults(t *testing.T) {
+	assert := assert.New(t)
+
+	total := 4
+	results := SearchResults{
+		Resources: []frictionless.DataResource{
+			{Id: "1", Path: "data/a.txt"},
+			{Id: "2", Path: "data/b.fastq.gz"},
+			{Id: "3", Path: "data/raw/c.txt"},
+			{Id: "4", Path: "data/raw/d.bin"},
+		},
+		Total: &total,
+		Groups: []SearchResultGroup{
+			{Id: "text", ResourceIds: []string{"1", "3"}},
+			{Id: "other", ResourceIds: []string{"2", "4"}},
+		},
+	}
+	ids := func(results SearchResults) []string {
+		ids := make([]string, len(results.Resources))
+		for i, resource := range results.Resources {
+			ids[i] = resource.Id
+		}
+		return ids
+	}
+
+	// no patterns, no filtering
+	filtered := FilterSearchResults(SearchParameters{}, results)
+	assert.Equal([]string{"1", "2", "3", "4"}, ids(filtered))
+	assert.Equal(0, filtered.NumSkipped)
+
+	// only text files
+	filtered = FilterSearchResults(SearchParameters{Include: "*.txt"}, results)
+	assert.Equal([]string{"1", "3"}, ids(filtered))
+	assert.Equal(2, filtered.NumSkipped)
+	assert.Equal([]SearchResultGroup{{Id: "text", ResourceIds: []string{"1", "3"}}},
+		filtered.Groups)
+
+	// everything but the raw subdirectory
+	for _, exclude := range []string{"data/raw", "data/raw/*", "raw"} {
+		filtered = FilterSearchResults(SearchParameters{Exclude: exclude}, results)
+		assert.Equal([]string{"1", "2"}, ids(filtered), exclude)
+	}
+
+	// text files outside the raw subdirectory
+	filtered = FilterSearchResults(SearchParameters{Include: "*.txt", Exclude: "raw"}, results)
+	assert.Equal([]string{"1"}, ids(filtered))
+	assert.Equal(3, filtered.NumSkipped)
+	assert.Equal([]SearchResultGroup{{Id: "text", ResourceIds: []string{"1"}}},
+		filtered.Groups)
+
+	// the original results are untouched
+	assert.Equal([]string{"1", "2", "3", "4"}, ids(results))
+	assert.Equal([]string{"2", "4"}, results.Groups[1].ResourceIds)
+}
+
+func TestValidateResources(t *testing.T) {
+	assert := assert.New(t)
+	resources := []frictionless.DataResource{
+		{
+			Id:    "file1",
+			Name:  "file1",
+			Path:  "dir1/file1.txt",
+			Bytes: 1024,
+		},
+	}
+	err := ValidateResources("test", resources)
+	assert.Nil(err)
+
+	// resources without names or paths are rejected
+	resources = append(resources, frictionless.DataResource{
+		Id:   "file2",
+		Name: "file2",
+	})
+	err = ValidateResources("test", resources)
+	assert.NotNil(err)
+	assert.Equal(InvalidResourceError{
+		Database:   "test",
+		ResourceId: "file2",
+		Message:    "no path",
+	}, err)
+	resources[1].Path = "dir2/file2.txt"
+	resources[1].Name = ""
+	err = ValidateResources("test", resources)
+	assert.NotNil(err)
+}
+
+func TestDoWithTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	// a server that takes its time responding
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		io.WriteString(w, "finally")
+	}))
+	defer server.Close()
+	client := http.Client{}
+
+	// the request is abandoned at the timeout
+	request, _ := http.NewRequest(http.MethodGet, server.URL, http.NoBody)
+	start := time.Now()
+	_, err := DoWithTimeout(&client, "slow", request, 100*time.Millisecond)
+	assert.Less(time.Since(start), 400*time.Millisecond)
+	assert.Equal(TimeoutError{Database: "slow"}, err)
+
+	// the client's own timeout produces the same error
+	client.Timeout = 100 * time.Millisecond
+	request, _ = http.NewRequest(http.MethodGet, server.URL, http.NoBody)
+	_, err = DoWithTimeout(&client, "slow", request, 0)
+	assert.Equal(TimeoutError{Database: "slow"}, err)
+
+	// a request that completes in time is undisturbed
+	client.Timeout = 0
+	request, _ = http.NewRequest(http.MethodGet, server.URL, http.NoBody)
+	response, err := DoWithTimeout(&client, "slow", request, 5*time.Second)
+	assert.Nil(err)
+	body, err := io.ReadAll(response.Body)
+	assert.Nil(err)
+	assert.Equal("finally", string(body))
+	assert.Nil(response.Body.Close())
+}
+
+func TestRequestHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	// a server that records the headers it receives
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+	}))
+	defer server.Close()
+	client := http.Client{}
+
+	// requests identify the service
+	SetUserAgent("test-dts", "1.2.3")
+	defer SetUserAgent("kbase-dts", "0.0.0")
+	request, _ := http.NewRequest(http.MethodGet, server.URL, http.NoBody)
+	response, err := DoWithTimeout(&client, "test", request, 0)
+	assert.Nil(err)
+	response.Body.Close()
+	assert.Equal("test-dts/1.2.3", headers.Get("User-Agent"))
+	assert.Empty(headers.Get("X-DTS-Correlation-ID"))
+
+	// and carry any correlation ID in their contexts
+	ctx := WithCorrelationId(context.Background(), "transfer-1")
+	request, _ = http.NewRequestWithContext(ctx, http.MethodPost, server.URL, http.NoBody)
+	response, err = DoWithTimeout(&client, "test", request, time.Second)
+	assert.Nil(err)
+	response.Body.Close()
+	assert.Equal("test-dts/1.2.3", headers.Get("User-Agent"))
+	assert.Equal("transfer-1", headers.Get("X-DTS-Correlation-ID"))
+}
+
+// checks that HTTP clients for databases are built with the requested
+// security and TLS settings
+func TestDatabaseHttpClient(t *testing.T) {
+	assert := assert.New(t)
+
+	// only secure clients enable HSTS and refuse redirects
+	client := NewHttpClient(HttpClientOptions{})
+	assert.Nil(client.Transport)
+	assert.Nil(client.CheckRedirect)
+	client = SecureHttpClient()
+	assert.IsType(&hsts.Transport{}, client.Transport)
+	assert.NotNil(client.CheckRedirect)
+	assert.Equal(10*time.Second, client.Timeout)
+
+	// a minimum TLS version is set on the client's transport
+	client = NewHttpClient(HttpClientOptions{MinTLSVersion: tls.VersionTLS13})
+	transport, ok := client.Transport.(*http.Transport)
+	assert.True(ok)
+	assert.Equal(uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+
+	// and servers that don't support it are refused
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+	_, err := client.Get(server.URL)
+	assert.ErrorContains(err, "protocol version")
+
+	// database settings override the given defaults
+	err = config.Init([]byte(`
+endpoints:
+  local:
+    name: Local endpoint
+    id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6
+    provider: local
+    root: /
+databases:
+  secure:
+    name: Secure database
+    organization: Secure, Inc.
+    endpoint: local
+    secure_http: true
+  insecure:
+    name: Insecure database
+    organization: Insecure, Inc.
+    endpoint: local
+    secure_http: false
+    min_tls_version: "1.2"
+    request_timeout: 30
+`))
+	assert.Nil(err)
+	client = DatabaseHttpClient("secure", HttpClientOptions{})
+	assert.IsType(&hsts.Transport{}, client.Transport)
+	client = DatabaseHttpClient("insecure", SecureHttpClientOptions())
+	assert.Nil(client.CheckRedirect)
+	transport, ok = client.Transport.(*http.Transport)
+	assert.True(ok)
+	assert.Equal(uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+	assert.Equal(30*time.Second, client.Timeout)
+	client = DatabaseHttpClient("unconfigured", SecureHttpClientOptions())
+	assert.IsType(&hsts.Transport{}, client.Transport)
+	assert.Equal(10*time.Second, client.Timeout)
+}
+
+// a database that records the correlation ID its requests would carry
+type correlatedDatabase struct {
+	describingDatabase
+	CorrelationId string
+}
+
+func (db *correlatedDatabase) WithCorrelationId(id string) Database {
+	view := *db
+	view.CorrelationId = id
+	return &view
+}
+
+func TestCorrelated(t *testing.T) {
+	assert := assert.New(t)
+
+	db := &correlatedDatabase{}
+	correlated := Correlated(db, "transfer-1").(*correlatedDatabase)
+	assert.Equal("transfer-1", correlated.CorrelationId)
+	assert.Empty(db.CorrelationId) // the original is untouched
+
+	// databases that can't send correlation IDs are returned as-is
+	describer := &describingDatabase{}
+	assert.Equal(describer, Correlated(describer, "transfer-1"))
+}
+
+// a database that describes any file and records the IDs it was asked about
+type describingDatabase struct {
+	Requests [][]string
+}
+
+func (db *describingDatabase) SpecificSearchParameters() map[string]interface{} {
+	return nil
+}
+
+func (db *describingDatabase) Search(params SearchParameters) (SearchResults, error) {
+	return SearchResults{}, nil
+}
+
+func (db *describingDatabase) Resources(fileIds []string) ([]frictionless.DataResource, error) {
+	db.Requests = append(db.Requests, fileIds)
+	resources := make([]frictionless.DataResource, len(fileIds))
+	for i, fileId := range fileIds {
+		resources[i] = frictionless.DataResource{
+			Id:    fileId,
+			Name:  fileId,
+			Path:  fileId + ".txt",
+			Bytes: 1024,
+		}
+	}
+	return resources, nil
+}
+
+func (db *describingDatabase) StageFiles(fileIds []string) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (db *describingDatabase) StagingStatus(id uuid.UUID) (StagingStatus, error) {
+	return StagingStatusSucceeded, nil
+}
+
+func (db *describingDatabase) CancelStaging(id uuid.UUID) error {
+	return nil
+}
+
+func (db *describingDatabase) LocalUser(orcid string) (string, error) {
+	return "", nil
+}
+
+func (db *describingDatabase) Save() (DatabaseSaveState, error) {
+	return DatabaseSaveState{Name: "describing"}, nil
+}
+
+func (db *describingDatabase) Load(state DatabaseSaveState) error {
+	return nil
+}
+
+// empties the descriptor cache, which is shared by all tests
+func clearDescriptorCache() {
+	descriptorCacheMutex.Lock()
+	defer descriptorCacheMutex.Unlock()
+	descriptorCacheOrder.Init()
+	clear(descriptorCacheElements)
+}
+
+func TestCachedResources(t *testing.T) {
+	assert := assert.New(t)
+
+	clearDescriptorCache()
+	t.Cleanup(clearDescriptorCache)
+
+	config.Service.DescriptorCacheTTL = 60
+	config.Service.DescriptorCacheSize = 2
+	defer func() {
+		config.Service.DescriptorCacheTTL = 0
+		config.Service.DescriptorCacheSize = 0
+	}()
+	db := &describingDatabase{}
+
+	// descriptors are fetched once and then served from the cache
+	resources, err := CachedResources("describing", db, []string{"a", "b"})
+	assert.Nil(err)
+	assert.Equal("a", resources[0].Id)
+	assert.Equal("b", resources[1].Id)
+	resources, err = CachedResources("describing", db, []string{"b", "a"})
+	assert.Nil(err)
+	assert.Equal("b", resources[0].Id)
+	assert.Equal("a", resources[1].Id)
+	assert.Equal([][]string{{"a", "b"}}, db.Requests)
+
+	// only uncached descriptors are fetched, and the least recently cached
+	// are evicted when the cache is full
+	resources, err = CachedResources("describing", db, []string{"a", "c"})
+	assert.Nil(err)
+	assert.Equal("a", resources[0].Id)
+	assert.Equal("c", resources[1].Id)
+	assert.Equal([]string{"c"}, db.Requests[1])
+	_, err = CachedResources("describing", db, []string{"a"})
+	assert.Nil(err)
+	assert.Equal([]string{"a"}, db.Requests[2])
+
+	// descriptors are cached per database
+	_, err = CachedResources("other", db, []string{"a"})
+	assert.Nil(err)
+	assert.Equal(4, len(db.Requests))
+
+	// invalidated descriptors are fetched again
+	InvalidateCachedResources("describing", []string{"a"})
+	_, err = CachedResources("describing", db, []string{"a"})
+	assert.Nil(err)
+	assert.Equal(5, len(db.Requests))
+
+	// nothing is cached when the cache is disabled
+	config.Service.DescriptorCacheTTL = 0
+	_, err = CachedResources("describing", db, []string{"d"})
+	assert.Nil(err)
+	_, err = CachedResources("describing", db, []string{"d"})
+	assert.Nil(err)
+	assert.Equal(7, len(db.Requests))
+}
+
+// a describing database that estimates transfers itself
+type estimatingDatabase struct {
+	describingDatabase
+}
+
+func (db *estimatingDatabase) EstimateTransfer(fileIds []string) (int, uint64, error) {
+	return len(fileIds), 42, nil
+}
+
+func TestEstimateTransfer(t *testing.T) {
+	assert := assert.New(t)
+
+	// databases without their own estimates sum the sizes of their descriptors
+	db := &describingDatabase{}
+	count, size, err := EstimateTransfer("describing", db, []string{"a", "b", "c"})
+	assert.Nil(err)
+	assert.Equal(3, count)
+	assert.Equal(uint64(3*1024), size)
+	assert.Equal(1, len(db.Requests))
+
+	// others provide their own, without describing any files
+	estimator := &estimatingDatabase{}
+	count, size, err = EstimateTransfer("estimating", estimator, []string{"a", "b"})
+	assert.Nil(err)
+	assert.Equal(2, count)
+	assert.Equal(uint64(42), size)
+	assert.Empty(estimator.Requests)
+}
+
+// a describing database with private data, available through a view
+type privateDatabase struct {
+	describingDatabase
+	Private bool
+}
+
+func (db *privateDatabase) IncludesPrivateData() bool {
+	return db.Private
+}
+
+func (db *privateDatabase) WithPrivateData() Database {
+	return &privateDatabase{Private: true}
+}
+
+func TestCachedPrivateResources(t *testing.T) {
+	assert := assert.New(t)
+
+	config.Service.DescriptorCacheTTL = 60
+	config.Service.DescriptorCacheSize = 10
+	defer func() {
+		config.Service.DescriptorCacheTTL = 0
+		config.Service.DescriptorCacheSize = 0
+	}()
+
+	// databases exclude private data unless it's requested
+	db := &privateDatabase{}
+	assert.Equal(db, WithPrivateData(db, false))
+	privateDb := WithPrivateData(db, true).(*privateDatabase)
+	assert.True(privateDb.IncludesPrivateData())
+	describer := &describingDatabase{}
+	assert.Equal(describer, WithPrivateData(describer, true))
+
+	// descriptors that may include private data are never cached
+	_, err := CachedResources("private", privateDb, []string{"a"})
+	assert.Nil(err)
+	_, err = CachedResources("private", privateDb, []string{"a"})
+	assert.Nil(err)
+	assert.Equal(2, len(privateDb.Requests))
+	_, err = CachedResources("private", db, []string{"a"})
+	assert.Nil(err)
+	assert.Equal(1, len(db.Requests))
+}
+
+func TestSearchIncludesPrivateData(t *testing.T) {
+	assert := assert.New(t)
+
+	db := &privateDatabase{}
+	assert.False(SearchIncludesPrivateData(db, SearchParameters{}))
+	assert.True(SearchIncludesPrivateData(WithPrivateData(db, true), SearchParameters{}))
+
+	// searches can also request private data themselves
+	for value, private := range map[string]bool{
+		"1":       true,
+		"true":    true,
+		`"1"`:     true,
+		"garbage": true,
+		"0":       false,
+		"false":   false,
+	} {
+		params := SearchParameters{
+			Specific: map[string]json.RawMessage{
+				"include_private_data": json.RawMessage(value),
+			},
+		}
+		assert.Equal(private, SearchIncludesPrivateData(&describingDatabase{}, params), value)
+	}
+}
+
+// a directory lister that describes a fixed set of files, listed out of order
+type fixedLister struct {
+	Files []frictionless.DataResource
+}
+
+func (l fixedLister) ListDirectory(dir string) ([]frictionless.DataResource, error) {
+	return append([]frictionless.DataResource{}, l.Files...), nil
+}
+
+// a listing database with a README and two sequence files in a subdirectory
+var listingDb = ListingDatabase{
+	Id: "listing",
+	Lister: fixedLister{
+		Files: []frictionless.DataResource{
+			{Id: "reads/sample2.fastq.gz", Path: "reads/sample2.fastq.gz", Bytes: 2097152},
+			{Id: "README.md", Path: "README.md", Bytes: 512},
+			{Id: "reads/sample1.fastq.gz", Path: "reads/sample1.fastq.gz", Bytes: 1048576},
+		},
+	},
+}
+
+func TestListingDatabaseSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	// an empty query lists all files, sorted by path
+	results, err := listingDb.Search(SearchParameters{})
+	assert.Nil(err)
+	paths := make([]string, len(results.Resources))
+	for i, resource := range results.Resources {
+		paths[i] = resource.Path
+	}
+	assert.Equal([]string{"README.md", "reads/sample1.fastq.gz", "reads/sample2.fastq.gz"}, paths)
+	assert.Equal(3, *results.Total)
+
+	// queries match any part of a path, and totals count all matches
+	results, err = listingDb.Search(SearchParameters{
+		Query: "sample",
+		Pagination: SearchPaginationParameters{
+			Offset: 1,
+			MaxNum: 5,
+		},
+	})
+	assert.Nil(err)
+	assert.Equal(1, len(results.Resources))
+	assert.Equal("reads/sample2.fastq.gz", results.Resources[0].Path)
+	assert.Equal(2, *results.Total)
+
+	results, err = listingDb.Search(SearchParameters{
+		Pagination: SearchPaginationParameters{
+			MaxNum: 2,
+		},
+	})
+	assert.Nil(err)
+	assert.Equal(2, len(results.Resources))
+	assert.Equal("README.md", results.Resources[0].Path)
+	assert.Equal(3, *results.Total)
+}
+
+func TestListingDatabaseFileIdsWithPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	fileIds, err := listingDb.FileIdsWithPrefix("reads/")
+	assert.Nil(err)
+	assert.Equal([]string{"reads/sample1.fastq.gz", "reads/sample2.fastq.gz"}, fileIds)
+
+	// paths must begin with the prefix, not merely contain it
+	fileIds, err = listingDb.FileIdsWithPrefix("sample1")
+	assert.Nil(err)
+	assert.Empty(fileIds)
+}
+
+func TestListingDatabaseResources(t *testing.T) {
+	assert := assert.New(t)
+
+	resources, err := listingDb.Resources([]string{"reads/sample2.fastq.gz", "README.md"})
+	assert.Nil(err)
+	assert.Equal(2, len(resources))
+	assert.Equal("reads/sample2.fastq.gz", resources[0].Path)
+	assert.Equal(2097152, resources[0].Bytes)
+	assert.Equal("README.md", resources[1].Path)
+	assert.Equal(512, resources[1].Bytes)
+
+	_, err = listingDb.Resources([]string{"reads/sample3.fastq.gz"})
+	assert.IsType(ResourceNotFoundError{}, err)
+
+	// files are already in place, so staging succeeds immediately
+	id, err := listingDb.StageFiles([]string{"README.md"})
+	assert.Nil(err)
+	status, err := listingDb.StagingStatus(id)
+	assert.Nil(err)
+	assert.Equal(StagingStatusSucceeded, status)
+}
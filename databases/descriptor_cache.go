@@ -0,0 +1,185 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/frictionless"
+)
+
+// returns the Frictionless DataResources for the files with the given IDs in
+// the database with the given name, serving those described recently from
+// the descriptor cache and fetching the rest from the database itself
+// (caching them for next time). Descriptors for databases that include
+// private data bypass the cache, so they aren't served to other users.
+func CachedResources(dbName string, db Database, fileIds []string) ([]frictionless.DataResource, error) {
+	if privateDb, ok := db.(PrivateDataDatabase); ok && privateDb.IncludesPrivateData() {
+		return db.Resources(fileIds)
+	}
+	resources := make([]frictionless.DataResource, len(fileIds))
+	cached := make([]frictionless.DataResource, 0)
+	missingIds := make([]string, 0)
+	missingIndices := make([]int, 0)
+	for i, fileId := range fileIds {
+		if resource, found := cachedResource(dbName, fileId); found {
+			resources[i] = resource
+			cached = append(cached, resource)
+		} else {
+			missingIds = append(missingIds, fileId)
+			missingIndices = append(missingIndices, i)
+		}
+	}
+	if len(missingIds) == 0 {
+		return resources, nil
+	}
+
+	fetched, err := db.Resources(missingIds)
+	if err != nil {
+		return nil, err
+	}
+	CacheResources(dbName, fetched)
+	if len(fetched) != len(missingIds) {
+		// the database couldn't describe every file, so we return the ones
+		// that are described and leave it to the caller to sort out the rest
+		return append(cached, fetched...), nil
+	}
+	for i, resource := range fetched {
+		resources[missingIndices[i]] = resource
+	}
+	return resources, nil
+}
+
+// returns the number of the files with the given IDs that the given database
+// (registered under the given name) recognizes and their total size in bytes,
+// using the database's own estimate if it provides one and summing the sizes
+// in the files' (cached) descriptors otherwise
+func EstimateTransfer(dbName string, db Database, fileIds []string) (int, uint64, error) {
+	if estimator, ok := db.(EstimatingDatabase); ok {
+		return estimator.EstimateTransfer(fileIds)
+	}
+	resources, err := CachedResources(dbName, db, fileIds)
+	if err != nil {
+		return 0, 0, err
+	}
+	var size uint64
+	for _, resource := range resources {
+		size += uint64(resource.Bytes)
+	}
+	return len(resources), size, nil
+}
+
+// adds the given resources from the database with the given name to the
+// descriptor cache (if it's enabled). Resources that may include private data
+// (see SearchIncludesPrivateData) must not be added.
+func CacheResources(dbName string, resources []frictionless.DataResource) {
+	ttl := time.Duration(config.Service.DescriptorCacheTTL) * time.Second
+	maxEntries := config.Service.DescriptorCacheSize
+	if ttl <= 0 || maxEntries <= 0 {
+		return
+	}
+	expiration := time.Now().Add(ttl)
+
+	descriptorCacheMutex.Lock()
+	defer descriptorCacheMutex.Unlock()
+	for _, resource := range resources {
+		key := descriptorCacheKey{Database: dbName, FileId: resource.Id}
+		entry := &descriptorCacheEntry{
+			Key:        key,
+			Resource:   resource,
+			Expiration: expiration,
+		}
+		if element, found := descriptorCacheElements[key]; found {
+			element.Value = entry
+			descriptorCacheOrder.MoveToBack(element)
+		} else {
+			descriptorCacheElements[key] = descriptorCacheOrder.PushBack(entry)
+		}
+	}
+
+	// evict the least recently cached entries past the maximum
+	for descriptorCacheOrder.Len() > maxEntries {
+		removeDescriptorCacheElement(descriptorCacheOrder.Front())
+	}
+}
+
+// removes any cached descriptors for the files with the given IDs in the
+// database with the given name (e.g. because their staging status changed)
+func InvalidateCachedResources(dbName string, fileIds []string) {
+	descriptorCacheMutex.Lock()
+	defer descriptorCacheMutex.Unlock()
+	for _, fileId := range fileIds {
+		key := descriptorCacheKey{Database: dbName, FileId: fileId}
+		if element, found := descriptorCacheElements[key]; found {
+			removeDescriptorCacheElement(element)
+		}
+	}
+}
+
+//-----------
+// Internals
+//-----------
+
+// identifies a cached descriptor by database and file ID
+type descriptorCacheKey struct {
+	Database, FileId string
+}
+
+// a cached descriptor, discarded after its expiration time
+type descriptorCacheEntry struct {
+	Key        descriptorCacheKey
+	Resource   frictionless.DataResource
+	Expiration time.Time
+}
+
+// cached descriptors, ordered from least to most recently cached so the
+// oldest can be evicted when the cache is full
+var descriptorCacheOrder = list.New()
+var descriptorCacheElements = make(map[descriptorCacheKey]*list.Element)
+var descriptorCacheMutex sync.Mutex
+
+// returns the unexpired cached descriptor for the given file in the given
+// database, if any
+func cachedResource(dbName, fileId string) (frictionless.DataResource, bool) {
+	descriptorCacheMutex.Lock()
+	defer descriptorCacheMutex.Unlock()
+	element, found := descriptorCacheElements[descriptorCacheKey{Database: dbName, FileId: fileId}]
+	if !found {
+		return frictionless.DataResource{}, false
+	}
+	entry := element.Value.(*descriptorCacheEntry)
+	if time.Now().After(entry.Expiration) {
+		removeDescriptorCacheElement(element)
+		return frictionless.DataResource{}, false
+	}
+	return entry.Resource, true
+}
+
+// removes the given element from the descriptor cache (the caller must hold
+// descriptorCacheMutex)
+func removeDescriptorCacheElement(element *list.Element) {
+	entry := descriptorCacheOrder.Remove(element).(*descriptorCacheEntry)
+	delete(descriptorCacheElements, entry.Key)
+}
@@ -0,0 +1,510 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ena
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/frictionless"
+)
+
+// This database resolves sequencing runs archived at the European Nucleotide
+// Archive (ENA, which mirrors the NCBI Sequence Read Archive) into their FASTQ
+// files using the ENA Portal API (https://www.ebi.ac.uk/ena/portal/api/).
+// ENA serves these files for direct download, so the database's endpoint must
+// be an "http" endpoint rooted at ENA's file server (https://ftp.sra.ebi.ac.uk/).
+// (implements the databases.Database interface)
+type Database struct {
+	// ORCID identifier for database proxy
+	Orcid string
+	// HTTP client used for Portal API requests
+	Client http.Client
+	// base URL of the Portal API
+	ApiURL string
+	// correlation ID sent with the database's requests (if any)
+	CorrelationId string
+}
+
+func NewDatabase(orcid string) (databases.Database, error) {
+	if orcid == "" {
+		return nil, databases.UnauthorizedError{
+			Database: "ena",
+			Message:  "No ORCID was given",
+		}
+	}
+
+	// ENA files are downloaded from its file server over HTTPS
+	endpointName := config.Databases["ena"].Endpoint
+	if endpointName == "" {
+		return nil, databases.InvalidEndpointsError{
+			Database: "ena",
+			Message:  "ENA requires an endpoint to be specified",
+		}
+	}
+	if config.Endpoints[endpointName].Provider != "http" {
+		return nil, databases.InvalidEndpointsError{
+			Database: "ena",
+			Message:  fmt.Sprintf("'%s' is not an HTTP endpoint", endpointName),
+		}
+	}
+
+	// NOTE: we prevent redirects from HTTPS -> HTTP!
+	db := &Database{
+		Orcid:  orcid,
+		Client: databases.DatabaseHttpClient("ena", databases.SecureHttpClientOptions()),
+		ApiURL: baseApiURL,
+	}
+	return db, nil
+}
+
+func (db Database) SpecificSearchParameters() map[string]interface{} {
+	return map[string]interface{}{
+		// if given, the query is interpreted as an accession of this type
+		"accession_type": []string{"run", "experiment", "study"},
+	}
+}
+
+func (db *Database) Search(params databases.SearchParameters) (databases.SearchResults, error) {
+	query, err := searchQuery(params)
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+
+	// ENA paginates runs, each of which can have several files, so we fetch
+	// enough runs to cover the requested window of files and select the window
+	// from them
+	offset := params.Pagination.Offset
+	pageSize := params.Pagination.MaxNum
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	runs, err := db.readRuns(query, offset+pageSize)
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+	resources := make([]frictionless.DataResource, 0)
+	for _, run := range runs {
+		resources = append(resources, dataResourcesFromRun(run)...)
+	}
+	hasMore := len(runs) == offset+pageSize // there may be more runs
+	start := min(offset, len(resources))
+	end := min(offset+pageSize, len(resources))
+	if end < len(resources) {
+		hasMore = true
+	}
+	return databases.SearchResults{
+		Resources: resources[start:end],
+		HasMore:   hasMore,
+	}, nil
+}
+
+func (db Database) Resources(fileIds []string) ([]frictionless.DataResource, error) {
+	// file IDs have the form ENA:<file name>, and ENA names FASTQ files after
+	// their runs (<run>.fastq.gz, <run>_1.fastq.gz, <run>_2.fastq.gz)
+	runAccessions := make([]string, 0)
+	for _, fileId := range fileIds {
+		runAccession := runAccessionForFileId(fileId)
+		if runAccession == "" {
+			return nil, databases.ResourceNotFoundError{
+				Database:   "ena",
+				ResourceId: fileId,
+			}
+		}
+		if !slices.Contains(runAccessions, runAccession) {
+			runAccessions = append(runAccessions, runAccession)
+		}
+	}
+	terms := make([]string, len(runAccessions))
+	for i, runAccession := range runAccessions {
+		terms[i] = fmt.Sprintf(`run_accession="%s"`, runAccession)
+	}
+	runs, err := db.readRuns(strings.Join(terms, " OR "), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceForId := make(map[string]frictionless.DataResource)
+	for _, run := range runs {
+		for _, resource := range dataResourcesFromRun(run) {
+			resourceForId[resource.Id] = resource
+		}
+	}
+	resources := make([]frictionless.DataResource, len(fileIds))
+	for i, fileId := range fileIds {
+		resource, found := resourceForId[fileId]
+		if !found {
+			return nil, databases.ResourceNotFoundError{
+				Database:   "ena",
+				ResourceId: fileId,
+			}
+		}
+		resources[i] = resource
+	}
+	return resources, nil
+}
+
+func (db Database) StageFiles(fileIds []string) (uuid.UUID, error) {
+	// ENA files are always available for download, so we simply generate a
+	// UUID that can be handed to db.StagingStatus
+	return uuid.New(), nil
+}
+
+func (db Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	return databases.StagingStatusSucceeded, nil
+}
+
+func (db Database) CancelStaging(id uuid.UUID) error {
+	// nothing to cancel
+	return nil
+}
+
+func (db Database) LocalUser(orcid string) (string, error) {
+	// ENA is a public archive with no local users, so it can only serve as a
+	// transfer source
+	return "", fmt.Errorf("The ENA database can't map ORCIDs to local users")
+}
+
+// returns a view of the database whose requests carry the given correlation
+// ID (implements the databases.CorrelatedDatabase interface)
+func (db Database) WithCorrelationId(id string) databases.Database {
+	db.CorrelationId = id
+	return &db
+}
+
+func (db Database) Save() (databases.DatabaseSaveState, error) {
+	// this database has no internal state
+	return databases.DatabaseSaveState{
+		Name: "ena",
+	}, nil
+}
+
+func (db *Database) Load(state databases.DatabaseSaveState) error {
+	// no internal state -> nothing to do
+	return nil
+}
+
+//--------------------
+// Internal machinery
+//--------------------
+
+const (
+	baseApiURL = "https://www.ebi.ac.uk/ena/portal/api/"
+	// number of files returned by a search if no maximum is given
+	defaultPageSize = 100
+)
+
+// fields requested for each run from the Portal API
+// (see https://www.ebi.ac.uk/ena/portal/api/returnFields?result=read_run)
+var runFields = []string{
+	"run_accession",
+	"experiment_accession",
+	"study_accession",
+	"sample_accession",
+	"study_title",
+	"scientific_name",
+	"center_name",
+	"instrument_platform",
+	"instrument_model",
+	"first_public",
+	"last_updated",
+	"fastq_ftp",
+	"fastq_md5",
+	"fastq_bytes",
+}
+
+// a sequencing run record returned by the Portal API, in which multiple FASTQ
+// files are given as semicolon-delimited lists
+type Run struct {
+	RunAccession        string `json:"run_accession"`
+	ExperimentAccession string `json:"experiment_accession"`
+	StudyAccession      string `json:"study_accession"`
+	SampleAccession     string `json:"sample_accession"`
+	StudyTitle          string `json:"study_title"`
+	ScientificName      string `json:"scientific_name"`
+	CenterName          string `json:"center_name"`
+	InstrumentPlatform  string `json:"instrument_platform"`
+	InstrumentModel     string `json:"instrument_model"`
+	FirstPublic         string `json:"first_public"`
+	LastUpdated         string `json:"last_updated"`
+	FastqFtp            string `json:"fastq_ftp"`
+	FastqMD5            string `json:"fastq_md5"`
+	FastqBytes          string `json:"fastq_bytes"`
+}
+
+// builds a Portal API query from the given search parameters
+func searchQuery(params databases.SearchParameters) (string, error) {
+	if strings.TrimSpace(params.Query) == "" {
+		return "", &databases.InvalidSearchParameter{
+			Database: "ENA",
+			Message:  "ENA searches require a query",
+		}
+	}
+	jsonType, found := params.Specific["accession_type"]
+	if !found {
+		// the query uses the Portal API's syntax, e.g. tax_tree(9606)
+		return params.Query, nil
+	}
+	var accessionType string
+	err := json.Unmarshal(jsonType, &accessionType)
+	if err != nil {
+		return "", &databases.InvalidSearchParameter{
+			Database: "ENA",
+			Message:  "Invalid accession type given (must be string)",
+		}
+	}
+	switch accessionType {
+	case "run", "experiment", "study":
+		return fmt.Sprintf(`%s_accession="%s"`, accessionType, params.Query), nil
+	default:
+		return "", &databases.InvalidSearchParameter{
+			Database: "ENA",
+			Message: fmt.Sprintf("Invalid accession type: %s (must be run, experiment, or study)",
+				accessionType),
+		}
+	}
+}
+
+// retrieves up to limit sequencing runs (all if limit is 0) matching the
+// given Portal API query
+func (db Database) readRuns(query string, limit int) ([]Run, error) {
+	values := url.Values{}
+	values.Add("result", "read_run")
+	values.Add("query", query)
+	values.Add("fields", strings.Join(runFields, ","))
+	values.Add("limit", strconv.Itoa(limit))
+	values.Add("format", "json")
+	body, err := db.get("search", values)
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	if len(body) > 0 { // the Portal API sends nothing if nothing matches
+		err = json.Unmarshal(body, &runs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return runs, nil
+}
+
+// performs a GET request on the given Portal API resource, returning the
+// resulting response body and/or error
+func (db Database) get(resource string, values url.Values) ([]byte, error) {
+	res, err := url.Parse(db.ApiURL)
+	if err != nil {
+		return nil, err
+	}
+	res.Path += resource
+	res.RawQuery = values.Encode()
+	slog.Debug(fmt.Sprintf("GET: %s", res.String()))
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodGet, res.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := databases.DoWithTimeout(&db.Client, "ena", req, db.Client.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200, 204:
+		return io.ReadAll(resp.Body)
+	case 400:
+		data, _ := io.ReadAll(resp.Body)
+		return nil, &databases.InvalidSearchParameter{
+			Database: "ENA",
+			Message:  string(data),
+		}
+	case 503:
+		return nil, &databases.UnavailableError{
+			Database: "ena",
+		}
+	default:
+		return nil, fmt.Errorf("An error occurred with the ENA database (%d)",
+			resp.StatusCode)
+	}
+}
+
+// returns the run accession for the file with the given ID, or an empty
+// string if the ID isn't that of an ENA FASTQ file
+func runAccessionForFileId(fileId string) string {
+	fileName, found := strings.CutPrefix(fileId, "ENA:")
+	if !found {
+		return ""
+	}
+	end := strings.IndexAny(fileName, "_.")
+	if end <= 0 {
+		return ""
+	}
+	return fileName[:end]
+}
+
+// creates Frictionless DataResources for the FASTQ files of the given run
+func dataResourcesFromRun(run Run) []frictionless.DataResource {
+	if run.FastqFtp == "" {
+		return nil
+	}
+	links := strings.Split(run.FastqFtp, ";")
+	checksums := strings.Split(run.FastqMD5, ";")
+	sizes := strings.Split(run.FastqBytes, ";")
+	resources := make([]frictionless.DataResource, len(links))
+	for i, link := range links {
+		// links omit their scheme (e.g. ftp.sra.ebi.ac.uk/vol1/fastq/...), so
+		// we strip the host to get a path relative to the file server's root
+		_, filePath, _ := strings.Cut(link, "/")
+		fileName := path.Base(filePath)
+		var checksum string
+		if i < len(checksums) {
+			checksum = checksums[i]
+		}
+		var size int
+		if i < len(sizes) {
+			size, _ = strconv.Atoi(sizes[i])
+		}
+
+		id := "ENA:" + fileName
+		resources[i] = frictionless.DataResource{
+			Id:        id,
+			Name:      dataResourceName(fileName),
+			Path:      filePath,
+			Format:    "fastq",
+			MediaType: mediaTypeFromFileName(fileName),
+			Bytes:     size,
+			Hash:      checksum,
+			Hashes:    frictionless.HashesForMD5(checksum),
+			Sources: []frictionless.DataSource{
+				{
+					Title: fmt.Sprintf("ENA run %s", run.RunAccession),
+					Path:  "https://www.ebi.ac.uk/ena/browser/view/" + run.RunAccession,
+				},
+			},
+			Credit: creditMetadataFromRun(id, run),
+		}
+		if config.Service.InstrumentMetadata && run.InstrumentModel != "" {
+			resources[i].Instrument = &frictionless.DataInstrument{
+				Name:     run.InstrumentModel,
+				Platform: run.InstrumentPlatform,
+				Model:    run.InstrumentModel,
+			}
+		}
+	}
+	return resources
+}
+
+// creates credit metadata for a file with the given ID belonging to the given run
+func creditMetadataFromRun(id string, run Run) credit.CreditMetadata {
+	metadata := credit.CreditMetadata{
+		Identifier:   id,
+		ResourceType: "dataset",
+		Publisher: credit.Organization{
+			OrganizationId:   "ROR:02catss52",
+			OrganizationName: "European Bioinformatics Institute",
+		},
+		Version: run.LastUpdated,
+	}
+	if run.StudyTitle != "" {
+		metadata.Titles = []credit.Title{
+			{
+				Title: run.StudyTitle,
+			},
+		}
+	}
+	if run.ScientificName != "" {
+		metadata.Descriptions = []credit.Description{
+			{
+				DescriptionText: fmt.Sprintf("Sequencing reads for %s", run.ScientificName),
+				DescriptionType: "other",
+			},
+		}
+	}
+	if run.FirstPublic != "" {
+		metadata.Dates = append(metadata.Dates, credit.EventDate{
+			Date:  run.FirstPublic,
+			Event: "Available",
+		})
+	}
+	if run.LastUpdated != "" {
+		metadata.Dates = append(metadata.Dates, credit.EventDate{
+			Date:  run.LastUpdated,
+			Event: "Updated",
+		})
+	}
+	for _, related := range []struct{ Accession, Description, Relationship string }{
+		{run.StudyAccession, "ENA study", "IsPartOf"},
+		{run.ExperimentAccession, "ENA experiment", "IsPartOf"},
+		{run.SampleAccession, "ENA sample", "IsDerivedFrom"},
+	} {
+		if related.Accession != "" {
+			metadata.RelatedIdentifiers = append(metadata.RelatedIdentifiers, credit.PermanentID{
+				Id:               "ENA:" + related.Accession,
+				Description:      related.Description,
+				RelationshipType: related.Relationship,
+			})
+		}
+	}
+	if run.CenterName != "" {
+		metadata.Contributors = []credit.Contributor{
+			{
+				ContributorType:  "Organization",
+				Name:             run.CenterName,
+				ContributorRoles: "DataCollector",
+			},
+		}
+	}
+	return metadata
+}
+
+// creates a Frictionless DataResource-savvy name for a file by lower-casing
+// it and removing its compression suffix (ENA file names contain only
+// letters, digits, '_', and '.')
+func dataResourceName(fileName string) string {
+	name := strings.ToLower(fileName)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// returns the media type for a FASTQ file with the given name
+func mediaTypeFromFileName(fileName string) string {
+	switch path.Ext(fileName) {
+	case ".gz":
+		return "application/gzip"
+	case ".bz2":
+		return "application/x-bzip2"
+	default:
+		return "text/plain"
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ena
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/frictionless"
+)
+
+const enaConfig string = `
+databases:
+  ena:
+    name: European Nucleotide Archive
+    organization: EMBL-EBI
+    endpoint: ena-http
+endpoints:
+  ena-http:
+    name: ENA file server
+    id: 5ea4bd52-1c2e-4d0e-b6f2-0c6d33bb1b95
+    provider: http
+    root: https://ftp.sra.ebi.ac.uk/
+`
+
+// sequencing runs served by our stand-in for the ENA Portal API: a paired-end
+// run and a single-end run from the same study
+const runsJSON string = `[
+  {
+    "run_accession": "ERR164407",
+    "experiment_accession": "ERX143176",
+    "study_accession": "PRJEB3102",
+    "sample_accession": "SAMEA1573362",
+    "study_title": "Genome sequencing of Escherichia coli isolates",
+    "scientific_name": "Escherichia coli",
+    "center_name": "WTSI",
+    "instrument_platform": "ILLUMINA",
+    "instrument_model": "Illumina HiSeq 2000",
+    "first_public": "2012-08-10",
+    "last_updated": "2018-11-16",
+    "fastq_ftp": "ftp.sra.ebi.ac.uk/vol1/fastq/ERR164/ERR164407/ERR164407_1.fastq.gz;ftp.sra.ebi.ac.uk/vol1/fastq/ERR164/ERR164407/ERR164407_2.fastq.gz",
+    "fastq_md5": "6ae3ab0dc6e3eb5e2b4c5d2b2c4bd5e1;2e7a5b4c3d8e9f0a1b2c3d4e5f6a7b8c",
+    "fastq_bytes": "1024;2048"
+  },
+  {
+    "run_accession": "ERR164408",
+    "experiment_accession": "ERX143177",
+    "study_accession": "PRJEB3102",
+    "sample_accession": "SAMEA1573363",
+    "study_title": "Genome sequencing of Escherichia coli isolates",
+    "scientific_name": "Escherichia coli",
+    "center_name": "WTSI",
+    "instrument_platform": "ILLUMINA",
+    "instrument_model": "Illumina HiSeq 2000",
+    "first_public": "2012-08-10",
+    "last_updated": "2018-11-16",
+    "fastq_ftp": "ftp.sra.ebi.ac.uk/vol1/fastq/ERR164/ERR164408/ERR164408.fastq.gz",
+    "fastq_md5": "9f86d081884c7d659a2feaa0c55ad015",
+    "fastq_bytes": "4096"
+  }
+]`
+
+// queries received by the stand-in Portal API
+var portalQueries []string
+
+// serves the runs above, respecting the requested limit
+func servePortalAPI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/search" || r.URL.Query().Get("result") != "read_run" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query().Get("query")
+	portalQueries = append(portalQueries, query)
+	var runs []Run
+	json.Unmarshal([]byte(runsJSON), &runs)
+	matches := make([]Run, 0)
+	for _, run := range runs {
+		if strings.Contains(query, run.RunAccession) || strings.Contains(query, run.StudyAccession) {
+			matches = append(matches, run)
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	if len(matches) == 0 {
+		return // the Portal API sends an empty body
+	}
+	data, _ := json.Marshal(matches)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+var portalServer *httptest.Server
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	config.Init([]byte(enaConfig))
+	portalServer = httptest.NewServer(http.HandlerFunc(servePortalAPI))
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	portalServer.Close()
+}
+
+// creates a database that uses the stand-in Portal API
+func newTestDatabase() *Database {
+	db, _ := NewDatabase("1234-5678-9101-1121")
+	enaDb := db.(*Database)
+	enaDb.ApiURL = portalServer.URL + "/"
+	return enaDb
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := NewDatabase("1234-5678-9101-1121")
+	assert.NotNil(db)
+	assert.Nil(err)
+
+	db, err = NewDatabase("")
+	assert.Nil(db)
+	assert.NotNil(err)
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	// search for the files in a study
+	studyType, _ := json.Marshal("study")
+	results, err := db.Search(databases.SearchParameters{
+		Query:    "PRJEB3102",
+		Specific: map[string]json.RawMessage{"accession_type": studyType},
+	})
+	assert.Nil(err)
+	assert.Equal(`study_accession="PRJEB3102"`, portalQueries[len(portalQueries)-1])
+	assert.Equal(3, len(results.Resources))
+	assert.False(results.HasMore)
+
+	// paired-end reads produce a resource for each file
+	resource := results.Resources[0]
+	assert.Equal("ENA:ERR164407_1.fastq.gz", resource.Id)
+	assert.Equal("err164407_1.fastq", resource.Name)
+	assert.Equal("vol1/fastq/ERR164/ERR164407/ERR164407_1.fastq.gz", resource.Path)
+	assert.Equal("fastq", resource.Format)
+	assert.Equal("application/gzip", resource.MediaType)
+	assert.Equal(1024, resource.Bytes)
+	assert.Equal("6ae3ab0dc6e3eb5e2b4c5d2b2c4bd5e1", resource.Hash)
+	assert.Equal("ENA:ERR164407_2.fastq.gz", results.Resources[1].Id)
+	assert.Equal(2048, results.Resources[1].Bytes)
+	assert.Equal("ENA:ERR164408.fastq.gz", results.Resources[2].Id)
+
+	// run metadata is mapped to credit metadata
+	assert.Equal("ENA:ERR164407_1.fastq.gz", resource.Credit.Identifier)
+	assert.Equal("Genome sequencing of Escherichia coli isolates", resource.Credit.Titles[0].Title)
+	assert.Equal("2018-11-16", resource.Credit.Version)
+	assert.Equal("WTSI", resource.Credit.Contributors[0].Name)
+	assert.Equal("ENA:PRJEB3102", resource.Credit.RelatedIdentifiers[0].Id)
+	assert.Equal("IsPartOf", resource.Credit.RelatedIdentifiers[0].RelationshipType)
+	assert.Nil(resource.Instrument)
+
+	// a page that ends within a run's files indicates there are more results
+	results, err = db.Search(databases.SearchParameters{
+		Query:      "PRJEB3102",
+		Specific:   map[string]json.RawMessage{"accession_type": studyType},
+		Pagination: databases.SearchPaginationParameters{Offset: 1, MaxNum: 1},
+	})
+	assert.Nil(err)
+	assert.Equal(1, len(results.Resources))
+	assert.Equal("ENA:ERR164407_2.fastq.gz", results.Resources[0].Id)
+	assert.True(results.HasMore)
+
+	// queries without accession types are passed along as-is
+	_, err = db.Search(databases.SearchParameters{Query: `run_accession="ERR164408"`})
+	assert.Nil(err)
+	assert.Equal(`run_accession="ERR164408"`, portalQueries[len(portalQueries)-1])
+}
+
+func TestSearchWithInvalidParameters(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	_, err := db.Search(databases.SearchParameters{})
+	assert.NotNil(err)
+
+	sampleType, _ := json.Marshal("sample")
+	_, err = db.Search(databases.SearchParameters{
+		Query:    "SAMEA1573362",
+		Specific: map[string]json.RawMessage{"accession_type": sampleType},
+	})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+}
+
+func TestResources(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	resources, err := db.Resources([]string{"ENA:ERR164408.fastq.gz", "ENA:ERR164407_2.fastq.gz"})
+	assert.Nil(err)
+	assert.Equal(2, len(resources))
+	assert.Equal("ENA:ERR164408.fastq.gz", resources[0].Id)
+	assert.Equal("vol1/fastq/ERR164/ERR164408/ERR164408.fastq.gz", resources[0].Path)
+	assert.Equal("ENA:ERR164407_2.fastq.gz", resources[1].Id)
+	assert.Equal(`run_accession="ERR164408" OR run_accession="ERR164407"`,
+		portalQueries[len(portalQueries)-1])
+
+	_, err = db.Resources([]string{"ENA:ERR999999.fastq.gz"})
+	assert.IsType(databases.ResourceNotFoundError{}, err)
+	_, err = db.Resources([]string{"JDP:57f9e03f7ded5e3135bc069e"})
+	assert.IsType(databases.ResourceNotFoundError{}, err)
+}
+
+// checks that descriptors fetched through the descriptor cache are served from
+// it the second time around
+func TestCachedResources(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	fileIds := []string{"ENA:ERR164407_1.fastq.gz", "ENA:ERR164407_2.fastq.gz"}
+	resources, err := databases.CachedResources("ena", db, fileIds)
+	assert.Nil(err)
+	assert.Equal(2, len(resources))
+	numQueries := len(portalQueries)
+
+	resources, err = databases.CachedResources("ena", db, fileIds)
+	assert.Nil(err)
+	assert.Equal(2, len(resources))
+	assert.Equal("ENA:ERR164407_2.fastq.gz", resources[1].Id)
+	assert.Equal(numQueries, len(portalQueries))
+
+	// ...until they're invalidated
+	databases.InvalidateCachedResources("ena", fileIds[:1])
+	_, err = databases.CachedResources("ena", db, fileIds)
+	assert.Nil(err)
+	assert.Equal(numQueries+1, len(portalQueries))
+	assert.Equal(`run_accession="ERR164407"`, portalQueries[len(portalQueries)-1])
+}
+
+func TestInstrumentMetadata(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	config.Service.InstrumentMetadata = true
+	defer func() { config.Service.InstrumentMetadata = false }()
+	resources, err := db.Resources([]string{"ENA:ERR164408.fastq.gz"})
+	assert.Nil(err)
+	assert.Equal(&frictionless.DataInstrument{
+		Name:     "Illumina HiSeq 2000",
+		Platform: "ILLUMINA",
+		Model:    "Illumina HiSeq 2000",
+	}, resources[0].Instrument)
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
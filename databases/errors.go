@@ -66,6 +66,17 @@ func (e UnavailableError) Error() string {
 	return fmt.Sprintf("Cannot reach database '%s': unavailable", e.Database)
 }
 
+// indicates that a request to a database was abandoned because it didn't
+// complete within the database's configured timeout (such a request may be
+// retried)
+type TimeoutError struct {
+	Database string
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("Request to database '%s' timed out", e.Database)
+}
+
 // This error type is returned when an invalid database-specific search
 // parameter is specified
 type InvalidSearchParameter struct {
@@ -116,6 +127,17 @@ func (e ResourceNotFoundError) Error() string {
 	return fmt.Sprintf("Can't access file '%s' in database '%s': not found", e.ResourceId, e.Database)
 }
 
+// this error type is returned when a resource's metadata is missing required
+// fields or is otherwise malformed
+type InvalidResourceError struct {
+	Database, ResourceId, Message string
+}
+
+func (e InvalidResourceError) Error() string {
+	return fmt.Sprintf("Invalid metadata for resource '%s' in database '%s': %s",
+		e.ResourceId, e.Database, e.Message)
+}
+
 // this error type is returned when an endpoint cannot be found for a file ID
 type ResourceEndpointNotFoundError struct {
 	Database, ResourceId string
@@ -0,0 +1,122 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// returns the names of the fields that can appear in a JSON representation of
+// a Frictionless DataResource (i.e. the keys that can be requested for a
+// search)
+func ResourceFieldNames() []string {
+	return resourceFieldNames_
+}
+
+// checks the given resource field names against those that can appear in a
+// JSON DataResource, returning an InvalidSearchParameter error for the given
+// database if any are not recognized
+func ValidateResourceFields(dbName string, fields []string) error {
+	for _, field := range fields {
+		if !isResourceField(field) {
+			return InvalidSearchParameter{
+				Database: dbName,
+				Message: fmt.Sprintf("Invalid resource field '%s' (valid fields: %s)",
+					field, strings.Join(resourceFieldNames_, ", ")),
+			}
+		}
+	}
+	return nil
+}
+
+// returns a slice of JSON objects containing only the given fields of the
+// given resources, or an InvalidSearchParameter error for the given database
+// if any of the fields are not recognized. If no fields are given, all fields
+// are included.
+func SelectResourceFields(dbName string, resources []frictionless.DataResource,
+	fields []string) ([]map[string]json.RawMessage, error) {
+	err := ValidateResourceFields(dbName, fields)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]map[string]json.RawMessage, len(resources))
+	for i, resource := range resources {
+		// round-trip the resource through JSON to get at its fields
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return nil, err
+		}
+		var allFields map[string]json.RawMessage
+		err = json.Unmarshal(data, &allFields)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			selected[i] = allFields
+		} else {
+			selected[i] = make(map[string]json.RawMessage)
+			for _, field := range fields {
+				if value, found := allFields[field]; found {
+					selected[i][field] = value
+				}
+			}
+		}
+	}
+	return selected, nil
+}
+
+//-----------
+// Internals
+//-----------
+
+// names of JSON fields in a DataResource, gathered from its struct tags
+var resourceFieldNames_ = jsonFieldNames(reflect.TypeOf(frictionless.DataResource{}))
+
+// returns the names of the JSON fields in the given struct type
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		tag, found := t.Field(i).Tag.Lookup("json")
+		if !found {
+			continue // fields without JSON tags aren't exposed
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isResourceField(field string) bool {
+	for _, name := range resourceFieldNames_ {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// Searches can include or exclude files whose paths match glob patterns (with
+// the syntax of path.Match). A pattern without a slash matches any component of
+// a file's path, and a pattern with one matches the file's path or that of any
+// directory containing it. For example, "*.fastq.gz" matches compressed FASTQ
+// files anywhere, and "raw" or "data/raw/*" matches everything in data/raw.
+// The patterns are applied to search results after their descriptors are
+// built, so they work with any database.
+
+// checks the include and exclude patterns in the given search parameters,
+// returning an InvalidSearchParameter error for the given database if either
+// is malformed
+func ValidateFilePatterns(dbName string, params SearchParameters) error {
+	for _, pattern := range []string{params.Include, params.Exclude} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return InvalidSearchParameter{
+				Database: dbName,
+				Message:  fmt.Sprintf("Invalid file pattern '%s': %s", pattern, err.Error()),
+			}
+		}
+	}
+	return nil
+}
+
+// returns the given search results without the resources excluded by the
+// include and exclude patterns in the given search parameters, adding the
+// number of resources left out to NumSkipped (the patterns must be valid)
+func FilterSearchResults(params SearchParameters, results SearchResults) SearchResults {
+	if params.Include == "" && params.Exclude == "" {
+		return results
+	}
+	numResources := len(results.Resources)
+	excludedIds := make(map[string]bool)
+	results.Resources = slices.DeleteFunc(slices.Clone(results.Resources),
+		func(resource frictionless.DataResource) bool {
+			excluded := (params.Include != "" && !matchesFilePattern(params.Include, resource.Path)) ||
+				(params.Exclude != "" && matchesFilePattern(params.Exclude, resource.Path))
+			if excluded {
+				excludedIds[resource.Id] = true
+			}
+			return excluded
+		})
+	results.NumSkipped += numResources - len(results.Resources)
+
+	// remove excluded resources from groups, dropping any groups left empty
+	if len(results.Groups) > 0 {
+		groups := make([]SearchResultGroup, 0, len(results.Groups))
+		for _, group := range results.Groups {
+			group.ResourceIds = slices.DeleteFunc(slices.Clone(group.ResourceIds),
+				func(id string) bool { return excludedIds[id] })
+			if len(group.ResourceIds) > 0 {
+				groups = append(groups, group)
+			}
+		}
+		results.Groups = groups
+	}
+	return results
+}
+
+//-----------
+// Internals
+//-----------
+
+// returns true if the given (valid) pattern matches the given file path or
+// that of any of its containing directories (or, for a pattern without a
+// slash, any component of the path)
+func matchesFilePattern(pattern, filePath string) bool {
+	matchComponents := !strings.Contains(pattern, "/")
+	for p := strings.Trim(path.Clean(filePath), "/"); p != "." && p != ""; p = path.Dir(p) {
+		name := p
+		if matchComponents {
+			name = path.Base(p)
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
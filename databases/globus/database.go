@@ -0,0 +1,85 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package globus
+
+import (
+	"fmt"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/globus"
+)
+
+// This database presents the files in a directory on a Globus collection as a
+// source of transferable files. It describes the files by listing the root
+// directory of the database's endpoint, so they needn't be listed in advance.
+// (implements the databases.Database interface)
+type Database struct {
+	databases.ListingDatabase
+	// Globus endpoint whose files are listed
+	Endpoint *globus.Endpoint
+}
+
+// returns a function that creates a Globus-backed database with the given
+// name, suitable for registration with databases.RegisterDatabase
+func DatabaseConstructor(dbName string) func(orcid string) (databases.Database, error) {
+	return func(orcid string) (databases.Database, error) {
+		return NewDatabase(dbName, orcid)
+	}
+}
+
+// creates a database with the given name that lists files on its configured
+// Globus endpoint
+func NewDatabase(dbName, orcid string) (databases.Database, error) {
+	if orcid == "" {
+		return nil, fmt.Errorf("No ORCID was given")
+	}
+	dbConfig, found := config.Databases[dbName]
+	if !found {
+		return nil, databases.NotFoundError{Database: dbName}
+	}
+	endpoint, err := endpoints.NewEndpoint(dbConfig.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	globusEndpoint, ok := endpoint.(*globus.Endpoint)
+	if !ok {
+		return nil, databases.InvalidEndpointsError{
+			Database: dbName,
+			Message:  fmt.Sprintf("'%s' is not a Globus endpoint", dbConfig.Endpoint),
+		}
+	}
+	return &Database{
+		ListingDatabase: databases.ListingDatabase{
+			Id:     dbName,
+			Lister: globusEndpoint,
+		},
+		Endpoint: globusEndpoint,
+	}, nil
+}
+
+func (db *Database) LocalUser(orcid string) (string, error) {
+	// we have no way to map ORCIDs to Globus identities yet, so this database
+	// can only serve as a source
+	return "", fmt.Errorf("Globus database '%s' can't map ORCIDs to local users", db.Id)
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package globus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/globus"
+)
+
+const globusConfig string = `
+endpoints:
+  collection:
+    name: Test Globus Collection
+    id: 5e61d1c0-9f3a-4d7a-8c3b-2f6f3bdbe1a4
+    provider: globus
+    root: /data
+databases:
+  collection:
+    name: Files on a Test Globus Collection
+    organization: Globus Testers, Inc.
+    endpoint: collection
+    provider: globus
+`
+
+// recorded Globus Transfer API directory listings, keyed by path
+// (https://docs.globus.org/api/transfer/file_operations/#dir_listing_response)
+var listings = map[string]string{
+	"/data": `{"DATA_TYPE": "file_list", "path": "/data/", "DATA": [
+    {"DATA_TYPE": "file", "name": "README.md", "type": "file", "size": 512},
+    {"DATA_TYPE": "file", "name": "reads", "type": "dir", "size": 4096}]}`,
+	"/data/reads": `{"DATA_TYPE": "file_list", "path": "/data/reads/", "DATA": [
+    {"DATA_TYPE": "file", "name": "sample1.fastq.gz", "type": "file", "size": 1048576},
+    {"DATA_TYPE": "file", "name": "sample2.fastq.gz", "type": "file", "size": 2097152}]}`,
+}
+
+// serves the recorded directory listings above
+func serveListing(w http.ResponseWriter, r *http.Request) {
+	listing, found := listings[r.URL.Query().Get("path")]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code": "ClientError.NotFound", "message": "Directory not found"}`))
+		return
+	}
+	w.Write([]byte(listing))
+}
+
+// an HTTP transport that handles requests with the given handler instead of
+// sending them over the network
+type handlerTransport struct {
+	Handler http.HandlerFunc
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.Handler(recorder, req)
+	return recorder.Result(), nil
+}
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	err := config.Init([]byte(globusConfig))
+	if err != nil {
+		panic(err)
+	}
+
+	// our Globus endpoint sends its requests to our recorded listings
+	endpoints.RegisterEndpointProvider("globus", func(name string) (endpoints.Endpoint, error) {
+		return &globus.Endpoint{
+			Name:    config.Endpoints[name].Name,
+			Id:      config.Endpoints[name].Id,
+			RootDir: config.Endpoints[name].Root,
+			Client: http.Client{
+				Transport: handlerTransport{Handler: serveListing},
+			},
+		}, nil
+	})
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := NewDatabase("collection", "1234-5678-9101-1121")
+	assert.NotNil(db)
+	assert.Nil(err)
+	_, isPrefixDb := db.(databases.PrefixDatabase)
+	assert.True(isPrefixDb)
+
+	db, err = NewDatabase("collection", "")
+	assert.Nil(db)
+	assert.NotNil(err)
+
+	db, err = NewDatabase("nonexistent", "1234-5678-9101-1121")
+	assert.Nil(db)
+	assert.NotNil(err)
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("collection", "1234-5678-9101-1121")
+
+	// an empty query lists all files
+	results, err := db.Search(databases.SearchParameters{})
+	assert.Nil(err)
+	assert.Equal(3, len(results.Resources))
+	paths := make([]string, len(results.Resources))
+	sizes := make([]int, len(results.Resources))
+	for i, resource := range results.Resources {
+		paths[i] = resource.Path
+		sizes[i] = resource.Bytes
+		assert.Equal(resource.Path, resource.Id)
+	}
+	assert.Equal([]string{"README.md", "reads/sample1.fastq.gz", "reads/sample2.fastq.gz"}, paths)
+	assert.Equal([]int{512, 1048576, 2097152}, sizes)
+	assert.Equal("sample1.fastq", results.Resources[1].Name)
+	assert.Equal("gz", results.Resources[1].Format)
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
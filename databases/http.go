@@ -22,27 +22,183 @@
 package databases
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/StalkR/hsts"
+
+	"github.com/kbase/dts/config"
 )
 
+// options for constructing an HTTP client for a database
+type HttpClientOptions struct {
+	// if set, the client enables HTTP Strict Transport Security (HSTS) and
+	// refuses redirects
+	Secure bool
+	// the minimum TLS version accepted by the client (a crypto/tls constant,
+	// or zero for Go's default)
+	MinTLSVersion uint16
+	// the interval after which the client abandons a request (if positive)
+	Timeout time.Duration
+}
+
 // Here's a secure HTTP client that can be used to connect to databases. It
 // sets a reasonable timeout and enables HTTP Strict Transport Security (HSTS).
 func SecureHttpClient() http.Client {
-	client := http.Client{
+	return NewHttpClient(SecureHttpClientOptions())
+}
+
+// returns the options used by SecureHttpClient, which also serve as the
+// defaults for most databases' clients
+func SecureHttpClientOptions() HttpClientOptions {
+	return HttpClientOptions{
+		Secure:  true,
 		Timeout: time.Second * 10,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+	}
+}
+
+// creates an HTTP client for connecting to databases with the given options
+func NewHttpClient(options HttpClientOptions) http.Client {
+	client := http.Client{
+		Timeout: options.Timeout,
+	}
+	if options.MinTLSVersion != 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{MinVersion: options.MinTLSVersion}
+		client.Transport = transport
+	}
+	if options.Secure {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			if req.URL.Scheme == "http" {
 				return DowngradedRedirectError{
 					Endpoint: fmt.Sprintf("%s%s", req.URL.Host, req.URL.Path),
 				}
 			}
 			return http.ErrUseLastResponse
-		},
+		}
+		client.Transport = hsts.New(client.Transport) // enable HSTS
 	}
-	client.Transport = hsts.New(client.Transport) // enable HSTS
 	return client
 }
+
+// creates an HTTP client for the database with the given name, overriding the
+// given defaults with its secure_http, min_tls_version, and request_timeout
+// settings (where given)
+func DatabaseHttpClient(dbName string, defaults HttpClientOptions) http.Client {
+	options := defaults
+	dbConfig := config.Databases[dbName]
+	if dbConfig.SecureHttp != nil {
+		options.Secure = *dbConfig.SecureHttp
+	}
+	if minTLS := dbConfig.MinTLS(); minTLS != 0 {
+		options.MinTLSVersion = minTLS
+	}
+	if timeout := RequestTimeout(dbName); timeout > 0 {
+		options.Timeout = timeout
+	}
+	return NewHttpClient(options)
+}
+
+// sets the User-Agent header sent with requests to databases, which identifies
+// the service by the given product token and version
+func SetUserAgent(product, version string) {
+	userAgent = fmt.Sprintf("%s/%s", product, version)
+}
+
+// returns a copy of the given context carrying the given correlation ID (e.g.
+// that of a transfer), which is sent in the X-DTS-Correlation-ID header of
+// database requests made with the context
+func WithCorrelationId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIdKey{}, id)
+}
+
+// adds the headers that identify the service (and the correlation ID carried
+// by the request's context, if any) to the given request to a database
+func AddRequestHeaders(request *http.Request) {
+	request.Header.Set("User-Agent", userAgent)
+	if id, ok := request.Context().Value(correlationIdKey{}).(string); ok && id != "" {
+		request.Header.Set("X-DTS-Correlation-ID", id)
+	}
+}
+
+// returns the timeout configured for requests to the database with the given
+// name, or zero if no timeout is configured
+func RequestTimeout(dbName string) time.Duration {
+	return time.Duration(config.Databases[dbName].RequestTimeout) * time.Second
+}
+
+// sends the given HTTP request to the database with the given name using the
+// given client, abandoning the request if it (including the reading of its
+// response body) doesn't complete within the given timeout. A non-positive
+// timeout imposes no deadline beyond any imposed by the client. A request
+// that times out produces a TimeoutError.
+func DoWithTimeout(client *http.Client, dbName string, request *http.Request,
+	timeout time.Duration) (*http.Response, error) {
+	AddRequestHeaders(request)
+	if timeout <= 0 {
+		response, err := client.Do(request)
+		return response, timeoutError(dbName, err)
+	}
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, timeoutError(dbName, err)
+	}
+	response.Body = deadlineBody{
+		ReadCloser: response.Body,
+		Database:   dbName,
+		cancel:     cancel,
+	}
+	return response, nil
+}
+
+//-----------
+// Internals
+//-----------
+
+// the User-Agent header sent with requests to databases
+var userAgent = "kbase-dts"
+
+// the key under which a correlation ID is stored in a request's context
+type correlationIdKey struct{}
+
+// converts the given error to a TimeoutError for the given database if it
+// indicates that a request timed out, passing any other error through
+func timeoutError(dbName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return TimeoutError{Database: dbName}
+	}
+	return err
+}
+
+// a response body that releases its request's context when closed, and that
+// reports reads interrupted by the request's deadline as TimeoutErrors
+type deadlineBody struct {
+	io.ReadCloser
+	Database string
+	cancel   context.CancelFunc
+}
+
+func (b deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = timeoutError(b.Database, err)
+	}
+	return n, err
+}
+
+func (b deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -23,6 +23,7 @@ package jdp
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
@@ -43,6 +44,7 @@ import (
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/frictionless"
 )
 
@@ -61,6 +63,11 @@ type Database struct {
 	SsoToken string
 	// mapping from staging UUIDs to JDP restoration request ID
 	StagingRequests map[uuid.UUID]StagingRequest
+	// set if private data (to which the JDP grants the user access) is
+	// included in the files described and staged
+	IncludePrivateData bool
+	// correlation ID sent with the database's requests (if any)
+	CorrelationId string
 }
 
 type StagingRequest struct {
@@ -68,6 +75,10 @@ type StagingRequest struct {
 	Id int
 	// time of staging request (for purging)
 	Time time.Time
+	// IDs of the files being staged (for verification)
+	FileIds []string
+	// set if the files being staged include private data
+	IncludePrivateData bool
 }
 
 func NewDatabase(orcid string) (databases.Database, error) {
@@ -75,13 +86,12 @@ func NewDatabase(orcid string) (databases.Database, error) {
 		return nil, fmt.Errorf("No ORCID was given")
 	}
 
-	// make sure we have a shared secret or an SSO token
-	secret, haveSecret := os.LookupEnv("DTS_JDP_SECRET")
-	if !haveSecret { // check for SSO token
-		_, haveToken := os.LookupEnv("DTS_JDP_SSO_TOKEN")
-		if !haveToken {
-			return nil, fmt.Errorf("No shared secret or SSO token was found for JDP authentication")
-		}
+	// make sure we have an SSO token or a shared secret (an SSO token takes
+	// precedence if both are present)
+	ssoToken := os.Getenv("DTS_JDP_SSO_TOKEN")
+	secret := os.Getenv("DTS_JDP_SECRET")
+	if ssoToken == "" && secret == "" {
+		return nil, fmt.Errorf("No shared secret or SSO token was found for JDP authentication")
 	}
 
 	// make sure we are using only a single endpoint
@@ -92,15 +102,14 @@ func NewDatabase(orcid string) (databases.Database, error) {
 		}
 	}
 
-	// NOTE: we can't enable HSTS for JDP requests at this time, because the
-	// NOTE: server doesn't seem to support it. Maybe raise this issue with the
-	// NOTE: team?
+	// NOTE: we don't enable HSTS for JDP requests by default, because the
+	// NOTE: server doesn't seem to support it (secure_http: true enables it)
 	return &Database{
-		//Client:          databases.SecureHttpClient(),
+		Client:          databases.DatabaseHttpClient("jdp", databases.HttpClientOptions{}),
 		Id:              "jdp",
 		Orcid:           orcid,
 		Secret:          secret,
-		SsoToken:        os.Getenv("DTS_JDP_SSO_TOKEN"),
+		SsoToken:        ssoToken,
 		StagingRequests: make(map[uuid.UUID]StagingRequest),
 	}, nil
 }
@@ -114,6 +123,18 @@ func (db Database) SpecificSearchParameters() map[string]interface{} {
 		"include_private_data": []int{0, 1},                                             // flag to include private data
 		"s":                    []string{"name", "id", "title", "kingdom", "score.avg"}, // sort order
 		"extra":                []string{"img_taxon_oid", "project_id"},                 // list of requested extra fields
+		"group_by":             []string{"organism"},                                    // grouping of results
+	}
+}
+
+func (db Database) SearchParameterDescriptions() map[string]databases.SearchParameterSpec {
+	return map[string]databases.SearchParameterSpec{
+		"d":                    {Description: "sort direction (ascending or descending)"},
+		"f":                    {Description: "specific field to search"},
+		"include_private_data": {Description: "flag (1) to include private data in results"},
+		"s":                    {Description: "sort order"},
+		"extra":                {Description: "additional field included in results"},
+		"group_by":             {Description: "attribute by which results are grouped"},
 	}
 }
 
@@ -139,7 +160,16 @@ func (db *Database) Search(params databases.SearchParameters) (databases.SearchR
 		}
 	}
 
-	return db.filesFromSearch(p)
+	results, err := db.filesFromSearch(p)
+	if err != nil {
+		return results, err
+	}
+
+	// the JDP groups its results by organism and doesn't report the total
+	// number of matching files, so we assume there are more whenever we
+	// receive a full page
+	results.HasMore = len(results.Resources) >= pageSize
+	return results, nil
 }
 
 func (db *Database) Resources(fileIds []string) ([]frictionless.DataResource, error) {
@@ -152,24 +182,7 @@ func (db *Database) Resources(fileIds []string) ([]frictionless.DataResource, er
 		indexForId[strippedFileIds[i]] = i
 	}
 
-	type MetadataRequest struct {
-		Ids                []string `json:"ids"`
-		Aggregations       bool     `json:"aggregations"`
-		IncludePrivateData bool     `json:"include_private_data"`
-	}
-	data, err := json.Marshal(MetadataRequest{
-		Ids:                strippedFileIds,
-		Aggregations:       false,
-		IncludePrivateData: true,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := db.post("search/by_file_ids/", bytes.NewReader(data))
-	defer resp.Body.Close()
-	var body []byte
-	body, err = io.ReadAll(resp.Body)
+	body, err := db.searchByFileIds(strippedFileIds)
 	if err != nil {
 		return nil, err
 	}
@@ -231,78 +244,70 @@ func (db *Database) Resources(fileIds []string) ([]frictionless.DataResource, er
 		resources[index].Format = formatFromFileName(resources[index].Path)
 		resources[index].MediaType = mimeTypeFromFormatAndTypes(resources[index].Format, []string{})
 	}
+
+	// the JDP returns no hits for files it doesn't know about, so we leave
+	// them out and let the caller sort them out
+	resources = slices.DeleteFunc(resources, func(resource frictionless.DataResource) bool {
+		return resource.Id == ""
+	})
 	return resources, err
 }
 
-func (db *Database) StageFiles(fileIds []string) (uuid.UUID, error) {
-	var xferId uuid.UUID
-
-	// construct a POST request to restore archived files with the given IDs
-	type RestoreRequest struct {
-		Ids                []string `json:"ids"`
-		SendEmail          bool     `json:"send_email"`
-		ApiVersion         string   `json:"api_version"`
-		IncludePrivateData int      `json:"include_private_data"`
-	}
-
-	// strip "JDP:" off the file IDs (and remove those without this prefix)
-	fileIdsWithoutPrefix := make([]string, 0)
-	for _, fileId := range fileIds {
-		if strings.HasPrefix(fileId, "JDP:") {
-			fileIdsWithoutPrefix = append(fileIdsWithoutPrefix, fileId[4:])
-		}
+// estimates the number and total size of the files with the given IDs from
+// the sizes the JDP reports for them, without building their descriptors
+// (implements the databases.EstimatingDatabase interface)
+func (db *Database) EstimateTransfer(fileIds []string) (int, uint64, error) {
+	strippedFileIds := make([]string, len(fileIds))
+	for i, fileId := range fileIds {
+		strippedFileIds[i] = strings.TrimPrefix(fileId, "JDP:")
 	}
-
-	data, err := json.Marshal(RestoreRequest{
-		Ids:                fileIdsWithoutPrefix,
-		SendEmail:          false,
-		ApiVersion:         "2",
-		IncludePrivateData: 1, // we need this just in case!
-	})
+	body, err := db.searchByFileIds(strippedFileIds)
 	if err != nil {
-		return xferId, err
+		return 0, 0, err
 	}
 
-	// NOTE: The slash in the resource is all-important for POST requests to
-	// NOTE: the JDP!!
-	response, err := db.post("request_archived_files/", bytes.NewReader(data))
+	type SizeResponse struct {
+		Hits struct {
+			Hits []struct {
+				Id     string `json:"_id"`
+				Source struct {
+					FileSize int `json:"file_size"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	var jdpResp SizeResponse
+	err = json.Unmarshal(body, &jdpResp)
 	if err != nil {
-		return xferId, err
+		return 0, 0, err
 	}
 
-	switch response.StatusCode {
-	case 200, 201, 204:
-		defer response.Body.Close()
-		var body []byte
-		body, err = io.ReadAll(response.Body)
-		if err != nil {
-			return xferId, err
-		}
-		type RestoreResponse struct {
-			RequestId int `json:"request_id"`
+	// as with Resources, files the JDP doesn't know about are left out
+	count := 0
+	var size uint64
+	for _, hit := range jdpResp.Hits.Hits {
+		if slices.Contains(strippedFileIds, hit.Id) {
+			count++
+			size += uint64(hit.Source.FileSize)
 		}
+	}
+	return count, size, nil
+}
 
-		var jdpResp RestoreResponse
-		err = json.Unmarshal(body, &jdpResp)
-		if err != nil {
-			return xferId, err
-		}
-		slog.Debug(fmt.Sprintf("Requested %d archived files from JDP (request ID: %d)",
-			len(fileIds), jdpResp.RequestId))
-		xferId = uuid.New()
-		db.StagingRequests[xferId] = StagingRequest{
-			Id:   jdpResp.RequestId,
-			Time: time.Now(),
-		}
-		return xferId, err
-	case 404:
-		return xferId, databases.ResourceNotFoundError{
-			Database:   "JDP",
-			ResourceId: strings.Join(fileIds, ","),
-		}
-	default:
+func (db *Database) StageFiles(fileIds []string) (uuid.UUID, error) {
+	var xferId uuid.UUID
+	requestId, err := db.requestArchivedFiles(fileIds)
+	if err != nil {
 		return xferId, err
 	}
+	xferId = uuid.New()
+	db.StagingRequests[xferId] = StagingRequest{
+		Id:                 requestId,
+		Time:               time.Now(),
+		FileIds:            fileIds,
+		IncludePrivateData: db.IncludePrivateData,
+	}
+	return xferId, nil
 }
 
 func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
@@ -314,6 +319,13 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 			return databases.StagingStatusUnknown, err
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			// the JDP no longer knows about this request (e.g. it was
+			// purged while we were down), so we stop tracking it
+			slog.Warn(fmt.Sprintf("JDP staging request %d no longer exists", request.Id))
+			delete(db.StagingRequests, id)
+			return databases.StagingStatusUnknown, nil
+		}
 		var body []byte
 		body, err = io.ReadAll(resp.Body)
 		if err != nil {
@@ -333,6 +345,9 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 			"ready":   databases.StagingStatusSucceeded,
 		}
 		if status, ok := statusForString[jdpResult.Status]; ok {
+			if status == databases.StagingStatusSucceeded && config.Databases["jdp"].VerifyStaging {
+				return db.verifyStagedFiles(id)
+			}
 			return status, nil
 		}
 		return databases.StagingStatusUnknown, fmt.Errorf("Unrecognized staging status string: %s", jdpResult.Status)
@@ -341,11 +356,81 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 	}
 }
 
+// checks the files staged by the request with the given ID against their
+// descriptors, re-issuing the request and returning StagingStatusActive if any
+// don't match, or StagingStatusSucceeded if all is well
+func (db *Database) verifyStagedFiles(id uuid.UUID) (databases.StagingStatus, error) {
+	request := db.StagingRequests[id]
+	if len(request.FileIds) == 0 { // staged before we recorded file IDs
+		return databases.StagingStatusSucceeded, nil
+	}
+	endpoint, err := endpoints.NewEndpoint(config.Databases["jdp"].Endpoint)
+	if err != nil {
+		return databases.StagingStatusUnknown, err
+	}
+	verifiable, ok := endpoint.(endpoints.VerifiableEndpoint)
+	if !ok { // nothing to verify against
+		return databases.StagingStatusSucceeded, nil
+	}
+	// describe and restage the files with the same access they were staged with
+	requester := db.withPrivateData(request.IncludePrivateData)
+	resources, err := requester.Resources(request.FileIds)
+	if err != nil {
+		return databases.StagingStatusUnknown, err
+	}
+	mismatched, err := verifiable.MismatchedFiles(resources)
+	if err != nil {
+		return databases.StagingStatusUnknown, err
+	}
+	if len(mismatched) == 0 {
+		return databases.StagingStatusSucceeded, nil
+	}
+
+	// stage the mismatched files again
+	slog.Warn(fmt.Sprintf("%d staged JDP file(s) don't match their descriptors (%s); staging again",
+		len(mismatched), strings.Join(mismatched, ", ")))
+	request.Id, err = requester.requestArchivedFiles(mismatched)
+	if err != nil {
+		return databases.StagingStatusUnknown, err
+	}
+	request.Time = time.Now()
+	db.StagingRequests[id] = request
+	return databases.StagingStatusActive, nil
+}
+
+func (db *Database) CancelStaging(id uuid.UUID) error {
+	// the JDP doesn't allow us to withdraw a restoration request, but we can
+	// stop tracking it
+	delete(db.StagingRequests, id)
+	return nil
+}
+
+// returns true if the database includes private data in the files it
+// describes and stages (implements the databases.PrivateDataDatabase interface)
+func (db *Database) IncludesPrivateData() bool {
+	return db.IncludePrivateData
+}
+
+// returns a view of the database that includes private data in the files it
+// describes and stages (implements the databases.PrivateDataDatabase
+// interface). The JDP itself decides which private files the user may access.
+func (db *Database) WithPrivateData() databases.Database {
+	return db.withPrivateData(true)
+}
+
 func (db *Database) LocalUser(orcid string) (string, error) {
 	// no current mechanism for this
 	return "localuser", nil
 }
 
+// returns a view of the database whose requests carry the given correlation
+// ID (implements the databases.CorrelatedDatabase interface)
+func (db *Database) WithCorrelationId(id string) databases.Database {
+	view := *db
+	view.CorrelationId = id
+	return &view
+}
+
 func (db Database) Save() (databases.DatabaseSaveState, error) {
 	var buffer bytes.Buffer
 	enc := gob.NewEncoder(&buffer)
@@ -368,11 +453,94 @@ func (db *Database) Load(state databases.DatabaseSaveState) error {
 // Internal machinery
 //--------------------
 
+// returns a view of the database that shares its staging requests and
+// includes private data only if include is true
+func (db *Database) withPrivateData(include bool) *Database {
+	if db.IncludePrivateData == include {
+		return db
+	}
+	view := *db
+	view.IncludePrivateData = include
+	return &view
+}
+
+// requests that the archived files with the given IDs be restored, returning
+// the ID of the JDP restoration request
+func (db *Database) requestArchivedFiles(fileIds []string) (int, error) {
+	// construct a POST request to restore archived files with the given IDs
+	type RestoreRequest struct {
+		Ids                []string `json:"ids"`
+		SendEmail          bool     `json:"send_email"`
+		ApiVersion         string   `json:"api_version"`
+		IncludePrivateData int      `json:"include_private_data"`
+	}
+
+	// strip "JDP:" off the file IDs (and remove those without this prefix)
+	fileIdsWithoutPrefix := make([]string, 0)
+	for _, fileId := range fileIds {
+		if strings.HasPrefix(fileId, "JDP:") {
+			fileIdsWithoutPrefix = append(fileIdsWithoutPrefix, fileId[4:])
+		}
+	}
+
+	includePrivateData := 0
+	if db.IncludePrivateData {
+		includePrivateData = 1
+	}
+	data, err := json.Marshal(RestoreRequest{
+		Ids:                fileIdsWithoutPrefix,
+		SendEmail:          false,
+		ApiVersion:         "2",
+		IncludePrivateData: includePrivateData,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// NOTE: The slash in the resource is all-important for POST requests to
+	// NOTE: the JDP!!
+	response, err := db.post("request_archived_files/", bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	switch response.StatusCode {
+	case 200, 201, 204:
+		defer response.Body.Close()
+		var body []byte
+		body, err = io.ReadAll(response.Body)
+		if err != nil {
+			return 0, err
+		}
+		type RestoreResponse struct {
+			RequestId int `json:"request_id"`
+		}
+
+		var jdpResp RestoreResponse
+		err = json.Unmarshal(body, &jdpResp)
+		if err != nil {
+			return 0, err
+		}
+		slog.Debug(fmt.Sprintf("Requested %d archived files from JDP (request ID: %d)",
+			len(fileIds), jdpResp.RequestId))
+		return jdpResp.RequestId, nil
+	case 404:
+		return 0, databases.ResourceNotFoundError{
+			Database:   "JDP",
+			ResourceId: strings.Join(fileIds, ","),
+		}
+	default:
+		return 0, err
+	}
+}
+
 const (
-	jdpBaseURL     = "https://files.jgi.doe.gov/"
 	filePathPrefix = "/global/dna/dm_archive/" // directory containing JDP files
 )
 
+// base URL for JDP requests (can be overridden for testing)
+var jdpBaseURL = "https://files.jgi.doe.gov/"
+
 // a mapping from file suffixes to format labels
 var suffixToFormat = map[string]string{
 	"bam":      "bam",
@@ -480,6 +648,34 @@ func fileTypesFromFile(_ File) []string {
 	return []string{}
 }
 
+// extracts instrument information from the given metadata if instrument
+// metadata is enabled and available, returning nil otherwise
+func instrumentFromMetadata(md Metadata) *frictionless.DataInstrument {
+	sow := md.SowSegment
+	if !config.Service.InstrumentMetadata || (sow.Platform == "" && sow.SequencerModel == "") {
+		return nil
+	}
+	return &frictionless.DataInstrument{
+		Name:     sow.SequencerModel,
+		Platform: sow.Platform,
+		Model:    sow.SequencerModel,
+	}
+}
+
+// extracts the date (YYYY-MM-DD) until which data with the given metadata is
+// under embargo, returning an empty string if it isn't embargoed or the date
+// can't be interpreted
+func embargoFromMetadata(md Metadata) string {
+	date := md.Proposal.EmbargoEndDate
+	if len(date) > 10 { // strip any time of day
+		date = date[:10]
+	}
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return ""
+	}
+	return date
+}
+
 // extracts source information from the given metadata
 func sourcesFromMetadata(md Metadata) []frictionless.DataSource {
 	sources := make([]frictionless.DataSource, 0)
@@ -566,14 +762,18 @@ func dataResourceFromFile(file File) frictionless.DataResource {
 
 	pi := file.Metadata.Proposal.PI
 	return frictionless.DataResource{
-		Id:        id,
-		Name:      dataResourceName(file.Name),
-		Path:      filePath,
-		Format:    format,
-		MediaType: mimeTypeFromFormatAndTypes(format, fileTypes),
-		Bytes:     file.Size,
-		Hash:      file.MD5Sum,
-		Sources:   sources,
+		Id:           id,
+		Name:         dataResourceName(file.Name),
+		Path:         filePath,
+		Format:       format,
+		MediaType:    mimeTypeFromFormatAndTypes(format, fileTypes),
+		Bytes:        file.Size,
+		Hash:         file.MD5Sum,
+		Hashes:       frictionless.HashesForMD5(file.MD5Sum),
+		Sources:      sources,
+		Instrument:   instrumentFromMetadata(file.Metadata),
+		EmbargoUntil: embargoFromMetadata(file.Metadata),
+		DataUse:      file.Metadata.Proposal.DataUsePolicy,
 		Credit: credit.CreditMetadata{
 			Identifier:   id,
 			ResourceType: "dataset",
@@ -632,12 +832,13 @@ func dataResourceFromFile(file File) frictionless.DataResource {
 	}
 }
 
-// adds an appropriate authorization header to given HTTP request
+// adds an appropriate authorization header to given HTTP request, preferring
+// an SSO token to a shared secret
 func (db Database) addAuthHeader(request *http.Request) {
-	if len(db.Secret) > 0 { // use shared secret
-		request.Header.Add("Authorization", fmt.Sprintf("Token %s_%s", db.Orcid, db.Secret))
-	} else { // try SSO token
+	if len(db.SsoToken) > 0 { // use SSO token
 		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", db.SsoToken))
+	} else { // use shared secret
+		request.Header.Add("Authorization", fmt.Sprintf("Token %s_%s", db.Orcid, db.Secret))
 	}
 }
 
@@ -653,12 +854,13 @@ func (db *Database) get(resource string, values url.Values) (*http.Response, err
 	u.RawQuery = values.Encode()
 	res := fmt.Sprintf("%v", u)
 	slog.Debug(fmt.Sprintf("GET: %s", res))
-	req, err := http.NewRequest(http.MethodGet, res, http.NoBody)
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodGet, res, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
 	db.addAuthHeader(req)
-	return db.Client.Do(req)
+	return databases.DoWithTimeout(&db.Client, db.Id, req, db.Client.Timeout)
 }
 
 // performs a POST request on the given resource, returning the resulting
@@ -671,13 +873,39 @@ func (db *Database) post(resource string, body io.Reader) (*http.Response, error
 	u.Path = resource
 	res := fmt.Sprintf("%v", u)
 	slog.Debug(fmt.Sprintf("POST: %s", res))
-	req, err := http.NewRequest(http.MethodPost, res, body)
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodPost, res, body)
 	if err != nil {
 		return nil, err
 	}
 	db.addAuthHeader(req)
 	req.Header.Set("Content-Type", "application/json")
-	return db.Client.Do(req)
+	return databases.DoWithTimeout(&db.Client, db.Id, req, db.Client.Timeout)
+}
+
+// fetches the JDP's metadata for the files with the given (unprefixed) IDs,
+// returning the body of its response
+func (db *Database) searchByFileIds(fileIds []string) ([]byte, error) {
+	type MetadataRequest struct {
+		Ids                []string `json:"ids"`
+		Aggregations       bool     `json:"aggregations"`
+		IncludePrivateData bool     `json:"include_private_data"`
+	}
+	data, err := json.Marshal(MetadataRequest{
+		Ids:                fileIds,
+		Aggregations:       false,
+		IncludePrivateData: db.IncludePrivateData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := db.post("search/by_file_ids/", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
 }
 
 // this helper extracts files for the JDP /search GET query with given parameters
@@ -693,6 +921,11 @@ func (db *Database) filesFromSearch(params url.Values) (databases.SearchResults,
 		params.Del("extra")
 	}
 
+	// determine whether results are grouped by organism (and scrub the request
+	// from params, since the JDP doesn't recognize it)
+	groupByOrganism := params.Get("group_by") == "organism"
+	params.Del("group_by")
+
 	resp, err := db.get("search", params)
 	if err != nil {
 		return results, err
@@ -706,6 +939,7 @@ func (db *Database) filesFromSearch(params url.Values) (databases.SearchResults,
 	type JDPResults struct {
 		Organisms []struct {
 			Id    string `json:"id"`
+			Title string `json:"title"`
 			Files []File `json:"files"`
 		} `json:"organisms"`
 	}
@@ -717,8 +951,16 @@ func (db *Database) filesFromSearch(params url.Values) (databases.SearchResults,
 	}
 	for _, org := range jdpResults.Organisms {
 		resources := make([]frictionless.DataResource, 0)
+		group := databases.SearchResultGroup{
+			Id:          org.Id,
+			Title:       org.Title,
+			ResourceIds: make([]string, 0),
+		}
 		for _, file := range org.Files {
 			res := dataResourceFromFile(file)
+			if !slices.Contains(group.ResourceIds, res.Id) {
+				group.ResourceIds = append(group.ResourceIds, res.Id)
+			}
 
 			// add any requested additional metadata
 			if extraFields != nil {
@@ -745,6 +987,9 @@ func (db *Database) filesFromSearch(params url.Values) (databases.SearchResults,
 			}
 		}
 		results.Resources = append(results.Resources, resources...)
+		if groupByOrganism {
+			results.Groups = append(results.Groups, group)
+		}
 	}
 	return results, nil
 }
@@ -857,6 +1102,24 @@ func (db Database) addSpecificSearchParameters(params map[string]json.RawMessage
 					Message:  fmt.Sprintf("Invalid requested extra field: %s", value),
 				}
 			}
+		case "group_by": // grouping of results
+			var value string
+			err := json.Unmarshal(jsonValue, &value)
+			if err != nil {
+				return &databases.InvalidSearchParameter{
+					Database: "JDP",
+					Message:  "Invalid JDP result grouping given (must be string)",
+				}
+			}
+			acceptedValues := paramSpec["group_by"].([]string)
+			if slices.Contains(acceptedValues, value) {
+				p.Add(name, value)
+			} else {
+				return &databases.InvalidSearchParameter{
+					Database: "JDP",
+					Message:  fmt.Sprintf("Invalid JDP result grouping: %s", value),
+				}
+			}
 		default:
 			return &databases.InvalidSearchParameter{
 				Database: "JDP",
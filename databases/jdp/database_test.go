@@ -1,9 +1,18 @@
 package jdp
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/kbase/dts/config"
@@ -11,6 +20,8 @@ import (
 	"github.com/kbase/dts/dtstest"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/frictionless"
 )
 
 const jdpConfig string = `
@@ -33,6 +44,9 @@ endpoints:
       client_secret: ${DTS_GLOBUS_CLIENT_SECRET}
 `
 
+// an ORCID used for tests that don't contact the JDP
+const testOrcid string = "0000-0002-1825-0097"
+
 // this function gets called at the begіnning of a test session
 func setup() {
 	dtstest.EnableDebugLogging()
@@ -64,13 +78,356 @@ func TestNewDatabaseWithoutJDPSharedSecret(t *testing.T) {
 	assert := assert.New(t)
 	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
 	jdpSecret := os.Getenv("DTS_JDP_SECRET")
+	jdpSsoToken := os.Getenv("DTS_JDP_SSO_TOKEN")
 	os.Unsetenv("DTS_JDP_SECRET")
+	os.Unsetenv("DTS_JDP_SSO_TOKEN")
 	jdpDb, err := NewDatabase(orcid)
 	os.Setenv("DTS_JDP_SECRET", jdpSecret)
+	os.Setenv("DTS_JDP_SSO_TOKEN", jdpSsoToken)
 	assert.Nil(jdpDb, "JDP database somehow created without shared secret available")
 	assert.NotNil(err, "JDP database creation without shared secret encountered no error")
 }
 
+// returns the Authorization header sent by a JDP database created with the
+// given shared secret and SSO token to a mock JDP server
+func authHeaderFor(t *testing.T, secret, ssoToken string) string {
+	assert := assert.New(t)
+
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", secret)
+	t.Setenv("DTS_JDP_SSO_TOKEN", ssoToken)
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err, "JDP database creation encountered an error")
+	resp, err := db.(*Database).get("search/", url.Values{})
+	assert.Nil(err, "JDP request to mock server encountered an error")
+	resp.Body.Close()
+	return header
+}
+
+func TestNewDatabaseWithSharedSecret(t *testing.T) {
+	assert := assert.New(t)
+	header := authHeaderFor(t, "sekrit", "")
+	assert.Equal("Token "+testOrcid+"_sekrit", header,
+		"JDP shared secret authorization header is malformed")
+}
+
+func TestNewDatabaseWithSSOToken(t *testing.T) {
+	assert := assert.New(t)
+	header := authHeaderFor(t, "", "sso-token")
+	assert.Equal("Bearer sso-token", header,
+		"JDP SSO token authorization header is malformed")
+
+	// the SSO token is preferred when a shared secret is also available
+	header = authHeaderFor(t, "sekrit", "sso-token")
+	assert.Equal("Bearer sso-token", header,
+		"JDP SSO token wasn't preferred to shared secret")
+}
+
+// a configuration whose JDP database verifies files staged to a local
+// endpoint
+const verifyStagingConfig string = `
+databases:
+  jdp:
+    name: JGI Data Portal
+    organization: Joint Genome Institue
+    url: https://files.jgi.doe.gov
+    endpoint: local-jdp
+    verify_staging: true
+endpoints:
+  local-jdp:
+    name: Local JDP staging area
+    id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6
+    provider: local
+    root: STAGING_ROOT
+`
+
+func TestVerifyStagedFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	// stage a file of the wrong size to a local endpoint
+	stagingRoot := t.TempDir()
+	stagedDir := filepath.Join(stagingRoot, "rqc", "123")
+	os.MkdirAll(stagedDir, 0755)
+	stagedFile := filepath.Join(stagedDir, "3300000123.a.fastq.gz")
+	os.WriteFile(stagedFile, []byte("truncated"), 0644)
+
+	err := config.Init([]byte(strings.ReplaceAll(verifyStagingConfig, "STAGING_ROOT", stagingRoot)))
+	assert.Nil(err)
+	defer config.Init([]byte(jdpConfig))
+	endpoints.RegisterEndpointProvider("local", local.NewEndpoint)
+
+	// set up a mock JDP that restores files instantly and describes our file
+	numRestores := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/request_archived_files/":
+			numRestores++
+			fmt.Fprintf(w, `{"request_id": %d}`, numRestores)
+		case strings.HasPrefix(r.URL.Path, "/request_archived_files/requests/"):
+			w.Write([]byte(`{"status": "ready"}`))
+		case r.URL.Path == "/search/by_file_ids/":
+			w.Write([]byte(`{"hits": {"hits": [{"_id": "52fd2f593b6d0e2e0ab5d2b4", "_source": {
+			  "file_path": "/global/dna/dm_archive/rqc/123",
+			  "file_name": "3300000123.a.fastq.gz",
+			  "file_size": 1024}}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+	stagingId, err := db.StageFiles([]string{"JDP:52fd2f593b6d0e2e0ab5d2b4"})
+	assert.Nil(err)
+	assert.Equal(1, numRestores)
+
+	// the mismatched file is staged again
+	status, err := db.StagingStatus(stagingId)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusActive, status)
+	assert.Equal(2, numRestores)
+	assert.Equal(2, db.(*Database).StagingRequests[stagingId].Id)
+
+	// once the file is restored properly, staging succeeds
+	os.WriteFile(stagedFile, make([]byte, 1024), 0644)
+	status, err = db.StagingStatus(stagingId)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusSucceeded, status)
+	assert.Equal(2, numRestores)
+}
+
+// tests that files unknown to the JDP are left out of the resources it
+// describes
+func TestResourcesOmitsUnknownFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a mock JDP that knows about only one of the requested files
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/by_file_ids/" {
+			w.Write([]byte(`{"hits": {"hits": [{"_id": "52fd2f593b6d0e2e0ab5d2b4", "_source": {
+			  "file_path": "/global/dna/dm_archive/rqc/123",
+			  "file_name": "3300000123.a.fastq.gz",
+			  "file_size": 1024}}]}}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+	resources, err := db.Resources([]string{"JDP:52fd2f593b6d0e2e0ab5d2b4", "JDP:nonexistent"})
+	assert.Nil(err)
+	assert.Len(resources, 1)
+	assert.Equal("JDP:52fd2f593b6d0e2e0ab5d2b4", resources[0].Id)
+}
+
+// tests that the JDP's transfer estimates match the sizes in the descriptors
+// of the files it recognizes
+func TestEstimateTransfer(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a mock JDP that knows about two of the three requested files
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/by_file_ids/" {
+			w.Write([]byte(`{"hits": {"hits": [
+			  {"_id": "52fd2f593b6d0e2e0ab5d2b4", "_source": {
+			    "file_path": "/global/dna/dm_archive/rqc/123",
+			    "file_name": "3300000123.a.fastq.gz",
+			    "file_size": 1024}},
+			  {"_id": "52fd2f593b6d0e2e0ab5d2b5", "_source": {
+			    "file_path": "/global/dna/dm_archive/rqc/124",
+			    "file_name": "3300000124.a.fastq.gz",
+			    "file_size": 3072}}]}}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+	fileIds := []string{"JDP:52fd2f593b6d0e2e0ab5d2b4", "JDP:52fd2f593b6d0e2e0ab5d2b5",
+		"JDP:nonexistent"}
+	count, size, err := db.(databases.EstimatingDatabase).EstimateTransfer(fileIds)
+	assert.Nil(err)
+	resources, err := db.Resources(fileIds)
+	assert.Nil(err)
+	assert.Equal(len(resources), count)
+	var descriptorSize uint64
+	for _, resource := range resources {
+		descriptorSize += uint64(resource.Bytes)
+	}
+	assert.Equal(descriptorSize, size)
+	assert.Equal(uint64(4096), size)
+}
+
+// tests that private data is excluded from the files the JDP describes and
+// stages unless a view of the database that includes it is requested
+func TestPrivateData(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a mock JDP that records the private data flags it's sent
+	var flags []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		flags = append(flags, body["include_private_data"])
+		switch r.URL.Path {
+		case "/search/by_file_ids/":
+			w.Write([]byte(`{"hits": {"hits": []}}`))
+		case "/request_archived_files/":
+			w.Write([]byte(`{"request_id": 1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+	fileIds := []string{"JDP:52fd2f593b6d0e2e0ab5d2b4"}
+
+	// by default, private data is excluded
+	assert.False(db.(databases.PrivateDataDatabase).IncludesPrivateData())
+	_, err = db.Resources(fileIds)
+	assert.Nil(err)
+	stagingId, err := db.StageFiles(fileIds)
+	assert.Nil(err)
+	assert.False(db.(*Database).StagingRequests[stagingId].IncludePrivateData)
+	assert.Equal([]any{false, float64(0)}, flags)
+
+	// a private data view includes it, sharing the database's staging requests
+	flags = nil
+	privateDb := databases.WithPrivateData(db, true)
+	assert.True(privateDb.(databases.PrivateDataDatabase).IncludesPrivateData())
+	assert.False(db.(databases.PrivateDataDatabase).IncludesPrivateData())
+	_, err = privateDb.Resources(fileIds)
+	assert.Nil(err)
+	stagingId, err = privateDb.StageFiles(fileIds)
+	assert.Nil(err)
+	assert.True(db.(*Database).StagingRequests[stagingId].IncludePrivateData)
+	assert.Equal([]any{true, float64(1)}, flags)
+
+	// no view is made when private data isn't requested
+	assert.Equal(db, databases.WithPrivateData(db, false))
+}
+
+// tests that requests to the JDP identify the DTS in their User-Agent headers,
+// and carry the correlation ID of the transfer on whose behalf they're made
+func TestUserAgent(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a mock JDP that records the user agents and correlation IDs of
+	// GETs and POSTs
+	userAgents := make(map[string]string)
+	correlationIds := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents[r.Method] = r.UserAgent()
+		correlationIds[r.Method] = r.Header.Get("X-DTS-Correlation-ID")
+		switch {
+		case r.URL.Path == "/request_archived_files/":
+			w.Write([]byte(`{"request_id": 1}`))
+		case strings.HasPrefix(r.URL.Path, "/request_archived_files/requests/"):
+			w.Write([]byte(`{"status": "pending"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	databases.SetUserAgent("kbase-dts", "1.2.3")
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+	db = databases.Correlated(db, "transfer-1")
+	stagingId, err := db.StageFiles([]string{"JDP:52fd2f593b6d0e2e0ab5d2b4"})
+	assert.Nil(err)
+	_, err = db.StagingStatus(stagingId)
+	assert.Nil(err)
+	assert.Equal("kbase-dts/1.2.3", userAgents[http.MethodPost])
+	assert.Equal("kbase-dts/1.2.3", userAgents[http.MethodGet])
+	assert.Equal("transfer-1", correlationIds[http.MethodPost])
+	assert.Equal("transfer-1", correlationIds[http.MethodGet])
+}
+
+// tests that staging requests restored from saved state report restores that
+// finished in the meantime, and that requests the JDP no longer knows about
+// are pruned
+func TestLoadedStagingRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a mock JDP that has finished one restore and forgotten another
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/request_archived_files/requests/1":
+			w.Write([]byte(`{"status": "ready"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	readyId, lostId := uuid.New(), uuid.New()
+	saved := Database{
+		StagingRequests: map[uuid.UUID]StagingRequest{
+			readyId: {Id: 1, Time: time.Now()},
+			lostId:  {Id: 2, Time: time.Now()},
+		},
+	}
+	state, err := saved.Save()
+	assert.Nil(err)
+
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+	err = db.Load(state)
+	assert.Nil(err)
+	assert.Len(db.(*Database).StagingRequests, 2)
+
+	status, err := db.StagingStatus(readyId)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusSucceeded, status)
+
+	status, err = db.StagingStatus(lostId)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusUnknown, status)
+	_, found := db.(*Database).StagingRequests[lostId]
+	assert.False(found, "Lost staging request wasn't pruned")
+}
+
 func TestSearch(t *testing.T) {
 	assert := assert.New(t)
 	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
@@ -89,6 +446,102 @@ func TestSearch(t *testing.T) {
 	assert.Nil(err, "JDP search query encountered an error")
 }
 
+func TestSearchParameterSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := databases.SearchParameterSchema(&Database{Id: "jdp"})
+	specs := make(map[string]databases.SearchParameterSpec)
+	for _, spec := range schema {
+		specs[spec.Name] = spec
+	}
+	assert.Equal(len(schema), len(specs))
+
+	// the field, sort order, and sort direction are string enums
+	assert.Equal("string", specs["f"].Type)
+	assert.Equal([]interface{}{"ssr", "biosample", "project_id", "library", "img_taxon_oid"},
+		specs["f"].AllowedValues)
+	assert.Equal("string", specs["s"].Type)
+	assert.Equal([]interface{}{"name", "id", "title", "kingdom", "score.avg"},
+		specs["s"].AllowedValues)
+	assert.Equal("string", specs["d"].Type)
+	assert.Equal([]interface{}{"asc", "desc"}, specs["d"].AllowedValues)
+
+	// include_private_data is an integer flag
+	assert.Equal("integer", specs["include_private_data"].Type)
+	assert.Equal([]interface{}{0, 1}, specs["include_private_data"].AllowedValues)
+
+	// every parameter is described and optional
+	for _, spec := range schema {
+		assert.NotEmpty(spec.Description, spec.Name)
+		assert.False(spec.Required, spec.Name)
+	}
+	assert.Equal("d", schema[0].Name) // sorted by name
+}
+
+func TestSearchGroupedByOrganism(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a mock JDP whose results span two organisms sharing a file
+	var groupByParam string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groupByParam = r.URL.Query().Get("group_by")
+		w.Write([]byte(`{"organisms": [
+		  {"id": "1000", "title": "Prochlorococcus marinus MIT 9313", "files": [
+		    {"_id": "a1", "file_name": "a1.fastq.gz", "file_path": "/rqc/1", "file_size": 1},
+		    {"_id": "s1", "file_name": "s1.fastq.gz", "file_path": "/rqc/s", "file_size": 1}]},
+		  {"id": "2000", "title": "Prochlorococcus marinus NATL2A", "files": [
+		    {"_id": "b1", "file_name": "b1.fastq.gz", "file_path": "/rqc/2", "file_size": 1},
+		    {"_id": "s1", "file_name": "s1.fastq.gz", "file_path": "/rqc/s", "file_size": 1}]}]}`))
+	}))
+	defer server.Close()
+	baseURL := jdpBaseURL
+	jdpBaseURL = server.URL + "/"
+	defer func() { jdpBaseURL = baseURL }()
+
+	t.Setenv("DTS_JDP_SECRET", "sekrit")
+	db, err := NewDatabase(testOrcid)
+	assert.Nil(err)
+
+	// by default, results are flat and ungrouped
+	results, err := db.Search(databases.SearchParameters{Query: "prochlorococcus"})
+	assert.Nil(err)
+	assert.Equal(3, len(results.Resources))
+	assert.Nil(results.Groups)
+
+	// grouped results preserve each organism's files (the JDP never sees the
+	// grouping parameter)
+	results, err = db.Search(databases.SearchParameters{
+		Query: "prochlorococcus",
+		Specific: map[string]json.RawMessage{
+			"group_by": json.RawMessage(`"organism"`),
+		},
+	})
+	assert.Nil(err)
+	assert.Equal("", groupByParam)
+	assert.Equal(3, len(results.Resources))
+	assert.Equal([]databases.SearchResultGroup{
+		{
+			Id:          "1000",
+			Title:       "Prochlorococcus marinus MIT 9313",
+			ResourceIds: []string{"JDP:a1", "JDP:s1"},
+		},
+		{
+			Id:          "2000",
+			Title:       "Prochlorococcus marinus NATL2A",
+			ResourceIds: []string{"JDP:b1", "JDP:s1"},
+		},
+	}, results.Groups)
+
+	// only supported groupings are accepted
+	_, err = db.Search(databases.SearchParameters{
+		Query: "prochlorococcus",
+		Specific: map[string]json.RawMessage{
+			"group_by": json.RawMessage(`"kingdom"`),
+		},
+	})
+	assert.NotNil(err)
+}
+
 func TestResources(t *testing.T) {
 	assert := assert.New(t)
 	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
@@ -119,6 +572,68 @@ func TestResources(t *testing.T) {
 	}
 }
 
+// checks that a file's MD5 checksum is recorded in its descriptor's hashes
+func TestDataResourceHashes(t *testing.T) {
+	assert := assert.New(t)
+	file := File{
+		Id:     "52fd2f593b6d0e2e0ab5d2b4",
+		Name:   "3300000123.a.fna",
+		Path:   "/global/dna/dm_archive/img/submissions/123",
+		Size:   1024,
+		MD5Sum: "d41d8cd98f00b204e9800998ecf8427e",
+	}
+	resource := dataResourceFromFile(file)
+	assert.Equal("d41d8cd98f00b204e9800998ecf8427e", resource.Hash)
+	assert.Equal(map[string]string{"md5": "d41d8cd98f00b204e9800998ecf8427e"}, resource.Hashes)
+}
+
+func TestDataResourceInstrument(t *testing.T) {
+	assert := assert.New(t)
+	file := File{
+		Id:   "52fd2f593b6d0e2e0ab5d2b4",
+		Name: "3300000123.a.fastq.gz",
+		Path: "/global/dna/dm_archive/rqc/123",
+	}
+	file.Metadata.SowSegment.Platform = "Illumina"
+	file.Metadata.SowSegment.SequencerModel = "NovaSeq S4"
+
+	// no instrument metadata unless configured
+	resource := dataResourceFromFile(file)
+	assert.Nil(resource.Instrument)
+
+	config.Service.InstrumentMetadata = true
+	defer func() { config.Service.InstrumentMetadata = false }()
+	resource = dataResourceFromFile(file)
+	assert.Equal(&frictionless.DataInstrument{
+		Name:     "NovaSeq S4",
+		Platform: "Illumina",
+		Model:    "NovaSeq S4",
+	}, resource.Instrument)
+
+	// no instrument metadata if the file doesn't have any
+	resource = dataResourceFromFile(File{Id: "52fd2f593b6d0e2e0ab5d2b5"})
+	assert.Nil(resource.Instrument)
+}
+
+func TestDataResourceEmbargo(t *testing.T) {
+	assert := assert.New(t)
+	file := File{
+		Id:   "52fd2f593b6d0e2e0ab5d2b4",
+		Name: "3300000123.a.fastq.gz",
+		Path: "/global/dna/dm_archive/rqc/123",
+	}
+	file.Metadata.Proposal.EmbargoEndDate = "2031-06-30T00:00:00"
+	file.Metadata.Proposal.DataUsePolicy = "JGI data utilization policy"
+	resource := dataResourceFromFile(file)
+	assert.Equal("2031-06-30", resource.EmbargoUntil)
+	assert.Equal("JGI data utilization policy", resource.DataUse)
+
+	// uninterpretable embargo dates are dropped
+	file.Metadata.Proposal.EmbargoEndDate = "someday"
+	resource = dataResourceFromFile(file)
+	assert.Equal("", resource.EmbargoUntil)
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	setup()
@@ -86,6 +86,10 @@ type Metadata struct {
 		} `json:"pi"`
 		// date of proposal approval
 		DateApproved string `json:"date_approved"`
+		// date until which the proposal's data is under embargo (if any)
+		EmbargoEndDate string `json:"embargo_end_date"`
+		// data-use policy governing the proposal's data (if any)
+		DataUsePolicy string `json:"data_use_policy"`
 		// proposal DOI
 		DOI string `json:"doi"`
 	} `json:"proposal"`
@@ -109,6 +113,13 @@ type Metadata struct {
 	// sequencing project ID, sometimes used as ITS project ID. This type can be a
 	// list or a number, so we have to unmarshal it into a RawMessage
 	SequencingProjectId json.RawMessage `json:"sequencing_project_id"`
+	// statement-of-work segment metadata, which records the sequencing platform
+	SowSegment struct {
+		// sequencing platform (e.g. "Illumina")
+		Platform string `json:"platform"`
+		// sequencer model (e.g. "NovaSeq S4")
+		SequencerModel string `json:"sequencer_model"`
+	} `json:"sow_segment"`
 	// NCBI taxon metadata
 	NCBITaxon struct {
 		Order   string `json:"ncbi_taxon_order"`
@@ -11,11 +11,14 @@ package jdp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/kbase/dts/databases"
 )
 
 // this type represents a request to JAMO's pagequery endpoint
@@ -83,8 +86,9 @@ var jamoClient = &http.Client{
 
 // This function gathers and returns all jamo file records that correspond to
 // the given list of file IDs. The list of files is returned in the same order
-// as the list of file IDs.
-func queryJamo(fileIds []string) ([]jamoFileRecord, error) {
+// as the list of file IDs. Requests identify the service (and carry the
+// database's correlation ID) like those sent to the JDP itself.
+func (db *Database) queryJamo(fileIds []string) ([]jamoFileRecord, error) {
 	const jamoBaseUrl = "https://jamo-dev.jgi.doe.gov/"
 
 	// prepare a JAMO query with the desired file IDs
@@ -112,12 +116,14 @@ func queryJamo(fileIds []string) ([]jamoFileRecord, error) {
 	// do the initial POST to JAMO and fetch results
 	const jamoApiUrl = jamoBaseUrl + "api/metadata/"
 	const jamoPageQueryURL = jamoApiUrl + "pagequery"
-	req, err := http.NewRequest(http.MethodPost, jamoPageQueryURL, bytes.NewReader(payload))
+	ctx := databases.WithCorrelationId(context.Background(), db.CorrelationId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, jamoPageQueryURL,
+		bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Content-type", "application/json; charset=utf-8")
-	resp, err := jamoClient.Do(req)
+	resp, err := databases.DoWithTimeout(jamoClient, db.Id, req, jamoClient.Timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -151,11 +157,11 @@ func queryJamo(fileIds []string) ([]jamoFileRecord, error) {
 		// go back for more records
 		if results.End < results.RecordCount {
 			jamoNextPageUrl := fmt.Sprintf("%snextpage/%s", jamoApiUrl, results.CursorId)
-			req, err = http.NewRequest(http.MethodGet, jamoNextPageUrl, http.NoBody)
+			req, err = http.NewRequestWithContext(ctx, http.MethodGet, jamoNextPageUrl, http.NoBody)
 			if err != nil {
 				break
 			}
-			resp, err = jamoClient.Do(req)
+			resp, err = databases.DoWithTimeout(jamoClient, db.Id, req, jamoClient.Timeout)
 			if err != nil {
 				break
 			}
@@ -22,7 +22,14 @@
 package kbase
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -32,10 +39,20 @@ import (
 )
 
 // file database appropriate for handling KBase searches and transfers
-// (implements the databases.Database interface)
+// (implements the databases.Database and databases.FinalizableDatabase
+// interfaces)
 type Database struct {
 	// database identifier
 	Id string
+	// HTTP client used to notify the KBase staging service of new files
+	Client http.Client
+	// URL at which the KBase staging service accepts notifications of
+	// transferred files (finalization is skipped if empty)
+	StagingServiceUrl string
+	// token used to authenticate with the KBase staging service (if any)
+	StagingServiceToken string
+	// correlation ID sent with the database's requests (if any)
+	CorrelationId string
 }
 
 func NewDatabase(orcid string) (databases.Database, error) {
@@ -44,7 +61,10 @@ func NewDatabase(orcid string) (databases.Database, error) {
 	}
 
 	return &Database{
-		Id: "kbase",
+		Id:                  "kbase",
+		Client:              databases.DatabaseHttpClient("kbase", databases.HttpClientOptions{}),
+		StagingServiceUrl:   os.Getenv("DTS_KBASE_STAGING_URL"),
+		StagingServiceToken: os.Getenv("DTS_KBASE_STAGING_TOKEN"),
 	}, nil
 }
 
@@ -72,12 +92,51 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 	return databases.StagingStatusUnknown, err
 }
 
+func (db *Database) CancelStaging(id uuid.UUID) error {
+	err := fmt.Errorf("CancelStaging not implemented for kbase database!")
+	return err
+}
+
 func (db *Database) LocalUser(orcid string) (string, error) {
 	// for KBase user federation, we rely on a table maintained by our KBase
 	// auth server proxy
 	return auth.KBaseLocalUsernameForOrcid(orcid)
 }
 
+// returns a view of the database whose requests carry the given correlation
+// ID (implements the databases.CorrelatedDatabase interface)
+func (db *Database) WithCorrelationId(id string) databases.Database {
+	view := *db
+	view.CorrelationId = id
+	return &view
+}
+
+// notifies the KBase staging service that files have arrived in the given
+// folder (whose first component is the user's KBase username), retrying
+// failed notifications a few times before giving up
+func (db *Database) Finalize(folder, manifest string) error {
+	if db.StagingServiceUrl == "" { // no staging service to notify
+		return nil
+	}
+	body, err := json.Marshal(stagingNotification{
+		DestinationFolder: folder,
+		Manifest:          manifest,
+	})
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err = db.notifyStagingService(body)
+		if err == nil || attempt == maxFinalizeAttempts {
+			break
+		}
+		slog.Warn(fmt.Sprintf("Notifying KBase staging service of %s (attempt %d of %d): %s",
+			folder, attempt, maxFinalizeAttempts, err.Error()))
+		time.Sleep(finalizeRetryInterval)
+	}
+	return err
+}
+
 func (db Database) Save() (databases.DatabaseSaveState, error) {
 	// so far, this database has no internal state
 	return databases.DatabaseSaveState{
@@ -89,3 +148,44 @@ func (db *Database) Load(state databases.DatabaseSaveState) error {
 	// no internal state -> nothing to do
 	return nil
 }
+
+//-----------
+// Internals
+//-----------
+
+// the number of times we try to notify the staging service of new files
+const maxFinalizeAttempts = 3
+
+// the time we wait between attempts to notify the staging service
+var finalizeRetryInterval = time.Second
+
+// the body of a request notifying the staging service of new files
+type stagingNotification struct {
+	// folder (relative to the user's staging area root) holding the files
+	DestinationFolder string `json:"destination_folder"`
+	// path of the transfer's manifest (relative to the same root)
+	Manifest string `json:"manifest"`
+}
+
+// sends the given notification body to the staging service, returning an
+// error if it isn't accepted
+func (db *Database) notifyStagingService(body []byte) error {
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodPost, db.StagingServiceUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if db.StagingServiceToken != "" {
+		req.Header.Set("Authorization", db.StagingServiceToken)
+	}
+	resp, err := databases.DoWithTimeout(&db.Client, db.Id, req, db.Client.Timeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("KBase staging service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
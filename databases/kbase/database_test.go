@@ -0,0 +1,118 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package kbase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// returns a mock KBase staging service that fails the given number of
+// notifications before accepting one, recording the number of notifications
+// it receives and the last accepted one
+func mockStagingService(t *testing.T, numFailures int32,
+	numReceived *atomic.Int32, received *stagingNotification) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "staging-token", r.Header.Get("Authorization"))
+		if numReceived.Add(1) <= numFailures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		err := json.NewDecoder(r.Body).Decode(received)
+		assert.Nil(t, err)
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// checks that the staging service is notified of transferred files
+func TestFinalize(t *testing.T) {
+	assert := assert.New(t)
+
+	var numReceived atomic.Int32
+	var received stagingNotification
+	server := mockStagingService(t, 0, &numReceived, &received)
+	defer server.Close()
+
+	db := &Database{
+		Id:                  "kbase",
+		StagingServiceUrl:   server.URL,
+		StagingServiceToken: "staging-token",
+	}
+	err := db.Finalize("joe-bob/dts-1234", "joe-bob/dts-1234/manifest.json")
+	assert.Nil(err)
+	assert.Equal(int32(1), numReceived.Load())
+	assert.Equal(stagingNotification{
+		DestinationFolder: "joe-bob/dts-1234",
+		Manifest:          "joe-bob/dts-1234/manifest.json",
+	}, received)
+}
+
+// checks that failed notifications are retried, and that an error is returned
+// if none succeeds
+func TestFinalizeRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	defaultInterval := finalizeRetryInterval
+	finalizeRetryInterval = time.Millisecond
+	defer func() { finalizeRetryInterval = defaultInterval }()
+
+	var numReceived atomic.Int32
+	var received stagingNotification
+	server := mockStagingService(t, maxFinalizeAttempts-1, &numReceived, &received)
+	defer server.Close()
+
+	db := &Database{
+		Id:                  "kbase",
+		StagingServiceUrl:   server.URL,
+		StagingServiceToken: "staging-token",
+	}
+	err := db.Finalize("joe-bob/dts-1234", "joe-bob/dts-1234/manifest.json")
+	assert.Nil(err)
+	assert.Equal(int32(maxFinalizeAttempts), numReceived.Load())
+	assert.Equal("joe-bob/dts-1234", received.DestinationFolder)
+
+	numReceived.Store(0)
+	server = mockStagingService(t, maxFinalizeAttempts, &numReceived, &received)
+	defer server.Close()
+	db.StagingServiceUrl = server.URL
+	err = db.Finalize("joe-bob/dts-5678", "joe-bob/dts-5678/manifest.json")
+	assert.NotNil(err)
+	assert.Equal(int32(maxFinalizeAttempts), numReceived.Load())
+}
+
+// checks that finalization does nothing without a staging service
+func TestFinalizeWithoutStagingService(t *testing.T) {
+	t.Setenv("DTS_KBASE_STAGING_URL", "")
+	db, err := NewDatabase("1234-5678-9012-3456")
+	assert.Nil(t, err)
+	assert.Equal(t, "", db.(*Database).StagingServiceUrl)
+	err = db.(*Database).Finalize("joe-bob/dts-1234", "joe-bob/dts-1234/manifest.json")
+	assert.Nil(t, err)
+}
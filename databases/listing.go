@@ -0,0 +1,148 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// This type represents an endpoint that can describe the files in a directory
+// (relative to its root) and its subdirectories, identifying each file by its
+// path relative to the root.
+type DirectoryLister interface {
+	ListDirectory(dir string) ([]frictionless.DataResource, error)
+}
+
+// This type implements the parts of a database whose files are those in the
+// root directory of an endpoint (and its subdirectories), described by listing
+// that directory, so they needn't be listed in advance. These files are
+// already in place, so staging them does nothing. A database type embeds a
+// ListingDatabase and supplies LocalUser to implement the Database interface
+// (and the PrefixDatabase interface, since file IDs are paths).
+type ListingDatabase struct {
+	// database identifier (its name in the DTS configuration)
+	Id string
+	// endpoint whose files are listed
+	Lister DirectoryLister
+}
+
+func (db *ListingDatabase) SpecificSearchParameters() map[string]interface{} {
+	return nil
+}
+
+// returns the files whose paths contain the query string (all files if the
+// query is empty), paginated as requested
+func (db *ListingDatabase) Search(params SearchParameters) (SearchResults, error) {
+	listing, err := db.Lister.ListDirectory("")
+	if err != nil {
+		return SearchResults{}, err
+	}
+	resources := make([]frictionless.DataResource, 0)
+	for _, resource := range listing {
+		if strings.Contains(resource.Path, params.Query) {
+			resources = append(resources, resource)
+		}
+	}
+
+	SortResourcesById(resources) // (IDs are paths)
+	total := len(resources)
+	offset := min(params.Pagination.Offset, total)
+	resources = resources[offset:]
+	if params.Pagination.MaxNum > 0 && params.Pagination.MaxNum < len(resources) {
+		resources = resources[:params.Pagination.MaxNum]
+	}
+	return SearchResults{
+		Resources: resources,
+		Total:     &total,
+	}, nil
+}
+
+// returns the IDs of the files whose paths begin with the given prefix, in
+// sorted order
+func (db *ListingDatabase) FileIdsWithPrefix(prefix string) ([]string, error) {
+	listing, err := db.Lister.ListDirectory("")
+	if err != nil {
+		return nil, err
+	}
+	SortResourcesById(listing)
+	fileIds := make([]string, 0)
+	for _, resource := range listing {
+		if strings.HasPrefix(resource.Id, prefix) {
+			fileIds = append(fileIds, resource.Id)
+		}
+	}
+	return fileIds, nil
+}
+
+func (db *ListingDatabase) Resources(fileIds []string) ([]frictionless.DataResource, error) {
+	listing, err := db.Lister.ListDirectory("")
+	if err != nil {
+		return nil, err
+	}
+	resourcesById := make(map[string]frictionless.DataResource)
+	for _, resource := range listing {
+		resourcesById[resource.Id] = resource
+	}
+	resources := make([]frictionless.DataResource, len(fileIds))
+	for i, fileId := range fileIds {
+		resource, found := resourcesById[fileId]
+		if !found {
+			return nil, ResourceNotFoundError{
+				Database:   db.Id,
+				ResourceId: fileId,
+			}
+		}
+		resources[i] = resource
+	}
+	return resources, nil
+}
+
+func (db *ListingDatabase) StageFiles(fileIds []string) (uuid.UUID, error) {
+	// listed files are already in place, so we simply generate a UUID that can
+	// be handed to db.StagingStatus
+	return uuid.New(), nil
+}
+
+func (db *ListingDatabase) StagingStatus(id uuid.UUID) (StagingStatus, error) {
+	return StagingStatusSucceeded, nil
+}
+
+func (db *ListingDatabase) CancelStaging(id uuid.UUID) error {
+	// nothing to cancel
+	return nil
+}
+
+func (db ListingDatabase) Save() (DatabaseSaveState, error) {
+	// this database has no internal state
+	return DatabaseSaveState{
+		Name: db.Id,
+	}, nil
+}
+
+func (db *ListingDatabase) Load(state DatabaseSaveState) error {
+	// no internal state -> nothing to do
+	return nil
+}
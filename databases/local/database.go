@@ -0,0 +1,86 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package local
+
+import (
+	"fmt"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+)
+
+// This database presents the files in the root directory of a local endpoint
+// (and its subdirectories) as a source of transferable files, and accepts
+// files transferred to that endpoint. It's useful for on-premises transfers
+// and testing.
+// (implements the databases.Database interface)
+type Database struct {
+	databases.ListingDatabase
+	// local endpoint whose files are listed
+	Endpoint *local.Endpoint
+}
+
+// returns a function that creates a local database with the given name,
+// suitable for registration with databases.RegisterDatabase
+func DatabaseConstructor(dbName string) func(orcid string) (databases.Database, error) {
+	return func(orcid string) (databases.Database, error) {
+		return NewDatabase(dbName, orcid)
+	}
+}
+
+// creates a database with the given name that lists files on its configured
+// local endpoint
+func NewDatabase(dbName, orcid string) (databases.Database, error) {
+	if orcid == "" {
+		return nil, fmt.Errorf("No ORCID was given")
+	}
+	dbConfig, found := config.Databases[dbName]
+	if !found {
+		return nil, databases.NotFoundError{Database: dbName}
+	}
+	endpoint, err := endpoints.NewEndpoint(dbConfig.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	localEndpoint, ok := endpoint.(*local.Endpoint)
+	if !ok {
+		return nil, databases.InvalidEndpointsError{
+			Database: dbName,
+			Message:  fmt.Sprintf("'%s' is not a local endpoint", dbConfig.Endpoint),
+		}
+	}
+	return &Database{
+		ListingDatabase: databases.ListingDatabase{
+			Id:     dbName,
+			Lister: localEndpoint,
+		},
+		Endpoint: localEndpoint,
+	}, nil
+}
+
+func (db *Database) LocalUser(orcid string) (string, error) {
+	// files transferred to a local database are organized by ORCID, since
+	// there's no account to map it to
+	return orcid, nil
+}
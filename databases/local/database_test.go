@@ -0,0 +1,181 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package local
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+)
+
+const localConfig string = `
+endpoints:
+  files:
+    name: Local Test Files
+    id: 0b6a6a34-8d1e-4d53-9a3e-64f1c1a0d0a1
+    provider: local
+    root: FILES_ROOT
+databases:
+  files:
+    name: Files in a Local Directory
+    organization: Local Testers, Inc.
+    endpoint: files
+    provider: local
+`
+
+// contents of the files in our test directory tree, keyed by path
+var fileContents = map[string]string{
+	"README.md":              "# Test files\n",
+	"reads/sample1.fastq.gz": "sample 1 reads",
+	"reads/sample2.fastq.gz": "more reads, from sample 2",
+}
+
+// root of our test directory tree
+var filesRoot string
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	var err error
+	filesRoot, err = os.MkdirTemp(os.TempDir(), "dts-local-database")
+	if err != nil {
+		panic(err)
+	}
+	for path, content := range fileContents {
+		err = os.MkdirAll(filepath.Join(filesRoot, filepath.Dir(path)), 0700)
+		if err != nil {
+			panic(err)
+		}
+		err = os.WriteFile(filepath.Join(filesRoot, path), []byte(content), 0600)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = config.Init([]byte(strings.ReplaceAll(localConfig, "FILES_ROOT", filesRoot)))
+	if err != nil {
+		panic(err)
+	}
+	endpoints.RegisterEndpointProvider("local", local.NewEndpoint)
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	os.RemoveAll(filesRoot)
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := NewDatabase("files", "1234-5678-9101-1121")
+	assert.NotNil(db)
+	assert.Nil(err)
+	_, isPrefixDb := db.(databases.PrefixDatabase)
+	assert.True(isPrefixDb)
+
+	db, err = NewDatabase("files", "")
+	assert.Nil(db)
+	assert.NotNil(err)
+
+	db, err = NewDatabase("nonexistent", "1234-5678-9101-1121")
+	assert.Nil(db)
+	assert.NotNil(err)
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("files", "1234-5678-9101-1121")
+
+	// an empty query lists all files
+	results, err := db.Search(databases.SearchParameters{})
+	assert.Nil(err)
+	assert.Equal(3, len(results.Resources))
+	paths := make([]string, len(results.Resources))
+	for i, resource := range results.Resources {
+		paths[i] = resource.Path
+		assert.Equal(resource.Path, resource.Id)
+		assert.Equal(len(fileContents[resource.Path]), resource.Bytes)
+		checksum := md5.Sum([]byte(fileContents[resource.Path]))
+		assert.Equal(hex.EncodeToString(checksum[:]), resource.Hash)
+	}
+	assert.Equal([]string{"README.md", "reads/sample1.fastq.gz", "reads/sample2.fastq.gz"}, paths)
+	assert.Equal("sample1.fastq", results.Resources[1].Name)
+	assert.Equal("gz", results.Resources[1].Format)
+	assert.Equal(mime.TypeByExtension(".gz"), results.Resources[1].MediaType)
+}
+
+// checks that the formats of files without recognized extensions are detected
+// from their content
+func TestDetectedFormats(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("files", "1234-5678-9101-1121")
+
+	unlabeled := map[string]string{
+		"unlabeled/contigs":   ">contig1\nACGTTGCA\n",
+		"unlabeled/reads.dat": "@read1\nACGT\n+\nIIII\n",
+		"unlabeled/notes":     "nothing to see here\n",
+	}
+	err := os.MkdirAll(filepath.Join(filesRoot, "unlabeled"), 0700)
+	assert.Nil(err)
+	defer os.RemoveAll(filepath.Join(filesRoot, "unlabeled"))
+	for path, content := range unlabeled {
+		err = os.WriteFile(filepath.Join(filesRoot, path), []byte(content), 0600)
+		assert.Nil(err)
+	}
+
+	resources, err := db.Resources([]string{"unlabeled/contigs", "unlabeled/reads.dat",
+		"unlabeled/notes", "README.md"})
+	assert.Nil(err)
+	assert.Equal("fasta", resources[0].Format)
+	assert.Equal("text/plain", resources[0].MediaType)
+	assert.Equal("fastq", resources[1].Format)
+	assert.Equal("reads", resources[1].Name)
+	assert.Equal("", resources[2].Format)   // not recognized
+	assert.Equal("md", resources[3].Format) // recognized extension
+}
+
+func TestLocalUser(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("files", "1234-5678-9101-1121")
+
+	username, err := db.LocalUser("1234-5678-9101-1121")
+	assert.Nil(err)
+	assert.Equal("1234-5678-9101-1121", username)
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
@@ -23,7 +23,9 @@ package nmdc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -33,6 +35,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -57,6 +60,8 @@ type Database struct {
 	Auth authorization
 	// mapping of host URLs to endpoints
 	EndpointForHost map[string]string
+	// correlation ID sent with the database's requests (if any)
+	CorrelationId string
 }
 
 func NewDatabase(orcid string) (databases.Database, error) {
@@ -106,7 +111,7 @@ func NewDatabase(orcid string) (databases.Database, error) {
 
 	// NOTE: we prevent redirects from HTTPS -> HTTP!
 	db := &Database{
-		Client: databases.SecureHttpClient(),
+		Client: databases.DatabaseHttpClient("nmdc", databases.SecureHttpClientOptions()),
 		EndpointForHost: map[string]string{
 			"https://data.microbiomedata.org/data/": nerscEndpoint,
 			"https://nmdcdemo.emsl.pnnl.gov/":       emslEndpoint,
@@ -166,11 +171,23 @@ func (db *Database) Search(params databases.SearchParameters) (databases.SearchR
 		p.Add("filter", params.Query)
 	}
 
+	// unless a sort is requested, we order results by ID so identical searches
+	// produce identical results
+	sortRequested := p.Has("sort")
+
 	if p.Has("study_id") { // fetch data objects associated with this study
-		return db.dataObjectsForStudy(p.Get("study_id"), p)
+		results, err := db.dataObjectsForStudy(p.Get("study_id"), p)
+		if err == nil && !sortRequested {
+			databases.SortResourcesById(results.Resources)
+		}
+		return results, err
 	}
 
-	// otherwise, simply call the data_objects/ endpoint (possibly with a filter applied)
+	// otherwise, simply call the data_objects/ endpoint (possibly with a filter
+	// applied), which sorts its results before paginating them
+	if !sortRequested {
+		p.Add("sort", "id")
+	}
 	return db.dataObjects(p)
 }
 
@@ -182,21 +199,23 @@ func (db Database) Resources(fileIds []string) ([]frictionless.DataResource, err
 	// we use the /data_objects/{data_object_id} GET endpoint to retrieve metadata
 	// for individual files
 
-	// gather relevant study IDs and use them to build credit metadata
-	studyIdForDataObjectId, err := db.studyIdsForDataObjectIds(fileIds)
+	// gather relevant study IDs and use them to build credit metadata (files
+	// requested by ID are credited to the first of several studies, since
+	// they're needed for a transfer)
+	studyIdForDataObjectId, ambiguities, err := db.studyIdsForDataObjectIds(fileIds)
 	if err != nil {
 		return nil, err
 	}
-	creditForStudyId := make(map[string]credit.CreditMetadata)
+	for _, ambiguity := range ambiguities {
+		slog.Warn(ambiguity.Error())
+	}
+	studyIds := make([]string, 0, len(studyIdForDataObjectId))
 	for _, studyId := range studyIdForDataObjectId {
-		credit, foundStudyCredit := creditForStudyId[studyId]
-		if !foundStudyCredit {
-			credit, err = db.creditMetadataForStudy(studyId)
-			if err != nil {
-				return nil, err
-			}
-			creditForStudyId[studyId] = credit // cache for other data objects
-		}
+		studyIds = append(studyIds, studyId)
+	}
+	creditForStudyId, err := db.creditMetadataForStudies(studyIds)
+	if err != nil {
+		return nil, err
 	}
 
 	// construct data resources from the IDs
@@ -222,7 +241,8 @@ func (db Database) Resources(fileIds []string) ([]frictionless.DataResource, err
 		resources[i].Credit.ResourceType = "dataset"
 		resources[i].Credit.Identifier = resources[i].Id
 	}
-	return resources, nil
+	err = db.addInstrumentMetadata(resources)
+	return resources, err
 }
 
 func (db Database) StageFiles(fileIds []string) (uuid.UUID, error) {
@@ -239,11 +259,23 @@ func (db Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 	return databases.StagingStatusSucceeded, nil
 }
 
+func (db Database) CancelStaging(id uuid.UUID) error {
+	// nothing to cancel
+	return nil
+}
+
 func (db Database) LocalUser(orcid string) (string, error) {
 	// no current mechanism for this
 	return "localuser", nil
 }
 
+// returns a view of the database whose requests carry the given correlation
+// ID (implements the databases.CorrelatedDatabase interface)
+func (db Database) WithCorrelationId(id string) databases.Database {
+	db.CorrelationId = id
+	return &db
+}
+
 func (db Database) Save() (databases.DatabaseSaveState, error) {
 	// so far, this database has no internal state
 	return databases.DatabaseSaveState{
@@ -270,6 +302,18 @@ const (
 	baseDataURL = "https://data-dev.microbiomedata.org/data/" // postgres (use in future)
 )
 
+// limits on the requests for biosamples issued when searching for a study's
+// data objects: the number of requests in flight at once, and the time allowed
+// for all of them to complete
+var (
+	maxBiosampleRequests    = 8
+	biosampleRequestTimeout = 30 * time.Second
+)
+
+// the number of requests for the study metadata used to credit data objects
+// that may be in flight at once, unless configured otherwise
+const defaultEnrichmentConcurrency = 8
+
 // Authorization / authentication
 
 type authorization struct {
@@ -299,11 +343,12 @@ func (db *Database) getAccessToken(credential credential) (authorization, error)
 	data.Set("grant_type", "password")
 	data.Set("username", credential.User)
 	data.Set("password", credential.Password)
-	request, err := http.NewRequest(http.MethodPost, resource, strings.NewReader(data.Encode()))
+	request, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodPost, resource, strings.NewReader(data.Encode()))
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.Header.Set("Accept", "application/json")
 
-	response, err := db.Client.Do(request)
+	response, err := databases.DoWithTimeout(&db.Client, "nmdc", request, db.Client.Timeout)
 	if err != nil {
 		return auth, err
 	}
@@ -381,6 +426,11 @@ func (db Database) addAuthHeader(request *http.Request) {
 // performs a GET request on the given resource, returning the resulting
 // response body and/or error
 func (db Database) get(resource string, values url.Values) ([]byte, error) {
+	return db.getWithContext(context.Background(), resource, values)
+}
+
+// like get, but abandons the request when the given context is done
+func (db Database) getWithContext(ctx context.Context, resource string, values url.Values) ([]byte, error) {
 	res, err := url.Parse(baseApiURL)
 	if err != nil {
 		return nil, err
@@ -388,12 +438,13 @@ func (db Database) get(resource string, values url.Values) ([]byte, error) {
 	res.Path += resource
 	res.RawQuery = values.Encode()
 	slog.Debug(fmt.Sprintf("GET: %s", res.String()))
-	req, err := http.NewRequest(http.MethodGet, res.String(), http.NoBody)
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(ctx, db.CorrelationId),
+		http.MethodGet, res.String(), http.NoBody)
 	if err != nil {
 		return nil, err
 	}
 	db.addAuthHeader(req)
-	resp, err := db.Client.Do(req)
+	resp, err := databases.DoWithTimeout(&db.Client, "nmdc", req, db.Client.Timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -420,14 +471,15 @@ func (db Database) post(resource string, body io.Reader) ([]byte, error) {
 	}
 	res.Path += resource
 	slog.Debug(fmt.Sprintf("POST: %s", res.String()))
-	req, err := http.NewRequest(http.MethodPost, res.String(), body)
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodPost, res.String(), body)
 	if err != nil {
 		return nil, err
 	}
 	db.addAuthHeader(req)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := db.Client.Do(req)
+	resp, err := databases.DoWithTimeout(&db.Client, "nmdc", req, db.Client.Timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -483,6 +535,7 @@ func (db Database) dataResourceFromDataObject(dataObject DataObject) (frictionle
 		Description: dataObject.Description,
 		Format:      formatFromType(dataObject.Type),
 		Hash:        dataObject.MD5Checksum,
+		Hashes:      frictionless.HashesForMD5(dataObject.MD5Checksum),
 		Id:          dataObject.Id,
 		MediaType:   mimeTypeFromFormat(formatFromType(dataObject.Type)),
 		Name:        dataResourceName(dataObject.Name),
@@ -500,7 +553,39 @@ func (db Database) dataResourceFromDataObject(dataObject DataObject) (frictionle
 	return resource, nil
 }
 
-func (db Database) studyIdsForDataObjectIds(dataObjectIds []string) (map[string]string, error) {
+// types for aggregation queries run with the queries:run endpoint
+type MatchIdInSlice struct {
+	In []string `json:"$in,omitempty"`
+}
+type MatchOperation struct {
+	// matches an ID with one of those in the given list
+	Id MatchIdInSlice `json:"id"`
+}
+type LookupOperation struct {
+	From         string `json:"from"`
+	LocalField   string `json:"localField"`
+	ForeignField string `json:"foreignField"`
+	As           string `json:"as"`
+}
+type PipelineOperation struct {
+	// this is a bit cheesy but is simple and works
+	// we use struct pointers here so omitempty works properly
+	Match  *MatchOperation  `json:"$match,omitempty"`
+	Lookup *LookupOperation `json:"$lookup,omitempty"`
+}
+type CursorProperty struct {
+	BatchSize int `json:"batchsize,omitempty"`
+}
+type AggregateRequest struct {
+	Aggregate string              `json:"aggregate"`
+	Pipeline  []PipelineOperation `json:"pipeline"`
+	Cursor    CursorProperty      `json:"cursor,omitempty"`
+}
+
+// maps the given data object IDs to the IDs of the studies for which they were
+// generated, also returning errors for data objects associated with more than
+// one study (which are mapped to the first of them)
+func (db Database) studyIdsForDataObjectIds(dataObjectIds []string) (map[string]string, []AmbiguousWorkflowStudyError, error) {
 	// We create an aggregation query on the data_generation_set collection.
 	// The data_generation_set collection associates studies with data objects:
 	// * the associated_studies field points to a study_set collection
@@ -518,33 +603,6 @@ func (db Database) studyIdsForDataObjectIds(dataObjectIds []string) (map[string]
 	// NOTE:
 	// NOTE: If we need to, we can break up our aggregate queries into smaller
 	// NOTE: chunks, since these queries are independent.
-	type MatchIdInSlice struct {
-		In []string `json:"$in,omitempty"`
-	}
-	type MatchOperation struct {
-		// matches an ID with one of those in the given list
-		Id MatchIdInSlice `json:"id"`
-	}
-	type LookupOperation struct {
-		From         string `json:"from"`
-		LocalField   string `json:"localField"`
-		ForeignField string `json:"foreignField"`
-		As           string `json:"as"`
-	}
-	type PipelineOperation struct {
-		// this is a bit cheesy but is simple and works
-		// we use struct pointers here so omitempty works properly
-		Match  *MatchOperation  `json:"$match,omitempty"`
-		Lookup *LookupOperation `json:"$lookup,omitempty"`
-	}
-	type CursorProperty struct {
-		BatchSize int `json:"batchsize,omitempty"`
-	}
-	type AggregateRequest struct {
-		Aggregate string              `json:"aggregate"`
-		Pipeline  []PipelineOperation `json:"pipeline"`
-		Cursor    CursorProperty      `json:"cursor,omitempty"`
-	}
 	data, err := json.Marshal(AggregateRequest{
 		Aggregate: "data_object_set",
 		Pipeline: []PipelineOperation{
@@ -570,14 +628,14 @@ func (db Database) studyIdsForDataObjectIds(dataObjectIds []string) (map[string]
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// run the query and extract the results
 	// NOTE: recall that trailing slashes in POSTs currently cause chaos!
 	body, err := db.post("queries:run", bytes.NewReader(data))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	type DataGenerationSet struct {
 		Id                string   `json:"id"`
@@ -598,16 +656,30 @@ func (db Database) studyIdsForDataObjectIds(dataObjectIds []string) (map[string]
 	var results QueryResults
 	err = json.Unmarshal(body, &results)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// map each data object ID to the corresponding study ID
 	studyIdForDataObjectId := make(map[string]string)
+	var ambiguities []AmbiguousWorkflowStudyError
 	for _, record := range results.Cursor.FirstBatch {
-		// FIXME: for now, take the first study in the first data generation set
 		if len(record.DataGenerationSets) > 0 {
-			if len(record.DataGenerationSets[0].AssociatedStudies) > 0 {
-				studyIdForDataObjectId[record.DataObjectId] = record.DataGenerationSets[0].AssociatedStudies[0]
+			var studyIds []string
+			for _, dataGenerationSet := range record.DataGenerationSets {
+				for _, studyId := range dataGenerationSet.AssociatedStudies {
+					if !slices.Contains(studyIds, studyId) {
+						studyIds = append(studyIds, studyId)
+					}
+				}
+			}
+			if len(studyIds) > 0 {
+				studyIdForDataObjectId[record.DataObjectId] = studyIds[0]
+				if len(studyIds) > 1 {
+					ambiguities = append(ambiguities, AmbiguousWorkflowStudyError{
+						DataObjectId: record.DataObjectId,
+						StudyIds:     studyIds,
+					})
+				}
 			} else {
 				slog.Debug(fmt.Sprintf("No study is associated with the data object %s", record.DataObjectId))
 			}
@@ -615,7 +687,107 @@ func (db Database) studyIdsForDataObjectIds(dataObjectIds []string) (map[string]
 			slog.Debug(fmt.Sprintf("No data generation info was found for the data object %s", record.DataObjectId))
 		}
 	}
-	return studyIdForDataObjectId, err
+	return studyIdForDataObjectId, ambiguities, err
+}
+
+// if instrument metadata is enabled, fills in the instruments that generated
+// the data for the given resources (where NMDC records them)
+func (db Database) addInstrumentMetadata(resources []frictionless.DataResource) error {
+	if !config.Service.InstrumentMetadata || len(resources) == 0 {
+		return nil
+	}
+	dataObjectIds := make([]string, len(resources))
+	for i, resource := range resources {
+		dataObjectIds[i] = resource.Id
+	}
+	instrumentForDataObjectId, err := db.instrumentsForDataObjectIds(dataObjectIds)
+	if err != nil {
+		return err
+	}
+	for i, resource := range resources {
+		resources[i].Instrument = instrumentForDataObjectId[resource.Id]
+	}
+	return nil
+}
+
+// returns a map of the given data object IDs to the instruments that generated
+// their data, omitting data objects without recorded instruments
+func (db Database) instrumentsForDataObjectIds(dataObjectIds []string) (map[string]*frictionless.DataInstrument, error) {
+	// we follow each data object to the data generation that produced it (as in
+	// studyIdsForDataObjectIds), and from there to its instrument in the
+	// instrument_set collection
+	data, err := json.Marshal(AggregateRequest{
+		Aggregate: "data_object_set",
+		Pipeline: []PipelineOperation{
+			{
+				Match: &MatchOperation{
+					Id: MatchIdInSlice{
+						In: dataObjectIds,
+					},
+				},
+			},
+			{
+				Lookup: &LookupOperation{
+					From:         "data_generation_set",
+					LocalField:   "was_generated_by",
+					ForeignField: "id",
+					As:           "data_generation_sets",
+				},
+			},
+			{
+				Lookup: &LookupOperation{
+					From:         "instrument_set",
+					LocalField:   "data_generation_sets.has_instrument",
+					ForeignField: "id",
+					As:           "instruments",
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, err := db.post("queries:run", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// https://microbiomedata.github.io/nmdc-schema/Instrument/
+	type Instrument struct {
+		Id     string `json:"id"`
+		Name   string `json:"name,omitempty"`
+		Vendor string `json:"vendor,omitempty"`
+		Model  string `json:"model,omitempty"`
+	}
+	type DataObjectAndInstruments struct {
+		DataObjectId string       `json:"id"`
+		Instruments  []Instrument `json:"instruments"`
+	}
+	type QueryResults struct {
+		Ok     int `json:"ok"`
+		Cursor struct {
+			FirstBatch []DataObjectAndInstruments `json:"firstBatch"`
+		}
+	}
+	var results QueryResults
+	err = json.Unmarshal(body, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	instrumentForDataObjectId := make(map[string]*frictionless.DataInstrument)
+	for _, record := range results.Cursor.FirstBatch {
+		// FIXME: as with studies, we take the first instrument we find
+		if len(record.Instruments) > 0 {
+			instrument := record.Instruments[0]
+			instrumentForDataObjectId[record.DataObjectId] = &frictionless.DataInstrument{
+				Name:     instrument.Name,
+				Platform: instrument.Vendor,
+				Model:    instrument.Model,
+			}
+		}
+	}
+	return instrumentForDataObjectId, nil
 }
 
 // fetches metadata for data objects (no credit metadata, alas) based on the
@@ -652,33 +824,101 @@ func (db Database) dataObjects(params url.Values) (databases.SearchResults, erro
 	for i, dataObject := range dataObjectResults.Results {
 		dataObjectIds[i] = dataObject.Id
 	}
-	studyIdForDataObjectId, err := db.studyIdsForDataObjectIds(dataObjectIds)
+	studyIdForDataObjectId, ambiguities, err := db.studyIdsForDataObjectIds(dataObjectIds)
 	if err != nil {
 		return results, err
 	}
 
-	// create data resources from data objects, fetch study metadata, and fill in
-	// data resource credit information
-	results.Resources = make([]frictionless.DataResource, len(dataObjectResults.Results))
-	creditForStudyId := make(map[string]credit.CreditMetadata)
-	for i, dataObject := range dataObjectResults.Results {
-		studyId := studyIdForDataObjectId[dataObject.Id]
-		credit, foundStudyCredit := creditForStudyId[studyId]
-		if !foundStudyCredit {
-			credit, err = db.creditMetadataForStudy(studyId)
-			if err != nil {
-				return results, err
-			}
-			creditForStudyId[studyId] = credit // cache for other data objects
+	// data objects associated with more than one study are left out of the
+	// results, since we can't credit them properly
+	ambiguous := make(map[string]bool)
+	for _, ambiguity := range ambiguities {
+		slog.Warn(fmt.Sprintf("%s (skipping it)", ambiguity.Error()))
+		ambiguous[ambiguity.DataObjectId] = true
+	}
+	results.NumSkipped = len(ambiguous)
+
+	// fetch study metadata for the remaining data objects
+	studyIds := make([]string, 0, len(dataObjectResults.Results))
+	for _, dataObject := range dataObjectResults.Results {
+		if !ambiguous[dataObject.Id] {
+			studyIds = append(studyIds, studyIdForDataObjectId[dataObject.Id])
+		}
+	}
+	creditForStudyId, err := db.creditMetadataForStudies(studyIds)
+	if err != nil {
+		return results, err
+	}
+
+	// create data resources from data objects and fill in data resource credit
+	// information
+	results.Resources = make([]frictionless.DataResource, 0, len(dataObjectResults.Results))
+	for _, dataObject := range dataObjectResults.Results {
+		if ambiguous[dataObject.Id] {
+			continue
 		}
-		results.Resources[i], err = db.dataResourceFromDataObject(dataObject)
+		resource, err := db.dataResourceFromDataObject(dataObject)
 		if err != nil {
 			return results, err
 		}
-		results.Resources[i].Credit = credit
+		resource.Credit = creditForStudyId[studyIdForDataObjectId[dataObject.Id]]
+		results.Resources = append(results.Resources, resource)
 	}
+	err = db.addInstrumentMetadata(results.Resources)
 
-	return results, nil
+	return results, err
+}
+
+// fetches credit metadata for the studies with the given IDs, mapping each ID
+// to its credit metadata. Each distinct study is fetched only once, however
+// often its ID appears, and at most enrichmentConcurrency() requests are in
+// flight at once.
+func (db Database) creditMetadataForStudies(studyIds []string) (map[string]credit.CreditMetadata, error) {
+	var mutex sync.Mutex
+	var firstErr error
+	creditForStudyId := make(map[string]credit.CreditMetadata)
+	slots := make(chan struct{}, enrichmentConcurrency())
+	var requests sync.WaitGroup
+	fetched := make(map[string]bool)
+	for _, studyId := range studyIds {
+		if fetched[studyId] {
+			continue
+		}
+		fetched[studyId] = true
+		requests.Add(1)
+		go func(studyId string) {
+			defer requests.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			mutex.Lock()
+			abandoned := firstErr != nil
+			mutex.Unlock()
+			if abandoned { // don't bother once a request has failed
+				return
+			}
+			creditMetadata, err := db.creditMetadataForStudy(studyId)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			creditForStudyId[studyId] = creditMetadata
+		}(studyId)
+	}
+	requests.Wait()
+	return creditForStudyId, firstErr
+}
+
+// returns the number of requests for study metadata that may be in flight at
+// once
+func enrichmentConcurrency() int {
+	if concurrency := config.Databases["nmdc"].EnrichmentConcurrency; concurrency > 0 {
+		return concurrency
+	}
+	return defaultEnrichmentConcurrency
 }
 
 // fetches credit metadata for the study with the given ID
@@ -761,27 +1001,43 @@ func (db Database) creditMetadataForStudy(studyId string) (credit.CreditMetadata
 		}
 	}
 
-	var relatedIdentifiers []credit.PermanentID
-	if len(study.AssociatedDois) > 0 {
-		relatedIdentifiers = make([]credit.PermanentID, len(study.AssociatedDois))
-		for i, doi := range study.AssociatedDois {
-			relatedIdentifiers[i] = credit.PermanentID{
-				Id:               doi.Value,
-				RelationshipType: "IsCitedBy",
-			}
-			switch doi.Category {
-			case "award_doi":
-				relatedIdentifiers[i].Description = "Awarded proposal DOI"
-			case "dataset_doi":
-				relatedIdentifiers[i].Description = "Dataset DOI"
-			case "publication_doi":
-				relatedIdentifiers[i].Description = "Publication DOI"
-			case "data_management_plan_doi":
-				relatedIdentifiers[i].Description = "Data management plan DOI"
-			}
+	var descriptions []credit.Description
+	if study.Description != "" {
+		descriptions = []credit.Description{
+			{
+				DescriptionText: study.Description,
+				DescriptionType: "abstract",
+			},
 		}
 	}
 
+	// the study itself is recorded as the collection to which its data objects
+	// belong, followed by any DOIs associated with it
+	relatedIdentifiers := []credit.PermanentID{
+		{
+			Id:               study.Id,
+			Description:      "NMDC study",
+			RelationshipType: "IsPartOf",
+		},
+	}
+	for _, doi := range study.AssociatedDois {
+		relatedIdentifier := credit.PermanentID{
+			Id:               doi.Value,
+			RelationshipType: "IsCitedBy",
+		}
+		switch doi.Category {
+		case "award_doi":
+			relatedIdentifier.Description = "Awarded proposal DOI"
+		case "dataset_doi":
+			relatedIdentifier.Description = "Dataset DOI"
+		case "publication_doi":
+			relatedIdentifier.Description = "Publication DOI"
+		case "data_management_plan_doi":
+			relatedIdentifier.Description = "Data management plan DOI"
+		}
+		relatedIdentifiers = append(relatedIdentifiers, relatedIdentifier)
+	}
+
 	var fundingSources []credit.FundingReference
 	if len(study.FundingSources) > 0 {
 		fundingSources = make([]credit.FundingReference, len(study.FundingSources))
@@ -799,6 +1055,7 @@ func (db Database) creditMetadataForStudy(studyId string) (credit.CreditMetadata
 	creditMetadata = credit.CreditMetadata{
 		// Identifier, Dates, and Version fields are specific to DataResources, omitted here
 		Contributors: contributors,
+		Descriptions: descriptions,
 		Funding:      fundingSources,
 		Publisher: credit.Organization{
 			OrganizationId:   "ROR:05cwx3318",
@@ -845,8 +1102,10 @@ func (db Database) dataObjectsForStudy(studyId string, params url.Values) (datab
 		}
 	}
 
-	// create resources for the data objects
+	// create resources for the data objects, noting their biosamples
 	results.Resources = make([]frictionless.DataResource, 0)
+	biosampleIds := make([]string, 0)
+	biosampleIdForResource := make([]string, 0)
 	for _, objectSet := range objectSets {
 		for _, dataObject := range objectSet.DataObjects {
 			// FIXME: apply hack!
@@ -859,6 +1118,22 @@ func (db Database) dataObjectsForStudy(studyId string, params url.Values) (datab
 				return results, err
 			}
 			results.Resources = append(results.Resources, resource)
+			biosampleIdForResource = append(biosampleIdForResource, objectSet.BiosampleId)
+			if objectSet.BiosampleId != "" && !slices.Contains(biosampleIds, objectSet.BiosampleId) {
+				biosampleIds = append(biosampleIds, objectSet.BiosampleId)
+			}
+		}
+	}
+
+	// record the biosample from which each data object was derived as one of
+	// its sources
+	biosamples, err := db.biosamplesForIds(biosampleIds)
+	if err != nil {
+		return results, err
+	}
+	for i, biosampleId := range biosampleIdForResource {
+		if biosample, found := biosamples[biosampleId]; found {
+			results.Resources[i].Sources = append(results.Resources[i].Sources, biosample.dataSource())
 		}
 	}
 
@@ -879,6 +1154,78 @@ func (db Database) dataObjectsForStudy(studyId string, params url.Values) (datab
 	return results, nil
 }
 
+// biosample type for JSON marshalling
+// (see https://microbiomedata.github.io/nmdc-schema/Biosample/)
+type Biosample struct { // partial representation, includes only relevant fields
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// returns a Frictionless data source referring to the biosample's page in the
+// NMDC data portal
+func (biosample Biosample) dataSource() frictionless.DataSource {
+	title := fmt.Sprintf("NMDC biosample %s", biosample.Id)
+	if biosample.Name != "" {
+		title = fmt.Sprintf("NMDC biosample %s (%s)", biosample.Id, biosample.Name)
+	}
+	return frictionless.DataSource{
+		Title: title,
+		Path:  "https://data.microbiomedata.org/details/sample/" + biosample.Id,
+	}
+}
+
+// fetches the biosamples with the given IDs, mapping each ID to its biosample.
+// At most maxBiosampleRequests requests are in flight at once, and all of them
+// are abandoned if they don't complete within biosampleRequestTimeout.
+func (db Database) biosamplesForIds(biosampleIds []string) (map[string]Biosample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), biosampleRequestTimeout)
+	defer cancel()
+
+	var mutex sync.Mutex
+	var firstErr error
+	biosamples := make(map[string]Biosample)
+	slots := make(chan struct{}, maxBiosampleRequests)
+	var requests sync.WaitGroup
+	for _, biosampleId := range biosampleIds {
+		requests.Add(1)
+		go func(biosampleId string) {
+			defer requests.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			biosample, err := db.biosample(ctx, biosampleId)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel() // abandon the other requests
+				}
+				return
+			}
+			biosamples[biosampleId] = biosample
+		}(biosampleId)
+	}
+	requests.Wait()
+	return biosamples, firstErr
+}
+
+// fetches the biosample with the given ID, giving up when the given context is
+// done
+func (db Database) biosample(ctx context.Context, biosampleId string) (Biosample, error) {
+	var biosample Biosample
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return biosample, databases.TimeoutError{Database: "nmdc"}
+	} else if ctx.Err() != nil {
+		return biosample, ctx.Err()
+	}
+	body, err := db.getWithContext(ctx, fmt.Sprintf("biosamples/%s", biosampleId), url.Values{})
+	if err != nil {
+		return biosample, err
+	}
+	err = json.Unmarshal(body, &biosample)
+	return biosample, err
+}
+
 // returns the page number and page size corresponding to the given Pagination
 // parameters
 func pageNumberAndSize(offset, maxNum int) (int, int) {
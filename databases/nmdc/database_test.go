@@ -2,8 +2,16 @@ package nmdc
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -12,6 +20,7 @@ import (
 	"github.com/kbase/dts/dtstest"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/frictionless"
 )
 
 const nmdcConfig string = `
@@ -128,6 +137,368 @@ func TestResources(t *testing.T) {
 	}
 }
 
+// checks that data objects are tagged with the endpoints serving their hosts
+func TestDataResourceEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	db := Database{
+		EndpointForHost: map[string]string{
+			"https://data.microbiomedata.org/data/": "globus-nmdc-nersc",
+			"https://nmdcdemo.emsl.pnnl.gov/":       "globus-nmdc-emsl",
+		},
+	}
+
+	resource, err := db.dataResourceFromDataObject(DataObject{
+		Id:  "nmdc:dobj-1",
+		URL: "https://data.microbiomedata.org/data/nmdc:omprc-1/assembly.fna",
+	})
+	assert.Nil(err)
+	assert.Equal("globus-nmdc-nersc", resource.Endpoint)
+	assert.Equal("nmdc:omprc-1/assembly.fna", resource.Path)
+
+	resource, err = db.dataResourceFromDataObject(DataObject{
+		Id:  "nmdc:dobj-2",
+		URL: "https://nmdcdemo.emsl.pnnl.gov/proteomics/results.tsv",
+	})
+	assert.Nil(err)
+	assert.Equal("globus-nmdc-emsl", resource.Endpoint)
+	assert.Equal("proteomics/results.tsv", resource.Path)
+}
+
+// an http.RoundTripper that serves requests with a handler function in place
+// of the NMDC API
+type handlerTransport struct {
+	Handler http.HandlerFunc
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.Handler(recorder, req)
+	return recorder.Result(), nil
+}
+
+// checks that data objects are annotated with the instruments that generated
+// their data when instrument metadata is enabled
+func TestInstrumentMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for the NMDC aggregation query endpoint
+	var request AggregateRequest
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(http.MethodPost, r.Method)
+		assert.Equal("/queries:run", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.Nil(json.Unmarshal(body, &request))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+  "ok": 1,
+  "cursor": {
+    "firstBatch": [
+      {
+        "id": "nmdc:dobj-1",
+        "instruments": [
+          {
+            "id": "nmdc:inst-14-xz5tb342",
+            "name": "NovaSeq S4",
+            "vendor": "illumina",
+            "model": "novaseq_6000"
+          }
+        ]
+      },
+      {
+        "id": "nmdc:dobj-2",
+        "instruments": []
+      }
+    ]
+  }
+}`))
+	}
+	db := Database{
+		Client: http.Client{Transport: handlerTransport{Handler: handler}},
+	}
+	resources := []frictionless.DataResource{
+		{Id: "nmdc:dobj-1"},
+		{Id: "nmdc:dobj-2"},
+	}
+
+	// nothing happens unless instrument metadata is enabled
+	err := db.addInstrumentMetadata(resources)
+	assert.Nil(err)
+	assert.Nil(resources[0].Instrument)
+
+	config.Service.InstrumentMetadata = true
+	defer func() { config.Service.InstrumentMetadata = false }()
+	err = db.addInstrumentMetadata(resources)
+	assert.Nil(err)
+	assert.Equal("data_object_set", request.Aggregate)
+	assert.Equal([]string{"nmdc:dobj-1", "nmdc:dobj-2"}, request.Pipeline[0].Match.Id.In)
+	assert.Equal("instrument_set", request.Pipeline[2].Lookup.From)
+	assert.Equal(&frictionless.DataInstrument{
+		Name:     "NovaSeq S4",
+		Platform: "illumina",
+		Model:    "novaseq_6000",
+	}, resources[0].Instrument)
+	assert.Nil(resources[1].Instrument)
+}
+
+// checks that identical searches produce resources in the same order, even if
+// NMDC returns its data objects in varying orders
+func TestSearchOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for the NMDC API that shuffles the data objects for a study
+	// and records the sort order requested for other data objects
+	dataObjects := []DataObject{
+		{Id: "nmdc:dobj-3", URL: "https://data.microbiomedata.org/data/c.fna"},
+		{Id: "nmdc:dobj-1", URL: "https://data.microbiomedata.org/data/a.fna"},
+		{Id: "nmdc:dobj-2", URL: "https://data.microbiomedata.org/data/b.fna"},
+	}
+	var requestedSort string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/data_objects/study/nmdc:sty-11-5tgfr349":
+			rand.Shuffle(len(dataObjects), func(i, j int) {
+				dataObjects[i], dataObjects[j] = dataObjects[j], dataObjects[i]
+			})
+			data, _ := json.Marshal([]map[string]any{
+				{"biosample_id": "nmdc:bsm-1", "data_objects": dataObjects[:2]},
+				{"biosample_id": "nmdc:bsm-2", "data_objects": dataObjects[2:]},
+			})
+			w.Write(data)
+		case "/data_objects/":
+			requestedSort = r.URL.Query().Get("sort")
+			w.Write([]byte(`{"results": []}`))
+		case "/queries:run":
+			w.Write([]byte(`{"ok": 1, "cursor": {"firstBatch": []}}`))
+		case "/studies/nmdc:sty-11-5tgfr349":
+			w.Write([]byte(`{"id": "nmdc:sty-11-5tgfr349", "title": "A study"}`))
+		case "/biosamples/nmdc:bsm-1", "/biosamples/nmdc:bsm-2":
+			fmt.Fprintf(w, `{"id": "%s"}`, strings.TrimPrefix(r.URL.Path, "/biosamples/"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	db := Database{
+		Client: http.Client{Transport: handlerTransport{Handler: handler}},
+		Auth:   authorization{ExpirationTime: time.Now().Add(time.Hour)},
+	}
+
+	var ids [][]string
+	for range 5 {
+		results, err := db.Search(databases.SearchParameters{Specific: nmdcSearchParams})
+		assert.Nil(err)
+		resultIds := make([]string, len(results.Resources))
+		for i, resource := range results.Resources {
+			resultIds[i] = resource.Id
+		}
+		ids = append(ids, resultIds)
+	}
+	for _, resultIds := range ids {
+		assert.Equal([]string{"nmdc:dobj-1", "nmdc:dobj-2", "nmdc:dobj-3"}, resultIds)
+	}
+
+	// other searches ask NMDC to sort their results by ID unless another sort
+	// is requested
+	_, err := db.Search(databases.SearchParameters{})
+	assert.Nil(err)
+	assert.Equal("id", requestedSort)
+	sort, _ := json.Marshal("name")
+	_, err = db.Search(databases.SearchParameters{
+		Specific: map[string]json.RawMessage{"sort": sort},
+	})
+	assert.Nil(err)
+	assert.Equal("name", requestedSort)
+}
+
+// checks that a search leaves out data objects associated with more than one
+// study, reporting how many it skipped
+func TestSearchSkipsAmbiguousStudies(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for the NMDC API whose second data object was generated for
+	// two studies
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/data_objects/":
+			w.Write([]byte(`{"results": [
+  {"id": "nmdc:dobj-1", "url": "https://data.microbiomedata.org/data/a.fna"},
+  {"id": "nmdc:dobj-2", "url": "https://data.microbiomedata.org/data/b.fna"},
+  {"id": "nmdc:dobj-3", "url": "https://data.microbiomedata.org/data/c.fna"}]}`))
+		case "/queries:run":
+			w.Write([]byte(`{"ok": 1, "cursor": {"firstBatch": [
+  {"id": "nmdc:dobj-1", "data_generation_sets": [
+    {"id": "nmdc:dgns-1", "associated_studies": ["nmdc:sty-1"]}]},
+  {"id": "nmdc:dobj-2", "data_generation_sets": [
+    {"id": "nmdc:dgns-2", "associated_studies": ["nmdc:sty-1"]},
+    {"id": "nmdc:dgns-3", "associated_studies": ["nmdc:sty-2"]}]},
+  {"id": "nmdc:dobj-3", "data_generation_sets": [
+    {"id": "nmdc:dgns-4", "associated_studies": ["nmdc:sty-1"]}]}]}}`))
+		case "/studies/nmdc:sty-1":
+			w.Write([]byte(`{"id": "nmdc:sty-1", "title": "A study"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	db := Database{
+		Client: http.Client{Transport: handlerTransport{Handler: handler}},
+		Auth:   authorization{ExpirationTime: time.Now().Add(time.Hour)},
+	}
+
+	results, err := db.Search(databases.SearchParameters{})
+	assert.Nil(err)
+	assert.Equal(2, len(results.Resources))
+	assert.Equal("nmdc:dobj-1", results.Resources[0].Id)
+	assert.Equal("nmdc:dobj-3", results.Resources[1].Id)
+	assert.Equal(1, results.NumSkipped)
+
+	// the ambiguity is reported with the studies involved
+	_, ambiguities, err := db.studyIdsForDataObjectIds([]string{"nmdc:dobj-2"})
+	assert.Nil(err)
+	assert.Equal([]AmbiguousWorkflowStudyError{
+		{DataObjectId: "nmdc:dobj-2", StudyIds: []string{"nmdc:sty-1", "nmdc:sty-2"}},
+	}, ambiguities)
+}
+
+// checks that a search for a study's data objects records the biosamples from
+// which they were derived, fetching them concurrently (within limits)
+func TestSearchStudyBiosamples(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for the NMDC API serving a study whose data objects were
+	// derived from three biosamples, which tracks concurrent biosample requests
+	var mutex sync.Mutex
+	var numBiosampleRequests, inFlight, maxInFlight int
+	biosampleDelay := 20 * time.Millisecond
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/data_objects/study/nmdc:sty-11-5tgfr349":
+			w.Write([]byte(`[
+  {"biosample_id": "nmdc:bsm-1", "data_objects": [
+    {"id": "nmdc:dobj-1", "url": "https://data.microbiomedata.org/data/a.fna"},
+    {"id": "nmdc:dobj-2", "url": "https://data.microbiomedata.org/data/b.fna"}]},
+  {"biosample_id": "nmdc:bsm-2", "data_objects": [
+    {"id": "nmdc:dobj-3", "url": "https://data.microbiomedata.org/data/c.fna"}]},
+  {"biosample_id": "nmdc:bsm-3", "data_objects": [
+    {"id": "nmdc:dobj-4", "url": "https://data.microbiomedata.org/data/d.fna"}]}]`))
+		case r.URL.Path == "/studies/nmdc:sty-11-5tgfr349":
+			w.Write([]byte(`{"id": "nmdc:sty-11-5tgfr349", "title": "A study"}`))
+		case strings.HasPrefix(r.URL.Path, "/biosamples/"):
+			mutex.Lock()
+			numBiosampleRequests++
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mutex.Unlock()
+			time.Sleep(biosampleDelay)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+			id := strings.TrimPrefix(r.URL.Path, "/biosamples/")
+			fmt.Fprintf(w, `{"id": "%s", "name": "Soil core %s"}`, id, id[len(id)-1:])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	db := Database{
+		Client: http.Client{Transport: handlerTransport{Handler: handler}},
+		Auth:   authorization{ExpirationTime: time.Now().Add(time.Hour)},
+	}
+	maxRequests, timeout := maxBiosampleRequests, biosampleRequestTimeout
+	defer func() { maxBiosampleRequests, biosampleRequestTimeout = maxRequests, timeout }()
+	maxBiosampleRequests = 2
+
+	// each data object records its biosample, each of which is fetched once
+	results, err := db.Search(databases.SearchParameters{Specific: nmdcSearchParams})
+	assert.Nil(err)
+	assert.Equal(4, len(results.Resources))
+	expectedBiosamples := []string{"nmdc:bsm-1", "nmdc:bsm-1", "nmdc:bsm-2", "nmdc:bsm-3"}
+	for i, resource := range results.Resources {
+		biosampleId := expectedBiosamples[i]
+		assert.Equal([]frictionless.DataSource{
+			{
+				Title: fmt.Sprintf("NMDC biosample %s (Soil core %s)", biosampleId,
+					biosampleId[len(biosampleId)-1:]),
+				Path: "https://data.microbiomedata.org/details/sample/" + biosampleId,
+			},
+		}, resource.Sources)
+		assert.Equal("A study", resource.Credit.Titles[0].Title)
+	}
+	assert.Equal(3, numBiosampleRequests)
+	assert.LessOrEqual(maxInFlight, 2)
+
+	// biosamples that can't all be fetched in time produce a timeout
+	maxBiosampleRequests = 1
+	biosampleRequestTimeout = 3 * biosampleDelay / 2
+	_, err = db.Search(databases.SearchParameters{Specific: nmdcSearchParams})
+	assert.IsType(databases.TimeoutError{}, err)
+}
+
+// checks that a search for data objects crediting a handful of studies fetches
+// each study only once, concurrently (within limits), and preserves the order
+// of the data objects
+func TestSearchStudyCredit(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for the NMDC API serving 12 data objects that credit 3
+	// studies, which tracks concurrent study requests
+	var dataObjects []string
+	var firstBatch []string
+	for i := range 12 {
+		id := fmt.Sprintf("nmdc:dobj-%02d", i)
+		dataObjects = append(dataObjects, fmt.Sprintf(
+			`{"id": "%s", "url": "https://data.microbiomedata.org/data/%d.fna"}`, id, i))
+		firstBatch = append(firstBatch, fmt.Sprintf(
+			`{"id": "%s", "data_generation_sets": [{"id": "nmdc:dgns-%d", "associated_studies": ["nmdc:sty-%d"]}]}`,
+			id, i, i%3))
+	}
+	var mutex sync.Mutex
+	studyRequests := make(map[string]int)
+	var inFlight, maxInFlight int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/data_objects/":
+			fmt.Fprintf(w, `{"results": [%s]}`, strings.Join(dataObjects, ","))
+		case r.URL.Path == "/queries:run":
+			fmt.Fprintf(w, `{"ok": 1, "cursor": {"firstBatch": [%s]}}`, strings.Join(firstBatch, ","))
+		case strings.HasPrefix(r.URL.Path, "/studies/"):
+			id := strings.TrimPrefix(r.URL.Path, "/studies/")
+			mutex.Lock()
+			studyRequests[id]++
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mutex.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+			fmt.Fprintf(w, `{"id": "%s", "title": "Study %s"}`, id, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	db := Database{
+		Client: http.Client{Transport: handlerTransport{Handler: handler}},
+		Auth:   authorization{ExpirationTime: time.Now().Add(time.Hour)},
+	}
+	nmdcConfig := config.Databases["nmdc"]
+	defer func() { config.Databases["nmdc"] = nmdcConfig }()
+	limitedConfig := nmdcConfig
+	limitedConfig.EnrichmentConcurrency = 2
+	config.Databases["nmdc"] = limitedConfig
+
+	results, err := db.Search(databases.SearchParameters{})
+	assert.Nil(err)
+	assert.Equal(12, len(results.Resources))
+	for i, resource := range results.Resources {
+		assert.Equal(fmt.Sprintf("nmdc:dobj-%02d", i), resource.Id)
+		assert.Equal(fmt.Sprintf("Study nmdc:sty-%d", i%3), resource.Credit.Titles[0].Title)
+	}
+	assert.Equal(map[string]int{"nmdc:sty-0": 1, "nmdc:sty-1": 1, "nmdc:sty-2": 1}, studyRequests)
+	assert.LessOrEqual(maxInFlight, 2)
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	setup()
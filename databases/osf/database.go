@@ -0,0 +1,634 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package osf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/frictionless"
+)
+
+// This database resolves projects (nodes) hosted by the Open Science Framework
+// (OSF, https://osf.io) into the files in their OSF Storage using the OSF API
+// (https://api.osf.io/v2/). OSF serves these files for direct download through
+// its file service, so the database's endpoint must be an "http" endpoint
+// rooted at https://files.osf.io/v1/resources/.
+// (implements the databases.Database interface)
+type Database struct {
+	// ORCID identifier for database proxy
+	Orcid string
+	// HTTP client used for OSF API requests
+	Client http.Client
+	// base URL of the OSF API
+	ApiURL string
+	// personal access token used to authenticate API requests (optional,
+	// since public projects can be read without one)
+	Token string
+	// correlation ID sent with the database's requests (if any)
+	CorrelationId string
+}
+
+func NewDatabase(orcid string) (databases.Database, error) {
+	if orcid == "" {
+		return nil, databases.UnauthorizedError{
+			Database: "osf",
+			Message:  "No ORCID was given",
+		}
+	}
+
+	// OSF files are downloaded from its file service over HTTPS
+	endpointName := config.Databases["osf"].Endpoint
+	if endpointName == "" {
+		return nil, databases.InvalidEndpointsError{
+			Database: "osf",
+			Message:  "OSF requires an endpoint to be specified",
+		}
+	}
+	if config.Endpoints[endpointName].Provider != "http" {
+		return nil, databases.InvalidEndpointsError{
+			Database: "osf",
+			Message:  fmt.Sprintf("'%s' is not an HTTP endpoint", endpointName),
+		}
+	}
+
+	// NOTE: we prevent redirects from HTTPS -> HTTP!
+	db := &Database{
+		Orcid:  orcid,
+		Client: databases.DatabaseHttpClient("osf", databases.SecureHttpClientOptions()),
+		ApiURL: baseApiURL,
+		Token:  os.Getenv("DTS_OSF_TOKEN"),
+	}
+	return db, nil
+}
+
+func (db Database) SpecificSearchParameters() map[string]interface{} {
+	return nil
+}
+
+func (db *Database) Search(params databases.SearchParameters) (databases.SearchResults, error) {
+	// the query is the GUID of an OSF project, whose files are all returned
+	guid := strings.TrimSpace(params.Query)
+	if guid == "" {
+		return databases.SearchResults{}, &databases.InvalidSearchParameter{
+			Database: "OSF",
+			Message:  "OSF searches require a project GUID",
+		}
+	}
+	node, err := db.readNode(guid)
+	if err != nil {
+		if errors.As(err, &databases.ResourceNotFoundError{}) {
+			return databases.SearchResults{}, &databases.InvalidSearchParameter{
+				Database: "OSF",
+				Message:  fmt.Sprintf("No OSF project was found with GUID '%s'", guid),
+			}
+		}
+		return databases.SearchResults{}, err
+	}
+	files, err := db.readFiles(guid, "/")
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+
+	offset := params.Pagination.Offset
+	pageSize := params.Pagination.MaxNum
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	start := min(offset, len(files))
+	end := min(offset+pageSize, len(files))
+	resources := make([]frictionless.DataResource, end-start)
+	for i, file := range files[start:end] {
+		resources[i] = dataResourceFromFile(file, node)
+	}
+	return databases.SearchResults{
+		Resources: resources,
+		HasMore:   end < len(files),
+	}, nil
+}
+
+func (db Database) Resources(fileIds []string) ([]frictionless.DataResource, error) {
+	// file IDs have the form OSF:<file ID>, and each file refers to the project
+	// (its "target") that holds it
+	resources := make([]frictionless.DataResource, len(fileIds))
+	nodes := make(map[string]Node)
+	for i, fileId := range fileIds {
+		osfId, found := strings.CutPrefix(fileId, "OSF:")
+		if !found || osfId == "" || strings.Contains(osfId, "/") {
+			return nil, databases.ResourceNotFoundError{
+				Database:   "osf",
+				ResourceId: fileId,
+			}
+		}
+		file, err := db.readFile(osfId)
+		if err != nil {
+			if errors.As(err, &databases.ResourceNotFoundError{}) {
+				err = databases.ResourceNotFoundError{
+					Database:   "osf",
+					ResourceId: fileId,
+				}
+			}
+			return nil, err
+		}
+		if file.Attributes.Kind != "file" {
+			return nil, databases.ResourceNotFoundError{
+				Database:   "osf",
+				ResourceId: fileId,
+			}
+		}
+		guid := file.Relationships.Target.Data.Id
+		node, found := nodes[guid]
+		if !found {
+			node, err = db.readNode(guid)
+			if err != nil {
+				return nil, err
+			}
+			nodes[guid] = node
+		}
+		resources[i] = dataResourceFromFile(file, node)
+	}
+	return resources, nil
+}
+
+func (db Database) StageFiles(fileIds []string) (uuid.UUID, error) {
+	// OSF Storage files are always available for download, so we simply
+	// generate a UUID that can be handed to db.StagingStatus
+	return uuid.New(), nil
+}
+
+func (db Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	return databases.StagingStatusSucceeded, nil
+}
+
+func (db Database) CancelStaging(id uuid.UUID) error {
+	// nothing to cancel
+	return nil
+}
+
+func (db Database) LocalUser(orcid string) (string, error) {
+	// the DTS only reads from OSF, so it can only serve as a transfer source
+	return "", fmt.Errorf("The OSF database can't map ORCIDs to local users")
+}
+
+// returns a view of the database whose requests carry the given correlation
+// ID (implements the databases.CorrelatedDatabase interface)
+func (db Database) WithCorrelationId(id string) databases.Database {
+	db.CorrelationId = id
+	return &db
+}
+
+func (db Database) Save() (databases.DatabaseSaveState, error) {
+	// this database has no internal state
+	return databases.DatabaseSaveState{
+		Name: "osf",
+	}, nil
+}
+
+func (db *Database) Load(state databases.DatabaseSaveState) error {
+	// no internal state -> nothing to do
+	return nil
+}
+
+//--------------------
+// Internal machinery
+//--------------------
+
+const (
+	baseApiURL = "https://api.osf.io/v2/"
+	// number of files returned by a search if no maximum is given
+	defaultPageSize = 100
+)
+
+// a file or folder in a project's OSF Storage, as returned by the OSF API
+// (see https://developer.osf.io/#tag/Files)
+type File struct {
+	Id         string `json:"id"`
+	Attributes struct {
+		Kind             string `json:"kind"`
+		Name             string `json:"name"`
+		Path             string `json:"path"`
+		MaterializedPath string `json:"materialized_path"`
+		Size             int    `json:"size"`
+		DateModified     string `json:"date_modified"`
+		Extra            struct {
+			Hashes struct {
+				MD5    string `json:"md5"`
+				SHA256 string `json:"sha256"`
+			} `json:"hashes"`
+		} `json:"extra"`
+	} `json:"attributes"`
+	Relationships struct {
+		Target struct {
+			Data struct {
+				Id string `json:"id"`
+			} `json:"data"`
+		} `json:"target"`
+	} `json:"relationships"`
+}
+
+// an OSF project (node) with the contributors and identifiers we use for
+// credit metadata
+type Node struct {
+	Id         string `json:"id"`
+	Attributes struct {
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		DateCreated  string `json:"date_created"`
+		DateModified string `json:"date_modified"`
+	} `json:"attributes"`
+	Links struct {
+		Html string `json:"html"`
+	} `json:"links"`
+	Contributors []Contributor `json:"-"`
+	DOIs         []string      `json:"-"`
+}
+
+// a contributor to an OSF project, with its embedded user record
+type Contributor struct {
+	Attributes struct {
+		Bibliographic bool `json:"bibliographic"`
+	} `json:"attributes"`
+	Embeds struct {
+		Users struct {
+			Data struct {
+				Attributes struct {
+					FullName   string `json:"full_name"`
+					GivenName  string `json:"given_name"`
+					FamilyName string `json:"family_name"`
+					Social     struct {
+						Orcid string `json:"orcid"`
+					} `json:"social"`
+				} `json:"attributes"`
+			} `json:"data"`
+		} `json:"users"`
+	} `json:"embeds"`
+}
+
+// an identifier (e.g. a DOI) registered for an OSF project
+type identifier struct {
+	Attributes struct {
+		Category string `json:"category"`
+		Value    string `json:"value"`
+	} `json:"attributes"`
+}
+
+// a page of results from the OSF API, which paginates lists of resources
+// following the JSON:API conventions
+type listResponse struct {
+	Data  []json.RawMessage `json:"data"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+// retrieves the project with the given GUID along with its contributors and
+// identifiers
+func (db Database) readNode(guid string) (Node, error) {
+	var node Node
+	body, err := db.get(fmt.Sprintf("nodes/%s/", guid), nil)
+	if err != nil {
+		return node, err
+	}
+	var response struct {
+		Data Node `json:"data"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return node, err
+	}
+	node = response.Data
+
+	values := url.Values{}
+	values.Add("embed", "users")
+	items, err := db.readList(fmt.Sprintf("nodes/%s/contributors/", guid), values)
+	if err != nil {
+		return node, err
+	}
+	node.Contributors = make([]Contributor, len(items))
+	for i, item := range items {
+		err = json.Unmarshal(item, &node.Contributors[i])
+		if err != nil {
+			return node, err
+		}
+	}
+
+	items, err = db.readList(fmt.Sprintf("nodes/%s/identifiers/", guid), nil)
+	if err != nil {
+		return node, err
+	}
+	for _, item := range items {
+		var identifier identifier
+		err = json.Unmarshal(item, &identifier)
+		if err != nil {
+			return node, err
+		}
+		if identifier.Attributes.Category == "doi" {
+			node.DOIs = append(node.DOIs, identifier.Attributes.Value)
+		}
+	}
+	return node, nil
+}
+
+// retrieves all files within the folder with the given path (e.g. "/" or
+// "/<folder ID>/") in the OSF Storage of the project with the given GUID,
+// descending into subfolders
+func (db Database) readFiles(guid, folderPath string) ([]File, error) {
+	resource := fmt.Sprintf("nodes/%s/files/osfstorage%s", guid, folderPath)
+	items, err := db.readList(resource, nil)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0)
+	for _, item := range items {
+		var entry File
+		err = json.Unmarshal(item, &entry)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Attributes.Kind == "folder" {
+			folderFiles, err := db.readFiles(guid, entry.Attributes.Path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, folderFiles...)
+		} else {
+			// files listed under a project don't always name it as their
+			// target, so we fill it in
+			entry.Relationships.Target.Data.Id = guid
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+// retrieves the file with the given OSF file ID
+func (db Database) readFile(osfId string) (File, error) {
+	var response struct {
+		Data File `json:"data"`
+	}
+	body, err := db.get(fmt.Sprintf("files/%s/", osfId), nil)
+	if err != nil {
+		return response.Data, err
+	}
+	err = json.Unmarshal(body, &response)
+	return response.Data, err
+}
+
+// retrieves every page of the list at the given OSF API resource, returning
+// the listed items as undecoded JSON
+func (db Database) readList(resource string, values url.Values) ([]json.RawMessage, error) {
+	items := make([]json.RawMessage, 0)
+	body, err := db.get(resource, values)
+	for {
+		if err != nil {
+			return nil, err
+		}
+		var page listResponse
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Data...)
+		if page.Links.Next == "" {
+			return items, nil
+		}
+		body, err = db.getURL(page.Links.Next)
+	}
+}
+
+// performs a GET request on the given OSF API resource, returning the
+// resulting response body and/or error
+func (db Database) get(resource string, values url.Values) ([]byte, error) {
+	res, err := url.Parse(db.ApiURL)
+	if err != nil {
+		return nil, err
+	}
+	res.Path += resource
+	res.RawQuery = values.Encode()
+	return db.getURL(res.String())
+}
+
+// performs a GET request on the given OSF API URL, returning the resulting
+// response body and/or error
+func (db Database) getURL(resourceURL string) ([]byte, error) {
+	slog.Debug(fmt.Sprintf("GET: %s", resourceURL))
+	req, err := http.NewRequestWithContext(databases.WithCorrelationId(context.Background(), db.CorrelationId),
+		http.MethodGet, resourceURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.api+json")
+	if db.Token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", db.Token))
+	}
+	resp, err := databases.DoWithTimeout(&db.Client, "osf", req, db.Client.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return io.ReadAll(resp.Body)
+	case 401, 403:
+		return nil, databases.UnauthorizedError{
+			Database: "osf",
+			Message:  "The OSF denied access (is DTS_OSF_TOKEN valid?)",
+		}
+	case 404, 410:
+		return nil, databases.ResourceNotFoundError{
+			Database:   "osf",
+			ResourceId: resourceURL,
+		}
+	case 503:
+		return nil, &databases.UnavailableError{
+			Database: "osf",
+		}
+	default:
+		return nil, fmt.Errorf("An error occurred with the OSF database (%d)",
+			resp.StatusCode)
+	}
+}
+
+// creates a Frictionless DataResource for the given file in the given project
+func dataResourceFromFile(file File, node Node) frictionless.DataResource {
+	id := "OSF:" + file.Id
+	hashes := frictionless.HashesForMD5(file.Attributes.Extra.Hashes.MD5)
+	if sha256 := file.Attributes.Extra.Hashes.SHA256; sha256 != "" {
+		if hashes == nil {
+			hashes = make(map[string]string)
+		}
+		hashes["sha256"] = sha256
+	}
+	// OSF's file service addresses OSF Storage files by their IDs, relative to
+	// the project that holds them
+	return frictionless.DataResource{
+		Id:        id,
+		Name:      dataResourceName(file.Attributes.Name),
+		Path:      path.Join(node.Id, "providers", "osfstorage", file.Id),
+		Format:    formatFromFileName(file.Attributes.Name),
+		MediaType: mediaTypeFromFileName(file.Attributes.Name),
+		Bytes:     file.Attributes.Size,
+		Hash:      file.Attributes.Extra.Hashes.MD5,
+		Hashes:    hashes,
+		Sources: []frictionless.DataSource{
+			{
+				Title: fmt.Sprintf("OSF project %s (%s)", node.Id, file.Attributes.MaterializedPath),
+				Path:  node.Links.Html,
+			},
+		},
+		Credit: creditMetadataFromNode(id, file, node),
+	}
+}
+
+// creates credit metadata for the file with the given ID and record belonging
+// to the given project
+func creditMetadataFromNode(id string, file File, node Node) credit.CreditMetadata {
+	metadata := credit.CreditMetadata{
+		Identifier:   id,
+		ResourceType: "dataset",
+		Publisher: credit.Organization{
+			OrganizationId:   "ROR:05d5mza29",
+			OrganizationName: "Center for Open Science",
+		},
+		Url:     node.Links.Html,
+		Version: file.Attributes.DateModified,
+	}
+	if node.Attributes.Title != "" {
+		metadata.Titles = []credit.Title{
+			{
+				Title: node.Attributes.Title,
+			},
+		}
+	}
+	if node.Attributes.Description != "" {
+		metadata.Descriptions = []credit.Description{
+			{
+				DescriptionText: node.Attributes.Description,
+				DescriptionType: "abstract",
+			},
+		}
+	}
+	if node.Attributes.DateCreated != "" {
+		metadata.Dates = append(metadata.Dates, credit.EventDate{
+			Date:  node.Attributes.DateCreated,
+			Event: "Created",
+		})
+	}
+	if file.Attributes.DateModified != "" {
+		metadata.Dates = append(metadata.Dates, credit.EventDate{
+			Date:  file.Attributes.DateModified,
+			Event: "Updated",
+		})
+	}
+
+	// only bibliographic contributors are credited by OSF's own citations
+	for _, contributor := range node.Contributors {
+		if !contributor.Attributes.Bibliographic {
+			continue
+		}
+		user := contributor.Embeds.Users.Data.Attributes
+		var orcid string
+		if user.Social.Orcid != "" {
+			orcid = "ORCID:" + user.Social.Orcid
+		}
+		metadata.Contributors = append(metadata.Contributors, credit.Contributor{
+			ContributorType:  "Person",
+			ContributorId:    orcid,
+			Name:             user.FullName,
+			GivenName:        user.GivenName,
+			FamilyName:       user.FamilyName,
+			ContributorRoles: "Creator",
+		})
+	}
+
+	// the project is recorded as the collection to which its files belong,
+	// followed by any DOIs registered for it
+	metadata.RelatedIdentifiers = []credit.PermanentID{
+		{
+			Id:               "OSF:" + node.Id,
+			Description:      "OSF project",
+			RelationshipType: "IsPartOf",
+		},
+	}
+	for _, doi := range node.DOIs {
+		metadata.RelatedIdentifiers = append(metadata.RelatedIdentifiers, credit.PermanentID{
+			Id:               "DOI:" + doi,
+			Description:      "Project DOI",
+			RelationshipType: "IsPartOf",
+		})
+	}
+	return metadata
+}
+
+// creates a Frictionless DataResource-savvy name for a file by lower-casing
+// it, stripping its extension, and replacing characters Frictionless doesn't
+// allow with underscores
+func dataResourceName(fileName string) string {
+	name := strings.ToLower(strings.TrimSuffix(fileName, path.Ext(fileName)))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || strings.ContainsRune("._-", r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// returns the format for a file with the given name (its extension)
+func formatFromFileName(fileName string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(fileName)), ".")
+}
+
+// returns the media type for a file with the given name
+func mediaTypeFromFileName(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".csv":
+		return "text/csv"
+	case ".tsv":
+		return "text/tab-separated-values"
+	case ".txt", ".md":
+		return "text/plain"
+	case ".json":
+		return "application/json"
+	case ".pdf":
+		return "application/pdf"
+	case ".zip":
+		return "application/zip"
+	case ".gz":
+		return "application/gzip"
+	default:
+		return "application/octet-stream"
+	}
+}
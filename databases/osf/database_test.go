@@ -0,0 +1,340 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package osf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+)
+
+const osfConfig string = `
+databases:
+  osf:
+    name: Open Science Framework
+    organization: Center for Open Science
+    endpoint: osf-http
+endpoints:
+  osf-http:
+    name: OSF file service
+    id: 3f1c2d4e-8b6a-4f0e-9d2c-7a5b1e6f4c3d
+    provider: http
+    root: https://files.osf.io/v1/resources/
+`
+
+// the project served by our stand-in for the OSF API
+const nodeJSON string = `{
+  "data": {
+    "id": "ab3cd",
+    "type": "nodes",
+    "attributes": {
+      "title": "Soil microbiome survey",
+      "description": "Amplicon data from grassland soils",
+      "date_created": "2021-03-04T12:00:00.000000",
+      "date_modified": "2023-06-07T12:00:00.000000"
+    },
+    "links": {"html": "https://osf.io/ab3cd/"}
+  }
+}`
+
+// the project's contributors, split across two pages; the second
+// contributor isn't bibliographic
+const contributorsPage1JSON string = `{
+  "data": [
+    {
+      "attributes": {"bibliographic": true},
+      "embeds": {"users": {"data": {"attributes": {
+        "full_name": "Ada Lovelace",
+        "given_name": "Ada",
+        "family_name": "Lovelace",
+        "social": {"orcid": "0000-0001-2345-6789"}
+      }}}}
+    }
+  ],
+  "links": {"next": "%s/nodes/ab3cd/contributors/?embed=users&page=2"}
+}`
+
+const contributorsPage2JSON string = `{
+  "data": [
+    {
+      "attributes": {"bibliographic": false},
+      "embeds": {"users": {"data": {"attributes": {"full_name": "Lab Manager"}}}}
+    }
+  ],
+  "links": {"next": null}
+}`
+
+const identifiersJSON string = `{
+  "data": [
+    {"attributes": {"category": "ark", "value": "c7605/osf.io/ab3cd"}},
+    {"attributes": {"category": "doi", "value": "10.17605/OSF.IO/AB3CD"}}
+  ],
+  "links": {"next": null}
+}`
+
+// the root of the project's OSF Storage holds a file and a folder
+const rootFilesJSON string = `{
+  "data": [
+    {
+      "id": "5f0a1b2c3d4e5f6a7b8c9d0e",
+      "attributes": {
+        "kind": "file",
+        "name": "Samples.csv",
+        "path": "/5f0a1b2c3d4e5f6a7b8c9d0e",
+        "materialized_path": "/Samples.csv",
+        "size": 1024,
+        "date_modified": "2023-06-07T12:00:00.000000",
+        "extra": {"hashes": {
+          "md5": "6ae3ab0dc6e3eb5e2b4c5d2b2c4bd5e1",
+          "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+        }}
+      }
+    },
+    {
+      "id": "5f0a1b2c3d4e5f6a7b8c9d0f",
+      "attributes": {
+        "kind": "folder",
+        "name": "reads",
+        "path": "/5f0a1b2c3d4e5f6a7b8c9d0f/",
+        "materialized_path": "/reads/"
+      }
+    }
+  ],
+  "links": {"next": null}
+}`
+
+const folderFilesJSON string = `{
+  "data": [
+    {
+      "id": "5f0a1b2c3d4e5f6a7b8c9d10",
+      "attributes": {
+        "kind": "file",
+        "name": "reads.fastq.gz",
+        "path": "/5f0a1b2c3d4e5f6a7b8c9d10",
+        "materialized_path": "/reads/reads.fastq.gz",
+        "size": 4096,
+        "date_modified": "2023-06-08T12:00:00.000000",
+        "extra": {"hashes": {"md5": "2e7a5b4c3d8e9f0a1b2c3d4e5f6a7b8c"}}
+      }
+    }
+  ],
+  "links": {"next": null}
+}`
+
+const fileJSON string = `{
+  "data": {
+    "id": "5f0a1b2c3d4e5f6a7b8c9d10",
+    "attributes": {
+      "kind": "file",
+      "name": "reads.fastq.gz",
+      "path": "/5f0a1b2c3d4e5f6a7b8c9d10",
+      "materialized_path": "/reads/reads.fastq.gz",
+      "size": 4096,
+      "date_modified": "2023-06-08T12:00:00.000000",
+      "extra": {"hashes": {"md5": "2e7a5b4c3d8e9f0a1b2c3d4e5f6a7b8c"}}
+    },
+    "relationships": {"target": {"data": {"id": "ab3cd", "type": "nodes"}}}
+  }
+}`
+
+// authorization headers received by the stand-in OSF API
+var authHeaders []string
+
+// serves the project above
+func serveOsfAPI(w http.ResponseWriter, r *http.Request) {
+	authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+	var body string
+	switch r.URL.Path {
+	case "/nodes/ab3cd/":
+		body = nodeJSON
+	case "/nodes/ab3cd/contributors/":
+		if r.URL.Query().Get("page") == "2" {
+			body = contributorsPage2JSON
+		} else {
+			body = fmt.Sprintf(contributorsPage1JSON, osfServer.URL)
+		}
+	case "/nodes/ab3cd/identifiers/":
+		body = identifiersJSON
+	case "/nodes/ab3cd/files/osfstorage/":
+		body = rootFilesJSON
+	case "/nodes/ab3cd/files/osfstorage/5f0a1b2c3d4e5f6a7b8c9d0f/":
+		body = folderFilesJSON
+	case "/files/5f0a1b2c3d4e5f6a7b8c9d10/":
+		body = fileJSON
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.Write([]byte(body))
+}
+
+var osfServer *httptest.Server
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	config.Init([]byte(osfConfig))
+	osfServer = httptest.NewServer(http.HandlerFunc(serveOsfAPI))
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	osfServer.Close()
+}
+
+// creates a database that uses the stand-in OSF API
+func newTestDatabase() *Database {
+	db, _ := NewDatabase("1234-5678-9101-1121")
+	osfDb := db.(*Database)
+	osfDb.ApiURL = osfServer.URL + "/"
+	return osfDb
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := NewDatabase("1234-5678-9101-1121")
+	assert.NotNil(db)
+	assert.Nil(err)
+
+	db, err = NewDatabase("")
+	assert.Nil(db)
+	assert.NotNil(err)
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	// a project's files are found in all of its folders
+	results, err := db.Search(databases.SearchParameters{Query: "ab3cd"})
+	assert.Nil(err)
+	assert.Equal(2, len(results.Resources))
+	assert.False(results.HasMore)
+
+	resource := results.Resources[0]
+	assert.Equal("OSF:5f0a1b2c3d4e5f6a7b8c9d0e", resource.Id)
+	assert.Equal("samples", resource.Name)
+	assert.Equal("ab3cd/providers/osfstorage/5f0a1b2c3d4e5f6a7b8c9d0e", resource.Path)
+	assert.Equal("csv", resource.Format)
+	assert.Equal("text/csv", resource.MediaType)
+	assert.Equal(1024, resource.Bytes)
+	assert.Equal("6ae3ab0dc6e3eb5e2b4c5d2b2c4bd5e1", resource.Hash)
+	assert.Equal("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		resource.Hashes["sha256"])
+	assert.Equal("OSF:5f0a1b2c3d4e5f6a7b8c9d10", results.Resources[1].Id)
+	assert.Equal(4096, results.Resources[1].Bytes)
+
+	// project metadata is mapped to credit metadata, crediting only
+	// bibliographic contributors (listed on every page)
+	assert.Equal("OSF:5f0a1b2c3d4e5f6a7b8c9d0e", resource.Credit.Identifier)
+	assert.Equal("Soil microbiome survey", resource.Credit.Titles[0].Title)
+	assert.Equal("https://osf.io/ab3cd/", resource.Credit.Url)
+	assert.Equal(1, len(resource.Credit.Contributors))
+	assert.Equal("Ada Lovelace", resource.Credit.Contributors[0].Name)
+	assert.Equal("ORCID:0000-0001-2345-6789", resource.Credit.Contributors[0].ContributorId)
+	assert.Equal(2, len(resource.Credit.RelatedIdentifiers))
+	assert.Equal("OSF:ab3cd", resource.Credit.RelatedIdentifiers[0].Id)
+	assert.Equal("DOI:10.17605/OSF.IO/AB3CD", resource.Credit.RelatedIdentifiers[1].Id)
+
+	// pages can end before the last file
+	results, err = db.Search(databases.SearchParameters{
+		Query:      "ab3cd",
+		Pagination: databases.SearchPaginationParameters{Offset: 0, MaxNum: 1},
+	})
+	assert.Nil(err)
+	assert.Equal(1, len(results.Resources))
+	assert.True(results.HasMore)
+}
+
+func TestSearchWithInvalidParameters(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	_, err := db.Search(databases.SearchParameters{})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+
+	_, err = db.Search(databases.SearchParameters{Query: "zz9zz"})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+}
+
+func TestResources(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestDatabase()
+
+	resources, err := db.Resources([]string{"OSF:5f0a1b2c3d4e5f6a7b8c9d10"})
+	assert.Nil(err)
+	assert.Equal(1, len(resources))
+	assert.Equal("OSF:5f0a1b2c3d4e5f6a7b8c9d10", resources[0].Id)
+	assert.Equal("reads.fastq", resources[0].Name)
+	assert.Equal("ab3cd/providers/osfstorage/5f0a1b2c3d4e5f6a7b8c9d10", resources[0].Path)
+	assert.Equal("Soil microbiome survey", resources[0].Credit.Titles[0].Title)
+
+	_, err = db.Resources([]string{"OSF:000000000000000000000000"})
+	assert.Equal(databases.ResourceNotFoundError{
+		Database:   "osf",
+		ResourceId: "OSF:000000000000000000000000",
+	}, err)
+	_, err = db.Resources([]string{"ENA:ERR164408.fastq.gz"})
+	assert.IsType(databases.ResourceNotFoundError{}, err)
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	assert := assert.New(t)
+
+	// requests are anonymous without a token
+	t.Setenv("DTS_OSF_TOKEN", "")
+	db := newTestDatabase()
+	authHeaders = nil
+	_, err := db.Resources([]string{"OSF:5f0a1b2c3d4e5f6a7b8c9d10"})
+	assert.Nil(err)
+	for _, header := range authHeaders {
+		assert.Equal("", header)
+	}
+
+	// ...and carry it otherwise
+	t.Setenv("DTS_OSF_TOKEN", "osf-personal-access-token")
+	db = newTestDatabase()
+	assert.Equal("osf-personal-access-token", db.Token)
+	authHeaders = nil
+	_, err = db.Resources([]string{"OSF:5f0a1b2c3d4e5f6a7b8c9d10"})
+	assert.Nil(err)
+	assert.NotEmpty(authHeaders)
+	for _, header := range authHeaders {
+		assert.Equal("Bearer osf-personal-access-token", header)
+	}
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
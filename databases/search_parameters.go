@@ -0,0 +1,96 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"slices"
+	"strings"
+)
+
+// describes a database-specific search parameter in a form clients can use
+// without inspecting Go types
+type SearchParameterSpec struct {
+	// parameter name
+	Name string `json:"name"`
+	// value type ("string", "integer", "number", or "boolean")
+	Type string `json:"type"`
+	// accepted values, if the parameter is selected from a list
+	AllowedValues []interface{} `json:"allowed_values,omitempty"`
+	// a brief description of the parameter
+	Description string `json:"description,omitempty"`
+	// true if every search must supply the parameter
+	Required bool `json:"required"`
+}
+
+// A database can implement this interface to describe its specific search
+// parameters. Names and types come from SpecificSearchParameters, so only the
+// Description and Required fields of the returned specs are used.
+type SearchParameterDescriber interface {
+	// returns descriptions of specific search parameters, keyed by name
+	SearchParameterDescriptions() map[string]SearchParameterSpec
+}
+
+// returns a schema for the given database's specific search parameters,
+// sorted by name, or an empty slice if it has none
+func SearchParameterSchema(db Database) []SearchParameterSpec {
+	params := db.SpecificSearchParameters()
+	var descriptions map[string]SearchParameterSpec
+	if describer, ok := db.(SearchParameterDescriber); ok {
+		descriptions = describer.SearchParameterDescriptions()
+	}
+
+	schema := make([]SearchParameterSpec, 0, len(params))
+	for name, value := range params {
+		spec := SearchParameterSpec{
+			Name:        name,
+			Description: descriptions[name].Description,
+			Required:    descriptions[name].Required,
+		}
+		switch val := value.(type) {
+		case int:
+			spec.Type = "integer"
+		case float64:
+			spec.Type = "number"
+		case bool:
+			spec.Type = "boolean"
+		case string:
+			spec.Type = "string"
+		case []string:
+			spec.Type = "string"
+			for _, v := range val {
+				spec.AllowedValues = append(spec.AllowedValues, v)
+			}
+		case []int:
+			spec.Type = "integer"
+			for _, v := range val {
+				spec.AllowedValues = append(spec.AllowedValues, v)
+			}
+		default:
+			continue // unsupported type
+		}
+		schema = append(schema, spec)
+	}
+	slices.SortFunc(schema, func(a, b SearchParameterSpec) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return schema
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"fmt"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// checks that each of the given resources from the given database has the
+// fields required of a Frictionless DataResource (a name and a path) with
+// sensible values, returning an InvalidResourceError describing the first
+// problem found
+func ValidateResources(dbName string, resources []frictionless.DataResource) error {
+	for _, resource := range resources {
+		err := validateResource(resource)
+		if err != nil {
+			return InvalidResourceError{
+				Database:   dbName,
+				ResourceId: resource.Id,
+				Message:    err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+func validateResource(resource frictionless.DataResource) error {
+	if resource.Id == "" {
+		return fmt.Errorf("no id")
+	}
+	if resource.Name == "" {
+		return fmt.Errorf("no name")
+	}
+	if resource.Path == "" {
+		return fmt.Errorf("no path")
+	}
+	if resource.Bytes < 0 {
+		return fmt.Errorf("negative size (%d bytes)", resource.Bytes)
+	}
+	return nil
+}
@@ -23,6 +23,7 @@
 package dtstest
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -35,14 +36,16 @@ import (
 	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/logging"
 )
 
-// Enables DEBUG log messages for DTS's structured log (slog).
+// Enables DEBUG log messages for DTS's structured log (slog), redacting the
+// values of sensitive fields by default.
 func EnableDebugLogging() {
 	logLevel := new(slog.LevelVar)
 	logLevel.Set(slog.LevelDebug)
 	h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})
-	slog.SetDefault(slog.New(h))
+	slog.SetDefault(slog.New(logging.NewRedactingHandler(h, logging.DefaultRedactedFields)))
 }
 
 // Given a specified configuration that has been initialized, this function
@@ -82,8 +85,10 @@ Did you call config.Init()?`)
 //------------------------
 
 type transferInfo struct {
-	Time   time.Time // transfer initiation time
-	Status endpoints.TransferStatus
+	Time        time.Time // transfer initiation time
+	Status      endpoints.TransferStatus
+	Destination endpoints.Endpoint       // destination endpoint
+	Files       []endpoints.FileTransfer // "files" being transferred
 }
 
 // This type contains options for Endpoint test fixtures
@@ -92,6 +97,15 @@ type EndpointOptions struct {
 	StagingDuration time.Duration
 	// time it takes to "transfer files"
 	TransferDuration time.Duration
+	// number of "file transfers" that fail (after TransferDuration) before
+	// transfers begin to succeed
+	FailedTransfers int
+	// error messages for "files" whose transfers fail, keyed by source path
+	// (the rest of the files in such a transfer are transferred)
+	FailedFiles map[string]string
+	// MD5 checksums reported for "files" at the endpoint, keyed by path
+	// (relative to the endpoint's root)
+	Checksums map[string]string
 }
 
 // This type implements an Endpoint test fixture
@@ -102,6 +116,8 @@ type Endpoint struct {
 	Options EndpointOptions
 	// a table of ongoing "file transfers"
 	Xfers map[uuid.UUID]transferInfo
+	// paths of "files" transferred to the endpoint (relative to its root)
+	Files map[string]bool
 	// root path
 	RootPath string
 }
@@ -115,6 +131,7 @@ func RegisterEndpoint(endpointName string, options EndpointOptions) error {
 		return &Endpoint{
 			Options:  options,
 			Xfers:    make(map[uuid.UUID]transferInfo),
+			Files:    make(map[string]bool),
 			RootPath: config.Endpoints[endpointName].Root,
 		}, nil
 	}
@@ -162,15 +179,39 @@ func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTrans
 			NumFiles:            len(files),
 			NumFilesTransferred: 0,
 		},
+		Destination: dst,
+		Files:       files,
 	}
 	return xferId, nil
 }
 
 func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 	if info, found := ep.Xfers[id]; found {
-		if info.Status.Code != endpoints.TransferStatusSucceeded &&
+		if info.Status.Code == endpoints.TransferStatusActive &&
 			time.Now().Sub(info.Time) >= ep.Options.TransferDuration { // update if needed
-			info.Status.Code = endpoints.TransferStatusSucceeded
+			if ep.Options.FailedTransfers > 0 {
+				ep.Options.FailedTransfers--
+				info.Status.Code = endpoints.TransferStatusFailed
+				info.Status.Message = "simulated transfer failure"
+			} else {
+				info.Status.Code = endpoints.TransferStatusSucceeded
+				dst, _ := info.Destination.(*Endpoint)
+				for _, file := range info.Files {
+					if message, failed := ep.Options.FailedFiles[file.SourcePath]; failed {
+						if info.Status.FailedFiles == nil {
+							info.Status.FailedFiles = make(map[string]string)
+						}
+						info.Status.FailedFiles[file.SourcePath] = message
+						info.Status.Code = endpoints.TransferStatusFailed
+						info.Status.Message = "simulated file transfer failure"
+						continue
+					}
+					info.Status.NumFilesTransferred++
+					if dst != nil {
+						dst.Files[file.DestinationPath] = true
+					}
+				}
+			}
 			ep.Xfers[id] = info
 		}
 		return info.Status, nil
@@ -178,10 +219,52 @@ func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 	return endpoints.TransferStatus{}, fmt.Errorf("Invalid transfer ID: %s", id.String())
 }
 
+// cancels the "file transfer" with the given ID, which fails if it hasn't
+// already finished
 func (ep *Endpoint) Cancel(id uuid.UUID) error {
+	if info, found := ep.Xfers[id]; found && info.Status.Code == endpoints.TransferStatusActive {
+		info.Status.Code = endpoints.TransferStatusFailed
+		info.Status.Message = "simulated transfer cancellation"
+		ep.Xfers[id] = info
+	}
 	return nil
 }
 
+// waits until all "file transfers" have had time to complete
+func (ep *Endpoint) Drain(ctx context.Context) error {
+	var latest time.Time
+	for _, info := range ep.Xfers {
+		if finish := info.Time.Add(ep.Options.TransferDuration); finish.After(latest) {
+			latest = finish
+		}
+	}
+	select {
+	case <-time.After(time.Until(latest)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reports the MD5 checksum assigned to the "file" at the given path
+func (ep *Endpoint) MD5Sum(path string) (string, error) {
+	if checksum, found := ep.Options.Checksums[path]; found {
+		return checksum, nil
+	}
+	return "", fmt.Errorf("No checksum available for %s", path)
+}
+
+// reports the "files" that have been transferred to the endpoint
+func (ep *Endpoint) ExistingFiles(files []frictionless.DataResource) ([]string, error) {
+	existing := make([]string, 0)
+	for _, file := range files {
+		if ep.Files[file.Path] {
+			existing = append(existing, file.Id)
+		}
+	}
+	return existing, nil
+}
+
 //------------------------
 // Database Test Fixtures
 //------------------------
@@ -269,6 +352,11 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 	return databases.StagingStatusUnknown, nil
 }
 
+func (db *Database) CancelStaging(id uuid.UUID) error {
+	delete(db.Staging, id)
+	return nil
+}
+
 func (db *Database) Endpoint() (endpoints.Endpoint, error) {
 	return db.Endpt, nil
 }
@@ -284,3 +372,43 @@ func (db *Database) Save() (databases.DatabaseSaveState, error) {
 func (db *Database) Load(state databases.DatabaseSaveState) error {
 	return nil
 }
+
+// This type implements a databases.PrefixDatabase test fixture, whose file IDs
+// are paths
+type PrefixDatabase struct {
+	Database
+}
+
+// Registers a database test fixture with the given name in the configuration
+// whose files can be requested by a common prefix.
+func RegisterPrefixDatabase(databaseName string, resources map[string]frictionless.DataResource) error {
+	slog.Debug(fmt.Sprintf("Registering test prefix database %s...", databaseName))
+	newDatabaseFunc := func(orcid string) (databases.Database, error) {
+		endpoint, err := endpoints.NewEndpoint(config.Databases[databaseName].Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		db := PrefixDatabase{
+			Database: Database{
+				Endpt:     endpoint,
+				resources: resources,
+				Staging:   make(map[uuid.UUID]stagingRequest),
+			},
+		}
+		if testEndpoint, isTestEndpoint := db.Endpt.(*Endpoint); isTestEndpoint {
+			testEndpoint.Database = &db.Database
+		}
+		return &db, nil
+	}
+	return databases.RegisterDatabase(databaseName, newDatabaseFunc)
+}
+
+func (db *PrefixDatabase) FileIdsWithPrefix(prefix string) ([]string, error) {
+	fileIds := make([]string, 0)
+	for fileId := range db.resources {
+		if strings.HasPrefix(fileId, prefix) {
+			fileIds = append(fileIds, fileId)
+		}
+	}
+	return fileIds, nil
+}
@@ -22,7 +22,10 @@
 package endpoints
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/google/uuid"
 
@@ -64,6 +67,9 @@ type TransferStatus struct {
 	NumFilesTransferred int
 	// number of files that are skipped for whatever reason
 	NumFilesSkipped int
+	// error messages for individual files that failed to transfer, keyed by
+	// source path (for endpoints that report them)
+	FailedFiles map[string]string
 }
 
 // This type represents an endpoint for transferring files.
@@ -85,8 +91,84 @@ type Endpoint interface {
 	Cancel(id uuid.UUID) error
 }
 
-// we maintain a table of endpoint instances, identified by their names
+// provider-specific options for an individual transfer, given as key-value
+// pairs (e.g. "sync_level": 3) that override an endpoint's defaults
+type TransferOptions map[string]any
+
+// This type represents an endpoint that accepts provider-specific options for
+// individual transfers.
+type ConfigurableEndpoint interface {
+	Endpoint
+	// returns an InvalidTransferOptionError if any of the given options isn't
+	// supported by the endpoint or has an invalid value, nil otherwise
+	ValidateTransferOptions(options TransferOptions) error
+	// like Transfer, but with the given options overriding the endpoint's
+	// defaults
+	TransferWithOptions(dst Endpoint, files []FileTransfer, options TransferOptions) (uuid.UUID, error)
+}
+
+// This type represents an endpoint whose configuration determines whether the
+// data it moves is encrypted in transit (e.g. one reached through a URL that
+// may or may not use TLS).
+type EncryptableEndpoint interface {
+	Endpoint
+	// returns true if data moved to or from the endpoint is encrypted in transit
+	EncryptsInTransit() bool
+}
+
+// This type represents an endpoint that moves files within the DTS process
+// itself (e.g. by copying or downloading them), so that its transfers are cut
+// off if the service stops.
+type DrainableEndpoint interface {
+	Endpoint
+	// blocks until all transfers in progress have finished, returning the given
+	// context's error if it's done first
+	Drain(ctx context.Context) error
+}
+
+// This type represents an endpoint that can cheaply check whether it's
+// reachable and usable (e.g. by querying the service that provides it).
+type CheckableEndpoint interface {
+	Endpoint
+	// returns an error describing any problem that prevents the endpoint from
+	// being used, nil otherwise
+	CheckHealth() error
+}
+
+// This type represents an endpoint that can report the sizes (and possibly
+// checksums) of the files it hosts, so staged files can be checked against
+// their descriptors.
+type VerifiableEndpoint interface {
+	Endpoint
+	// returns the IDs of those of the given resources whose files are present
+	// at the endpoint but don't match their sizes (or, where the endpoint can
+	// compute them, their hashes)
+	MismatchedFiles(files []frictionless.DataResource) ([]string, error)
+}
+
+// This type represents an endpoint that can compute the MD5 checksums of the
+// files it hosts, so transferred files can be checked against their sources.
+type HashableEndpoint interface {
+	Endpoint
+	// returns the hex-encoded MD5 checksum of the file at the given path
+	// (relative to the endpoint's root)
+	MD5Sum(path string) (string, error)
+}
+
+// This type represents an endpoint that can report which of a set of files it
+// already hosts, so transfers to it can skip them.
+type IncrementalEndpoint interface {
+	Endpoint
+	// returns the IDs of those of the given resources whose files are present
+	// at the endpoint and match their sizes (and, where the endpoint can
+	// compute them, their hashes)
+	ExistingFiles(files []frictionless.DataResource) ([]string, error)
+}
+
+// we maintain a table of endpoint instances, identified by their names, which
+// is accessed concurrently by request handlers and the task processor
 var allEndpoints map[string]Endpoint = make(map[string]Endpoint)
+var allEndpointsMutex sync.Mutex
 
 // here's a table of endpoint creation functions
 var createEndpointFuncs = make(map[string]func(name string) (Endpoint, error))
@@ -102,11 +184,24 @@ func RegisterEndpointProvider(provider string, createEp func(name string) (Endpo
 	}
 }
 
+// returns the names of all registered endpoint providers in sorted order
+func Providers() []string {
+	providers := make([]string, 0, len(createEndpointFuncs))
+	for provider := range createEndpointFuncs {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
 // creates an endpoint based on the configured type, or returns an existing
 // instance
 func NewEndpoint(endpointName string) (Endpoint, error) {
 	var err error
 
+	allEndpointsMutex.Lock()
+	defer allEndpointsMutex.Unlock()
+
 	// do we have one of these already?
 	endpoint, found := allEndpoints[endpointName]
 	if !found {
@@ -131,3 +226,112 @@ func NewEndpoint(endpointName string) (Endpoint, error) {
 	}
 	return endpoint, err
 }
+
+// checks whether the given transfer options are supported by the endpoint
+// with the given name, returning an InvalidTransferOptionError if not
+func ValidateTransferOptions(endpointName string, options TransferOptions) error {
+	if len(options) == 0 {
+		return nil
+	}
+	endpoint, err := NewEndpoint(endpointName)
+	if err != nil {
+		return err
+	}
+	if configurable, ok := endpoint.(ConfigurableEndpoint); ok {
+		return configurable.ValidateTransferOptions(options)
+	}
+	names := make([]string, 0, len(options))
+	for option := range options {
+		names = append(names, option)
+	}
+	sort.Strings(names)
+	return InvalidTransferOptionError{
+		Name:    endpointName,
+		Option:  names[0],
+		Message: "the endpoint does not accept transfer options",
+	}
+}
+
+// blocks until the transfers in progress for all drainable endpoints have
+// finished, returning the given context's error if it's done first
+func Drain(ctx context.Context) error {
+	allEndpointsMutex.Lock()
+	endpointList := make([]Endpoint, 0, len(allEndpoints))
+	for _, endpoint := range allEndpoints {
+		endpointList = append(endpointList, endpoint)
+	}
+	allEndpointsMutex.Unlock()
+
+	for _, endpoint := range endpointList {
+		if drainable, ok := endpoint.(DrainableEndpoint); ok {
+			err := drainable.Drain(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waits for the transfers tracked by the given wait group to finish, returning
+// the given context's error if it's done first (for use in implementing Drain)
+func WaitForTransfers(ctx context.Context, transfers *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		transfers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// returns an UnencryptedEndpointError if the endpoint with the given name moves
+// data over unencrypted connections, nil otherwise. Endpoints that don't report
+// on their transport are assumed to encrypt data in transit.
+func CheckEncryption(endpointName string) error {
+	endpoint, err := NewEndpoint(endpointName)
+	if err != nil {
+		return err
+	}
+	if encryptable, ok := endpoint.(EncryptableEndpoint); ok && !encryptable.EncryptsInTransit() {
+		return UnencryptedEndpointError{Name: endpointName}
+	}
+	return nil
+}
+
+// returns an UnhealthyEndpointError if the endpoint with the given name can't
+// be created or fails its health check, nil otherwise. Endpoints that can't
+// check their health are assumed to be healthy.
+func CheckHealth(endpointName string) error {
+	endpoint, err := NewEndpoint(endpointName)
+	if err != nil {
+		if _, notFound := err.(NotFoundError); notFound {
+			return err
+		}
+		return UnhealthyEndpointError{Name: endpointName, Message: err.Error()}
+	}
+	if checkable, ok := endpoint.(CheckableEndpoint); ok {
+		err = checkable.CheckHealth()
+		if err != nil {
+			return UnhealthyEndpointError{Name: endpointName, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// begins a transfer of the given files from the given source endpoint to the
+// given destination, applying the given options (if any)
+func TransferWithOptions(src, dst Endpoint, files []FileTransfer,
+	options TransferOptions) (uuid.UUID, error) {
+	if len(options) == 0 {
+		return src.Transfer(dst, files)
+	}
+	if configurable, ok := src.(ConfigurableEndpoint); ok {
+		return configurable.TransferWithOptions(dst, files, options)
+	}
+	return uuid.UUID{}, fmt.Errorf("The source endpoint does not accept transfer options")
+}
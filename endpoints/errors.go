@@ -43,3 +43,49 @@ func (e InvalidProviderError) Error() string {
 	return fmt.Sprintf("The endpoint '%s' has an invalid provider: '%s'.",
 		e.Name, e.Provider)
 }
+
+// indicates that an option given for a transfer is not supported by an
+// endpoint, or has an invalid value
+type InvalidTransferOptionError struct {
+	Name, Option, Message string
+}
+
+func (e InvalidTransferOptionError) Error() string {
+	return fmt.Sprintf("Invalid transfer option '%s' for endpoint '%s': %s",
+		e.Option, e.Name, e.Message)
+}
+
+// indicates that an endpoint moves data over unencrypted connections when
+// encryption in transit is required
+type UnencryptedEndpointError struct {
+	Name string
+}
+
+func (e UnencryptedEndpointError) Error() string {
+	return fmt.Sprintf("The endpoint '%s' does not encrypt data in transit, which is required for transfers.",
+		e.Name)
+}
+
+// indicates that an endpoint is unreachable or otherwise unusable
+type UnhealthyEndpointError struct {
+	Name, Message string
+}
+
+func (e UnhealthyEndpointError) Error() string {
+	return fmt.Sprintf("The endpoint '%s' is unhealthy: %s", e.Name, e.Message)
+}
+
+// indicates that a request to an endpoint failed transiently (e.g. because its
+// provider was briefly unavailable), so that it may succeed if made again later
+type TransientError struct {
+	Name string
+	Err  error
+}
+
+func (e TransientError) Error() string {
+	return fmt.Sprintf("The endpoint '%s' failed transiently: %s", e.Name, e.Err.Error())
+}
+
+func (e TransientError) Unwrap() error {
+	return e.Err
+}
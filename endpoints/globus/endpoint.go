@@ -23,14 +23,22 @@ package globus
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -54,12 +62,53 @@ type GlobusError struct {
 
 	// ConsentRequired error field
 	RequiredScopes []string `json:"required_scopes"`
+
+	// HTTP status code of the response carrying the error (if any)
+	StatusCode int `json:"-"`
 }
 
 func (e GlobusError) Error() string {
 	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
 }
 
+// returns true if the error indicates that Globus rejected our credentials
+func (e GlobusError) authorizationFailed() bool {
+	return e.Code == "ConsentRequired" || e.Code == "AuthenticationFailed"
+}
+
+// returns true if the error indicates a transient failure on the part of
+// Globus, such that the request that produced it may succeed if retried
+func (e GlobusError) transient() bool {
+	return e.StatusCode >= http.StatusInternalServerError ||
+		e.StatusCode == http.StatusTooManyRequests
+}
+
+// this error type is returned when Globus rejects a request even after the
+// endpoint has reauthenticated, which requires attention from an administrator
+// (e.g. to grant a missing consent) and is never retried
+type AuthorizationError struct {
+	Endpoint string
+	Err      *GlobusError
+}
+
+func (e AuthorizationError) Error() string {
+	return fmt.Sprintf("Globus endpoint %s is not authorized: %s", e.Endpoint, e.Err.Error())
+}
+
+func (e AuthorizationError) Unwrap() error {
+	return e.Err
+}
+
+// the default interval before an access token expires at which it is refreshed
+const defaultRefreshMargin = 15 * time.Minute
+
+// the default number of retries for transiently failing transfer submissions
+// and status checks, and the minimum interval before the first of them
+const (
+	defaultMaxRetries    = 3
+	defaultRetryInterval = time.Second
+)
+
 // this type satisfies the endpoints.Endpoint interface for Globus endpoints
 type Endpoint struct {
 	// descriptive endpoint name (obtained from config)
@@ -72,12 +121,43 @@ type Endpoint struct {
 	Client http.Client
 	// OAuth2 access token
 	AccessToken string
+	// time at which the access token expires (zero if unknown)
+	TokenExpiration time.Time
+	// interval before the access token expires at which it is refreshed
+	RefreshMargin time.Duration
 	// access scopes
 	Scopes []string
+	// number of times a transiently failing transfer submission or status
+	// check is retried (0 disables retries)
+	MaxRetries int
+	// minimum interval before the first retry, which doubles with each retry
+	RetryInterval time.Duration
 
 	// authentication stuff
 	ClientId     uuid.UUID
 	ClientSecret string
+
+	// guards the access token, its expiration, and scopes during (re)authentication
+	authMutex sync.Mutex
+
+	// guards retry states and submission IDs
+	retryMutex sync.Mutex
+	// states of transiently failing requests, keyed by operation
+	retries map[string]retryState
+	// submission IDs of transfers not yet accepted by Globus, keyed by the
+	// transfers' content, so retried submissions reuse them
+	submissionIds map[string]uuid.UUID
+}
+
+// the state of a transiently failing request, which is retried when it's next
+// made (e.g. at the next poll) after its retry interval has elapsed
+type retryState struct {
+	// number of failed attempts so far
+	Attempts int
+	// time before which the request isn't attempted again
+	NextAttempt time.Time
+	// error from the last attempt
+	Err error
 }
 
 // creates a new Globus endpoint using the information supplied in the
@@ -93,11 +173,23 @@ func NewEndpoint(endpointName string) (endpoints.Endpoint, error) {
 
 	defaultScopes := []string{"urn:globus:auth:scope:transfer.api.globus.org:all"}
 	ep := &Endpoint{
-		Name:         epConfig.Name,
-		Id:           epConfig.Id,
-		Scopes:       defaultScopes,
-		ClientId:     epConfig.Auth.ClientId,
-		ClientSecret: epConfig.Auth.ClientSecret,
+		Name:          epConfig.Name,
+		Id:            epConfig.Id,
+		Scopes:        defaultScopes,
+		ClientId:      epConfig.Auth.ClientId,
+		ClientSecret:  epConfig.Auth.ClientSecret,
+		RefreshMargin: time.Duration(epConfig.Auth.RefreshMargin) * time.Second,
+		MaxRetries:    defaultMaxRetries,
+		RetryInterval: time.Duration(epConfig.RetryInterval) * time.Millisecond,
+	}
+	if ep.RefreshMargin == 0 {
+		ep.RefreshMargin = defaultRefreshMargin
+	}
+	if epConfig.MaxRetries != nil {
+		ep.MaxRetries = *epConfig.MaxRetries
+	}
+	if ep.RetryInterval == 0 {
+		ep.RetryInterval = defaultRetryInterval
 	}
 
 	// if needed, authenticate to obtain a Globus Transfer API access token
@@ -147,15 +239,12 @@ func (ep *Endpoint) FilesStaged(files []frictionless.DataResource) (bool, error)
 		resource := fmt.Sprintf("operation/endpoint/%s/ls", ep.Id.String())
 		body, err := ep.get(resource, values)
 		if err != nil {
-			globusErr := err.(*GlobusError)
-			switch globusErr.Code {
-			case "ClientError.NotFound":
+			if globusErr, ok := err.(*GlobusError); ok && globusErr.Code == "ClientError.NotFound" {
 				// it's okay if the directory doesn't exist -- it might need to be staged
 				return false, nil
-			default:
-				// propagate the error
-				return false, err
 			}
+			// propagate the error
+			return false, err
 		}
 
 		// https://docs.globus.org/api/transfer/file_operations/#dir_listing_response
@@ -182,6 +271,110 @@ func (ep *Endpoint) FilesStaged(files []frictionless.DataResource) (bool, error)
 	return true, nil
 }
 
+// reports files whose sizes (as listed by the Transfer API) differ from those
+// of their resources
+func (ep *Endpoint) MismatchedFiles(files []frictionless.DataResource) ([]string, error) {
+	sizes, err := ep.listedSizes(files)
+	if err != nil {
+		return nil, err
+	}
+	mismatched := make([]string, 0)
+	for _, resource := range files {
+		size, present := sizes[resource.Id]
+		if present && size != resource.Bytes {
+			mismatched = append(mismatched, resource.Id)
+		}
+	}
+	return mismatched, nil
+}
+
+// reports files whose sizes (as listed by the Transfer API) match those of
+// their resources
+func (ep *Endpoint) ExistingFiles(files []frictionless.DataResource) ([]string, error) {
+	sizes, err := ep.listedSizes(files)
+	if err != nil {
+		return nil, err
+	}
+	existing := make([]string, 0)
+	for _, resource := range files {
+		size, present := sizes[resource.Id]
+		if present && size == resource.Bytes {
+			existing = append(existing, resource.Id)
+		}
+	}
+	return existing, nil
+}
+
+// returns a map of the IDs of those of the given resources whose files are
+// present at the endpoint to the sizes listed for them by the Transfer API
+func (ep *Endpoint) listedSizes(files []frictionless.DataResource) (map[string]int, error) {
+	// group the resources by the directories in which their files reside
+	resourcesInDir := make(map[string][]frictionless.DataResource)
+	for _, resource := range files {
+		dir := filepath.Join(ep.RootDir, filepath.Dir(resource.Path))
+		resourcesInDir[dir] = append(resourcesInDir[dir], resource)
+	}
+
+	// list each directory and record the sizes of the files within
+	// (https://docs.globus.org/api/transfer/file_operations/#list_directory_contents)
+	listedSizes := make(map[string]int)
+	for dir, resources := range resourcesInDir {
+		values := url.Values{}
+		values.Add("path", dir)
+		resource := fmt.Sprintf("operation/endpoint/%s/ls", ep.Id.String())
+		body, err := ep.get(resource, values)
+		if err != nil {
+			if globusErr, ok := err.(*GlobusError); ok && globusErr.Code == "ClientError.NotFound" {
+				continue // no files present
+			}
+			return nil, err
+		}
+		type DirListingResponse struct {
+			Data []struct {
+				Name string `json:"name"`
+				Size int    `json:"size"`
+			} `json:"DATA"`
+		}
+		var response DirListingResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, err
+		}
+		sizes := make(map[string]int)
+		for _, data := range response.Data {
+			sizes[data.Name] = data.Size
+		}
+		for _, resource := range resources {
+			if size, present := sizes[filepath.Base(resource.Path)]; present {
+				listedSizes[resource.Id] = size
+			}
+		}
+	}
+	return listedSizes, nil
+}
+
+// the endpoint is healthy if the Transfer API can retrieve it and reports that
+// it's activated
+func (ep *Endpoint) CheckHealth() error {
+	// https://docs.globus.org/api/transfer/endpoints_and_collections/#get_endpoint_or_collection_by_id
+	body, err := ep.get(fmt.Sprintf("endpoint/%s", ep.Id.String()), url.Values{})
+	if err != nil {
+		return err
+	}
+	type EndpointResponse struct {
+		Activated bool `json:"activated"`
+	}
+	var response EndpointResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return err
+	}
+	if !response.Activated {
+		return fmt.Errorf("endpoint %s is not activated", ep.Id.String())
+	}
+	return nil
+}
+
 func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 	// https://docs.globus.org/api/transfer/task/#get_task_list
 	values := url.Values{}
@@ -214,20 +407,162 @@ func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 }
 
 func (ep *Endpoint) Transfer(destination endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	return ep.TransferWithOptions(destination, files, nil)
+}
+
+// options for Globus transfer tasks, with their default values
+// (see https://docs.globus.org/api/transfer/task_submit/#transfer_specific_fields)
+type transferOptions struct {
+	SyncLevel         int  // transfer only files that differ at this level
+	VerifyChecksum    bool // verify checksums after transfer
+	PreserveTimestamp bool // preserve file modification times
+	EncryptData       bool // encrypt data in transit
+}
+
+var defaultTransferOptions = transferOptions{
+	SyncLevel:      3, // transfer only if checksums don't match
+	VerifyChecksum: true,
+}
+
+// names for Globus sync levels
+var syncLevelsForNames = map[string]int{
+	"exists":   0,
+	"size":     1,
+	"mtime":    2,
+	"checksum": 3,
+}
+
+// overrides the default transfer options with the given ones, returning an
+// error if any of them are unsupported or have invalid values
+func (ep *Endpoint) transferOptions(options endpoints.TransferOptions) (transferOptions, error) {
+	xferOptions := defaultTransferOptions
+	for name, value := range options {
+		var ok bool
+		switch name {
+		case "sync_level":
+			switch v := value.(type) {
+			case string:
+				xferOptions.SyncLevel, ok = syncLevelsForNames[v]
+			case float64: // from JSON
+				xferOptions.SyncLevel, ok = int(v), v == float64(int(v)) && v >= 0 && v <= 3
+			case int:
+				xferOptions.SyncLevel, ok = v, v >= 0 && v <= 3
+			}
+			if !ok {
+				return xferOptions, endpoints.InvalidTransferOptionError{
+					Name:    ep.Name,
+					Option:  name,
+					Message: "must be 0-3 or one of exists, size, mtime, checksum",
+				}
+			}
+		case "verify_checksum", "preserve_timestamp", "encrypt_data":
+			var flag bool
+			flag, ok = value.(bool)
+			if !ok {
+				return xferOptions, endpoints.InvalidTransferOptionError{
+					Name:    ep.Name,
+					Option:  name,
+					Message: "must be true or false",
+				}
+			}
+			switch name {
+			case "verify_checksum":
+				xferOptions.VerifyChecksum = flag
+			case "preserve_timestamp":
+				xferOptions.PreserveTimestamp = flag
+			default:
+				xferOptions.EncryptData = flag
+			}
+		default:
+			return xferOptions, endpoints.InvalidTransferOptionError{
+				Name:    ep.Name,
+				Option:  name,
+				Message: "unsupported by Globus endpoints",
+			}
+		}
+	}
+
+	// data is always encrypted in transit if the service requires it
+	if config.Service.RequireEncryption {
+		if !xferOptions.EncryptData && options["encrypt_data"] != nil {
+			return xferOptions, endpoints.InvalidTransferOptionError{
+				Name:    ep.Name,
+				Option:  "encrypt_data",
+				Message: "must be true (encryption in transit is required)",
+			}
+		}
+		xferOptions.EncryptData = true
+	}
+	return xferOptions, nil
+}
+
+func (ep *Endpoint) ValidateTransferOptions(options endpoints.TransferOptions) error {
+	_, err := ep.transferOptions(options)
+	return err
+}
+
+func (ep *Endpoint) TransferWithOptions(destination endpoints.Endpoint,
+	files []endpoints.FileTransfer, options endpoints.TransferOptions) (uuid.UUID, error) {
+	xferOptions, err := ep.transferOptions(options)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
 	// NOTE: We don't check whether files are staged here, because the endpoint
 	// NOTE: itself doesn't always have a reliable staging check (e.g. JDP's
 	// NOTE: private data is invisible to Globus directory listings).
 	// NOTE: Consequently, we assume that files are staged by the time this
 	// NOTE: function is called.
 
-	// obtain a submission ID
-	submissionId, err := ep.getSubmissionId()
-	if err != nil {
-		return uuid.UUID{}, err
+	// obtain a submission ID, reusing the one from any earlier attempt to
+	// submit this transfer (Globus accepts a given submission ID only once, so
+	// a retried submission can't start a second transfer)
+	key := transferKey(destination, files)
+	ep.retryMutex.Lock()
+	submissionId, found := ep.submissionIds[key]
+	ep.retryMutex.Unlock()
+	if !found {
+		err = ep.retry("submission ID request", "submission_id/"+key, func() error {
+			submissionId, err = ep.getSubmissionId()
+			return err
+		})
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		ep.retryMutex.Lock()
+		if ep.submissionIds == nil {
+			ep.submissionIds = make(map[string]uuid.UUID)
+		}
+		ep.submissionIds[key] = submissionId
+		ep.retryMutex.Unlock()
 	}
 
 	// now, submit the transfer task itself
-	return ep.submitTransfer(destination, submissionId, files)
+	var xferId uuid.UUID
+	err = ep.retry("transfer submission", "transfer/"+key, func() error {
+		xferId, err = ep.submitTransfer(destination, submissionId, files, xferOptions)
+		return err
+	})
+	var transient endpoints.TransientError
+	if !errors.As(err, &transient) { // the submission ID is spent
+		ep.retryMutex.Lock()
+		delete(ep.submissionIds, key)
+		ep.retryMutex.Unlock()
+	}
+	return xferId, err
+}
+
+// returns a key identifying the transfer of the given files to the given
+// destination, which is the same for every attempt to submit it
+func transferKey(destination endpoints.Endpoint, files []endpoints.FileTransfer) string {
+	hash := sha256.New()
+	if gDestination, ok := destination.(*Endpoint); ok {
+		hash.Write(gDestination.Id[:])
+	}
+	for _, file := range files {
+		fmt.Fprintf(hash, "%s\x00%s\x00", file.SourcePath, file.DestinationPath)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
 }
 
 // mapping of Globus status code strings to DTS status codes
@@ -240,7 +575,12 @@ var statusCodesForStrings = map[string]endpoints.TransferStatusCode{
 
 func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 	resource := fmt.Sprintf("task/%s", id.String())
-	body, err := ep.get(resource, url.Values{})
+	var body []byte
+	err := ep.retry("status check", resource, func() error {
+		var err error
+		body, err = ep.get(resource, url.Values{})
+		return err
+	})
 	if err != nil {
 		return endpoints.TransferStatus{}, err
 	}
@@ -356,15 +696,74 @@ func (ep *Endpoint) Cancel(id uuid.UUID) error {
 	return err
 }
 
+// returns Frictionless DataResources describing the files in the given
+// directory (relative to the endpoint's root) and all of its subdirectories.
+// Each resource's ID and path is the file's path relative to the root.
+// Globus directory listings don't include checksums, so these resources have
+// no hashes.
+func (ep *Endpoint) ListDirectory(dir string) ([]frictionless.DataResource, error) {
+	// https://docs.globus.org/api/transfer/file_operations/#dir_listing_response
+	type DirListingResponse struct {
+		Data []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+			Size int    `json:"size"`
+		} `json:"DATA"`
+	}
+
+	resources := make([]frictionless.DataResource, 0)
+	dirs := []string{dir}
+	for len(dirs) > 0 {
+		dir, dirs = dirs[0], dirs[1:]
+
+		// https://docs.globus.org/api/transfer/file_operations/#list_directory_contents
+		values := url.Values{}
+		values.Add("path", filepath.Join(ep.RootDir, dir))
+		values.Add("orderby", "name ASC")
+		resource := fmt.Sprintf("operation/endpoint/%s/ls", ep.Id.String())
+		body, err := ep.get(resource, values)
+		if err != nil {
+			return nil, err
+		}
+		var response DirListingResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range response.Data {
+			path := filepath.Join(dir, entry.Name)
+			switch entry.Type {
+			case "dir":
+				dirs = append(dirs, path)
+			case "file":
+				ext := filepath.Ext(entry.Name)
+				resources = append(resources, frictionless.DataResource{
+					Id:        path,
+					Name:      strings.TrimSuffix(entry.Name, ext),
+					Path:      path,
+					Format:    strings.TrimPrefix(ext, "."),
+					MediaType: mime.TypeByExtension(ext),
+					Bytes:     entry.Size,
+				})
+			}
+		}
+	}
+	return resources, nil
+}
+
 //-----------
 // Internals
 //-----------
 
+// matches the codes of a successful transfer submission result: Accepted, or
+// Duplicate for a submission whose ID Globus has already accepted
+var submissionResultCode = regexp.MustCompile(`"code"\s*:\s*"(Accepted|Duplicate)"`)
+
 // returns true if a Globus response body matches an error
 func responseIsError(body []byte) bool {
 	bodyStr := string(body)
 	return strings.Contains(bodyStr, "\"code\"") &&
-		!strings.Contains(bodyStr, "\"code\": \"Accepted\"") &&
+		!submissionResultCode.MatchString(bodyStr) &&
 		strings.Contains(string(body), "\"message\"")
 }
 
@@ -372,6 +771,36 @@ func responseIsError(body []byte) bool {
 // access token with consents for its relevant list of scopes
 // (https://docs.globus.org/api/auth/reference/#client_credentials_grant)
 func (ep *Endpoint) authenticate() error {
+	ep.authMutex.Lock()
+	defer ep.authMutex.Unlock()
+	return ep.requestAccessToken()
+}
+
+// obtains a new access token if the current one expires within the endpoint's
+// refresh margin. This is called before each request to the Transfer API, so
+// that a long-running transfer's status checks never outlive the token.
+func (ep *Endpoint) refreshAccessTokenIfExpiring() error {
+	ep.authMutex.Lock()
+	defer ep.authMutex.Unlock()
+	var zeroId uuid.UUID
+	if ep.ClientId == zeroId || ep.TokenExpiration.IsZero() ||
+		time.Until(ep.TokenExpiration) > ep.RefreshMargin {
+		return nil
+	}
+	slog.Debug(fmt.Sprintf("Endpoint %s: refreshing access token (expires %s)",
+		ep.Name, ep.TokenExpiration.Format(time.RFC3339)))
+	return ep.requestAccessToken()
+}
+
+// returns the endpoint's current access token
+func (ep *Endpoint) accessToken() string {
+	ep.authMutex.Lock()
+	defer ep.authMutex.Unlock()
+	return ep.AccessToken
+}
+
+// requests a new access token from Globus Auth (the caller must hold authMutex)
+func (ep *Endpoint) requestAccessToken() error {
 	authUrl := "https://auth.globus.org/v2/oauth2/token"
 	data := url.Values{}
 	data.Set("scope", strings.Join(ep.Scopes, "+"))
@@ -412,8 +841,13 @@ func (ep *Endpoint) authenticate() error {
 
 	// FIXME: check the scopes to see if they match our requested ones?
 
-	// stash the access token
+	// stash the access token and note when it expires
 	ep.AccessToken = authResponse.AccessToken
+	if authResponse.ExpiresIn > 0 {
+		ep.TokenExpiration = time.Now().Add(time.Duration(authResponse.ExpiresIn) * time.Second)
+	} else {
+		ep.TokenExpiration = time.Time{}
+	}
 
 	return nil
 }
@@ -422,47 +856,123 @@ func (ep *Endpoint) authenticate() error {
 // Globus-style error codes/messages and handling the ones that can be
 // handled automatically (e.g. consent/scope related errors). In any case,
 // it returns a byte slice containing the body of the response or an
-// error indicating failure.
+// error indicating failure. Errors that persist after reauthentication are
+// returned as AuthorizationErrors.
 func (ep *Endpoint) sendRequest(request *http.Request) ([]byte, error) {
 	// send the initial request and read its contents
+	body, err := ep.doRequest(request)
+	var globusErr *GlobusError
+	if errors.As(err, &globusErr) && globusErr.authorizationFailed() {
+		// our token has expired or we're missing a required scope,
+		// so reauthenticate
+		ep.authMutex.Lock()
+		if len(globusErr.RequiredScopes) > 0 {
+			ep.Scopes = globusErr.RequiredScopes
+		}
+		err = ep.requestAccessToken()
+		ep.authMutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		// try the request again with the new token
+		if request.GetBody != nil {
+			request.Body, err = request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ep.accessToken()))
+		body, err = ep.doRequest(request)
+		if errors.As(err, &globusErr) && globusErr.authorizationFailed() {
+			err = AuthorizationError{Endpoint: ep.Name, Err: globusErr}
+			slog.Error(err.Error())
+		}
+	}
+	return body, err
+}
+
+// sends the given HTTP request once, returning the body of the response, or
+// a GlobusError if the response carries a Globus-style error or indicates a
+// server-side failure
+func (ep *Endpoint) doRequest(request *http.Request) ([]byte, error) {
 	resp, err := ep.Client.Do(request)
 	if err != nil {
 		return nil, err
 	}
 	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
 	if err != nil {
 		return nil, err
 	}
-	resp.Body.Close()
-
-	// check the response for a Globus-style error code / message
 	if responseIsError(body) {
 		var errResp GlobusError
 		err = json.Unmarshal(body, &errResp)
 		if err != nil {
 			return nil, err
 		}
-		if errResp.Code == "ConsentRequired" || errResp.Code == "AuthenticationFailed" {
-			// our token has expired or we're missing a required scope,
-			// so reauthenticate
-			ep.Scopes = errResp.RequiredScopes
-			err = ep.authenticate()
-			if err != nil {
-				return nil, err
-			}
-			// try the request again
-			resp, err = ep.Client.Do(request)
-			if err != nil {
-				return nil, err
-			}
-			body, err = io.ReadAll(resp.Body)
-			resp.Body.Close()
-		} else {
-			// other errors are propagated
-			err = &errResp
+		errResp.StatusCode = resp.StatusCode
+		return nil, &errResp
+	}
+	if resp.StatusCode >= http.StatusInternalServerError ||
+		resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &GlobusError{
+			Code:       strings.ReplaceAll(http.StatusText(resp.StatusCode), " ", ""),
+			Message:    fmt.Sprintf("Globus responded with status %d", resp.StatusCode),
+			StatusCode: resp.StatusCode,
 		}
 	}
-	return body, err
+	return body, nil
+}
+
+// calls the given function to perform the operation with the given key,
+// unless an earlier attempt failed transiently and its retry interval (which
+// doubles with each failure) hasn't elapsed. Transient failures are returned as
+// endpoints.TransientErrors so the caller can try again later (e.g. at the
+// next poll) instead of waiting here; once the endpoint's retries are
+// exhausted, the error from the last attempt is returned as is.
+func (ep *Endpoint) retry(operation, key string, f func() error) error {
+	ep.retryMutex.Lock()
+	state, retrying := ep.retries[key]
+	ep.retryMutex.Unlock()
+	if retrying && time.Now().Before(state.NextAttempt) {
+		return endpoints.TransientError{Name: ep.Name, Err: state.Err}
+	}
+
+	err := f()
+
+	ep.retryMutex.Lock()
+	defer ep.retryMutex.Unlock()
+	if err == nil || !isTransient(err) || state.Attempts >= ep.MaxRetries {
+		delete(ep.retries, key)
+		return err
+	}
+	interval := ep.RetryInterval << state.Attempts
+	state.Attempts++
+	state.NextAttempt = time.Now().Add(interval)
+	state.Err = err
+	if ep.retries == nil {
+		ep.retries = make(map[string]retryState)
+	}
+	ep.retries[key] = state
+	slog.Warn(fmt.Sprintf("Endpoint %s: %s failed (%s); retrying in %s (retry %d of %d)",
+		ep.Name, operation, err.Error(), interval, state.Attempts, ep.MaxRetries))
+	return endpoints.TransientError{Name: ep.Name, Err: err}
+}
+
+// returns true if the given error from a request to Globus indicates a
+// transient failure (a network problem or a server-side error) rather than a
+// terminal one (e.g. an authorization failure or an invalid request)
+func isTransient(err error) bool {
+	var authErr AuthorizationError
+	if errors.As(err, &authErr) {
+		return false
+	}
+	var globusErr *GlobusError
+	if errors.As(err, &globusErr) {
+		return globusErr.transient()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
 // Performs a GET request on the given Globus resource, handling any obvious
@@ -472,6 +982,10 @@ func (ep *Endpoint) sendRequest(request *http.Request) ([]byte, error) {
 // retrying the operation. See https://docs.globus.org/api/flows/working-with-consents/
 // for details on Globus scopes and consents.
 func (ep *Endpoint) get(resource string, values url.Values) ([]byte, error) {
+	err := ep.refreshAccessTokenIfExpiring()
+	if err != nil {
+		return nil, err
+	}
 	u, err := url.ParseRequestURI(globusTransferBaseURL)
 	if err != nil {
 		return nil, err
@@ -484,7 +998,7 @@ func (ep *Endpoint) get(resource string, values url.Values) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.AccessToken))
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.accessToken()))
 
 	return ep.sendRequest(req)
 }
@@ -496,6 +1010,10 @@ func (ep *Endpoint) get(resource string, values url.Values) ([]byte, error) {
 // retrying the operation. See https://docs.globus.org/api/flows/working-with-consents/
 // for details on Globus scopes and consents.
 func (ep *Endpoint) post(resource string, body io.Reader) ([]byte, error) {
+	err := ep.refreshAccessTokenIfExpiring()
+	if err != nil {
+		return nil, err
+	}
 	u, err := url.ParseRequestURI(globusTransferBaseURL)
 	if err != nil {
 		return nil, err
@@ -507,7 +1025,7 @@ func (ep *Endpoint) post(resource string, body io.Reader) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.AccessToken))
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.accessToken()))
 	req.Header.Set("Content-Type", "application/json")
 
 	return ep.sendRequest(req)
@@ -531,7 +1049,8 @@ func (ep *Endpoint) getSubmissionId() (uuid.UUID, error) {
 // https://docs.globus.org/api/transfer/task_submit/#submit_transfer_task
 // https://docs.globus.org/api/transfer/task_submit/#transfer_item_fields
 func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
-	submissionId uuid.UUID, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	submissionId uuid.UUID, files []endpoints.FileTransfer,
+	options transferOptions) (uuid.UUID, error) {
 	var xferId uuid.UUID
 
 	type TransferItem struct {
@@ -550,6 +1069,8 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		SourceEndpoint      string         `json:"source_endpoint"`
 		SyncLevel           int            `json:"sync_level"`
 		VerifyChecksum      bool           `json:"verify_checksum"`
+		PreserveTimestamp   bool           `json:"preserve_timestamp"`
+		EncryptData         bool           `json:"encrypt_data"`
 		FailOnQuotaErrors   bool           `json:"fail_on_quota_errors"`
 	}
 	xferItems := make([]TransferItem, len(files))
@@ -576,8 +1097,10 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		Data:                xferItems,
 		DestinationEndpoint: gDestination.Id.String(),
 		SourceEndpoint:      ep.Id.String(),
-		SyncLevel:           3, // transfer only if checksums don't match
-		VerifyChecksum:      true,
+		SyncLevel:           options.SyncLevel,
+		VerifyChecksum:      options.VerifyChecksum,
+		PreserveTimestamp:   options.PreserveTimestamp,
+		EncryptData:         options.EncryptData,
 		FailOnQuotaErrors:   true,
 	})
 	if err != nil {
@@ -596,6 +1119,7 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		return xferId, err
 	}
 	type SubmissionResponse struct {
+		Code   string    `json:"code"`
 		TaskId uuid.UUID `json:"task_id"`
 	}
 
@@ -604,6 +1128,13 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 	if err != nil {
 		return xferId, err
 	}
+	// Globus answers a submission whose ID it has already accepted (e.g. one
+	// retried after a failed response) with a Duplicate code and the ID of the
+	// task it started, which counts as a success
+	if gResp.Code == "Duplicate" {
+		slog.Info(fmt.Sprintf("Endpoint %s: Globus had already accepted submission %s (task %s)",
+			ep.Name, submissionId.String(), gResp.TaskId.String()))
+	}
 	xferId = gResp.TaskId
 	slog.Debug(fmt.Sprintf("Initiated Globus transfer task %s (%d files)",
 		xferId.String(), len(files)))
@@ -22,10 +22,14 @@
 package globus
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -254,6 +258,413 @@ func TestGlobusTransferCancellation(t *testing.T) {
 	assert.Nil(err)
 }
 
+// checks that transfer options override Globus defaults and are validated
+func TestGlobusTransferOptions(t *testing.T) {
+	assert := assert.New(t)
+	endpoint := &Endpoint{Name: "Globus"}
+
+	// no options: defaults
+	options, err := endpoint.transferOptions(nil)
+	assert.Nil(err)
+	assert.Equal(defaultTransferOptions, options)
+
+	// supported options override defaults (numbers arrive from JSON as float64)
+	options, err = endpoint.transferOptions(endpoints.TransferOptions{
+		"encrypt_data":    true,
+		"verify_checksum": false,
+		"sync_level":      float64(1),
+	})
+	assert.Nil(err)
+	assert.True(options.EncryptData)
+	assert.False(options.VerifyChecksum)
+	assert.Equal(1, options.SyncLevel)
+	options, err = endpoint.transferOptions(endpoints.TransferOptions{"sync_level": "mtime"})
+	assert.Nil(err)
+	assert.Equal(2, options.SyncLevel)
+
+	// unsupported options and invalid values are rejected
+	err = endpoint.ValidateTransferOptions(endpoints.TransferOptions{"acl": "private"})
+	assert.Equal(endpoints.InvalidTransferOptionError{
+		Name:    "Globus",
+		Option:  "acl",
+		Message: "unsupported by Globus endpoints",
+	}, err)
+	err = endpoint.ValidateTransferOptions(endpoints.TransferOptions{"sync_level": float64(4)})
+	assert.IsType(endpoints.InvalidTransferOptionError{}, err)
+	err = endpoint.ValidateTransferOptions(endpoints.TransferOptions{"encrypt_data": "yes"})
+	assert.IsType(endpoints.InvalidTransferOptionError{}, err)
+
+	// data is always encrypted if the service requires it
+	config.Service.RequireEncryption = true
+	defer func() { config.Service.RequireEncryption = false }()
+	options, err = endpoint.transferOptions(nil)
+	assert.Nil(err)
+	assert.True(options.EncryptData)
+	err = endpoint.ValidateTransferOptions(endpoints.TransferOptions{"encrypt_data": false})
+	assert.IsType(endpoints.InvalidTransferOptionError{}, err)
+}
+
+// an HTTP transport that sends requests to a handler instead of the network
+type handlerTransport struct {
+	Handler http.HandlerFunc
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.Handler(recorder, req)
+	return recorder.Result(), nil
+}
+
+// checks that an access token near its expiration is refreshed before a
+// transfer is submitted
+func TestGlobusTokenRefreshBeforeTransfer(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for Globus Auth and the Transfer API that accepts only the
+	// refreshed token
+	numAuthRequests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "auth.globus.org" {
+			numAuthRequests++
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "fresh-token",
+				"expires_in":   172800,
+			})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			json.NewEncoder(w).Encode(map[string]any{
+				"code":    "AuthenticationFailed",
+				"message": "Token is not active",
+			})
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/submission_id") {
+			json.NewEncoder(w).Encode(map[string]any{"value": uuid.New()})
+		} else {
+			json.NewEncoder(w).Encode(map[string]any{"task_id": uuid.New()})
+		}
+	}
+
+	source := &Endpoint{
+		Name:            "Source",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "stale-token",
+		TokenExpiration: time.Now().Add(time.Minute), // expires soon!
+		RefreshMargin:   defaultRefreshMargin,
+		ClientId:        uuid.New(),
+		ClientSecret:    "secret",
+	}
+	destination := &Endpoint{Name: "Destination", Id: uuid.New()}
+
+	taskId, err := source.Transfer(destination, []endpoints.FileTransfer{
+		{SourcePath: "a.txt", DestinationPath: "b.txt"},
+	})
+	assert.Nil(err)
+	assert.NotEqual(uuid.UUID{}, taskId)
+	assert.Equal(1, numAuthRequests)
+	assert.Equal("fresh-token", source.AccessToken)
+	assert.Greater(time.Until(source.TokenExpiration), 47*time.Hour)
+
+	// a token that isn't near expiration is left alone
+	_, err = source.Transfer(destination, []endpoints.FileTransfer{
+		{SourcePath: "a.txt", DestinationPath: "b.txt"},
+	})
+	assert.Nil(err)
+	assert.Equal(1, numAuthRequests)
+}
+
+// checks that an endpoint is healthy only if the Transfer API reports that
+// it's activated
+func TestGlobusCheckHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	activated := true
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"DATA_TYPE": "endpoint",
+			"activated": activated,
+		})
+	}
+	endpoint := &Endpoint{
+		Name:            "Endpoint",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "token",
+		TokenExpiration: time.Now().Add(time.Hour),
+		RefreshMargin:   defaultRefreshMargin,
+	}
+	assert.Nil(endpoint.CheckHealth())
+
+	activated = false
+	assert.NotNil(endpoint.CheckHealth())
+}
+
+// checks that transfer submissions and status checks that fail transiently
+// are reported as such, and succeed when made again once Globus recovers
+func TestGlobusRetriesTransientFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	// a stand-in for the Transfer API that fails a couple of times for each
+	// kind of request before succeeding
+	numRequests := make(map[string]int)
+	submissionIds := make(map[string]bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		resource := path.Base(r.URL.Path)
+		if strings.Contains(r.URL.Path, "/task/") {
+			resource = "task"
+		}
+		numRequests[resource]++
+		if resource == "transfer" {
+			var submission map[string]any
+			json.NewDecoder(r.Body).Decode(&submission)
+			submissionIds[submission["submission_id"].(string)] = true
+		}
+		if numRequests[resource] <= 2 {
+			if resource == "transfer" { // a Globus-style error
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]any{
+					"code":    "ServiceUnavailable",
+					"message": "Please try again later",
+				})
+			} else { // a bare gateway error
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte("<html>Bad Gateway</html>"))
+			}
+			return
+		}
+		switch resource {
+		case "submission_id":
+			json.NewEncoder(w).Encode(map[string]any{"value": uuid.New()})
+		case "transfer":
+			json.NewEncoder(w).Encode(map[string]any{"task_id": uuid.New()})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":            "SUCCEEDED",
+				"files":             1,
+				"files_transferred": 1,
+			})
+		}
+	}
+
+	source := &Endpoint{
+		Name:            "Source",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "token",
+		TokenExpiration: time.Now().Add(time.Hour),
+		RefreshMargin:   defaultRefreshMargin,
+		MaxRetries:      3,
+		RetryInterval:   time.Millisecond,
+	}
+	destination := &Endpoint{Name: "Destination", Id: uuid.New()}
+	files := []endpoints.FileTransfer{
+		{SourcePath: "a.txt", DestinationPath: "b.txt"},
+	}
+
+	// the submission ID request fails twice, then the submission itself
+	var taskId uuid.UUID
+	var err error
+	for i := 0; i < 4; i++ {
+		taskId, err = source.Transfer(destination, files)
+		assert.IsType(endpoints.TransientError{}, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	taskId, err = source.Transfer(destination, files)
+	assert.Nil(err)
+	assert.NotEqual(uuid.UUID{}, taskId)
+	assert.Equal(3, numRequests["submission_id"])
+	assert.Equal(3, numRequests["transfer"])
+	assert.Equal(1, len(submissionIds)) // retried submissions reuse their ID
+
+	for i := 0; i < 2; i++ {
+		_, err = source.Status(taskId)
+		assert.IsType(endpoints.TransientError{}, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	status, err := source.Status(taskId)
+	assert.Nil(err)
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+	assert.Equal(3, numRequests["task"])
+}
+
+// checks that a transiently failing request isn't made again before its retry
+// interval has elapsed
+func TestGlobusRetryInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	numRequests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	endpoint := &Endpoint{
+		Name:            "Endpoint",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "token",
+		TokenExpiration: time.Now().Add(time.Hour),
+		RefreshMargin:   defaultRefreshMargin,
+		MaxRetries:      3,
+		RetryInterval:   time.Hour,
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := endpoint.Status(uuid.New())
+		assert.IsType(endpoints.TransientError{}, err)
+	}
+	assert.Less(time.Since(start), time.Second) // no waiting around
+	assert.Equal(3, numRequests)                // one for each task
+
+	taskId := uuid.New()
+	for i := 0; i < 3; i++ {
+		_, err := endpoint.Status(taskId)
+		assert.IsType(endpoints.TransientError{}, err)
+	}
+	assert.Equal(4, numRequests) // only the first for the same task
+}
+
+// checks that an endpoint gives up on a transiently failing request once it
+// has exhausted its retries, and that retries can be disabled
+func TestGlobusRetryLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	numRequests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	endpoint := &Endpoint{
+		Name:            "Endpoint",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "token",
+		TokenExpiration: time.Now().Add(time.Hour),
+		RefreshMargin:   defaultRefreshMargin,
+		MaxRetries:      2,
+		RetryInterval:   time.Millisecond,
+	}
+	taskId := uuid.New()
+	for i := 0; i < 2; i++ {
+		_, err := endpoint.Status(taskId)
+		assert.IsType(endpoints.TransientError{}, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err := endpoint.Status(taskId)
+	assert.NotNil(err)
+	assert.Equal(3, numRequests)
+	globusErr, ok := err.(*GlobusError)
+	assert.True(ok)
+	assert.Equal(http.StatusServiceUnavailable, globusErr.StatusCode)
+
+	endpoint.MaxRetries = 0
+	_, err = endpoint.Status(taskId)
+	assert.IsType(&GlobusError{}, err)
+	assert.Equal(4, numRequests)
+}
+
+// checks that a retried submission that Globus reports as a duplicate (because
+// it accepted the original despite failing to respond) counts as a success
+func TestGlobusDuplicateSubmission(t *testing.T) {
+	assert := assert.New(t)
+
+	globusTaskId := uuid.New()
+	numSubmissions := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "submission_id" {
+			json.NewEncoder(w).Encode(map[string]any{"value": uuid.New()})
+			return
+		}
+		numSubmissions++
+		if numSubmissions == 1 { // accepted, but the response is lost
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"DATA_TYPE": "transfer_result",
+			"code":      "Duplicate",
+			"message":   "A transfer with this submission id was already submitted",
+			"task_id":   globusTaskId,
+		})
+	}
+	source := &Endpoint{
+		Name:            "Source",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "token",
+		TokenExpiration: time.Now().Add(time.Hour),
+		RefreshMargin:   defaultRefreshMargin,
+		MaxRetries:      3,
+		RetryInterval:   time.Millisecond,
+	}
+	destination := &Endpoint{Name: "Destination", Id: uuid.New()}
+	files := []endpoints.FileTransfer{
+		{SourcePath: "a.txt", DestinationPath: "b.txt"},
+	}
+
+	_, err := source.Transfer(destination, files)
+	assert.IsType(endpoints.TransientError{}, err)
+	time.Sleep(10 * time.Millisecond)
+	taskId, err := source.Transfer(destination, files)
+	assert.Nil(err)
+	assert.Equal(globusTaskId, taskId)
+	assert.Equal(2, numSubmissions)
+}
+
+// checks that authorization errors that persist after reauthentication are
+// reported as such and not retried
+func TestGlobusAuthorizationErrorsNotRetried(t *testing.T) {
+	assert := assert.New(t)
+
+	numAuthRequests, numRequests := 0, 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "auth.globus.org" {
+			numAuthRequests++
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "useless-token",
+				"expires_in":   172800,
+			})
+			return
+		}
+		numRequests++
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"code":            "ConsentRequired",
+			"message":         "Missing required data_access consent",
+			"required_scopes": []string{"urn:globus:auth:scope:transfer.api.globus.org:all"},
+		})
+	}
+	source := &Endpoint{
+		Name:            "Source",
+		Id:              uuid.New(),
+		RootDir:         "/",
+		Client:          http.Client{Transport: handlerTransport{Handler: handler}},
+		AccessToken:     "token",
+		TokenExpiration: time.Now().Add(time.Hour),
+		RefreshMargin:   defaultRefreshMargin,
+		ClientId:        uuid.New(),
+		ClientSecret:    "secret",
+		MaxRetries:      3,
+		RetryInterval:   time.Millisecond,
+	}
+	destination := &Endpoint{Name: "Destination", Id: uuid.New()}
+
+	_, err := source.Transfer(destination, []endpoints.FileTransfer{
+		{SourcePath: "a.txt", DestinationPath: "b.txt"},
+	})
+	assert.IsType(AuthorizationError{}, err)
+	assert.Contains(err.Error(), "Missing required data_access consent")
+	assert.Equal(1, numAuthRequests)
+	assert.Equal(2, numRequests) // the original request and one after reauthenticating
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	var status int
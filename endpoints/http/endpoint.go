@@ -0,0 +1,370 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/frictionless"
+)
+
+type xferRecord struct {
+	Status   endpoints.TransferStatus
+	Files    []endpoints.FileTransfer
+	Canceled bool
+	// cancels the transfer's context, aborting any download in progress
+	cancel context.CancelFunc
+}
+
+// This type implements a read-only source endpoint that retrieves files over
+// HTTP(S) from a server offering direct downloads (e.g. Zenodo). Files are
+// "transferred" by streaming each source URL to a destination endpoint with
+// access to the local file system.
+type Endpoint struct {
+	// descriptive endpoint name (obtained from config)
+	Name string
+	// endpoint UUID (obtained from config)
+	Id uuid.UUID
+	// base URL against which resource paths are resolved
+	root *url.URL
+	// HTTP client used for requests
+	Client http.Client
+	// interval after which a metadata (HEAD) request is abandoned, if positive
+	// (downloads aren't subject to it, but can be canceled)
+	MetadataTimeout time.Duration
+	// transfers in progress, guarded by a mutex since they're updated
+	// asynchronously
+	Xfers map[uuid.UUID]xferRecord
+	mutex sync.Mutex
+	// tracks transfers whose files are still being downloaded
+	active sync.WaitGroup
+}
+
+// creates a new HTTP(S) endpoint using the information supplied in the DTS
+// configuration file under the given endpoint name. The endpoint's root is
+// the base URL against which resource paths are resolved.
+func NewEndpoint(endpointName string) (endpoints.Endpoint, error) {
+	epConfig, found := config.Endpoints[endpointName]
+	if !found {
+		return nil, fmt.Errorf("'%s' is not an endpoint", endpointName)
+	}
+	if epConfig.Provider != "http" {
+		return nil, fmt.Errorf("'%s' is not an HTTP endpoint", endpointName)
+	}
+	if epConfig.Root == "" {
+		return nil, fmt.Errorf("'%s' requires a root URL to be specified", endpointName)
+	}
+	root, err := url.Parse(epConfig.Root)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' has an invalid root URL: %s", endpointName, err.Error())
+	}
+	if root.Scheme != "http" && root.Scheme != "https" {
+		return nil, fmt.Errorf("'%s' requires an http or https root URL", endpointName)
+	}
+
+	return &Endpoint{
+		Name:            epConfig.Name,
+		Id:              epConfig.Id,
+		root:            root,
+		MetadataTimeout: 10 * time.Second,
+		Xfers:           make(map[uuid.UUID]xferRecord),
+	}, nil
+}
+
+func (ep *Endpoint) Root() string {
+	return ep.root.String()
+}
+
+// files are encrypted in transit only if they're retrieved with HTTPS
+func (ep *Endpoint) EncryptsInTransit() bool {
+	return ep.root.Scheme == "https"
+}
+
+// the endpoint is healthy if its server responds to a request for its root
+// URL without a server error
+func (ep *Endpoint) CheckHealth() error {
+	resp, err := ep.head(ep.root.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s responded with status %d", ep.root.String(), resp.StatusCode)
+	}
+	return nil
+}
+
+// sends a HEAD request for the given URL, abandoning it after the endpoint's
+// metadata timeout so a slow server can't stall the caller
+func (ep *Endpoint) head(u string) (*http.Response, error) {
+	ctx := context.Background()
+	if ep.MetadataTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ep.MetadataTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	return ep.Client.Do(req)
+}
+
+// resolves the given resource path to a URL relative to the endpoint's root
+// (absolute URLs are used as-is), refusing any URL that isn't under the root
+func (ep *Endpoint) resolve(resourcePath string) (string, error) {
+	ref, err := url.Parse(resourcePath)
+	if err != nil {
+		return "", err
+	}
+	var resolved *url.URL
+	if ref.IsAbs() {
+		resolved = ep.root.ResolveReference(ref) // removes dot segments
+	} else {
+		resolved = ep.root.JoinPath(ref.Path)
+	}
+	if !ep.contains(resolved) {
+		return "", fmt.Errorf("%s is not under the endpoint's root URL %s",
+			resourcePath, ep.root.String())
+	}
+	return resolved.String(), nil
+}
+
+// returns true if the given (resolved) URL lies under the endpoint's root
+func (ep *Endpoint) contains(u *url.URL) bool {
+	if u.Scheme != ep.root.Scheme || u.Host != ep.root.Host || u.User != nil {
+		return false
+	}
+	rootPath := strings.TrimSuffix(ep.root.Path, "/")
+	urlPath := path.Clean("/" + u.Path)
+	return rootPath == "" || urlPath == rootPath || strings.HasPrefix(urlPath, rootPath+"/")
+}
+
+func (ep *Endpoint) FilesStaged(files []frictionless.DataResource) (bool, error) {
+	for _, resource := range files {
+		fileUrl, err := ep.resolve(resource.Path)
+		if err != nil {
+			return false, err
+		}
+		resp, err := ep.head(fileUrl)
+		if err != nil {
+			return false, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfers := make([]uuid.UUID, 0)
+	for xferId, xfer := range ep.Xfers {
+		switch xfer.Status.Code {
+		case endpoints.TransferStatusSucceeded, endpoints.TransferStatusFailed:
+		default:
+			xfers = append(xfers, xferId)
+		}
+	}
+	return xfers, nil
+}
+
+// downloads the file at the given URL to the given path on the local file
+// system, stopping if the given context is canceled
+func (ep *Endpoint) download(ctx context.Context, fileUrl, destPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileUrl, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := ep.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", fileUrl, resp.StatusCode)
+	}
+
+	err = os.MkdirAll(filepath.Dir(destPath), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, resp.Body)
+	if err != nil {
+		// don't leave a partial file behind
+		file.Close()
+		os.Remove(destPath)
+		return err
+	}
+	return file.Close()
+}
+
+// implements asynchronous file downloads, which stop when the given context
+// is canceled
+func (ep *Endpoint) transferFiles(ctx context.Context, xferId uuid.UUID, dest endpoints.Endpoint) {
+	defer ep.active.Done()
+	ep.mutex.Lock()
+	files := ep.Xfers[xferId].Files
+	ep.mutex.Unlock()
+
+	var err error
+	for _, file := range files {
+		// has the transfer been canceled?
+		ep.mutex.Lock()
+		canceled := ep.Xfers[xferId].Canceled
+		ep.mutex.Unlock()
+		if canceled {
+			break
+		}
+
+		var fileUrl string
+		fileUrl, err = ep.resolve(file.SourcePath)
+		if err != nil {
+			break
+		}
+		err = ep.download(ctx, fileUrl, filepath.Join(dest.Root(), file.DestinationPath))
+		if err != nil {
+			break
+		}
+
+		ep.mutex.Lock()
+		xfer := ep.Xfers[xferId]
+		xfer.Status.NumFilesTransferred++
+		ep.Xfers[xferId] = xfer
+		ep.mutex.Unlock()
+	}
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfer := ep.Xfers[xferId]
+	xfer.cancel() // release the context's resources
+
+	// cancellation interrupts downloads, so we check for it before errors
+	if xfer.Canceled {
+		xfer.Status.Code = endpoints.TransferStatusFailed
+		xfer.Status.Message = "Transfer canceled"
+		xfer.Status.NumFilesSkipped = xfer.Status.NumFiles - xfer.Status.NumFilesTransferred
+	} else if err != nil { // trouble!
+		xfer.Status.Code = endpoints.TransferStatusFailed
+		xfer.Status.Message = err.Error()
+	} else { // all's well
+		xfer.Status.Code = endpoints.TransferStatusSucceeded
+	}
+	ep.Xfers[xferId] = xfer
+}
+
+func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	var xferId uuid.UUID
+	_, ok := dst.(*local.Endpoint)
+	if !ok {
+		return xferId, fmt.Errorf("An HTTP endpoint can only transfer files to a local endpoint!")
+	}
+
+	// no file may be written outside of the destination's root
+	for _, file := range files {
+		if !filepath.IsLocal(file.DestinationPath) {
+			return xferId, fmt.Errorf("The destination path %s is not within the destination endpoint.",
+				file.DestinationPath)
+		}
+	}
+
+	// first, we check that all requested files are available
+	requestedFiles := make([]frictionless.DataResource, len(files))
+	for i, file := range files {
+		requestedFiles[i].Path = file.SourcePath // only the Path field is required
+	}
+	staged, err := ep.FilesStaged(requestedFiles)
+	if err != nil {
+		return xferId, err
+	}
+	if !staged {
+		return xferId, fmt.Errorf("The files requested for transfer are not available.")
+	}
+
+	// assign a UUID to the transfer and set it going with a context that
+	// Cancel can use to interrupt it
+	xferId = uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ep.mutex.Lock()
+	ep.Xfers[xferId] = xferRecord{
+		Status: endpoints.TransferStatus{
+			Code:                endpoints.TransferStatusActive,
+			NumFiles:            len(files),
+			NumFilesTransferred: 0,
+		},
+		Files:  files,
+		cancel: cancel,
+	}
+	ep.mutex.Unlock()
+	ep.active.Add(1)
+	go ep.transferFiles(ctx, xferId, dst)
+	return xferId, nil
+}
+
+func (ep *Endpoint) Drain(ctx context.Context) error {
+	return endpoints.WaitForTransfers(ctx, &ep.active)
+}
+
+func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.Xfers[id]; found {
+		return xfer.Status, nil
+	}
+	return endpoints.TransferStatus{
+		Code: endpoints.TransferStatusUnknown,
+	}, fmt.Errorf("Transfer %s not found!", id.String())
+}
+
+func (ep *Endpoint) Cancel(id uuid.UUID) error {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.Xfers[id]; found {
+		xfer.Canceled = true
+		ep.Xfers[id] = xfer
+		xfer.cancel() // interrupts the download in progress, if any
+		return nil
+	}
+	return fmt.Errorf("Transfer %s not found!", id.String())
+}
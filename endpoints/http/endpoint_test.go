@@ -0,0 +1,377 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/frictionless"
+)
+
+var server *httptest.Server
+var destinationRoot string
+
+// files served by the test server, mapped to their contents
+var sourceFiles = map[string]string{
+	"records/1/file1.txt": "This is the content of file 1.",
+	"records/2/file2.txt": "This is the content of file 2.",
+}
+
+// a file served so slowly that its download must be canceled
+const slowFile = "records/3/slow.txt"
+
+// signals that a download of the slow file has begun
+var slowDownloadStarted = make(chan struct{}, 1)
+
+// serves the start of the slow file and then stalls until the client goes
+// away (or it's clear that the client isn't going to)
+func serveSlowFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte("This file takes a long "))
+	w.(http.Flusher).Flush()
+	slowDownloadStarted <- struct{}{}
+	select {
+	case <-r.Context().Done():
+	case <-time.After(10 * time.Second):
+		w.Write([]byte("time to download."))
+	}
+}
+
+const httpConfig string = `
+endpoints:
+  source:
+    name: HTTP Source Endpoint
+    id: 5a2a1c6e-5d43-4f0b-8a4e-2f4b5ae0c1d7
+    provider: http
+    root: SOURCE_URL
+  destination:
+    name: Destination Endpoint
+    id: b925d96e-7e39-473b-a658-714f8c243b1c
+    provider: local
+    root: DESTINATION_ROOT
+`
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	// serve our source files
+	mux := http.NewServeMux()
+	for path, content := range sourceFiles {
+		mux.HandleFunc("/"+path, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(content))
+		})
+	}
+	mux.HandleFunc("/"+slowFile, serveSlowFile)
+	server = httptest.NewServer(mux)
+
+	var err error
+	destinationRoot, err = os.MkdirTemp(os.TempDir(), "dts-http-endpoints")
+	if err != nil {
+		panic(err)
+	}
+
+	myConfig := strings.ReplaceAll(httpConfig, "SOURCE_URL", server.URL)
+	myConfig = strings.ReplaceAll(myConfig, "DESTINATION_ROOT", destinationRoot)
+	err = config.Init([]byte(myConfig))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	server.Close()
+	os.RemoveAll(destinationRoot)
+}
+
+func TestHttpConstructor(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, err := NewEndpoint("source")
+	assert.NotNil(endpoint)
+	assert.Nil(err)
+	assert.Equal(server.URL, endpoint.Root())
+
+	endpoint, err = NewEndpoint("destination") // not an HTTP endpoint
+	assert.Nil(endpoint)
+	assert.NotNil(err)
+}
+
+func TestHttpEncryptsInTransit(t *testing.T) {
+	assert := assert.New(t)
+
+	// our test server uses plain HTTP
+	endpoint, err := NewEndpoint("source")
+	assert.Nil(err)
+	assert.False(endpoint.(endpoints.EncryptableEndpoint).EncryptsInTransit())
+
+	endpoint = &Endpoint{root: &url.URL{Scheme: "https", Host: "zenodo.org"}}
+	assert.True(endpoint.(endpoints.EncryptableEndpoint).EncryptsInTransit())
+}
+
+func TestHttpCheckHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	// our test server is up
+	source, _ := NewEndpoint("source")
+	assert.Nil(source.(endpoints.CheckableEndpoint).CheckHealth())
+
+	// a server that responds with errors is unhealthy
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingServer.Close()
+	failingUrl, _ := url.Parse(failingServer.URL)
+	endpoint := &Endpoint{root: failingUrl}
+	assert.NotNil(endpoint.CheckHealth())
+
+	// so is a server that's down
+	downServer := httptest.NewServer(http.NotFoundHandler())
+	downUrl, _ := url.Parse(downServer.URL)
+	downServer.Close()
+	endpoint = &Endpoint{root: downUrl}
+	assert.NotNil(endpoint.CheckHealth())
+
+	// as is one that doesn't respond in time
+	stalled := make(chan struct{})
+	stalledServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stalled:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stalledServer.Close()
+	defer close(stalled)
+	stalledUrl, _ := url.Parse(stalledServer.URL)
+	endpoint = &Endpoint{root: stalledUrl, MetadataTimeout: 100 * time.Millisecond}
+	start := time.Now()
+	assert.NotNil(endpoint.CheckHealth())
+	assert.Less(time.Since(start), 5*time.Second)
+	_, err := endpoint.FilesStaged([]frictionless.DataResource{{Path: "file1.txt"}})
+	assert.NotNil(err)
+}
+
+func TestHttpFilesStaged(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, _ := NewEndpoint("source")
+
+	resources := make([]frictionless.DataResource, 0)
+	for path := range sourceFiles {
+		resources = append(resources, frictionless.DataResource{Path: path})
+	}
+	staged, err := endpoint.FilesStaged(resources)
+	assert.True(staged)
+	assert.Nil(err)
+
+	// absolute URLs are accepted as-is
+	staged, err = endpoint.FilesStaged([]frictionless.DataResource{
+		{Path: server.URL + "/records/1/file1.txt"},
+	})
+	assert.True(staged)
+	assert.Nil(err)
+
+	staged, err = endpoint.FilesStaged([]frictionless.DataResource{
+		{Path: "records/3/nonexistent.txt"},
+	})
+	assert.False(staged)
+	assert.Nil(err)
+}
+
+// checks that resource paths can't refer to URLs outside of the root
+func TestHttpResolve(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := url.Parse("https://example.com/records/")
+	endpoint := &Endpoint{root: root}
+
+	fileUrl, err := endpoint.resolve("1/file1.txt")
+	assert.Nil(err)
+	assert.Equal("https://example.com/records/1/file1.txt", fileUrl)
+	fileUrl, err = endpoint.resolve("https://example.com/records/1/file1.txt")
+	assert.Nil(err)
+	assert.Equal("https://example.com/records/1/file1.txt", fileUrl)
+
+	for _, resourcePath := range []string{
+		"../secrets.txt",
+		"1/../../secrets.txt",
+		"https://example.com/secrets.txt",
+		"https://example.com/records/../secrets.txt",
+		"https://example.com/recordsandmore/file.txt",
+		"http://example.com/records/1/file1.txt",
+		"https://attacker.example.org/records/1/file1.txt",
+		"https://user@example.com/records/1/file1.txt",
+	} {
+		_, err = endpoint.resolve(resourcePath)
+		assert.NotNil(err, "%s was resolved outside of the root", resourcePath)
+	}
+}
+
+func TestHttpTransfer(t *testing.T) {
+	assert := assert.New(t)
+	source, _ := NewEndpoint("source")
+	destination, _ := local.NewEndpoint("destination")
+
+	fileXfers := make([]endpoints.FileTransfer, 0)
+	for path := range sourceFiles {
+		fileXfers = append(fileXfers, endpoints.FileTransfer{
+			SourcePath:      path,
+			DestinationPath: filepath.Join("xfer", filepath.Base(path)),
+		})
+	}
+	xferId, err := source.Transfer(destination, fileXfers)
+	assert.Nil(err)
+
+	var status endpoints.TransferStatus
+	for i := 0; i < 100; i++ {
+		status, err = source.Status(xferId)
+		assert.Nil(err)
+		if status.Code == endpoints.TransferStatusSucceeded ||
+			status.Code == endpoints.TransferStatusFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+	assert.Equal(len(sourceFiles), status.NumFilesTransferred)
+
+	for path, content := range sourceFiles {
+		data, err := os.ReadFile(filepath.Join(destinationRoot, "xfer", filepath.Base(path)))
+		assert.Nil(err)
+		assert.Equal(content, string(data))
+	}
+}
+
+func TestBadHttpTransfer(t *testing.T) {
+	assert := assert.New(t)
+	source, _ := NewEndpoint("source")
+	destination, _ := local.NewEndpoint("destination")
+
+	_, err := source.Transfer(destination, []endpoints.FileTransfer{
+		{
+			SourcePath:      "records/3/nonexistent.txt",
+			DestinationPath: "nonexistent.txt",
+		},
+	})
+	assert.NotNil(err)
+
+	// files can't be retrieved from other servers
+	_, err = source.Transfer(destination, []endpoints.FileTransfer{
+		{
+			SourcePath:      "http://example.com/records/1/file1.txt",
+			DestinationPath: "file1.txt",
+		},
+	})
+	assert.NotNil(err)
+
+	// files can't be written outside of the destination's root
+	for _, destPath := range []string{"../file1.txt", "xfer/../../file1.txt", "/tmp/file1.txt"} {
+		_, err = source.Transfer(destination, []endpoints.FileTransfer{
+			{
+				SourcePath:      "records/1/file1.txt",
+				DestinationPath: destPath,
+			},
+		})
+		assert.NotNil(err, "%s was accepted as a destination path", destPath)
+	}
+
+	// only local destinations are supported
+	_, err = source.Transfer(source, []endpoints.FileTransfer{})
+	assert.NotNil(err)
+}
+
+func TestCanceledHttpTransfer(t *testing.T) {
+	assert := assert.New(t)
+	source, _ := NewEndpoint("source")
+	destination, _ := local.NewEndpoint("destination")
+
+	xferId, err := source.Transfer(destination, []endpoints.FileTransfer{
+		{
+			SourcePath:      slowFile,
+			DestinationPath: filepath.Join("canceled", "slow.txt"),
+		},
+		{
+			SourcePath:      "records/1/file1.txt",
+			DestinationPath: filepath.Join("canceled", "file1.txt"),
+		},
+	})
+	assert.Nil(err)
+
+	// cancel the transfer while the slow file is being downloaded
+	<-slowDownloadStarted
+	canceledAt := time.Now()
+	assert.Nil(source.Cancel(xferId))
+
+	var status endpoints.TransferStatus
+	for i := 0; i < 100; i++ {
+		status, err = source.Status(xferId)
+		assert.Nil(err)
+		if status.Code == endpoints.TransferStatusFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the download stops promptly and neither file is transferred
+	assert.Less(time.Since(canceledAt), time.Second)
+	assert.Equal(endpoints.TransferStatusFailed, status.Code)
+	assert.Equal("Transfer canceled", status.Message)
+	assert.Equal(0, status.NumFilesTransferred)
+	assert.Equal(2, status.NumFilesSkipped)
+	_, err = os.Stat(filepath.Join(destinationRoot, "canceled", "slow.txt"))
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(destinationRoot, "canceled", "file1.txt"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestUnknownHttpStatus(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, _ := NewEndpoint("source")
+
+	// make up a bogus transfer UUID and check its status
+	taskId := uuid.New()
+	status, err := endpoint.Status(taskId)
+	assert.Equal(endpoints.TransferStatusUnknown, status.Code)
+	assert.NotNil(err)
+	assert.NotNil(endpoint.Cancel(taskId))
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
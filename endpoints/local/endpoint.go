@@ -22,11 +22,19 @@
 package local
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
+	"mime"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 
@@ -50,8 +58,13 @@ type Endpoint struct {
 	Id uuid.UUID
 	// root directory for endpoint (default: current working directory)
 	root string
+	// if true, files transferred from this endpoint are hard-linked into
+	// place where possible instead of being copied
+	PreferHardlink bool
 	// transfers in progress
 	Xfers map[uuid.UUID]xferRecord
+	// tracks transfers whose files are still being copied
+	active sync.WaitGroup
 }
 
 // creates a new local endpoint using the information supplied in the
@@ -69,9 +82,10 @@ func NewEndpoint(endpointName string) (endpoints.Endpoint, error) {
 	}
 
 	ep := &Endpoint{
-		Name:  epConfig.Name,
-		Id:    epConfig.Id,
-		Xfers: make(map[uuid.UUID]xferRecord),
+		Name:           epConfig.Name,
+		Id:             epConfig.Id,
+		PreferHardlink: epConfig.PreferHardlink,
+		Xfers:          make(map[uuid.UUID]xferRecord),
 	}
 	err := ep.setRoot(epConfig.Root)
 	return ep, err
@@ -90,6 +104,18 @@ func (ep *Endpoint) Root() string {
 	return ep.root
 }
 
+// the endpoint is healthy if its root directory exists
+func (ep *Endpoint) CheckHealth() error {
+	info, err := os.Stat(ep.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", ep.root)
+	}
+	return nil
+}
+
 func (ep *Endpoint) FilesStaged(files []frictionless.DataResource) (bool, error) {
 	for _, resource := range files {
 		absPath := filepath.Join(ep.root, resource.Path)
@@ -101,6 +127,146 @@ func (ep *Endpoint) FilesStaged(files []frictionless.DataResource) (bool, error)
 	return true, nil
 }
 
+// reports files whose sizes or MD5 checksums (where given) differ from those
+// of their resources
+func (ep *Endpoint) MismatchedFiles(files []frictionless.DataResource) ([]string, error) {
+	mismatched := make([]string, 0)
+	for _, resource := range files {
+		present, matches, err := ep.fileMatches(resource)
+		if err != nil {
+			return nil, err
+		}
+		if present && !matches {
+			mismatched = append(mismatched, resource.Id)
+		}
+	}
+	return mismatched, nil
+}
+
+// reports files whose sizes and MD5 checksums (where given) match those of
+// their resources
+func (ep *Endpoint) ExistingFiles(files []frictionless.DataResource) ([]string, error) {
+	existing := make([]string, 0)
+	for _, resource := range files {
+		present, matches, err := ep.fileMatches(resource)
+		if err != nil {
+			return nil, err
+		}
+		if present && matches {
+			existing = append(existing, resource.Id)
+		}
+	}
+	return existing, nil
+}
+
+// reports whether the file for the given resource is present at the endpoint
+// and, if so, whether it matches the resource's size (and MD5 checksum, if any)
+func (ep *Endpoint) fileMatches(resource frictionless.DataResource) (present, matches bool, err error) {
+	absPath := filepath.Join(ep.root, resource.Path)
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	if int(info.Size()) != resource.Bytes {
+		return true, false, nil
+	}
+	if hash := resource.HashFor("md5"); hash != "" {
+		sum, err := md5Sum(absPath)
+		if err != nil {
+			return true, false, err
+		}
+		return true, sum == hash, nil
+	}
+	return true, true, nil
+}
+
+// computes the MD5 checksum of the file at the given path relative to the
+// endpoint's root
+func (ep *Endpoint) MD5Sum(path string) (string, error) {
+	return md5Sum(filepath.Join(ep.root, path))
+}
+
+// returns Frictionless DataResources for the files in the given directory
+// (relative to the endpoint's root) and its subdirectories, whose IDs are
+// their paths relative to the endpoint's root
+func (ep *Endpoint) ListDirectory(dir string) ([]frictionless.DataResource, error) {
+	resources := make([]frictionless.DataResource, 0)
+	err := filepath.WalkDir(filepath.Join(ep.root, dir),
+		func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(ep.root, path)
+			if err != nil {
+				return err
+			}
+			relPath = filepath.ToSlash(relPath)
+			hash, err := md5Sum(path)
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(entry.Name())
+			format, mediaType := strings.TrimPrefix(ext, "."), mime.TypeByExtension(ext)
+			if mediaType == "" { // unrecognized extension, so look at the content
+				sample, err := formatSample(path)
+				if err != nil {
+					return err
+				}
+				if detectedFormat, detectedType := frictionless.DetectFormat(sample); detectedFormat != "" {
+					format, mediaType = detectedFormat, detectedType
+				}
+			}
+			resources = append(resources, frictionless.DataResource{
+				Id:        relPath,
+				Name:      strings.TrimSuffix(entry.Name(), ext),
+				Path:      relPath,
+				Format:    format,
+				MediaType: mediaType,
+				Bytes:     int(info.Size()),
+				Hash:      hash,
+			})
+			return nil
+		})
+	return resources, err
+}
+
+// reads the leading bytes of the file at the given path for format detection
+func formatSample(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sample := make([]byte, frictionless.FormatSampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return sample[:n], nil
+}
+
+// computes the (hex-encoded) MD5 checksum of the file at the given path
+func md5Sum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := md5.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 	xfers := make([]uuid.UUID, 0)
 	for xferId, xfer := range ep.Xfers {
@@ -115,9 +281,12 @@ func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 
 // implements asynchronous local file transfers and validation
 func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
+	defer ep.active.Done()
 	var err error
+	var failedFile endpoints.FileTransfer
 	xfer := ep.Xfers[xferId]
 	for _, file := range xfer.Files {
+		failedFile = file
 		// has the transfer been canceled?
 		if xfer.Canceled {
 			break
@@ -145,18 +314,12 @@ func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
 			}
 		}
 
-		// copy the file into place
-		var data []byte
-		var sourceFileInfo os.FileInfo
-		sourceFileInfo, err = os.Stat(sourcePath)
-		if err != nil {
-			break
+		// link or copy the file into place
+		if ep.PreferHardlink {
+			err = linkOrCopyFile(sourcePath, destPath)
+		} else {
+			err = copyFile(sourcePath, destPath)
 		}
-		data, err = os.ReadFile(sourcePath)
-		if err != nil {
-			break
-		}
-		err = os.WriteFile(destPath, data, sourceFileInfo.Mode())
 		if err != nil {
 			break
 		}
@@ -165,6 +328,8 @@ func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
 	}
 	if err != nil { // trouble!
 		xfer.Status.Code = endpoints.TransferStatusFailed
+		xfer.Status.Message = err.Error()
+		xfer.Status.FailedFiles = map[string]string{failedFile.SourcePath: err.Error()}
 	} else if xfer.Canceled {
 		xfer.Status.Code = endpoints.TransferStatusFailed
 	} else { // all's well
@@ -173,6 +338,39 @@ func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
 	ep.Xfers[xferId] = xfer
 }
 
+// creates a hard link (a package variable so tests can simulate links that
+// cross devices)
+var link = os.Link
+
+// copies the file at sourcePath to destPath, preserving its mode
+func copyFile(sourcePath, destPath string) error {
+	sourceFileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(destPath, data, sourceFileInfo.Mode())
+}
+
+// hard-links the file at sourcePath to destPath, copying it instead if the
+// link can't be made (e.g. because the paths are on different devices)
+func linkOrCopyFile(sourcePath, destPath string) error {
+	// like a copy, a link replaces any existing file
+	err := os.Remove(destPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	err = link(sourcePath, destPath)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Copying %s (can't hard-link it: %s)", sourcePath, err.Error()))
+		return copyFile(sourcePath, destPath)
+	}
+	return nil
+}
+
 func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
 	var xferId uuid.UUID
 	_, ok := dst.(*Endpoint)
@@ -200,12 +398,17 @@ func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTrans
 			},
 			Files: files,
 		}
+		ep.active.Add(1)
 		go ep.transferFiles(xferId, dst)
 		return xferId, nil
 	}
 	return xferId, fmt.Errorf("The files requested for transfer are not yet staged.")
 }
 
+func (ep *Endpoint) Drain(ctx context.Context) error {
+	return endpoints.WaitForTransfers(ctx, &ep.active)
+}
+
 func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 	if xfer, found := ep.Xfers[id]; found {
 		return xfer.Status, nil
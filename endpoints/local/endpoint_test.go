@@ -22,11 +22,16 @@
 package local
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -40,6 +45,7 @@ var tempRoot string
 var sourceRoot string
 var destinationRoot string
 var destinationRootCancel string
+var destinationRootHardlink string
 
 // source database files by ID
 var sourceFilesById = map[string]string{
@@ -65,6 +71,17 @@ endpoints:
     id: b925d96e-7e39-473b-a658-714f8c243b1c
     provider: local
     root: DESTINATION_CANCEL
+  source-hardlink:
+    name: Source Endpoint preferring hard links
+    id: 2ee69538-10d5-4d1e-a890-1127b5e42004
+    provider: local
+    root: SOURCE_ROOT
+    prefer_hardlink: true
+  destination-hardlink:
+    name: Destination Endpoint for hard links
+    id: b925d96e-7e39-473b-a658-714f8c243b1d
+    provider: local
+    root: DESTINATION_HARDLINK
 `
 
 // this function gets called at the begіnning of a test session
@@ -90,6 +107,11 @@ func setup() {
 	if err != nil {
 		panic(err)
 	}
+	destinationRootHardlink = filepath.Join(tempRoot, "destination-hardlink")
+	err = os.Mkdir(destinationRootHardlink, 0700)
+	if err != nil {
+		panic(err)
+	}
 	// create source files
 	for i := 1; i <= 3; i++ {
 		err = os.WriteFile(filepath.Join(sourceRoot, fmt.Sprintf("file%d.txt", i)),
@@ -103,6 +125,7 @@ func setup() {
 	myConfig := strings.ReplaceAll(localConfig, "SOURCE_ROOT", sourceRoot)
 	myConfig = strings.ReplaceAll(myConfig, "DESTINATION_ROOT", destinationRoot)
 	myConfig = strings.ReplaceAll(myConfig, "DESTINATION_CANCEL", destinationRootCancel)
+	myConfig = strings.ReplaceAll(myConfig, "DESTINATION_HARDLINK", destinationRootHardlink)
 	fmt.Printf(myConfig)
 	err = config.Init([]byte(myConfig))
 	if err != nil {
@@ -123,6 +146,17 @@ func TestLocalConstructor(t *testing.T) {
 	assert.Nil(err)
 }
 
+func TestLocalCheckHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, _ := NewEndpoint("source")
+	assert.Nil(endpoint.(endpoints.CheckableEndpoint).CheckHealth())
+
+	// an endpoint whose root directory has disappeared is unhealthy
+	endpoint = &Endpoint{root: filepath.Join(tempRoot, "nonexistent")}
+	assert.NotNil(endpoint.(endpoints.CheckableEndpoint).CheckHealth())
+}
+
 func TestBadLocalConstructor(t *testing.T) {
 	assert := assert.New(t)
 
@@ -174,6 +208,47 @@ func TestGlobusFilesStaged(t *testing.T) {
 	assert.Nil(err)
 }
 
+func TestLocalMismatchedFiles(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, _ := NewEndpoint("source")
+	verifiable := endpoint.(endpoints.VerifiableEndpoint)
+
+	content := []byte("This is the content of file 1.")
+	checksum := md5.Sum(content)
+	resources := []frictionless.DataResource{
+		{ // matches in size and hash
+			Id:     "1",
+			Path:   sourceFilesById["1"],
+			Bytes:  len(content),
+			Hashes: frictionless.HashesForMD5(hex.EncodeToString(checksum[:])),
+		},
+		{ // wrong size
+			Id:    "2",
+			Path:  sourceFilesById["2"],
+			Bytes: 1024,
+		},
+		{ // right size, wrong hash
+			Id:     "3",
+			Path:   sourceFilesById["3"],
+			Bytes:  len(content),
+			Hashes: frictionless.HashesForMD5("d41d8cd98f00b204e9800998ecf8427e"),
+		},
+		{ // not staged
+			Id:    "yadda",
+			Path:  "yaddayadda/yadda/yaddayadda/yaddayaddayadda.xml",
+			Bytes: 1024,
+		},
+	}
+	mismatched, err := verifiable.MismatchedFiles(resources)
+	assert.Nil(err)
+	assert.Equal([]string{"2", "3"}, mismatched)
+
+	// only the matching file can be skipped by an incremental transfer
+	existing, err := endpoint.(endpoints.IncrementalEndpoint).ExistingFiles(resources)
+	assert.Nil(err)
+	assert.Equal([]string{"1"}, existing)
+}
+
 func TestLocalTransfer(t *testing.T) {
 	assert := assert.New(t)
 
@@ -193,6 +268,66 @@ func TestLocalTransfer(t *testing.T) {
 	assert.Nil(err)
 }
 
+// checks that draining an endpoint waits for its transfers to finish
+func TestLocalDrain(t *testing.T) {
+	assert := assert.New(t)
+
+	source, _ := NewEndpoint("source")
+	destination, _ := NewEndpoint("destination")
+
+	fileXfers := []endpoints.FileTransfer{
+		{
+			SourcePath:      sourceFilesById["1"],
+			DestinationPath: sourceFilesById["1"],
+		},
+	}
+	xferId, err := source.Transfer(destination, fileXfers)
+	assert.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = source.(endpoints.DrainableEndpoint).Drain(ctx)
+	assert.Nil(err)
+	status, err := source.Status(xferId)
+	assert.Nil(err)
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+}
+
+// checks that a local transfer reports the file that failed to transfer
+func TestLocalTransferFailedFile(t *testing.T) {
+	assert := assert.New(t)
+
+	source, _ := NewEndpoint("source")
+	destination, _ := NewEndpoint("destination")
+
+	// a regular file where a directory is expected blocks the second file
+	err := os.WriteFile(filepath.Join(destinationRoot, "blocked"), []byte("blocked"), 0600)
+	assert.Nil(err)
+	fileXfers := []endpoints.FileTransfer{
+		{
+			SourcePath:      sourceFilesById["1"],
+			DestinationPath: sourceFilesById["1"],
+		},
+		{
+			SourcePath:      sourceFilesById["2"],
+			DestinationPath: filepath.Join("blocked", sourceFilesById["2"]),
+		},
+	}
+	xferId, err := source.Transfer(destination, fileXfers)
+	assert.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = source.(endpoints.DrainableEndpoint).Drain(ctx)
+	assert.Nil(err)
+	status, err := source.Status(xferId)
+	assert.Nil(err)
+	assert.Equal(endpoints.TransferStatusFailed, status.Code)
+	assert.Equal(1, status.NumFilesTransferred)
+	assert.Equal(1, len(status.FailedFiles))
+	assert.NotEmpty(status.FailedFiles[sourceFilesById["2"]])
+}
+
 func TestBadLocalTransfer(t *testing.T) {
 	assert := assert.New(t)
 	source, _ := NewEndpoint("source")
@@ -243,6 +378,76 @@ func TestLocalTransferCancellation(t *testing.T) {
 	assert.Nil(err)
 }
 
+// transfers all source files from the endpoint preferring hard links into the
+// given folder at its destination, returning the transfer's final status
+func transferWithHardlinks(t *testing.T, folder string) endpoints.TransferStatus {
+	assert := assert.New(t)
+	source, _ := NewEndpoint("source-hardlink")
+	destination, _ := NewEndpoint("destination-hardlink")
+
+	fileXfers := make([]endpoints.FileTransfer, 0)
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("%d", i)
+		fileXfers = append(fileXfers, endpoints.FileTransfer{
+			SourcePath:      sourceFilesById[id],
+			DestinationPath: filepath.Join(folder, sourceFilesById[id]),
+		})
+	}
+	xferId, err := source.Transfer(destination, fileXfers)
+	assert.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = source.(endpoints.DrainableEndpoint).Drain(ctx)
+	assert.Nil(err)
+	status, err := source.Status(xferId)
+	assert.Nil(err)
+	return status
+}
+
+// checks that files on the same device are hard-linked into place
+func TestLocalHardlinkTransfer(t *testing.T) {
+	assert := assert.New(t)
+
+	status := transferWithHardlinks(t, "linked")
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+	assert.Equal(3, status.NumFilesTransferred)
+	for _, fileName := range sourceFilesById {
+		sourceInfo, err := os.Stat(filepath.Join(sourceRoot, fileName))
+		assert.Nil(err)
+		destInfo, err := os.Stat(filepath.Join(destinationRootHardlink, "linked", fileName))
+		assert.Nil(err)
+		assert.True(os.SameFile(sourceInfo, destInfo))
+	}
+}
+
+// checks that files that can't be hard-linked (e.g. across devices) are
+// copied into place
+func TestLocalHardlinkCopyFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	link = func(oldname, newname string) error {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EXDEV}
+	}
+	defer func() { link = os.Link }()
+
+	status := transferWithHardlinks(t, "copied")
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+	assert.Equal(3, status.NumFilesTransferred)
+	for _, fileName := range sourceFilesById {
+		sourcePath := filepath.Join(sourceRoot, fileName)
+		destPath := filepath.Join(destinationRootHardlink, "copied", fileName)
+		sourceInfo, err := os.Stat(sourcePath)
+		assert.Nil(err)
+		destInfo, err := os.Stat(destPath)
+		assert.Nil(err)
+		assert.False(os.SameFile(sourceInfo, destInfo))
+		sourceData, _ := os.ReadFile(sourcePath)
+		destData, _ := os.ReadFile(destPath)
+		assert.Equal(sourceData, destData)
+	}
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	var status int
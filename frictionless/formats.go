@@ -0,0 +1,103 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frictionless
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// the number of leading bytes of a file examined by DetectFormat
+const FormatSampleSize = 4096
+
+// returns the format and media type of a file, given a sample of its leading
+// bytes (up to FormatSampleSize of them), for files whose names don't reveal
+// their formats. Recognized formats are "bam", "fasta", and "fastq" (possibly
+// gzip-compressed, which is reflected only in the media type) and "gz" (other
+// gzip-compressed files). If the format isn't recognized, empty strings are
+// returned.
+func DetectFormat(sample []byte) (format, mediaType string) {
+	if bytes.HasPrefix(sample, gzipMagic) {
+		// look at the beginning of the decompressed content (reading as much
+		// as the sample holds)
+		var content []byte
+		if reader, err := gzip.NewReader(bytes.NewReader(sample)); err == nil {
+			content, _ = io.ReadAll(io.LimitReader(reader, FormatSampleSize))
+		}
+		if bytes.HasPrefix(content, bamMagic) {
+			return "bam", "application/octet-stream"
+		}
+		if format := sequenceFormat(content); format != "" {
+			return format, "application/gzip"
+		}
+		return "gz", "application/gzip"
+	}
+	if format := sequenceFormat(sample); format != "" {
+		return format, "text/plain"
+	}
+	return "", ""
+}
+
+//-----------
+// Internals
+//-----------
+
+// magic numbers identifying gzip-compressed content and (decompressed) BAM
+// content
+var gzipMagic = []byte{0x1f, 0x8b}
+var bamMagic = []byte("BAM\x01")
+
+// returns "fasta" or "fastq" if the given sample of text begins like a FASTA
+// or FASTQ file, or an empty string otherwise
+func sequenceFormat(sample []byte) string {
+	if len(sample) == 0 || bytes.IndexByte(sample, 0) != -1 { // binary data
+		return ""
+	}
+	lines := bytes.SplitN(sample, []byte("\n"), 4)
+	switch sample[0] {
+	case '>': // FASTA header followed by a sequence
+		if len(lines) > 1 && isSequence(lines[1]) {
+			return "fasta"
+		}
+	case '@': // FASTQ header, sequence, and separator
+		if len(lines) > 2 && isSequence(lines[1]) && bytes.HasPrefix(lines[2], []byte("+")) {
+			return "fastq"
+		}
+	}
+	return ""
+}
+
+// returns true if the given line consists of (at least one) nucleotide or
+// amino acid codes
+func isSequence(line []byte) bool {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return false
+	}
+	for _, c := range line {
+		if !(('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || c == '*' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
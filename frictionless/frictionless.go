@@ -23,6 +23,7 @@ package frictionless
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/kbase/dts/credit"
@@ -37,6 +38,8 @@ type DataPackage struct {
 	Created string `json:"created,omitempty"`
 	// a Markdown description of the data package
 	Description string `json:"description,omitempty"`
+	// acknowledgments of funding for the data package (optional)
+	Funding []credit.FundingReference `json:"funding,omitempty"`
 	// a URL for a web address related to the data package
 	Homepage string `json:"homepage,omitempty"`
 	// an image to use for this data package (URL or POSIX path)
@@ -54,6 +57,9 @@ type DataPackage struct {
 	// the profile of this descriptor per the DataPackage profiles specification
 	// (https://specs.frictionlessdata.io/profiles/#language)
 	Profile string `json:"profile,omitempty"`
+	// a list of identifiers for entities related to the package as a whole, such
+	// as a collection from which all of its resources are drawn (optional)
+	RelatedIdentifiers []credit.PermanentID `json:"related_identifiers,omitempty"`
 	// a list of resources that belong to the package
 	Resources []DataResource `json:"resources"`
 	// a list identifying the sources for this resource (optional)
@@ -68,12 +74,23 @@ type DataPackage struct {
 // a Frictionless data resource describing a file in a search
 // (https://specs.frictionlessdata.io/data-resource/)
 type DataResource struct {
+	// the IDs of other resources with identical content whose file was
+	// transferred as this one's (optional, included in transfer manifests
+	// for transfers deduplicated by hash)
+	Aliases []string `json:"aliases,omitempty"`
 	// the size of the resource's file in bytes
 	Bytes int `json:"bytes"`
 	// credit metadata associated with the resource (optional for now)
 	Credit credit.CreditMetadata `json:"credit,omitempty"`
+	// a statement of any data-use agreement governing the resource (optional,
+	// included where the resource's database provides it)
+	DataUse string `json:"data_use,omitempty"`
 	// a description of the resource (optional)
 	Description string `json:"description,omitempty"`
+	// the date (YYYY-MM-DD) until which the resource is under embargo and may
+	// not be transferred (optional, included where the resource's database
+	// provides it)
+	EmbargoUntil string `json:"embargo_until,omitempty"`
 	// the character encoding for the resource's file (optional, default: UTF-8)
 	Encoding string `json:"encoding,omitempty"`
 	// any other fields requested e.g. by a search query (optional, raw JSON object)
@@ -83,8 +100,18 @@ type DataResource struct {
 	// the hash for the resource's file (algorithms other than MD5 are indicated
 	// with a prefix to the hash delimited by a colon)
 	Hash string `json:"hash"`
+	// set if the hash computed for the resource's transferred file disagrees
+	// with the one reported by its source, in which case Hash holds the
+	// computed hash and SourceHash the reported one (optional)
+	HashMismatch bool `json:"hash_mismatch,omitempty"`
+	// hashes for the resource's file keyed by algorithm (e.g. "md5", "sha256"),
+	// supplementing Hash for consumers that need a specific algorithm (optional)
+	Hashes map[string]string `json:"hashes,omitempty"`
 	// a unique identifier for the resource
 	Id string `json:"id"`
+	// metadata for the instrument that generated the resource's data (optional,
+	// included if configured and provided by the resource's database)
+	Instrument *DataInstrument `json:"instrument,omitempty"`
 	// a list identifying the license or licenses under which this resource is
 	// managed (optional)
 	Licenses []DataLicense `json:"licenses,omitempty"`
@@ -94,10 +121,19 @@ type DataResource struct {
 	Name string `json:"name"`
 	// a relative path to the resource's file within a data package directory
 	Path string `json:"path"`
+	// the size of the resource's file in human-readable units (optional, e.g.
+	// "1.2 GiB", included in search results on request)
+	SizeHuman string `json:"size_human,omitempty"`
+	// the hash reported for the resource by its source, if it disagrees with
+	// the one computed for its transferred file (optional)
+	SourceHash string `json:"source_hash,omitempty"`
 	// a list identifying the sources for this resource (optional)
 	Sources []DataSource `json:"sources,omitempty"`
 	// a title or label for the resource (optional)
 	Title string `json:"title,omitempty"`
+	// metadata for the endpoint from which the resource was transferred
+	// (optional, included in transfer manifests if configured)
+	SourceEndpoint *DataEndpoint `json:"source_endpoint,omitempty"`
 	// the name of the endpoint at which this resource is accessed (not exposed to JSON)
 	Endpoint string
 }
@@ -113,6 +149,64 @@ func (res DataResource) HashAlgorithm() string {
 	}
 }
 
+// returns the hash for the receiver's file computed with the given algorithm
+// (e.g. "md5" or "sha256"), or an empty string if none is available
+func (res DataResource) HashFor(algorithm string) string {
+	if hash, found := res.Hashes[algorithm]; found {
+		return hash
+	}
+	if res.Hash != "" && res.HashAlgorithm() == algorithm {
+		return strings.TrimPrefix(res.Hash, algorithm+":")
+	}
+	return ""
+}
+
+// returns a map of hashes keyed by algorithm holding the given MD5 checksum,
+// or nil if the checksum is empty
+func HashesForMD5(checksum string) map[string]string {
+	if checksum == "" {
+		return nil
+	}
+	return map[string]string{"md5": checksum}
+}
+
+// returns a human-readable representation of the given size in bytes using
+// binary (IEC) units, e.g. "512 B", "1.5 KiB", "1.2 GiB"
+func HumanReadableSize(bytes int) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := unit, 0
+	for n := bytes / unit; n >= unit && exp < 5; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// information about an endpoint from which a resource is transferred
+type DataEndpoint struct {
+	// the name identifying the endpoint in the DTS configuration
+	Name string `json:"name"`
+	// a descriptive title for the endpoint
+	Title string `json:"title,omitempty"`
+	// the name of the endpoint's provider (e.g. "globus")
+	Provider string `json:"provider"`
+	// a provider-specific identifier for the endpoint (optional)
+	Id string `json:"id,omitempty"`
+}
+
+// metadata for the instrument that generated a DataResource's data
+type DataInstrument struct {
+	// the name of the instrument (e.g. "NovaSeq S4")
+	Name string `json:"name,omitempty"`
+	// the platform or vendor of the instrument (e.g. "Illumina")
+	Platform string `json:"platform,omitempty"`
+	// the instrument's model (e.g. "NovaSeq 6000")
+	Model string `json:"model,omitempty"`
+}
+
 // information about the source of a DataResource
 type DataSource struct {
 	// an email address identifying a contact associated with the source (optional)
@@ -0,0 +1,133 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frictionless
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tests whether human-readable sizes are formatted properly
+func TestHumanReadableSize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("0 B", HumanReadableSize(0))
+	assert.Equal("512 B", HumanReadableSize(512))
+	assert.Equal("1023 B", HumanReadableSize(1023))
+	assert.Equal("1.0 KiB", HumanReadableSize(1024))
+	assert.Equal("1.5 KiB", HumanReadableSize(1536))
+	assert.Equal("1.0 MiB", HumanReadableSize(1024*1024))
+	assert.Equal("1.2 GiB", HumanReadableSize(1288490189))
+	assert.Equal("2.0 TiB", HumanReadableSize(2*1024*1024*1024*1024))
+}
+
+// tests whether hashes are found for the requested algorithms
+func TestHashFor(t *testing.T) {
+	assert := assert.New(t)
+
+	// a legacy hash with no algorithm prefix is an MD5 checksum
+	resource := DataResource{Hash: "d41d8cd98f00b204e9800998ecf8427e"}
+	assert.Equal("d41d8cd98f00b204e9800998ecf8427e", resource.HashFor("md5"))
+	assert.Equal("", resource.HashFor("sha256"))
+
+	// a prefixed hash names its algorithm
+	resource = DataResource{Hash: "sha256:e3b0c442"}
+	assert.Equal("", resource.HashFor("md5"))
+	assert.Equal("e3b0c442", resource.HashFor("sha256"))
+
+	// hashes keyed by algorithm take precedence
+	resource = DataResource{
+		Hash:   "d41d8cd98f00b204e9800998ecf8427e",
+		Hashes: map[string]string{"md5": "d41d8cd98f00b204e9800998ecf8427e", "sha256": "e3b0c442"},
+	}
+	assert.Equal("d41d8cd98f00b204e9800998ecf8427e", resource.HashFor("md5"))
+	assert.Equal("e3b0c442", resource.HashFor("sha256"))
+}
+
+// tests whether MD5 checksums are keyed properly
+func TestHashesForMD5(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(HashesForMD5(""))
+	assert.Equal(map[string]string{"md5": "d41d8cd98f00b204e9800998ecf8427e"},
+		HashesForMD5("d41d8cd98f00b204e9800998ecf8427e"))
+}
+
+// returns the gzip-compressed form of the given content
+func gzipped(content []byte) []byte {
+	var b bytes.Buffer
+	writer := gzip.NewWriter(&b)
+	writer.Write(content)
+	writer.Close()
+	return b.Bytes()
+}
+
+// tests whether formats are detected from samples of file content
+func TestDetectFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	fasta := []byte(">seq1 Prochlorococcus marinus\nACGTACGTNNACGT\n>seq2\nMKV*\n")
+	fastq := []byte("@read1\nACGTACGT\n+\nIIIIIIII\n@read2\nTTGA\n+\nIIII\n")
+
+	format, mediaType := DetectFormat(fasta)
+	assert.Equal("fasta", format)
+	assert.Equal("text/plain", mediaType)
+
+	format, mediaType = DetectFormat(fastq)
+	assert.Equal("fastq", format)
+	assert.Equal("text/plain", mediaType)
+
+	// compressed sequence data is identified by its content
+	format, mediaType = DetectFormat(gzipped(fastq))
+	assert.Equal("fastq", format)
+	assert.Equal("application/gzip", mediaType)
+
+	// other compressed data is simply gzipped
+	format, mediaType = DetectFormat(gzipped([]byte("just some text")))
+	assert.Equal("gz", format)
+	assert.Equal("application/gzip", mediaType)
+
+	// BAM files are compressed and identified by their own magic number
+	format, _ = DetectFormat(gzipped([]byte("BAM\x01\x00\x00\x00\x00")))
+	assert.Equal("bam", format)
+
+	// a truncated sample of a large compressed file still works
+	largeFasta := []byte(">seq\n" + strings.Repeat("ACGTTGCAAGCT", 10000) + "\n")
+	sample := gzipped(largeFasta)[:64]
+	format, _ = DetectFormat(sample)
+	assert.Equal("fasta", format)
+
+	// unrecognized content
+	for _, sample := range [][]byte{
+		nil,
+		[]byte("name,size\nfile1,12\n"),
+		[]byte(">not a sequence\n1234\n"),
+		[]byte("@user mentioned\nsomething\n"),
+		{0x00, 0x01, 0x02},
+	} {
+		format, mediaType = DetectFormat(sample)
+		assert.Equal("", format, string(sample))
+		assert.Equal("", mediaType)
+	}
+}
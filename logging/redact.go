@@ -0,0 +1,195 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// This package provides logging utilities for the Data Transfer System.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// the text that replaces redacted values
+const Redacted = "[REDACTED]"
+
+// the names of the fields redacted from the log by default
+var DefaultRedactedFields = []string{
+	"email",
+	"token",
+	"access_token",
+	"authorization",
+	"client_secret",
+	"password",
+}
+
+// matches email addresses
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// matches bearer and basic credentials (e.g. in Authorization headers)
+var credentialsPattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`)
+
+// This type removes the values of sensitive fields from log text.
+type redactor struct {
+	// matches a field name and its value, e.g. "email": "x@y.org",
+	// email=x@y.org, or Email:x@y.org
+	fieldPattern *regexp.Regexp
+	// set if email addresses and credentials are redacted wherever they appear
+	redactEmails, redactCredentials bool
+	// lowercased names of the redacted fields
+	fields []string
+}
+
+func newRedactor(fields []string) redactor {
+	r := redactor{fields: make([]string, len(fields))}
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		r.fields[i] = strings.ToLower(field)
+		quoted[i] = regexp.QuoteMeta(field)
+	}
+	if len(fields) > 0 {
+		r.fieldPattern = regexp.MustCompile(`(?i)(["']?\b(?:` + strings.Join(quoted, "|") +
+			`)\b["']?\s*[:=]\s*)(\[[^\]]*\]|"[^"]*"|(?:Bearer|Basic)\s+[^\s",&}\]]+|[^\s",&}\]]+)`)
+	}
+	r.redactEmails = slices.Contains(r.fields, "email")
+	r.redactCredentials = slices.Contains(r.fields, "authorization")
+	return r
+}
+
+// returns true if the field with the given name is redacted
+func (r redactor) redactsField(name string) bool {
+	return slices.Contains(r.fields, strings.ToLower(name))
+}
+
+// returns the given text with the values of redacted fields (and, if these
+// fields are redacted, any email addresses and credentials) replaced
+func (r redactor) scrub(text string) string {
+	if r.fieldPattern != nil {
+		text = r.fieldPattern.ReplaceAllStringFunc(text, func(match string) string {
+			parts := r.fieldPattern.FindStringSubmatch(match)
+			if strings.HasPrefix(parts[2], `"`) || strings.HasPrefix(parts[2], "[") {
+				return parts[1] + `"` + Redacted + `"` // keeps JSON valid
+			}
+			return parts[1] + Redacted
+		})
+	}
+	if r.redactCredentials {
+		text = credentialsPattern.ReplaceAllString(text, "$1 "+Redacted)
+	}
+	if r.redactEmails {
+		text = emailPattern.ReplaceAllString(text, Redacted)
+	}
+	return text
+}
+
+// returns the given attribute with any sensitive values redacted
+func (r redactor) attr(a slog.Attr) slog.Attr {
+	if r.redactsField(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.scrub(value.String()))
+	case slog.KindGroup:
+		attrs := value.Group()
+		redacted := make([]any, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = r.attr(attr)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		// errors and stringers are logged (and scrubbed) as their text, since
+		// they often have no exported fields to encode
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.scrub(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.scrub(v.String()))
+		}
+		// scrub the value's JSON representation if it has a meaningful one
+		// (or failing that, its text)
+		if data, err := json.Marshal(value.Any()); err == nil && string(data) != "{}" {
+			scrubbed := r.scrub(string(data))
+			if json.Valid([]byte(scrubbed)) {
+				return slog.Any(a.Key, json.RawMessage(scrubbed))
+			}
+			return slog.String(a.Key, scrubbed)
+		}
+		return slog.String(a.Key, r.scrub(fmt.Sprintf("%+v", value.Any())))
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// This slog.Handler removes the values of sensitive fields from log records
+// (their messages and attributes) before passing them on to another handler.
+type redactingHandler struct {
+	handler  slog.Handler
+	redactor redactor
+}
+
+// Returns a handler that redacts the values of the fields with the given
+// (case-insensitive) names from log records before passing them to the given
+// handler. Fields are redacted wherever they appear as attributes, or as
+// "name": value, name=value, or name:value in message text. If "email" is one
+// of the fields, email addresses are redacted wherever they appear, and if
+// "authorization" is one of them, so are bearer and basic credentials.
+func NewRedactingHandler(handler slog.Handler, fields []string) slog.Handler {
+	return &redactingHandler{
+		handler:  handler,
+		redactor: newRedactor(fields),
+	}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.scrub(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.attr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactor.attr(a)
+	}
+	return &redactingHandler{
+		handler:  h.handler.WithAttrs(redacted),
+		redactor: h.redactor,
+	}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{
+		handler:  h.handler.WithGroup(name),
+		redactor: h.redactor,
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// returns a logger that redacts the default fields, and the buffer to which
+// it writes JSON records
+func redactingLogger() (*slog.Logger, *bytes.Buffer) {
+	var logs bytes.Buffer
+	handler := slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(NewRedactingHandler(handler, DefaultRedactedFields)), &logs
+}
+
+// checks that an email address in a descriptor is redacted from both a log
+// message and a log attribute
+func TestRedactDescriptorEmail(t *testing.T) {
+	assert := assert.New(t)
+
+	descriptor := frictionless.DataResource{
+		Id:   "JDP:12345",
+		Name: "sample",
+		Path: "data/sample.fastq",
+		Sources: []frictionless.DataSource{
+			{Title: "Jane Investigator", Email: "pi@example.org"},
+		},
+	}
+
+	logger, logs := redactingLogger()
+	logger.Debug(fmt.Sprintf("Fetched descriptor: %+v", descriptor))
+	logger.Debug("Fetched descriptor", "descriptor", descriptor)
+
+	output := logs.String()
+	assert.NotContains(output, "pi@example.org")
+	assert.Contains(output, Redacted)
+	assert.Contains(output, "JDP:12345") // the rest is left alone
+
+	// structured attributes remain valid JSON
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	assert.Equal(2, len(lines))
+	var record struct {
+		Descriptor frictionless.DataResource `json:"descriptor"`
+	}
+	err := json.Unmarshal([]byte(lines[1]), &record)
+	assert.Nil(err)
+	assert.Equal(Redacted, record.Descriptor.Sources[0].Email)
+	assert.Equal("Jane Investigator", record.Descriptor.Sources[0].Title)
+}
+
+// checks that the Authorization header of a request is redacted from log
+// output
+func TestRedactAuthorizationHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	request, err := http.NewRequest(http.MethodGet,
+		"https://example.com/files?token=s3cr3t-t0ken&id=1", http.NoBody)
+	assert.Nil(err)
+	request.Header.Set("Authorization", "Bearer abcdef123456")
+
+	logger, logs := redactingLogger()
+	logger.Debug(fmt.Sprintf("GET %s (headers: %v)", request.URL, request.Header))
+	logger.Debug("Sending request", "headers", request.Header)
+	logger.With("authorization", request.Header.Get("Authorization")).Info("Request sent")
+
+	output := logs.String()
+	assert.NotContains(output, "abcdef123456")
+	assert.NotContains(output, "s3cr3t-t0ken")
+	assert.Contains(output, "id=1")
+}
+
+// checks that only the configured fields are redacted
+func TestRedactConfiguredFields(t *testing.T) {
+	assert := assert.New(t)
+
+	var logs bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewJSONHandler(&logs, nil), []string{"api_key"}))
+	logger.Info("Calling database: api_key=abc123, contact pi@example.org", "API_KEY", "xyz789")
+
+	output := logs.String()
+	assert.NotContains(output, "abc123")
+	assert.NotContains(output, "xyz789")
+	assert.Contains(output, "pi@example.org") // emails aren't redacted here
+
+	// no fields, no redaction
+	logs.Reset()
+	logger = slog.New(NewRedactingHandler(slog.NewJSONHandler(&logs, nil), nil))
+	logger.Info("api_key=abc123 pi@example.org")
+	assert.Contains(logs.String(), "api_key=abc123 pi@example.org")
+}
+
+// checks that errors and other values without meaningful JSON encodings are
+// logged (and redacted) as their text
+func TestRedactErrorAttributes(t *testing.T) {
+	assert := assert.New(t)
+
+	logger, logs := redactingLogger()
+	logger.Info("Request failed", "error", errors.New("boom"))
+	logger.Info("Request failed", "error", fmt.Errorf("notifying pi@example.org: %w", errors.New("boom")))
+	logger.Info("Request failed", "handler", struct{ name string }{name: "transfers"})
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	assert.Equal(3, len(lines))
+	var record map[string]any
+	err := json.Unmarshal([]byte(lines[0]), &record)
+	assert.Nil(err)
+	assert.Equal("boom", record["error"])
+	err = json.Unmarshal([]byte(lines[1]), &record)
+	assert.Nil(err)
+	assert.Equal("notifying "+Redacted+": boom", record["error"])
+	err = json.Unmarshal([]byte(lines[2]), &record)
+	assert.Nil(err)
+	assert.Equal("{name:transfers}", record["handler"])
+}
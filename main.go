@@ -33,6 +33,7 @@ import (
 	"time"
 
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/logging"
 	"github.com/kbase/dts/services"
 )
 
@@ -62,7 +63,8 @@ func enableLogging() {
 	}
 	handler := slog.NewJSONHandler(os.Stdout,
 		&slog.HandlerOptions{Level: logLevel})
-	slog.SetDefault(slog.New(handler))
+	slog.SetDefault(slog.New(logging.NewRedactingHandler(handler,
+		config.Service.RedactedLogFields)))
 	slog.Debug("Debug logging enabled.")
 }
 
@@ -119,8 +121,9 @@ func main() {
 	// block till we receive one of the above signals
 	<-sigChan
 
-	// create a deadline to wait for
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// create a deadline to wait for (allowing for transfers to be drained)
+	drainTimeout := time.Duration(config.Service.DrainTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout+30*time.Second)
 	defer cancel()
 
 	// wait for connections to close until the deadline elapses
@@ -0,0 +1,214 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// This package provides a minimal set of counters, gauges, and histograms that
+// can be written in the Prometheus text exposition format. See
+// https://prometheus.io/docs/instrumenting/exposition_formats/ for details.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A counter holds a value that only increases.
+type Counter struct {
+	mutex sync.Mutex
+	value float64
+}
+
+// increments the counter by 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// adds the given (non-negative) amount to the counter
+func (c *Counter) Add(amount float64) {
+	if amount < 0 {
+		return
+	}
+	c.mutex.Lock()
+	c.value += amount
+	c.mutex.Unlock()
+}
+
+// returns the current value of the counter
+func (c *Counter) Value() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.value
+}
+
+// A gauge holds a value that can increase or decrease.
+type Gauge struct {
+	mutex sync.Mutex
+	value float64
+}
+
+// sets the value of the gauge
+func (g *Gauge) Set(value float64) {
+	g.mutex.Lock()
+	g.value = value
+	g.mutex.Unlock()
+}
+
+// returns the current value of the gauge
+func (g *Gauge) Value() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.value
+}
+
+// A histogram counts observations in cumulative buckets with given upper
+// bounds.
+type Histogram struct {
+	mutex  sync.Mutex
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// records an observation in the histogram
+func (h *Histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// registers a counter with the given name and help text (replacing any metric
+// registered with that name), returning it
+func NewCounter(name, help string) *Counter {
+	counter := &Counter{}
+	register(name, help, "counter", counter)
+	return counter
+}
+
+// registers a gauge with the given name and help text (replacing any metric
+// registered with that name), returning it
+func NewGauge(name, help string) *Gauge {
+	gauge := &Gauge{}
+	register(name, help, "gauge", gauge)
+	return gauge
+}
+
+// registers a histogram with the given name, help text, and bucket upper
+// bounds (replacing any metric registered with that name), returning it
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	histogram := &Histogram{
+		bounds: make([]float64, len(bounds)),
+		counts: make([]uint64, len(bounds)),
+	}
+	copy(histogram.bounds, bounds)
+	sort.Float64s(histogram.bounds)
+	register(name, help, "histogram", histogram)
+	return histogram
+}
+
+// writes all registered metrics to the given writer in the Prometheus text
+// exposition format
+func Write(w io.Writer) error {
+	registryMutex_.Lock()
+	defer registryMutex_.Unlock()
+	for _, m := range registry_ {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type)
+		if err != nil {
+			return err
+		}
+		switch metric := m.Metric.(type) {
+		case *Counter:
+			_, err = fmt.Fprintf(w, "%s %s\n", m.Name, formatValue(metric.Value()))
+		case *Gauge:
+			_, err = fmt.Fprintf(w, "%s %s\n", m.Name, formatValue(metric.Value()))
+		case *Histogram:
+			err = writeHistogram(w, m.Name, metric)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------
+// Internals
+//-----------
+
+// a registered metric
+type registeredMetric struct {
+	Name, Help, Type string
+	Metric           interface{}
+}
+
+// registered metrics in the order of registration
+var registry_ []registeredMetric
+var registryMutex_ sync.Mutex
+
+// registers the given metric, replacing any already registered under the same
+// name (which the exposition format allows only once)
+func register(name, help, metricType string, metric interface{}) {
+	registryMutex_.Lock()
+	defer registryMutex_.Unlock()
+	registered := registeredMetric{
+		Name:   name,
+		Help:   help,
+		Type:   metricType,
+		Metric: metric,
+	}
+	for i := range registry_ {
+		if registry_[i].Name == name {
+			registry_[i] = registered
+			return
+		}
+	}
+	registry_ = append(registry_, registered)
+}
+
+func writeHistogram(w io.Writer, name string, h *Histogram) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, bound := range h.bounds {
+		_, err := fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatValue(bound), h.counts[i])
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n",
+		name, h.count, name, formatValue(h.sum), name, h.count)
+	return err
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
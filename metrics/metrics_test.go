@@ -0,0 +1,71 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	registryMutex_.Lock()
+	registry_ = nil
+	registryMutex_.Unlock()
+
+	// a metric registered again under the same name replaces the original
+	NewCounter("test_things_total", "Number of things")
+	counter := NewCounter("test_things_total", "Number of things")
+	gauge := NewGauge("test_things_active", "Number of active things")
+	histogram := NewHistogram("test_thing_duration_seconds", "Duration of things",
+		[]float64{10, 1})
+
+	counter.Inc()
+	counter.Add(2)
+	counter.Add(-5) // ignored
+	assert.Equal(3.0, counter.Value())
+	gauge.Set(4)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	histogram.Observe(50)
+
+	var b strings.Builder
+	err := Write(&b)
+	assert.Nil(err)
+	assert.Equal(`# HELP test_things_total Number of things
+# TYPE test_things_total counter
+test_things_total 3
+# HELP test_things_active Number of active things
+# TYPE test_things_active gauge
+test_things_active 4
+# HELP test_thing_duration_seconds Duration of things
+# TYPE test_thing_duration_seconds histogram
+test_thing_duration_seconds_bucket{le="1"} 1
+test_thing_duration_seconds_bucket{le="10"} 2
+test_thing_duration_seconds_bucket{le="+Inf"} 3
+test_thing_duration_seconds_sum 55.5
+test_thing_duration_seconds_count 3
+`, b.String())
+}
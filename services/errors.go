@@ -0,0 +1,188 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package services
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/tasks"
+)
+
+// an error response body: an RFC 9457 problem description with a
+// machine-readable code identifying the failure
+type ErrorResponse struct {
+	huma.ErrorModel
+	Code string `json:"code,omitempty" example:"database_not_found" doc:"A machine-readable code identifying the error"`
+}
+
+// creates an error with the given HTTP status, error code, and message
+func apiError(status int, code, msg string, errs ...error) huma.StatusError {
+	model := newErrorModel(status, msg, errs...).(*huma.ErrorModel)
+	return &ErrorResponse{
+		ErrorModel: *model,
+		Code:       code,
+	}
+}
+
+// huma's own error constructor, which we wrap so that errors generated by huma
+// itself (e.g. for request validation) also carry a code
+var newErrorModel = huma.NewError
+
+func init() {
+	huma.NewError = func(status int, msg string, errs ...error) huma.StatusError {
+		return apiError(status, codeForStatus(status), msg, errs...)
+	}
+}
+
+// returns a generic error code for the given HTTP status, used for errors that
+// aren't assigned a more specific code
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusNotAcceptable:
+		return "not_acceptable"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnprocessableEntity:
+		return "invalid_request"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	default:
+		if status >= 500 {
+			return "internal_error"
+		}
+		return ""
+	}
+}
+
+// translates database-related errors to API errors with appropriate statuses
+// and codes
+func databaseError(err error) error {
+	if err != nil {
+		slog.Error(err.Error())
+		switch err.(type) {
+		case databases.InvalidSearchParameter, *databases.InvalidSearchParameter:
+			return apiError(http.StatusBadRequest, "invalid_search_parameter", err.Error())
+		case databases.UnavailableError, *databases.UnavailableError:
+			return apiError(http.StatusServiceUnavailable, "database_unavailable", err.Error())
+		case databases.TimeoutError, *databases.TimeoutError:
+			return apiError(http.StatusGatewayTimeout, "database_timeout", err.Error())
+		case databases.UnauthorizedError, *databases.UnauthorizedError:
+			return apiError(http.StatusUnauthorized, "unauthorized", err.Error())
+		case databases.PermissionDeniedError, *databases.PermissionDeniedError:
+			return apiError(http.StatusForbidden, "permission_denied", err.Error())
+		case databases.NotFoundError, *databases.NotFoundError:
+			return apiError(http.StatusNotFound, "database_not_found", err.Error())
+		case databases.ResourceNotFoundError, *databases.ResourceNotFoundError:
+			return apiError(http.StatusNotFound, "resource_not_found", err.Error())
+		case databases.ResourceEndpointNotFoundError, *databases.ResourceEndpointNotFoundError:
+			return apiError(http.StatusNotFound, "resource_endpoint_not_found", err.Error())
+		default:
+			return apiError(http.StatusInternalServerError, "internal_error", err.Error())
+		}
+	}
+	return nil
+}
+
+// translates task-related errors to API errors with appropriate statuses and
+// codes
+func taskError(err error) error {
+	switch err.(type) {
+	case tasks.NotFoundError, *tasks.NotFoundError:
+		slog.Error(err.Error())
+		return apiError(http.StatusNotFound, "transfer_not_found", err.Error())
+	case tasks.ManifestNotFoundError, *tasks.ManifestNotFoundError:
+		slog.Error(err.Error())
+		return apiError(http.StatusNotFound, "manifest_not_found", err.Error())
+	case tasks.DrainingError, *tasks.DrainingError:
+		slog.Error(err.Error())
+		return apiError(http.StatusServiceUnavailable, "shutting_down", err.Error())
+	case tasks.NoFilesRequestedError, *tasks.NoFilesRequestedError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "no_files_requested", err.Error())
+	case tasks.PayloadTooLargeError, *tasks.PayloadTooLargeError:
+		slog.Error(err.Error())
+		return apiError(http.StatusRequestEntityTooLarge, "payload_too_large", err.Error())
+	case tasks.TooManyFilesError, *tasks.TooManyFilesError:
+		slog.Error(err.Error())
+		return apiError(http.StatusRequestEntityTooLarge, "too_many_files", err.Error())
+	case tasks.InsufficientSpaceError, *tasks.InsufficientSpaceError:
+		slog.Error(err.Error())
+		return apiError(http.StatusInsufficientStorage, "insufficient_storage", err.Error())
+	case tasks.TransferNotAllowedError, *tasks.TransferNotAllowedError:
+		slog.Error(err.Error())
+		return apiError(http.StatusForbidden, "transfer_not_allowed", err.Error())
+	case tasks.InvalidPriorityError, *tasks.InvalidPriorityError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "invalid_priority", err.Error())
+	case tasks.FilesNotFoundError, *tasks.FilesNotFoundError:
+		// report each unrecognized file as a separate error detail
+		notFound, isValue := err.(tasks.FilesNotFoundError)
+		if !isValue {
+			notFound = *err.(*tasks.FilesNotFoundError)
+		}
+		errs := make([]error, len(notFound.FileIds))
+		for i, fileId := range notFound.FileIds {
+			errs[i] = databases.ResourceNotFoundError{
+				Database:   notFound.Database,
+				ResourceId: fileId,
+			}
+		}
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "resource_not_found", err.Error(), errs...)
+	case tasks.InvalidLabelError, *tasks.InvalidLabelError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "invalid_label", err.Error())
+	case tasks.InvalidDestinationsError, *tasks.InvalidDestinationsError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "invalid_destinations", err.Error())
+	case tasks.InvalidCallbackURLError, *tasks.InvalidCallbackURLError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "invalid_callback_url", err.Error())
+	case tasks.InvalidBaseTransferError, *tasks.InvalidBaseTransferError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "invalid_base_transfer", err.Error())
+	case tasks.NoChangedFilesError, *tasks.NoChangedFilesError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "no_changed_files", err.Error())
+	case endpoints.InvalidTransferOptionError, *endpoints.InvalidTransferOptionError:
+		slog.Error(err.Error())
+		return apiError(http.StatusBadRequest, "invalid_endpoint_option", err.Error())
+	case endpoints.UnencryptedEndpointError, *endpoints.UnencryptedEndpointError:
+		slog.Error(err.Error())
+		return apiError(http.StatusForbidden, "encryption_required", err.Error())
+	default:
+		return databaseError(err)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/kbase/dts/config"
+)
+
+// The DTS can render search results as comma- or tab-separated values in
+// addition to JSON, either by request (format=csv or format=tsv) or via
+// content negotiation (Accept: text/csv or text/tab-separated-values). Each
+// row describes a resource, and the columns are the requested resource fields
+// (or a default set of columns if no fields were requested). Nested fields
+// (e.g. credit) are written as compact JSON.
+//
+// Search results can also be streamed as newline-delimited JSON (format=ndjson
+// or Accept: application/x-ndjson), with one resource descriptor per line, so
+// clients can process large result sets without parsing a single JSON array.
+//
+// Formats other than JSON have no place for pagination information, so it's
+// also sent in the X-Total-Count and X-Next-Offset headers of search results.
+
+// columns included in delimited search results if no fields are requested
+var defaultDelimitedColumns = []string{"id", "name", "path", "format", "media_type", "bytes", "hash"}
+
+// content types for delimited search results
+const (
+	csvContentType = "text/csv"
+	tsvContentType = "text/tab-separated-values"
+)
+
+// content type for newline-delimited JSON search results
+const ndjsonContentType = "application/x-ndjson"
+
+// returns the formats available for content negotiation, including the
+// default (JSON) formats and those for delimited search results
+func responseFormats() map[string]huma.Format {
+	formats := make(map[string]huma.Format)
+	for name, format := range huma.DefaultFormats {
+		formats[name] = format
+	}
+	formats[csvContentType] = delimitedFormat(',')
+	formats["csv"] = delimitedFormat(',')
+	formats[tsvContentType] = delimitedFormat('\t')
+	formats["tsv"] = delimitedFormat('\t')
+	formats[ndjsonContentType] = ndjsonFormat()
+	formats["ndjson"] = ndjsonFormat()
+	return formats
+}
+
+// returns the content type for the given search results format ("json",
+// "csv", "tsv", or "ndjson"), or an empty string if the type is to be
+// negotiated with the client
+func contentTypeForFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "":
+		return "", nil
+	case "json":
+		return "application/json", nil
+	case "csv":
+		return csvContentType, nil
+	case "tsv":
+		return tsvContentType, nil
+	case "ndjson":
+		return ndjsonContentType, nil
+	default:
+		return "", apiError(http.StatusBadRequest, "invalid_format",
+			"Invalid format: "+format+" (must be json, csv, tsv, or ndjson)")
+	}
+}
+
+// returns a format that writes search results as delimited values using the
+// given delimiter
+func delimitedFormat(delimiter rune) huma.Format {
+	return huma.Format{
+		Marshal: func(w io.Writer, v any) error {
+			return writeDelimited(w, v, delimiter)
+		},
+		Unmarshal: json.Unmarshal, // we only write delimited values
+	}
+}
+
+// writes search results to the given writer as delimited values
+func writeDelimited(w io.Writer, v any, delimiter rune) error {
+	// the response body may have been transformed into a type that we can't
+	// name, so we extract the relevant parts from its JSON representation
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var results struct {
+		Fields    []string                     `json:"fields"`
+		Resources []map[string]json.RawMessage `json:"resources"`
+	}
+	err = json.Unmarshal(data, &results)
+	if err != nil || results.Resources == nil {
+		// not search results (e.g. an error), so we send it as is
+		_, err = w.Write(data)
+		return err
+	}
+
+	columns := results.Fields
+	if len(columns) == 0 {
+		columns = defaultDelimitedColumns
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+	err = writer.Write(columns)
+	if err != nil {
+		return err
+	}
+	row := make([]string, len(columns))
+	for _, resource := range results.Resources {
+		for i, column := range columns {
+			row[i] = delimitedValue(resource[column])
+		}
+		err = writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// converts a JSON value to a string suitable for a delimited column
+func delimitedValue(value json.RawMessage) string {
+	if len(value) == 0 || string(value) == "null" {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(value, &s) == nil { // strings are unquoted
+		return s
+	}
+	var compacted bytes.Buffer
+	if json.Compact(&compacted, value) == nil {
+		return compacted.String()
+	}
+	return string(value)
+}
+
+// returns a format that writes search results as newline-delimited JSON
+func ndjsonFormat() huma.Format {
+	return huma.Format{
+		Marshal:   writeNdjson,
+		Unmarshal: json.Unmarshal, // we only write newline-delimited JSON
+	}
+}
+
+// writes the resources in search results to the given writer as
+// newline-delimited JSON, one compact descriptor per line, flushing each line
+// as it's written so that clients can process resources as they arrive
+// (pagination information is sent in the response's headers). The server's
+// write timeout is restarted for each line, so a long stream isn't cut off as
+// long as lines keep arriving.
+func writeNdjson(w io.Writer, v any) error {
+	resources, ok := searchResultResources(v)
+	if !ok { // not search results (e.g. an error), so we send it as is
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	encoder := json.NewEncoder(w) // writes compact JSON followed by a newline
+	flusher, canFlush := w.(http.Flusher)
+	var controller *http.ResponseController
+	if rw, ok := w.(http.ResponseWriter); ok && config.Service.WriteTimeout > 0 {
+		controller = http.NewResponseController(rw)
+	}
+	for i := 0; i < resources.Len(); i++ {
+		if controller != nil {
+			// not all writers support deadlines, and that's fine
+			controller.SetWriteDeadline(time.Now().Add(
+				time.Duration(config.Service.WriteTimeout) * time.Second))
+		}
+		err := encoder.Encode(resources.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
+// returns the resources in the given response body if it holds search
+// results. The body may have been transformed into a type that we can't name,
+// so we look for its resources by field name.
+func searchResultResources(v any) (reflect.Value, bool) {
+	body := reflect.Indirect(reflect.ValueOf(v))
+	if body.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	resources := body.FieldByName("Resources")
+	if resources.Kind() != reflect.Slice {
+		return reflect.Value{}, false
+	}
+	return resources, true
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// the header in which the service returns the ID it assigns to a request
+const requestIdHeader = "X-Request-Id"
+
+// context keys for request-scoped values
+type requestIdKey struct{}
+type requestLoggerKey struct{}
+
+// huma middleware that assigns a unique ID to each request and attaches it
+// (along with a logger that records it) to the request's context
+func withRequestLogger(ctx huma.Context, next func(huma.Context)) {
+	requestId := uuid.NewString()
+	ctx.SetHeader(requestIdHeader, requestId)
+	ctx = huma.WithValue(ctx, requestIdKey{}, requestId)
+	ctx = huma.WithValue(ctx, requestLoggerKey{}, slog.Default().With("request_id", requestId))
+	next(ctx)
+}
+
+// returns the ID assigned to the request with the given context, or an empty
+// string if no ID was assigned
+func requestIdFromContext(ctx context.Context) string {
+	if requestId, ok := ctx.Value(requestIdKey{}).(string); ok {
+		return requestId
+	}
+	return ""
+}
+
+// returns a logger that records the ID of the request with the given context,
+// falling back to the default logger if no ID was assigned
+func requestLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(requestLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// returns a logger that records the ID of the request with the given context
+// and the ORCID of the authenticated client that made it
+func clientLogger(ctx context.Context, orcid string) *slog.Logger {
+	return requestLogger(ctx).With("orcid", orcid)
+}
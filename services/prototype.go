@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
@@ -22,8 +23,11 @@ import (
 
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/metrics"
 	"github.com/kbase/dts/tasks"
 )
 
@@ -60,6 +64,12 @@ func NewDTSPrototype() (TransferService, error) {
 		return nil, fmt.Errorf("No endpoints were specified.")
 	}
 
+	// validated access tokens are cached for the configured period
+	auth.SetCacheTTL(time.Duration(config.Service.AuthCacheTTL) * time.Second)
+
+	// requests to databases identify the service and its version
+	databases.SetUserAgent(config.Service.UserAgent, version)
+
 	service := new(prototype)
 	service.Name = "DTS prototype"
 	service.Version = version
@@ -67,27 +77,50 @@ func NewDTSPrototype() (TransferService, error) {
 
 	// set up routing
 	service.Router = mux.NewRouter()
-	api := humamux.New(service.Router, huma.DefaultConfig(service.Name, service.Version))
+	apiConfig := huma.DefaultConfig(service.Name, service.Version)
+	apiConfig.Formats = responseFormats()
+	api := humamux.New(service.Router, apiConfig)
+	api.UseMiddleware(withRequestLogger)
 	huma.Get(api, "/", service.getRoot)
+	huma.Get(api, "/health", service.getHealth)
 
 	// API v1
+	huma.Get(api, "/api/v1/providers", service.getProviders)
 	huma.Get(api, "/api/v1/databases", service.getDatabases)
 	huma.Get(api, "/api/v1/databases/{db}", service.getDatabase)
 	huma.Get(api, "/api/v1/databases/{db}/search-parameters", service.getDatabaseSearchParameters)
+	huma.Get(api, "/api/v1/databases/{db}/endpoints", service.getDatabaseEndpoints)
+	huma.Get(api, "/api/v1/endpoints", service.getEndpoints)
 	huma.Get(api, "/api/v1/files", service.searchDatabase)
-	huma.Post(api, "/api/v1/files", service.searchDatabaseWithSpecificParams)
+	huma.Post(api, "/api/v1/files", service.searchDatabaseWithSpecificParams, limitRequestBody)
 	huma.Get(api, "/api/v1/files/by-id", service.fetchFileMetadata)
-	huma.Post(api, "/api/v1/transfers", service.createTransfer)
+	huma.Get(api, "/api/v1/transfers", service.listTransfers)
+	huma.Post(api, "/api/v1/transfers", service.createTransfer, limitRequestBody)
+	huma.Register(api, huma.Operation{
+		OperationID:  "post-api-v1-transfers-upload",
+		Method:       http.MethodPost,
+		Path:         "/api/v1/transfers/upload",
+		Summary:      "Create a transfer of the files listed in an uploaded manifest",
+		MaxBodyBytes: maxManifestUploadSize,
+	}, service.createTransferFromManifest)
 	huma.Get(api, "/api/v1/transfers/{id}", service.getTransferStatus)
+	huma.Get(api, "/api/v1/transfers/{id}/manifest", service.getTransferManifest)
 	huma.Delete(api, "/api/v1/transfers/{id}", service.deleteTransfer)
+	huma.Post(api, "/api/v1/transfers/{id}/retry", service.retryTransfer)
+
+	// administrative endpoints
+	huma.Delete(api, "/api/v1/admin/transfers/{id}", service.purgeTransfer)
+
+	// Prometheus metrics (plain text, so this bypasses the API wrapper)
+	service.Router.HandleFunc("/metrics", service.getMetrics).Methods(http.MethodGet)
 
 	return service, nil
 }
 
 // starts the prototype data transfer service
 func (service *prototype) Start(port int) error {
-	slog.Info(fmt.Sprintf("Starting %s v%s on port %d...", service.Name, version, port))
-	slog.Info(fmt.Sprintf("(Accepting up to %d connections)", config.Service.MaxConnections))
+	slog.Info("Starting service", "name", service.Name, "version", version,
+		"port", port, "max_connections", config.Service.MaxConnections)
 
 	service.StartTime = time.Now()
 
@@ -108,7 +141,13 @@ func (service *prototype) Start(port int) error {
 
 	// start the server
 	service.Server = &http.Server{
-		Handler: service.Router}
+		Handler:           service.Router,
+		ReadHeaderTimeout: time.Duration(config.Service.ReadTimeout) * time.Second,
+		ReadTimeout:       time.Duration(config.Service.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(config.Service.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(config.Service.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
 	err = service.Server.Serve(listener)
 
 	// we don't report the server closing as an error
@@ -118,8 +157,19 @@ func (service *prototype) Start(port int) error {
 	return nil
 }
 
-// gracefully shuts down the service without interrupting active connections
+// gracefully shuts down the service without interrupting active connections,
+// first refusing new transfers and letting file transfers performed by the
+// service itself finish (up to the configured drain timeout)
 func (service *prototype) Shutdown(ctx context.Context) error {
+	if tasks.Running() {
+		drainCtx, cancel := context.WithTimeout(ctx,
+			time.Duration(config.Service.DrainTimeout)*time.Second)
+		err := tasks.Drain(drainCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("Transfers in progress were not drained", "error", err.Error())
+		}
+	}
 	tasks.Stop()
 	if service.Server != nil {
 		return service.Server.Shutdown(ctx)
@@ -139,6 +189,15 @@ func (service *prototype) Close() {
 // Internals
 //-----------
 
+// maximum size of the headers of a request to the service
+const maxHeaderBytes = 64 * 1024
+
+// limits the size of the body of a request to the given operation to the
+// configured maximum (larger requests are rejected with a 413 response)
+func limitRequestBody(op *huma.Operation) {
+	op.MaxBodyBytes = config.Service.MaxBodyBytes
+}
+
 // Version numbers
 var majorVersion = 0
 var minorVersion = 2
@@ -152,12 +211,13 @@ var version = fmt.Sprintf("%d.%d.%d", majorVersion, minorVersion, patchVersion)
 // encountered)
 func authorize(authorizationHeader string) (auth.Client, error) {
 	if !strings.Contains(authorizationHeader, "Bearer") {
-		return auth.Client{}, fmt.Errorf("Invalid authorization header")
+		return auth.Client{}, apiError(http.StatusUnauthorized, "invalid_authorization",
+			"Invalid authorization header")
 	}
 	b64Token := authorizationHeader[len("Bearer "):]
 	accessTokenBytes, err := base64.StdEncoding.DecodeString(b64Token)
 	if err != nil {
-		return auth.Client{}, huma.Error401Unauthorized(err.Error())
+		return auth.Client{}, apiError(http.StatusUnauthorized, "invalid_authorization", err.Error())
 	}
 	accessToken := strings.TrimSpace(string(accessTokenBytes))
 
@@ -173,7 +233,8 @@ func authorize(authorizationHeader string) (auth.Client, error) {
 	}
 	// the client needs at least one associated ORCID
 	if client.Orcid == "" {
-		return client, huma.Error403Forbidden("The DTS client has no associated ORCID!")
+		return client, apiError(http.StatusForbidden, "missing_orcid",
+			"The DTS client has no associated ORCID!")
 	}
 	return client, nil
 }
@@ -186,7 +247,7 @@ type ServiceInfoOutput struct {
 func (service *prototype) getRoot(ctx context.Context,
 	input *struct{}) (*ServiceInfoOutput, error) {
 
-	slog.Info("Querying root endpoint...")
+	requestLogger(ctx).Info("Querying root endpoint")
 	return &ServiceInfoOutput{
 		Body: ServiceInfoResponse{
 			Name:          service.Name,
@@ -197,6 +258,80 @@ func (service *prototype) getRoot(ctx context.Context,
 	}, nil
 }
 
+type HealthOutput struct {
+	Body HealthResponse `doc:"the health of the service's endpoints and databases"`
+}
+
+// handler method for health checks (no authorization needed for this one)
+func (service *prototype) getHealth(ctx context.Context,
+	input *struct{}) (*HealthOutput, error) {
+
+	requestLogger(ctx).Info("Checking endpoint and database health")
+	health := tasks.CheckHealth()
+	output := &HealthOutput{
+		Body: HealthResponse{
+			Status:    "ok",
+			Endpoints: componentHealth(health.Endpoints),
+			Databases: componentHealth(health.Databases),
+		},
+	}
+	for _, components := range []map[string]ComponentHealth{output.Body.Endpoints, output.Body.Databases} {
+		for _, component := range components {
+			if !component.Healthy {
+				output.Body.Status = "degraded"
+			}
+		}
+	}
+	return output, nil
+}
+
+// converts a mapping of component names to errors (nil for healthy components)
+// to a mapping of names to health reports
+func componentHealth(errs map[string]error) map[string]ComponentHealth {
+	health := make(map[string]ComponentHealth)
+	for name, err := range errs {
+		if err != nil {
+			health[name] = ComponentHealth{Message: err.Error()}
+		} else {
+			health[name] = ComponentHealth{Healthy: true}
+		}
+	}
+	return health
+}
+
+type ProvidersOutput struct {
+	Body ProvidersResponse `doc:"the registered endpoint providers and databases and their configuration fields"`
+}
+
+// handler method for querying endpoint providers and databases and their
+// configuration fields (no authorization needed for this one)
+func (service *prototype) getProviders(ctx context.Context,
+	input *struct{}) (*ProvidersOutput, error) {
+
+	requestLogger(ctx).Info("Querying providers")
+	output := &ProvidersOutput{
+		Body: ProvidersResponse{
+			Endpoints: make([]ProviderInfo, 0),
+			Databases: make([]ProviderInfo, 0),
+		},
+	}
+	endpointSchema := config.EndpointSchema()
+	for _, provider := range endpoints.Providers() {
+		output.Body.Endpoints = append(output.Body.Endpoints, ProviderInfo{
+			Name:   provider,
+			Config: endpointSchema,
+		})
+	}
+	databaseSchema := config.DatabaseSchema()
+	for _, dbName := range databases.RegisteredDatabases() {
+		output.Body.Databases = append(output.Body.Databases, ProviderInfo{
+			Name:   dbName,
+			Config: databaseSchema,
+		})
+	}
+	return output, nil
+}
+
 type DatabaseOutput struct {
 	Body DatabaseResponse `doc:"Information about the requested available database"`
 }
@@ -211,12 +346,12 @@ func (service *prototype) getDatabases(ctx context.Context,
 		Authorization string `header:"authorization"`
 	}) (*DatabasesOutput, error) {
 
-	_, err := authorize(input.Authorization)
+	client, err := authorize(input.Authorization)
 	if err != nil {
 		return nil, err
 	}
 
-	slog.Info("Querying organizational databases...")
+	clientLogger(ctx, client.Orcid).Info("Querying organizational databases")
 	output := &DatabasesOutput{
 		Body: make([]DatabaseResponse, 0),
 	}
@@ -240,15 +375,15 @@ func (service *prototype) getDatabase(ctx context.Context,
 		Id            string `path:"db" example:"jdp" doc:"the abbreviated name of a database"`
 	}) (*DatabaseOutput, error) {
 
-	_, err := authorize(input.Authorization)
+	client, err := authorize(input.Authorization)
 	if err != nil {
 		return nil, err
 	}
 
-	slog.Info(fmt.Sprintf("Querying database %s...", input.Id))
+	clientLogger(ctx, client.Orcid).Info("Querying database", "database", input.Id)
 	db, ok := config.Databases[input.Id]
 	if !ok {
-		return nil, huma.Error404NotFound(fmt.Sprintf("Database %s not found", input.Id))
+		return nil, databaseError(databases.NotFoundError{Database: input.Id})
 	}
 	return &DatabaseOutput{
 		Body: DatabaseResponse{
@@ -259,8 +394,79 @@ func (service *prototype) getDatabase(ctx context.Context,
 	}, nil
 }
 
+type EndpointsOutput struct {
+	Body []EndpointResponse `doc:"A list of information about configured endpoints"`
+}
+
+// handler method for querying all configured endpoints
+func (service *prototype) getEndpoints(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+	}) (*EndpointsOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	clientLogger(ctx, client.Orcid).Info("Querying endpoints")
+	output := &EndpointsOutput{
+		Body: make([]EndpointResponse, 0),
+	}
+	for endpointName := range config.Endpoints {
+		output.Body = append(output.Body, endpointResponse(endpointName))
+	}
+	slices.SortFunc(output.Body, func(e1, e2 EndpointResponse) int { // sort by ID
+		return cmp.Compare(e1.Id, e2.Id)
+	})
+	return output, nil
+}
+
+// handler method for querying the endpoints used by a database
+func (service *prototype) getDatabaseEndpoints(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            string `path:"db" example:"nmdc" doc:"the abbreviated name of a database"`
+	}) (*EndpointsOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	clientLogger(ctx, client.Orcid).Info("Querying database endpoints", "database", input.Id)
+	db, ok := config.Databases[input.Id]
+	if !ok {
+		return nil, databaseError(databases.NotFoundError{Database: input.Id})
+	}
+	output := &EndpointsOutput{
+		Body: make([]EndpointResponse, 0),
+	}
+	for _, endpointName := range db.EndpointNames() {
+		output.Body = append(output.Body, endpointResponse(endpointName))
+	}
+	return output, nil
+}
+
+// describes the configured endpoint with the given name, leaving out its
+// credentials (including any embedded in a root URL)
+func endpointResponse(endpointName string) EndpointResponse {
+	endpoint := config.Endpoints[endpointName]
+	root := endpoint.Root
+	if rootURL, err := url.Parse(root); err == nil && rootURL.User != nil {
+		rootURL.User = nil
+		root = rootURL.String()
+	}
+	return EndpointResponse{
+		Id:       endpointName,
+		Name:     endpoint.Name,
+		Provider: endpoint.Provider,
+		Root:     root,
+	}
+}
+
 type SearchParametersOutput struct {
-	Body json.RawMessage `doc:"a JSON object whose fields are search parameters and whose values indicate their type"`
+	Body json.RawMessage `doc:"a JSON object whose fields are search parameters and whose values indicate their type (or a typed schema if requested)"`
 }
 
 // We map database-specific search parameters to JSON according to the following
@@ -344,6 +550,7 @@ func (service *prototype) getDatabaseSearchParameters(ctx context.Context,
 	input *struct {
 		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
 		Database      string `path:"db" example:"jdp" doc:"the abbreviated name of a database"`
+		Schema        bool   `query:"schema" example:"true" doc:"(Optional) If true, parameters are described by a typed schema (name, type, allowed values, description, required)"`
 	}) (*SearchParametersOutput, error) {
 
 	client, err := authorize(input.Authorization)
@@ -354,11 +561,21 @@ func (service *prototype) getDatabaseSearchParameters(ctx context.Context,
 	// is the database valid?
 	_, ok := config.Databases[input.Database]
 	if !ok {
-		return nil, fmt.Errorf("Database %s not found", input.Database)
+		return nil, databaseError(databases.NotFoundError{Database: input.Database})
 	}
 	db, err := databases.NewDatabase(client.Orcid, input.Database)
 	if err != nil {
-		return nil, err
+		return nil, databaseError(err)
+	}
+
+	if input.Schema {
+		schemaData, _ := json.Marshal(SearchParameterSchemaResponse{
+			Database:   input.Database,
+			Parameters: databases.SearchParameterSchema(db),
+		})
+		return &SearchParametersOutput{
+			Body: json.RawMessage(schemaData),
+		}, nil
 	}
 
 	// Fish the database-specific search parameters out of the database
@@ -370,15 +587,24 @@ func (service *prototype) getDatabaseSearchParameters(ctx context.Context,
 }
 
 type SearchResultsOutput struct {
-	Body SearchResultsResponse `doc:"Search results containing matching files that match the given query"`
+	Body        SearchResultsResponse `doc:"Search results containing matching files that match the given query"`
+	ContentType string                `header:"Content-Type"`
+	// pagination, for formats (e.g. NDJSON) whose bodies have no place for it
+	TotalCount string `header:"X-Total-Count" doc:"The total number of matching files (if known)"`
+	NextOffset string `header:"X-Next-Offset" doc:"The offset of the next page of search results (if any)"`
 }
 
 type SearchDatabaseInputWithoutHeader struct {
-	Database string `json:"database" query:"database" example:"jdp" doc:"The ID of the database to search"`
-	Query    string `json:"query" query:"query" example:"prochlorococcus" doc:"A query used to search the database for matching files"`
-	Status   string `json:"status" query:"status" example:"\"staged\"" doc:"(Optional) The staged or unstaged status of the desired files"`
-	Offset   int    `json:"offset" query:"offset" example:"100" doc:"Search results begin at the given offset"`
-	Limit    int    `json:"limit" query:"limit" example:"50" doc:"Limits the number of search results returned"`
+	Database   string `json:"database" query:"database" example:"jdp" doc:"The ID of the database to search"`
+	Query      string `json:"query" query:"query" example:"prochlorococcus" doc:"A query used to search the database for matching files"`
+	Status     string `json:"status" query:"status" example:"\"staged\"" doc:"(Optional) The staged or unstaged status of the desired files"`
+	Offset     int    `json:"offset" query:"offset" example:"100" doc:"Search results begin at the given offset"`
+	Limit      int    `json:"limit" query:"limit" example:"50" doc:"Limits the number of search results returned"`
+	Fields     string `json:"fields" query:"fields" example:"id,path,bytes" doc:"(Optional) A comma-separated list of resource fields to include in search results"`
+	Format     string `json:"format" query:"format" example:"csv" doc:"(Optional) The format of search results (json, csv, tsv, or ndjson; negotiated via the Accept header if omitted)"`
+	HumanSizes bool   `json:"human_sizes" query:"human_sizes" example:"true" doc:"(Optional) If true, each resource includes its size in human-readable units (size_human)"`
+	Include    string `json:"include" query:"include" example:"*.fastq.gz" doc:"(Optional) A glob pattern that the paths of files in search results must match"`
+	Exclude    string `json:"exclude" query:"exclude" example:"raw/*" doc:"(Optional) A glob pattern that the paths of files in search results must not match"`
 }
 
 type SearchDatabaseInput struct {
@@ -386,28 +612,8 @@ type SearchDatabaseInput struct {
 	SearchDatabaseInputWithoutHeader
 }
 
-// routes database-related errors through Huma
-func databaseError(err error) error {
-	if err != nil {
-		slog.Error(err.Error())
-		switch err.(type) {
-		case *databases.InvalidSearchParameter:
-			return huma.Error400BadRequest(err.Error(), err)
-		case *databases.UnavailableError:
-			return huma.Error503ServiceUnavailable(err.Error(), err)
-		case *databases.PermissionDeniedError, *databases.UnauthorizedError:
-			return huma.Error401Unauthorized(err.Error(), err)
-		case *databases.NotFoundError, *databases.ResourceNotFoundError, *databases.ResourceEndpointNotFoundError:
-			return huma.Error404NotFound(err.Error(), err)
-		default:
-			return huma.Error500InternalServerError(err.Error(), err)
-		}
-	}
-	return nil
-}
-
 // implements database search for both GET and POST requests
-func searchDatabase(_ context.Context,
+func searchDatabase(ctx context.Context,
 	input *SearchDatabaseInput,
 	specific map[string]json.RawMessage) (*SearchResultsOutput, error) {
 
@@ -432,16 +638,30 @@ func searchDatabase(_ context.Context,
 	case "unstaged", "UNSTAGED":
 		fileStatus = databases.SearchFileStatusUnstaged
 	default:
-		return nil, fmt.Errorf("Invalid status parameter: %s", input.Status)
+		return nil, apiError(http.StatusBadRequest, "invalid_search_parameter",
+			fmt.Sprintf("Invalid status parameter: %s", input.Status))
 	}
 
-	slog.Info(fmt.Sprintf("Searching database %s for files...", input.Database))
-	db, err := databases.NewDatabase(client.Orcid, input.Database)
+	// check the requested output format
+	contentType, err := contentTypeForFormat(input.Format)
 	if err != nil {
-		return nil, databaseError(err)
+		return nil, err
+	}
+
+	// check any requested resource fields
+	var fields []string
+	if strings.TrimSpace(input.Fields) != "" {
+		fields = strings.Split(input.Fields, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		err = databases.ValidateResourceFields(input.Database, fields)
+		if err != nil {
+			return nil, databaseError(err)
+		}
 	}
 
-	results, err := db.Search(databases.SearchParameters{
+	params := databases.SearchParameters{
 		Query:  input.Query,
 		Status: fileStatus,
 		Pagination: databases.SearchPaginationParameters{
@@ -449,17 +669,84 @@ func searchDatabase(_ context.Context,
 			MaxNum: input.Limit,
 		},
 		Specific: specific,
-	})
+		Fields:   fields,
+		Include:  input.Include,
+		Exclude:  input.Exclude,
+	}
+	err = databases.ValidateFilePatterns(input.Database, params)
 	if err != nil {
 		return nil, databaseError(err)
 	}
-	return &SearchResultsOutput{
-		Body: SearchResultsResponse{
-			Database:  input.Database,
-			Query:     input.Query,
-			Resources: results.Resources,
-		},
-	}, nil
+
+	clientLogger(ctx, client.Orcid).Info("Searching database for files",
+		"database", input.Database, "query", input.Query)
+	db, err := databases.NewDatabase(client.Orcid, input.Database)
+	if err != nil {
+		return nil, databaseError(err)
+	}
+
+	results, err := db.Search(params)
+	if err != nil {
+		return nil, databaseError(err)
+	}
+	// only complete descriptors without private data are cached, since
+	// cached descriptors are served to any user
+	if len(fields) == 0 && !databases.SearchIncludesPrivateData(db, params) {
+		databases.CacheResources(input.Database, results.Resources)
+	}
+	results = databases.FilterSearchResults(params, results)
+	if input.HumanSizes {
+		addHumanReadableSizes(results.Resources)
+	}
+	resources := make([]SelectedDataResource, len(results.Resources))
+	for i, resource := range results.Resources {
+		resources[i] = SelectedDataResource{
+			DataResource: resource,
+			fields:       fields,
+		}
+	}
+	response := SearchResultsResponse{
+		Database:   input.Database,
+		Query:      input.Query,
+		Fields:     fields,
+		SearchId:   saveSearch(client.Orcid, input.Database, results.Resources),
+		Resources:  resources,
+		Total:      results.Total,
+		Groups:     results.Groups,
+		NumSkipped: results.NumSkipped,
+	}
+	if nextOffset, hasMore := nextSearchOffset(input.Offset, results); hasMore {
+		response.HasMore = true
+		response.NextOffset = &nextOffset
+	}
+	output := SearchResultsOutput{
+		Body:        response,
+		ContentType: contentType,
+	}
+	if response.Total != nil {
+		output.TotalCount = strconv.Itoa(*response.Total)
+	}
+	if response.NextOffset != nil {
+		output.NextOffset = strconv.Itoa(*response.NextOffset)
+	}
+	return &output, nil
+}
+
+// returns the offset of the page of search results following the given results
+// (which begin at the given offset), and whether more results remain
+func nextSearchOffset(offset int, results databases.SearchResults) (int, bool) {
+	nextOffset := offset + len(results.Resources) + results.NumSkipped
+	if results.Total != nil {
+		return nextOffset, nextOffset < *results.Total
+	}
+	return nextOffset, results.HasMore
+}
+
+// fills in the human-readable size of each of the given resources
+func addHumanReadableSizes(resources []frictionless.DataResource) {
+	for i := range resources {
+		resources[i].SizeHuman = frictionless.HumanReadableSize(resources[i].Bytes)
+	}
 }
 
 // handle search queries for files of interest (GET, no DB-specific parameters)
@@ -483,16 +770,21 @@ func (service *prototype) searchDatabaseWithSpecificParams(ctx context.Context,
 	}
 	err := json.Unmarshal(input.Body, &body)
 	if err != nil {
-		return nil, err
+		return nil, apiError(http.StatusBadRequest, "invalid_request_body", err.Error())
 	}
 	searchInput := SearchDatabaseInput{
 		Authorization: input.Authorization,
 		SearchDatabaseInputWithoutHeader: SearchDatabaseInputWithoutHeader{
-			Database: body.Database,
-			Query:    body.Query,
-			Status:   body.Status,
-			Offset:   body.Offset,
-			Limit:    body.Limit,
+			Database:   body.Database,
+			Query:      body.Query,
+			Status:     body.Status,
+			Offset:     body.Offset,
+			Limit:      body.Limit,
+			Fields:     body.Fields,
+			Format:     body.Format,
+			HumanSizes: body.HumanSizes,
+			Include:    body.Include,
+			Exclude:    body.Exclude,
 		},
 	}
 	return searchDatabase(ctx, &searchInput, body.Specific)
@@ -510,6 +802,7 @@ func (service *prototype) fetchFileMetadata(ctx context.Context,
 		Ids           string `json:"ids" query:"ids" example:"JDP:6101cc0f2b1f2eeea564c978" doc:"A comma-separated list of file IDs"`
 		Offset        int    `json:"offset" query:"offset" example:"100" doc:"Metadata records begin at the given offset"`
 		Limit         int    `json:"limit" query:"limit" example:"50" doc:"Limits the number of metadata records returned"`
+		HumanSizes    bool   `json:"human_sizes" query:"human_sizes" example:"true" doc:"(Optional) If true, each resource includes its size in human-readable units (size_human)"`
 	}) (*FileMetadataOutput, error) {
 
 	client, err := authorize(input.Authorization)
@@ -520,26 +813,28 @@ func (service *prototype) fetchFileMetadata(ctx context.Context,
 	// is the database valid?
 	_, ok := config.Databases[input.Database]
 	if !ok {
-		return nil, fmt.Errorf("Database %s not found", input.Database)
+		return nil, databaseError(databases.NotFoundError{Database: input.Database})
 	}
 
 	// have we been given any IDs?
 	if strings.TrimSpace(input.Ids) == "" {
-		return nil, huma.Error400BadRequest("No file IDs were provided!")
+		return nil, apiError(http.StatusBadRequest, "no_files_requested", "No file IDs were provided!")
 	}
 	ids := strings.Split(input.Ids, ",")
 
-	slog.Info(fmt.Sprintf("Fetching file metadata for %d files in database %s...",
-		len(ids), input.Database))
+	clientLogger(ctx, client.Orcid).Info("Fetching file metadata",
+		"database", input.Database, "num_files", len(ids))
 	db, err := databases.NewDatabase(client.Orcid, input.Database)
 	if err != nil {
-		return nil, err
+		return nil, databaseError(err)
 	}
 
-	results, err := db.Resources(ids)
+	results, err := databases.CachedResources(input.Database, db, ids)
 	if err != nil {
-		slog.Error(err.Error())
-		return nil, err
+		return nil, databaseError(err)
+	}
+	if input.HumanSizes {
+		addHumanReadableSizes(results)
 	}
 	return &FileMetadataOutput{
 		Body: FileMetadataResponse{
@@ -566,13 +861,19 @@ func (service *prototype) createTransfer(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
+	return createTransfer(ctx, client, input.Body)
+}
 
+// creates a transfer task for the given client from the given request, which
+// is associated with the given context
+func createTransfer(ctx context.Context, client auth.Client,
+	request TransferRequest) (*TransferOutput, error) {
 	// fetch information about the requesting user
 	var user auth.User
-	if input.Body.Orcid != "" {
+	if request.Orcid != "" {
 		// FIXME: we just extract the ORCID at the moment
 		// FIXME: we should get the other stuff from the ORCID public API
-		user.Orcid = input.Body.Orcid
+		user.Orcid = request.Orcid
 	} else {
 		// FIXME: for now, while we're in transition, we can fall back to the client's
 		// FIXME: info if a user ORCID is not provided
@@ -584,26 +885,82 @@ func (service *prototype) createTransfer(ctx context.Context,
 		}
 	}
 
+	// expand any saved search into the file IDs it found
+	fileIds := request.FileIds
+	if request.SearchId != "" {
+		search, found := savedSearchForId(request.SearchId)
+		if !found || search.Orcid != client.Orcid { // don't reveal others' searches
+			return nil, apiError(http.StatusNotFound, "search_not_found",
+				fmt.Sprintf("Search %s not found (it may have expired)", request.SearchId))
+		}
+		if search.Database != request.Source {
+			return nil, apiError(http.StatusBadRequest, "search_database_mismatch",
+				fmt.Sprintf("Search %s was not performed on source database %s",
+					request.SearchId, request.Source))
+		}
+		fileIds = append(fileIds, search.FileIds...)
+	}
+
+	// expand any path prefix into the files it matches
+	if request.Prefix != "" {
+		prefixFileIds, err := fileIdsWithPrefix(client.Orcid, request.Source, request.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		fileIds = append(fileIds, prefixFileIds...)
+	}
+
+	priority, err := tasks.ParseTransferPriority(request.Priority)
+	if err != nil {
+		return nil, taskError(err)
+	}
+
+	// a transfer may only be based on one of the requester's own transfers
+	// (tasks.Create reports base transfers that don't exist)
+	var basedOn uuid.UUID
+	if request.BasedOn != "" {
+		basedOn, err = uuid.Parse(request.BasedOn)
+		if err != nil {
+			return nil, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid based_on transfer ID: %s", request.BasedOn))
+		}
+		if baseSpec, err := tasks.SpecificationForTask(basedOn); err == nil &&
+			client.Orcid != baseSpec.User.Orcid && client.Orcid != baseSpec.Client.Orcid {
+			return nil, apiError(http.StatusForbidden, "permission_denied",
+				fmt.Sprintf("Transfer %s may only be used as a base by its owner.", request.BasedOn))
+		}
+	}
+
 	taskId, err := tasks.Create(tasks.Specification{
-		Client:       client,
-		User:         user,
-		Source:       input.Body.Source,
-		Destination:  input.Body.Destination,
-		FileIds:      input.Body.FileIds,
-		Description:  input.Body.Description,
-		Instructions: input.Body.Instructions,
+		Client:             client,
+		User:               user,
+		Source:             request.Source,
+		Destination:        request.Destination,
+		Destinations:       request.Destinations,
+		FileIds:            fileIds,
+		Description:        request.Description,
+		Instructions:       request.Instructions,
+		NotifyByEmail:      request.NotifyByEmail,
+		EndpointOptions:    request.EndpointOptions,
+		RequestId:          requestIdFromContext(ctx),
+		CallbackURL:        request.CallbackURL,
+		SkipExisting:       request.SkipExisting,
+		DedupeByHash:       request.DedupeByHash,
+		IncludePrivateData: request.IncludePrivateData,
+		BasedOn:            basedOn,
+		Priority:           priority,
+		Labels:             request.Labels,
+		ManifestMetadata:   manifestMetadata(request.ManifestMetadata),
 	})
 	if err != nil {
-		slog.Error(err.Error())
-		switch err.(type) {
-		case *tasks.NoFilesRequestedError:
-			return nil, huma.Error400BadRequest(err.Error())
-		case *databases.NotFoundError:
-			return nil, huma.Error404NotFound(err.Error())
-		default:
-			return nil, huma.Error500InternalServerError(err.Error())
-		}
+		return nil, taskError(err)
 	}
+	destination := request.Destination
+	if len(request.Destinations) > 0 {
+		destination = strings.Join(request.Destinations, ",")
+	}
+	clientLogger(ctx, client.Orcid).Info("Created transfer", "transfer", taskId.String(),
+		"source", request.Source, "destination", destination, "num_files", len(fileIds))
 	return &TransferOutput{
 		Body: TransferResponse{
 			Id: taskId,
@@ -612,6 +969,70 @@ func (service *prototype) createTransfer(ctx context.Context,
 	}, nil
 }
 
+// returns the IDs of the files in the database with the given name whose IDs
+// begin with the given prefix, or an error if the database doesn't support
+// prefixes or the prefix matches more files than permitted
+func fileIdsWithPrefix(orcid, dbName, prefix string) ([]string, error) {
+	db, err := databases.NewDatabase(orcid, dbName)
+	if err != nil {
+		return nil, databaseError(err)
+	}
+	prefixDb, ok := db.(databases.PrefixDatabase)
+	if !ok {
+		return nil, apiError(http.StatusBadRequest, "prefix_not_supported",
+			fmt.Sprintf("Database %s doesn't support requesting files by prefix", dbName))
+	}
+	fileIds, err := prefixDb.FileIdsWithPrefix(prefix)
+	if err != nil {
+		return nil, databaseError(err)
+	}
+	maxFiles := config.Service.MaxPrefixFiles
+	if maxFiles > 0 && len(fileIds) > maxFiles {
+		return nil, apiError(http.StatusRequestEntityTooLarge, "too_many_files",
+			fmt.Sprintf("Prefix %s matches %d files (at most %d are permitted)",
+				prefix, len(fileIds), maxFiles))
+	}
+	return fileIds, nil
+}
+
+// converts manifest metadata given in a transfer request to the form expected
+// by the tasks package (nil if none was given)
+func manifestMetadata(request *ManifestMetadataRequest) *tasks.ManifestMetadata {
+	if request == nil {
+		return nil
+	}
+	metadata := tasks.ManifestMetadata{
+		Keywords: request.Keywords,
+	}
+	for _, license := range request.Licenses {
+		metadata.Licenses = append(metadata.Licenses, frictionless.DataLicense{
+			Name:  license.Name,
+			Path:  license.Path,
+			Title: license.Title,
+		})
+	}
+	if request.Publisher != nil {
+		metadata.Publisher = &frictionless.Contributor{
+			Title:        request.Publisher.Title,
+			Email:        request.Publisher.Email,
+			Path:         request.Publisher.Path,
+			Organization: request.Publisher.Title,
+		}
+	}
+	for _, funding := range request.Funding {
+		metadata.Funding = append(metadata.Funding, credit.FundingReference{
+			GrantId:    funding.GrantId,
+			GrantTitle: funding.GrantTitle,
+			GrantUrl:   funding.GrantUrl,
+			Funder: credit.Organization{
+				OrganizationId:   funding.FunderId,
+				OrganizationName: funding.FunderName,
+			},
+		})
+	}
+	return &metadata
+}
+
 // convert a transfer status code to a nice human-friendly string
 func statusAsString(statusCode endpoints.TransferStatusCode) string {
 	switch statusCode {
@@ -640,26 +1061,191 @@ func (service *prototype) getTransferStatus(ctx context.Context,
 	input *struct {
 		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
 		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
+		Detail        string    `query:"detail" example:"files" doc:"(Optional) If \"files\", the statuses of individual files are included regardless of the transfer's status"`
 	}) (*TransferStatusOutput, error) {
 
 	_, err := authorize(input.Authorization)
 	if err != nil {
 		return nil, err
 	}
+	if input.Detail != "" && input.Detail != "files" {
+		return nil, apiError(http.StatusBadRequest, "invalid_detail",
+			fmt.Sprintf("Invalid status detail requested: %s", input.Detail))
+	}
 
 	// fetch the status for the job using the appropriate task data
-	status, err := tasks.Status(input.Id)
+	response, err := transferStatusResponse(input.Id)
 	if err != nil {
-		return nil, huma.Error404NotFound(err.Error())
+		return nil, taskError(err)
+	}
+
+	// report individual files for failed transfers (or on request), since the
+	// list can be long
+	if response.Status == "failed" || input.Detail == "files" {
+		fileStatuses, err := tasks.FileStatuses(input.Id)
+		if err != nil {
+			return nil, taskError(err)
+		}
+		response.Files = make([]FileStatusResponse, len(fileStatuses))
+		for i, fileStatus := range fileStatuses {
+			response.Files[i] = FileStatusResponse{
+				Id:    fileStatus.Id,
+				State: string(fileStatus.State),
+				Error: fileStatus.Error,
+			}
+		}
 	}
 	return &TransferStatusOutput{
-		Body: TransferStatusResponse{
-			Id:                  input.Id.String(),
+		Body: response,
+	}, nil
+}
+
+// assembles a status response (without individual file statuses) for the
+// transfer with the given ID
+func transferStatusResponse(taskId uuid.UUID) (TransferStatusResponse, error) {
+	status, err := tasks.Status(taskId)
+	if err != nil {
+		return TransferStatusResponse{}, err
+	}
+	spec, err := tasks.SpecificationForTask(taskId)
+	if err != nil {
+		return TransferStatusResponse{}, err
+	}
+	children, err := tasks.SubTransfers(taskId)
+	if err != nil {
+		return TransferStatusResponse{}, err
+	}
+	var subTransfers []string
+	for _, childId := range children {
+		subTransfers = append(subTransfers, childId.String())
+	}
+	var destinations []DestinationStatusResponse
+	if len(spec.Destinations) > 0 {
+		destinations, err = destinationStatuses(children)
+		if err != nil {
+			return TransferStatusResponse{}, err
+		}
+	}
+	return TransferStatusResponse{
+		Id:                  taskId.String(),
+		Status:              statusAsString(status.Code),
+		Message:             status.Message,
+		NumFiles:            status.NumFiles,
+		NumFilesTransferred: status.NumFilesTransferred,
+		Description:         spec.Description,
+		Labels:              spec.Labels,
+		SubTransfers:        subTransfers,
+		Destinations:        destinations,
+	}, nil
+}
+
+// returns the progress of a transfer toward each of its destinations, given
+// the IDs of its sub-transfers
+func destinationStatuses(children []uuid.UUID) ([]DestinationStatusResponse, error) {
+	destinations := make([]DestinationStatusResponse, len(children))
+	for i, childId := range children {
+		status, err := tasks.Status(childId)
+		if err != nil {
+			return nil, err
+		}
+		spec, err := tasks.SpecificationForTask(childId)
+		if err != nil {
+			return nil, err
+		}
+		destinations[i] = DestinationStatusResponse{
+			Destination:         spec.Destination,
+			Id:                  childId.String(),
 			Status:              statusAsString(status.Code),
 			Message:             status.Message,
 			NumFiles:            status.NumFiles,
 			NumFilesTransferred: status.NumFilesTransferred,
-		},
+		}
+	}
+	return destinations, nil
+}
+
+type TransferListOutput struct {
+	Body TransferListResponse `doc:"The statuses of the requesting user's transfers"`
+}
+
+// handler method for listing the requesting user's transfers, optionally
+// filtered by label
+func (service *prototype) listTransfers(ctx context.Context,
+	input *struct {
+		Authorization string   `header:"authorization" doc:"Authorization header with encoded access token"`
+		Labels        []string `query:"label,explode" example:"project:soil-survey" doc:"(Optional) a label (key:value) that listed transfers must have (may be repeated to require several labels)"`
+	}) (*TransferListOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string)
+	for _, label := range input.Labels {
+		key, value, found := strings.Cut(label, ":")
+		if !found || key == "" {
+			return nil, apiError(http.StatusBadRequest, "invalid_label",
+				fmt.Sprintf("Invalid label filter: %s (must have the form key:value)", label))
+		}
+		labels[key] = value
+	}
+
+	taskIds, err := tasks.List(client.Orcid, labels)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	response := TransferListResponse{
+		Transfers: make([]TransferStatusResponse, 0, len(taskIds)),
+	}
+	for _, taskId := range taskIds {
+		status, err := transferStatusResponse(taskId)
+		if err != nil {
+			if _, notFound := err.(tasks.NotFoundError); notFound {
+				continue // purged since it was listed
+			}
+			return nil, taskError(err)
+		}
+		response.Transfers = append(response.Transfers, status)
+	}
+	return &TransferListOutput{
+		Body: response,
+	}, nil
+}
+
+type TransferManifestOutput struct {
+	ContentType string `header:"Content-Type"`
+	Body        []byte `doc:"The Frictionless data package manifest generated for the completed transfer with the given ID"`
+}
+
+// handler method for fetching the manifest of a completed transfer
+func (service *prototype) getTransferManifest(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
+	}) (*TransferManifestOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the user who requested the transfer may fetch its manifest
+	spec, err := tasks.SpecificationForTask(input.Id)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	if client.Orcid != spec.User.Orcid && client.Orcid != spec.Client.Orcid {
+		return nil, apiError(http.StatusForbidden, "permission_denied",
+			fmt.Sprintf("The manifest for transfer %s is only available to its owner.", input.Id.String()))
+	}
+
+	manifest, err := tasks.Manifest(input.Id)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	return &TransferManifestOutput{
+		ContentType: "application/json",
+		Body:        manifest,
 	}, nil
 }
 
@@ -677,13 +1263,119 @@ func (service *prototype) deleteTransfer(ctx context.Context,
 	// request that the task be canceled
 	err := tasks.Cancel(input.Id)
 	if err != nil {
-		return nil, err
+		return nil, taskError(err)
 	}
 	return &TaskDeletionOutput{
 		Status: http.StatusAccepted,
 	}, nil
 }
 
+// handler method for re-driving a failed (or canceled) transfer with the
+// specification it was originally given
+func (service *prototype) retryTransfer(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the failed transfer"`
+	}) (*TransferOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the user who requested the transfer may retry it
+	spec, err := tasks.SpecificationForTask(input.Id)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	if client.Orcid != spec.User.Orcid && client.Orcid != spec.Client.Orcid {
+		return nil, apiError(http.StatusForbidden, "permission_denied",
+			fmt.Sprintf("Transfer %s may only be retried by its owner.", input.Id.String()))
+	}
+
+	// canceled transfers are reported as failed, so this covers both
+	status, err := tasks.Status(input.Id)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	if status.Code != tasks.TransferStatusFailed {
+		return nil, apiError(http.StatusConflict, "transfer_not_retryable",
+			fmt.Sprintf("Transfer %s has not failed or been canceled, so it can't be retried.",
+				input.Id.String()))
+	}
+
+	spec.Client = client
+	spec.RequestId = requestIdFromContext(ctx)
+	taskId, err := tasks.Create(spec)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	clientLogger(ctx, client.Orcid).Info("Retried transfer", "transfer", taskId.String(),
+		"original_transfer", input.Id.String())
+	return &TransferOutput{
+		Body: TransferResponse{
+			Id: taskId,
+		},
+		Status: http.StatusCreated,
+	}, nil
+}
+
+// handler method for canceling or purging a transfer on behalf of an
+// administrator
+func (service *prototype) purgeTransfer(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
+		Purge         bool      `query:"purge" doc:"if true, the transfer's records and manifest are removed immediately, regardless of age"`
+	}) (*TaskDeletionOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	err = authorizeAdmin(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if !input.Purge { // just cancel the transfer
+		err = tasks.Cancel(input.Id)
+		if err != nil {
+			return nil, taskError(err)
+		}
+		return &TaskDeletionOutput{
+			Status: http.StatusAccepted,
+		}, nil
+	}
+
+	clientLogger(ctx, client.Orcid).Info("Purging transfer", "transfer", input.Id.String())
+	err = tasks.Purge(input.Id)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	return &TaskDeletionOutput{
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+// returns an error unless the given client is one of the DTS's administrators
+func authorizeAdmin(client auth.Client) error {
+	if !slices.Contains(config.Service.Admins, client.Orcid) {
+		return apiError(http.StatusForbidden, "permission_denied",
+			"This operation is only available to DTS administrators.")
+	}
+	return nil
+}
+
+// handler method for Prometheus metrics (no authorization needed for this one)
+func (service *prototype) getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := metrics.Write(w)
+	if err != nil {
+		slog.Error("Writing metrics", "error", err.Error())
+	}
+}
+
 // returns the uptime for the service in seconds
 func (service *prototype) uptime() float64 {
 	return time.Since(service.StartTime).Seconds()
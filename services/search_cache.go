@@ -0,0 +1,105 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/frictionless"
+)
+
+// the results of a file search, saved so that a transfer request can refer to
+// them by an opaque search ID instead of listing every file ID
+type savedSearch struct {
+	// ORCID of the user who performed the search (the only user who may refer
+	// to it)
+	Orcid string
+	// database that was searched
+	Database string
+	// IDs of the files returned by the search
+	FileIds []string
+	// time after which the saved search is discarded
+	Expiration time.Time
+}
+
+// saved searches, mapped by search ID
+var savedSearches = make(map[string]savedSearch)
+var savedSearchesMutex sync.Mutex
+
+// saves the IDs of the given resources found in the given database by the user
+// with the given ORCID, returning a new search ID that refers to them, or an
+// empty string if saved searches are disabled. If the maximum number of saved
+// searches is reached, the oldest are discarded.
+func saveSearch(orcid, database string, resources []frictionless.DataResource) string {
+	if config.Service.SearchCacheTTL <= 0 || config.Service.SearchCacheSize <= 0 {
+		return ""
+	}
+	search := savedSearch{
+		Orcid:      orcid,
+		Database:   database,
+		FileIds:    make([]string, len(resources)),
+		Expiration: time.Now().Add(time.Duration(config.Service.SearchCacheTTL) * time.Second),
+	}
+	for i, resource := range resources {
+		search.FileIds[i] = resource.Id
+	}
+	searchId := uuid.NewString()
+
+	savedSearchesMutex.Lock()
+	defer savedSearchesMutex.Unlock()
+
+	// discard any expired searches while we're here, and then the oldest (the
+	// earliest to expire) until there's room for this one
+	now := time.Now()
+	for id, s := range savedSearches {
+		if now.After(s.Expiration) {
+			delete(savedSearches, id)
+		}
+	}
+	for len(savedSearches) >= config.Service.SearchCacheSize {
+		var oldestId string
+		for id, s := range savedSearches {
+			if oldestId == "" || s.Expiration.Before(savedSearches[oldestId].Expiration) {
+				oldestId = id
+			}
+		}
+		delete(savedSearches, oldestId)
+	}
+	savedSearches[searchId] = search
+	return searchId
+}
+
+// retrieves the unexpired saved search with the given ID, if any
+func savedSearchForId(searchId string) (savedSearch, bool) {
+	savedSearchesMutex.Lock()
+	defer savedSearchesMutex.Unlock()
+	search, found := savedSearches[searchId]
+	if found && time.Now().After(search.Expiration) {
+		delete(savedSearches, searchId)
+		found = false
+	}
+	return search, found
+}
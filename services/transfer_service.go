@@ -6,6 +6,8 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/frictionless"
 )
 
@@ -17,6 +19,32 @@ type ServiceInfoResponse struct {
 	Documentation string `json:"documentation" example:"/docs" doc:"The OpenAPI documentation endpoint"`
 }
 
+// a response for a health check (GET)
+type HealthResponse struct {
+	Status    string                     `json:"status" example:"ok" doc:"ok if all endpoints and databases are healthy, degraded otherwise"`
+	Endpoints map[string]ComponentHealth `json:"endpoints" doc:"the health of each configured endpoint, by name"`
+	Databases map[string]ComponentHealth `json:"databases" doc:"the health of each configured database, by name (healthy if all of its endpoints are)"`
+}
+
+// the health of an individual endpoint or database
+type ComponentHealth struct {
+	Healthy bool   `json:"healthy" example:"true" doc:"true if the component is reachable and usable"`
+	Message string `json:"message,omitempty" example:"The endpoint 'globus-jdp' is unhealthy: endpoint is not activated" doc:"a description of any problem with the component"`
+}
+
+// a response listing the registered endpoint providers and databases along
+// with descriptions of their configuration fields
+type ProvidersResponse struct {
+	Endpoints []ProviderInfo `json:"endpoints" doc:"registered endpoint providers, with the fields of an entry in the config file's endpoints section"`
+	Databases []ProviderInfo `json:"databases" doc:"registered databases, with the fields of an entry in the config file's databases section"`
+}
+
+// information about an individual endpoint provider or database
+type ProviderInfo struct {
+	Name   string               `json:"name" example:"globus" doc:"the name of the provider or database"`
+	Config []config.FieldSchema `json:"config" doc:"descriptions of the provider's or database's configuration fields"`
+}
+
 // a response for a database-related query (GET)
 type DatabaseResponse struct {
 	Id           string `json:"id" example:"jdp" `
@@ -25,14 +53,62 @@ type DatabaseResponse struct {
 	URL          string `json:"url" example:"https://data.jgi.doe.gov"`
 }
 
+// a response describing a configured endpoint (GET)
+type EndpointResponse struct {
+	Id       string `json:"id" example:"globus-jdp" doc:"the name of the endpoint in the service's configuration"`
+	Name     string `json:"name" example:"JGI Globus endpoint" doc:"the descriptive name of the endpoint"`
+	Provider string `json:"provider" example:"globus" doc:"the endpoint's provider (e.g. globus or local)"`
+	Root     string `json:"root,omitempty" example:"/" doc:"the root directory (or URL) from which the endpoint accesses files, if any"`
+}
+
+// a response for a database search parameter schema query (GET)
+type SearchParameterSchemaResponse struct {
+	// database name
+	Database string `json:"database" example:"jdp" doc:"the abbreviated name of the database"`
+	// search parameters, sorted by name
+	Parameters []databases.SearchParameterSpec `json:"parameters" doc:"the database's specific search parameters"`
+}
+
 // a response for a file search query (GET)
 type SearchResultsResponse struct {
 	// name of organization database
 	Database string `json:"database" example:"jdp" doc:"the database searched"`
 	// ElasticSearch query string
 	Query string `json:"query" example:"prochlorococcus" doc:"the given query string"`
+	// names of resource fields included in results (all if omitted)
+	Fields []string `json:"fields,omitempty" example:"[\"id\", \"path\", \"bytes\"]" doc:"the requested resource fields, if any"`
+	// ID that refers to these results in a subsequent transfer request
+	SearchId string `json:"search_id,omitempty" example:"0d5d3b9e-7e1f-4b6e-9f3a-62a9a6bb5c7e" doc:"an ID that can be given in a transfer request to transfer the results returned by this search (only this page, if the search is paginated)"`
 	// resources matching the query
-	Resources []frictionless.DataResource `json:"resources" doc:"an array of Frictionless DataResources"`
+	Resources []SelectedDataResource `json:"resources" doc:"an array of Frictionless DataResources"`
+	// total number of resources matching the query (if reported by the database)
+	Total *int `json:"total,omitempty" example:"250" doc:"the total number of resources matching the query, if the database reports it"`
+	// indicates whether more resources match the query than were returned
+	HasMore bool `json:"has_more" example:"true" doc:"true if more resources match the query than are included in these results"`
+	// groups of resources sharing a database-specific attribute (if requested)
+	Groups []databases.SearchResultGroup `json:"groups,omitempty" doc:"groups of resources sharing a database-specific attribute (e.g. organism), if requested"`
+	// number of matching resources left out of these results
+	NumSkipped int `json:"num_skipped,omitempty" example:"2" doc:"the number of resources matching the query that were left out of these results (e.g. because the database's metadata for them is inconsistent, or because they were excluded by file patterns)"`
+	// offset of the next page of results (if any)
+	NextOffset *int `json:"next_offset,omitempty" example:"100" doc:"the offset at which the next page of results begins, if there is one"`
+}
+
+// a Frictionless DataResource whose JSON representation is restricted to a
+// selection of its fields (all fields if none are selected)
+type SelectedDataResource struct {
+	frictionless.DataResource
+	fields []string
+}
+
+func (r SelectedDataResource) MarshalJSON() ([]byte, error) {
+	if len(r.fields) == 0 {
+		return json.Marshal(r.DataResource)
+	}
+	selected, err := databases.SelectResourceFields("", []frictionless.DataResource{r.DataResource}, r.fields)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(selected[0])
 }
 
 // a response for a file metadata query (GET)
@@ -50,13 +126,74 @@ type TransferRequest struct {
 	// name of source database
 	Source string `json:"source" example:"jdp" doc:"source database identifier"`
 	// identifiers for files to be transferred
-	FileIds []string `json:"file_ids" example:"[\"fileid1\", \"fileid2\"]" doc:"source-specific identifiers for files to be transferred"`
+	FileIds []string `json:"file_ids,omitempty" example:"[\"fileid1\", \"fileid2\"]" doc:"source-specific identifiers for files to be transferred"`
+	// ID of a saved search whose results are to be transferred
+	SearchId string `json:"search_id,omitempty" example:"0d5d3b9e-7e1f-4b6e-9f3a-62a9a6bb5c7e" doc:"ID of a previous search of the source database by the requesting user, the results returned by which are transferred (in addition to any file_ids)"`
+	// path prefix (e.g. a directory) whose files are to be transferred
+	Prefix string `json:"prefix,omitempty" example:"dir2/" doc:"a path prefix (e.g. a directory) all of whose files in the source database are transferred (in addition to any file_ids); supported only by databases whose file IDs are paths"`
+	// ID of a prior completed transfer on which this one is based
+	BasedOn string `json:"based_on,omitempty" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"ID of a prior completed transfer of the requester's from the same source; files it moved with unchanged hashes and sizes are left out of this transfer"`
 	// name of destination database
-	Destination string `json:"destination" example:"kbase" doc:"destination database identifier"`
+	Destination string `json:"destination,omitempty" example:"kbase" doc:"destination database identifier (required unless destinations is given)"`
+	// names of several destination databases
+	Destinations []string `json:"destinations,omitempty" example:"[\"kbase\", \"nmdc\"]" doc:"identifiers for several destination databases, to each of which the files are transferred by a separate sub-transfer (in place of destination)"`
 	// a Markdown description of the transfer request
 	Description string `json:"description,omitempty" example:"# title\n* type: assembly\n" doc:"Markdown task description"`
 	// machine-readable instructions for processing a payload at the destination site
 	Instructions json.RawMessage `json:"instructions,omitempty" doc:"JSON object containing machine-readable instructions for processing payload at destination"`
+	// set to request an email notification when the transfer completes
+	NotifyByEmail bool `json:"notify_by_email,omitempty" doc:"if true, the requesting user is emailed when the transfer completes"`
+	// URL to which a signed summary is POSTed when the transfer completes
+	CallbackURL string `json:"callback_url,omitempty" example:"https://example.com/dts-callback" doc:"a URL to which a signed JSON summary of the transfer is POSTed when it completes (if enabled on the service)"`
+	// provider-specific options that override the source endpoint's defaults
+	EndpointOptions map[string]any `json:"endpoint_options,omitempty" doc:"provider-specific options for the source endpoint (e.g. {\"encrypt_data\": true} for Globus) that override its defaults for this transfer"`
+	// set to skip files already present at the destination
+	SkipExisting bool `json:"skip_existing,omitempty" doc:"if true, files already present at the destination with matching sizes (and checksums, where available) are skipped instead of transferred again"`
+	// set to transfer files with identical content only once
+	DedupeByHash bool `json:"dedupe_by_hash,omitempty" doc:"if true, files with identical hashes and sizes are transferred only once, and the manifest lists the IDs of the duplicates as aliases of the transferred file"`
+	// set to include private data to which the user is entitled
+	IncludePrivateData bool `json:"include_private_data,omitempty" doc:"if true, the source database includes private data to which the requesting user is entitled (where it has any, e.g. the JDP) when describing and staging files"`
+	// priority with which the transfer begins relative to others waiting
+	Priority string `json:"priority,omitempty" example:"high" doc:"the priority (high, normal, or low) with which the transfer begins when the service limits the number of active transfers (normal if omitted)"`
+	// free-form labels for organizing and filtering transfers
+	Labels map[string]string `json:"labels,omitempty" example:"{\"project\": \"soil-survey\"}" doc:"free-form key/value labels (e.g. project or run names) by which the requesting user can filter their transfers (at most 16, with keys of up to 64 bytes and values of up to 256 bytes)"`
+	// metadata included in the transfer's manifest
+	ManifestMetadata *ManifestMetadataRequest `json:"manifest_metadata,omitempty" doc:"metadata included in the transfer's manifest, taking precedence over the service's defaults (keywords are added to the defaults)"`
+}
+
+// metadata given in a transfer request for inclusion in its manifest
+type ManifestMetadataRequest struct {
+	// keywords added to the manifest
+	Keywords []string `json:"keywords,omitempty" example:"[\"soil\"]" doc:"keywords added to those of the manifest"`
+	// licenses under which the transferred data are managed
+	Licenses []ManifestLicense `json:"licenses,omitempty" doc:"licenses under which the transferred data are managed (replacing the service's defaults)"`
+	// the organization publishing the transferred data
+	Publisher *ManifestPublisher `json:"publisher,omitempty" doc:"the organization publishing the transferred data (replacing the service's default)"`
+	// funding acknowledgments
+	Funding []ManifestFunding `json:"funding,omitempty" doc:"acknowledgments of funding for the transferred data (replacing the service's defaults)"`
+}
+
+// a license included in a transfer's manifest
+type ManifestLicense struct {
+	Name  string `json:"name" minLength:"1" example:"CC0-1.0" doc:"the abbreviated name of the license (e.g. an SPDX identifier)"`
+	Path  string `json:"path,omitempty" example:"https://creativecommons.org/publicdomain/zero/1.0/" doc:"a URL at which the license text may be retrieved"`
+	Title string `json:"title,omitempty" doc:"the descriptive title of the license"`
+}
+
+// the publisher listed in a transfer's manifest
+type ManifestPublisher struct {
+	Title string `json:"title" minLength:"1" example:"Lawrence Berkeley National Laboratory" doc:"the name of the publishing organization"`
+	Email string `json:"email,omitempty" doc:"a contact email address for the organization"`
+	Path  string `json:"path,omitempty" doc:"a URL for the organization"`
+}
+
+// a funding acknowledgment included in a transfer's manifest
+type ManifestFunding struct {
+	FunderName string `json:"funder_name" minLength:"1" example:"U.S. Department of Energy" doc:"the name of the funding organization"`
+	FunderId   string `json:"funder_id,omitempty" example:"ROR:01bj3aw27" doc:"a persistent identifier for the funding organization"`
+	GrantId    string `json:"grant_id,omitempty" doc:"the code assigned to the grant by the funder"`
+	GrantTitle string `json:"grant_title,omitempty" doc:"the title of the grant"`
+	GrantUrl   string `json:"grant_url,omitempty" doc:"a URL for the grant"`
 }
 
 // a response for a file transfer request (POST)
@@ -77,6 +214,48 @@ type TransferStatusResponse struct {
 	NumFiles int `json:"num_files"`
 	// number of files that have been completely transferred
 	NumFilesTransferred int `json:"num_files_transferred"`
+	// Markdown description given when the transfer was requested
+	Description string `json:"description,omitempty"`
+	// labels given when the transfer was requested
+	Labels map[string]string `json:"labels,omitempty"`
+	// IDs of the sub-transfers into which a large transfer was split (if any)
+	SubTransfers []string `json:"sub_transfers,omitempty"`
+	// progress toward each destination of a transfer to several destinations
+	Destinations []DestinationStatusResponse `json:"destinations,omitempty" doc:"the progress of the transfer to each of its destinations (for transfers to several destinations)"`
+	// statuses of individual files (for failed transfers, or if requested)
+	Files []FileStatusResponse `json:"files,omitempty" doc:"the statuses of the individual files in the transfer (included for failed transfers, or if detail=files is requested)"`
+}
+
+// the progress of a transfer toward one of its several destinations
+type DestinationStatusResponse struct {
+	// name of the destination database
+	Destination string `json:"destination" example:"kbase"`
+	// ID of the sub-transfer to the destination
+	Id string `json:"id"`
+	// sub-transfer status
+	Status string `json:"status"`
+	// message (if any) related to status
+	Message string `json:"message,omitempty"`
+	// number of files being transferred to the destination
+	NumFiles int `json:"num_files"`
+	// number of files that have been completely transferred to the destination
+	NumFilesTransferred int `json:"num_files_transferred"`
+}
+
+// a response for a transfer listing request (GET)
+type TransferListResponse struct {
+	// statuses of the listed transfers
+	Transfers []TransferStatusResponse `json:"transfers" doc:"the statuses of the requesting user's transfers, in the order in which they were requested"`
+}
+
+// the status of an individual file within a transfer
+type FileStatusResponse struct {
+	// file ID
+	Id string `json:"id" example:"JDP:57f9e03f7ded5e3135bc069e" doc:"the ID of the file in its source database"`
+	// file state
+	State string `json:"state" example:"failed" doc:"the state of the file (pending, transferred, skipped, or failed)"`
+	// error message for a failed file
+	Error string `json:"error,omitempty" doc:"a message describing why the file failed to transfer (if it did)"`
 }
 
 // TransferService defines the interface for our data transfer service.
@@ -0,0 +1,248 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// the largest manifest that can be uploaded for a transfer (bytes)
+const maxManifestUploadSize = 64 * 1024 * 1024
+
+// the multipart form fields for a transfer request with an uploaded manifest
+// (the remaining fields of a TransferRequest are given as form values)
+type TransferManifestForm struct {
+	Manifest huma.FormFile `form:"manifest" required:"true" doc:"A Frictionless data package, a JSON array of file IDs, or a CSV file with an id column listing the files to transfer"`
+}
+
+// handler method for initiating a file transfer whose files are listed in an
+// uploaded manifest instead of the request body
+func (service *prototype) createTransferFromManifest(ctx context.Context,
+	input *struct {
+		Authorization string `header:"Authorization" doc:"Authorization header with encoded access token"`
+		RawBody       huma.MultipartFormFiles[TransferManifestForm]
+	}) (*TransferOutput, error) {
+
+	client, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := transferRequestFromForm(input.RawBody.Form.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// read the file IDs from the manifest
+	manifest, err := io.ReadAll(input.RawBody.Data().Manifest)
+	if err != nil {
+		return nil, apiError(http.StatusBadRequest, "invalid_manifest", err.Error())
+	}
+	request.FileIds, err = fileIdsFromManifest(manifest)
+	if err != nil {
+		return nil, apiError(http.StatusBadRequest, "invalid_manifest", err.Error())
+	}
+
+	clientLogger(ctx, client.Orcid).Info("Creating transfer from uploaded manifest",
+		"num_files", len(request.FileIds))
+	return createTransfer(ctx, client, request)
+}
+
+// gathers the fields of a transfer request (all but its file IDs) from the
+// given multipart form values, which are named for the fields of a
+// TransferRequest. Fields that aren't strings or booleans are given as JSON.
+func transferRequestFromForm(values map[string][]string) (TransferRequest, error) {
+	var err error
+	formValue := func(name string) string {
+		if formValues := values[name]; len(formValues) > 0 {
+			return formValues[0]
+		}
+		return ""
+	}
+	request := TransferRequest{
+		Orcid:       formValue("orcid"),
+		Source:      formValue("source"),
+		Destination: formValue("destination"),
+		Description: formValue("description"),
+		SearchId:    formValue("search_id"),
+		Prefix:      formValue("prefix"),
+		BasedOn:     formValue("based_on"),
+		CallbackURL: formValue("callback_url"),
+		Priority:    formValue("priority"),
+	}
+	if instructions := formValue("instructions"); instructions != "" {
+		request.Instructions = json.RawMessage(instructions)
+	}
+	if options := formValue("endpoint_options"); options != "" {
+		err = json.Unmarshal([]byte(options), &request.EndpointOptions)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid endpoint_options value: %s", err.Error()))
+		}
+	}
+	if notify := formValue("notify_by_email"); notify != "" {
+		request.NotifyByEmail, err = strconv.ParseBool(notify)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid notify_by_email value: %s", notify))
+		}
+	}
+	if skip := formValue("skip_existing"); skip != "" {
+		request.SkipExisting, err = strconv.ParseBool(skip)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid skip_existing value: %s", skip))
+		}
+	}
+	if dedupe := formValue("dedupe_by_hash"); dedupe != "" {
+		request.DedupeByHash, err = strconv.ParseBool(dedupe)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid dedupe_by_hash value: %s", dedupe))
+		}
+	}
+	if private := formValue("include_private_data"); private != "" {
+		request.IncludePrivateData, err = strconv.ParseBool(private)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid include_private_data value: %s", private))
+		}
+	}
+	if destinations := formValue("destinations"); destinations != "" {
+		err = json.Unmarshal([]byte(destinations), &request.Destinations)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid destinations value: %s", err.Error()))
+		}
+	}
+	if labels := formValue("labels"); labels != "" {
+		err = json.Unmarshal([]byte(labels), &request.Labels)
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid labels value: %s", err.Error()))
+		}
+	}
+	if metadata := formValue("manifest_metadata"); metadata != "" {
+		err = json.Unmarshal([]byte(metadata), &request.ManifestMetadata)
+		if err == nil {
+			err = validateManifestMetadata(request.ManifestMetadata)
+		}
+		if err != nil {
+			return request, apiError(http.StatusBadRequest, "invalid_request_body",
+				fmt.Sprintf("Invalid manifest_metadata value: %s", err.Error()))
+		}
+	}
+	return request, nil
+}
+
+// checks that the given manifest metadata (if any) has the fields required by
+// its schema, which isn't applied to values given in forms
+func validateManifestMetadata(metadata *ManifestMetadataRequest) error {
+	if metadata == nil {
+		return nil
+	}
+	for _, license := range metadata.Licenses {
+		if license.Name == "" {
+			return fmt.Errorf("every license requires a name")
+		}
+	}
+	if metadata.Publisher != nil && metadata.Publisher.Title == "" {
+		return fmt.Errorf("the publisher requires a title")
+	}
+	for _, funding := range metadata.Funding {
+		if funding.FunderName == "" {
+			return fmt.Errorf("every funding acknowledgment requires a funder_name")
+		}
+	}
+	return nil
+}
+
+// extracts a list of file IDs from the given manifest, which can be
+//   - a Frictionless data package (the IDs of its resources),
+//   - a JSON array of file IDs or a JSON object with a "file_ids" array, or
+//   - CSV data, whose "id" column (or first column, if there's no header) lists
+//     file IDs
+func fileIdsFromManifest(manifest []byte) ([]string, error) {
+	var fileIds []string
+	trimmed := bytes.TrimSpace(manifest)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if trimmed[0] == '[' {
+			if err := json.Unmarshal(trimmed, &fileIds); err != nil {
+				return nil, fmt.Errorf("Invalid JSON manifest: %s", err.Error())
+			}
+		} else {
+			var object struct {
+				Resources []struct {
+					Id string `json:"id"`
+				} `json:"resources"`
+				FileIds []string `json:"file_ids"`
+			}
+			if err := json.Unmarshal(trimmed, &object); err != nil {
+				return nil, fmt.Errorf("Invalid JSON manifest: %s", err.Error())
+			}
+			fileIds = object.FileIds
+			for _, resource := range object.Resources {
+				fileIds = append(fileIds, resource.Id)
+			}
+		}
+	} else {
+		reader := csv.NewReader(bytes.NewReader(trimmed))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV manifest: %s", err.Error())
+		}
+		column := 0
+		if len(records) > 0 {
+			if idColumn := slices.Index(records[0], "id"); idColumn != -1 {
+				column = idColumn
+				records = records[1:]
+			}
+		}
+		for _, record := range records {
+			if column < len(record) {
+				fileIds = append(fileIds, record[column])
+			}
+		}
+	}
+
+	// weed out empty IDs
+	fileIds = slices.DeleteFunc(fileIds, func(id string) bool {
+		return strings.TrimSpace(id) == ""
+	})
+	if len(fileIds) == 0 {
+		return nil, fmt.Errorf("The manifest lists no file IDs")
+	}
+	return fileIds, nil
+}
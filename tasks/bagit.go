@@ -0,0 +1,115 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// When DTS is configured to write BagIt manifests (manifest_format: bagit),
+// payload files are transferred into a data/ directory within a transfer's
+// destination folder, and the bag's tag files are written alongside it. See
+// https://www.rfc-editor.org/rfc/rfc8493 for the BagIt specification.
+
+// the name of the payload directory within a bag
+const bagPayloadDirectory = "data"
+
+// the name of a bag's (MD5) payload manifest, which is the manifest passed to
+// destination databases that are finalized after a transfer
+const bagManifestFileName = "manifest-md5.txt"
+
+// writes the tag files for a BagIt bag describing the resources in the given
+// manifest to the given directory, which is created if needed, returning the
+// names of the tag files written. A SHA-256 payload manifest is written in
+// addition to the MD5 one if every resource has a SHA-256 hash.
+func writeBagTagFiles(dir string, manifest DataPackage) ([]string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating bag directory: %s", err.Error())
+	}
+
+	// bagit.txt: bag declaration
+	declaration := "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"
+
+	// manifest-md5.txt (and manifest-sha256.txt): payload manifests
+	var payloadManifest, sha256Manifest strings.Builder
+	var payloadBytes int
+	haveSha256 := true
+	for _, resource := range manifest.Resources {
+		md5 := resource.HashFor("md5")
+		if md5 == "" {
+			return nil, fmt.Errorf("resource %s has no MD5 checksum for the bag manifest",
+				resource.Id)
+		}
+		payloadPath := filepath.ToSlash(filepath.Join(bagPayloadDirectory, resource.Path))
+		payloadManifest.WriteString(fmt.Sprintf("%s  %s\n", md5, payloadPath))
+		if sha256 := resource.HashFor("sha256"); sha256 != "" {
+			sha256Manifest.WriteString(fmt.Sprintf("%s  %s\n", sha256, payloadPath))
+		} else {
+			haveSha256 = false
+		}
+		payloadBytes += resource.Bytes
+	}
+
+	// bag-info.txt: bag metadata
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("Bagging-Date: %s\n", time.Now().Format(time.DateOnly)))
+	info.WriteString(fmt.Sprintf("Payload-Oxum: %d.%d\n", payloadBytes, len(manifest.Resources)))
+	for _, contributor := range manifest.Contributors {
+		if contributor.Organization != "" {
+			info.WriteString(fmt.Sprintf("Source-Organization: %s\n", contributor.Organization))
+		}
+		if contributor.Title != "" {
+			info.WriteString(fmt.Sprintf("Contact-Name: %s\n", contributor.Title))
+		}
+		if contributor.Email != "" {
+			info.WriteString(fmt.Sprintf("Contact-Email: %s\n", contributor.Email))
+		}
+	}
+	if description := strings.TrimSpace(manifest.Description); description != "" {
+		// continuation lines are indented
+		description = strings.ReplaceAll(description, "\n", "\n  ")
+		info.WriteString(fmt.Sprintf("External-Description: %s\n", description))
+	}
+
+	contents := map[string]string{
+		"bagit.txt":         declaration,
+		"bag-info.txt":      info.String(),
+		bagManifestFileName: payloadManifest.String(),
+	}
+	tagFiles := []string{"bagit.txt", "bag-info.txt", bagManifestFileName}
+	if haveSha256 && len(manifest.Resources) > 0 {
+		contents["manifest-sha256.txt"] = sha256Manifest.String()
+		tagFiles = append(tagFiles, "manifest-sha256.txt")
+	}
+	for _, tagFile := range tagFiles {
+		err = os.WriteFile(filepath.Join(dir, tagFile), []byte(contents[tagFile]), 0644)
+		if err != nil {
+			return nil, fmt.Errorf("writing bag file %s: %s", tagFile, err.Error())
+		}
+	}
+	return tagFiles, nil
+}
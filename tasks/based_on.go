@@ -0,0 +1,153 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/databases"
+)
+
+// returns the IDs of the files requested by the given specification (from the
+// given source database) that its base transfer didn't move with the same
+// hashes and sizes, preserving their order. Files without hashes and files the
+// source doesn't recognize are always kept.
+func changedFiles(source databases.Database, spec Specification) ([]string, error) {
+	baseResources, err := baseTransferResources(spec.BasedOn, spec.Source)
+	if err != nil {
+		return nil, err
+	}
+	transferred := make(map[string]DataResource)
+	for _, resource := range baseResources {
+		transferred[resource.Id] = resource
+		for _, alias := range resource.Aliases {
+			transferred[alias] = resource
+		}
+	}
+
+	resources, err := databases.CachedResources(spec.Source, source, spec.FileIds)
+	if err != nil {
+		return nil, err
+	}
+	unchanged := make(map[string]bool)
+	for _, resource := range resources {
+		if base, found := transferred[resource.Id]; found && resource.Hash != "" &&
+			resource.Hash == base.Hash && resource.Bytes == base.Bytes {
+			unchanged[resource.Id] = true
+		}
+	}
+	fileIds := make([]string, 0, len(spec.FileIds))
+	for _, fileId := range spec.FileIds {
+		if !unchanged[fileId] {
+			fileIds = append(fileIds, fileId)
+		}
+	}
+	if len(fileIds) == 0 {
+		return nil, NoChangedFilesError{Id: spec.BasedOn}
+	}
+	return fileIds, nil
+}
+
+// returns the resources listed in the manifest(s) of the completed transfer
+// with the given ID, which must have moved files from the given source
+// database, or an InvalidBaseTransferError if it can't serve as a base
+func baseTransferResources(baseId uuid.UUID, sourceName string) ([]DataResource, error) {
+	baseSpec, err := SpecificationForTask(baseId)
+	if err != nil {
+		var notFound NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, InvalidBaseTransferError{
+				Id:      baseId,
+				Message: "transfer not found (it may have been purged)",
+			}
+		}
+		return nil, err
+	}
+	if baseSpec.Source != sourceName {
+		return nil, InvalidBaseTransferError{
+			Id:      baseId,
+			Message: "transfer was from a different source database",
+		}
+	}
+	status, err := Status(baseId)
+	if err != nil {
+		return nil, err
+	}
+	if status.Code != TransferStatusSucceeded {
+		return nil, InvalidBaseTransferError{
+			Id:      baseId,
+			Message: "transfer has not completed successfully",
+		}
+	}
+
+	// a split or fanned-out transfer's manifests belong to its sub-transfers
+	// (which may themselves be split)
+	transferIds, err := leafTransfers(baseId)
+	if err != nil {
+		return nil, err
+	}
+	var resources []DataResource
+	for _, transferId := range transferIds {
+		manifestContent, err := Manifest(transferId)
+		if err != nil {
+			var noManifest ManifestNotFoundError
+			if errors.As(err, &noManifest) {
+				return nil, InvalidBaseTransferError{
+					Id:      baseId,
+					Message: "transfer has no manifest listing its files",
+				}
+			}
+			return nil, err
+		}
+		var manifest DataPackage
+		err = json.Unmarshal(manifestContent, &manifest)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, manifest.Resources...)
+	}
+	return resources, nil
+}
+
+// returns the IDs of the transfers at the bottom of the tree of sub-transfers
+// rooted at the one with the given ID (just that ID if it has none)
+func leafTransfers(taskId uuid.UUID) ([]uuid.UUID, error) {
+	children, err := SubTransfers(taskId)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return []uuid.UUID{taskId}, nil
+	}
+	var leaves []uuid.UUID
+	for _, childId := range children {
+		childLeaves, err := leafTransfers(childId)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, childLeaves...)
+	}
+	return leaves, nil
+}
@@ -23,6 +23,7 @@ package tasks
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 
@@ -38,6 +39,16 @@ func (t NotFoundError) Error() string {
 	return fmt.Sprintf("The task %s was not found.", t.Id.String())
 }
 
+// indicates that a manifest is sought for a task that has not successfully
+// completed
+type ManifestNotFoundError struct {
+	Id uuid.UUID
+}
+
+func (t ManifestNotFoundError) Error() string {
+	return fmt.Sprintf("No manifest is available for the task %s.", t.Id.String())
+}
+
 // indicates that Start() has been called when tasks are being processed
 type AlreadyRunningError struct{}
 
@@ -52,6 +63,14 @@ func (t NotRunningError) Error() string {
 	return fmt.Sprintf("Tasks are not currently being processed.")
 }
 
+// indicates that a transfer has been requested while tasks are being drained
+// before the service shuts down
+type DrainingError struct{}
+
+func (t DrainingError) Error() string {
+	return fmt.Sprintf("The service is shutting down and is not accepting new transfers.")
+}
+
 // indicates that a transfer has been requested with no files(!)
 type NoFilesRequestedError struct{}
 
@@ -68,3 +87,122 @@ func (e PayloadTooLargeError) Error() string {
 	return fmt.Sprintf("Requested payload is too large: %g GB (limit is %g GB).",
 		e.Size, config.Service.MaxPayloadSize)
 }
+
+// indicates that a directory to which a requested transfer writes files
+// doesn't have enough free space for it
+type InsufficientSpaceError struct {
+	Directory string // the directory lacking space
+	Required  uint64 // the space required (bytes)
+	Available uint64 // the space available (bytes)
+}
+
+func (e InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("Insufficient free space in %s: %d bytes required, %d available.",
+		e.Directory, e.Required, e.Available)
+}
+
+// indicates that a transfer has been requested for too many files
+type TooManyFilesError struct {
+	Count int // number of requested files
+}
+
+func (e TooManyFilesError) Error() string {
+	return fmt.Sprintf("Too many files requested: %d (limit is %d).",
+		e.Count, config.Service.MaxFilesPerRequest)
+}
+
+// indicates that a transfer has been requested with an unrecognized priority
+type InvalidPriorityError struct {
+	Priority string
+}
+
+func (e InvalidPriorityError) Error() string {
+	return fmt.Sprintf("Invalid transfer priority: %s (must be high, normal, or low)", e.Priority)
+}
+
+// indicates that a transfer has been requested for files that its source
+// database doesn't recognize
+type FilesNotFoundError struct {
+	Database string   // name of the source database
+	FileIds  []string // IDs of the unrecognized files
+}
+
+func (e FilesNotFoundError) Error() string {
+	return fmt.Sprintf("Files not found in database %s: %s", e.Database,
+		strings.Join(e.FileIds, ", "))
+}
+
+// indicates that a transfer request includes a label that is invalid or
+// exceeds the service's limits
+type InvalidLabelError struct {
+	Key, Message string
+}
+
+func (e InvalidLabelError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("Invalid transfer labels: %s", e.Message)
+	}
+	return fmt.Sprintf("Invalid transfer label %s: %s", e.Key, e.Message)
+}
+
+// indicates that a transfer request includes a callback URL that the service
+// can't or won't contact
+type InvalidCallbackURLError struct {
+	URL, Message string
+}
+
+func (e InvalidCallbackURLError) Error() string {
+	return fmt.Sprintf("Invalid callback URL %s: %s", e.URL, e.Message)
+}
+
+// indicates that a transfer includes a resource that is under embargo
+type EmbargoedResourceError struct {
+	ResourceId string // ID of the embargoed resource
+	Until      string // date (YYYY-MM-DD) on which the embargo ends
+}
+
+func (e EmbargoedResourceError) Error() string {
+	return fmt.Sprintf("Resource %s is under embargo until %s and can't be transferred.",
+		e.ResourceId, e.Until)
+}
+
+// indicates that transfers between the requested source and destination
+// databases are not permitted by the service's configuration
+type TransferNotAllowedError struct {
+	Source, Destination string
+}
+
+func (e TransferNotAllowedError) Error() string {
+	return fmt.Sprintf("Transfers from %s to %s are not allowed.", e.Source, e.Destination)
+}
+
+// indicates that a transfer request's destination databases are invalid
+// (e.g. a destination is given more than once)
+type InvalidDestinationsError struct {
+	Message string
+}
+
+func (e InvalidDestinationsError) Error() string {
+	return fmt.Sprintf("Invalid destinations: %s", e.Message)
+}
+
+// indicates that the transfer on which a transfer request is based can't be
+// used as its base (e.g. because it has been purged)
+type InvalidBaseTransferError struct {
+	Id      uuid.UUID // ID of the base transfer
+	Message string
+}
+
+func (e InvalidBaseTransferError) Error() string {
+	return fmt.Sprintf("Invalid base transfer %s: %s", e.Id.String(), e.Message)
+}
+
+// indicates that none of the files requested by a transfer have changed since
+// the transfer on which it's based
+type NoChangedFilesError struct {
+	Id uuid.UUID // ID of the base transfer
+}
+
+func (e NoChangedFilesError) Error() string {
+	return fmt.Sprintf("None of the requested files have changed since transfer %s.", e.Id.String())
+}
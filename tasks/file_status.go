@@ -0,0 +1,106 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// the state of an individual file within a transfer
+type FileState string
+
+const (
+	FileStatePending     FileState = "pending"     // not yet transferred
+	FileStateTransferred FileState = "transferred" // transferred to the destination
+	FileStateSkipped     FileState = "skipped"     // already at the destination
+	FileStateFailed      FileState = "failed"      // failed to transfer
+)
+
+// the status of an individual file within a transfer
+type FileStatus struct {
+	// ID of the file in its source database
+	Id string
+	// path of the file at its source endpoint
+	Path string
+	// state of the file
+	State FileState
+	// error message for a failed file (if any)
+	Error string
+}
+
+// returns the statuses of the files in the given task, gathering those of its
+// sub-transfers if it was split
+func fileStatuses(tasks map[uuid.UUID]transferTask, task transferTask) []FileStatus {
+	var statuses []FileStatus
+	if len(task.Children) > 0 {
+		for _, childId := range task.Children {
+			statuses = append(statuses, fileStatuses(tasks, tasks[childId])...)
+		}
+		return statuses
+	}
+	if len(task.Subtasks) == 0 { // task hasn't started
+		for _, fileId := range task.FileIds {
+			statuses = append(statuses, FileStatus{Id: fileId, State: FileStatePending})
+		}
+		return statuses
+	}
+	for _, subtask := range task.Subtasks {
+		statuses = append(statuses, subtask.fileStatuses()...)
+	}
+	return statuses
+}
+
+// returns the statuses of the files in the subtask
+func (subtask transferSubtask) fileStatuses() []FileStatus {
+	status := subtask.TransferStatus
+	numSent := len(subtask.Resources) - len(subtask.SkippedFiles)
+	statuses := make([]FileStatus, len(subtask.Resources))
+	for i, resource := range subtask.Resources {
+		statuses[i] = FileStatus{
+			Id:    resource.Id,
+			Path:  resource.Path,
+			State: FileStatePending,
+		}
+		if slices.Contains(subtask.SkippedFiles, resource.Id) {
+			statuses[i].State = FileStateSkipped
+		} else if subtask.Transfer.Valid || subtask.Staging.Valid { // in progress
+			continue
+		} else if status.Code == TransferStatusSucceeded {
+			statuses[i].State = FileStateTransferred
+		} else if status.Code == TransferStatusFailed {
+			if message, failed := status.FailedFiles[resource.Path]; failed {
+				statuses[i].State = FileStateFailed
+				statuses[i].Error = message
+			} else if len(status.FailedFiles) == 0 {
+				// the endpoint didn't say which files failed
+				statuses[i].State = FileStateFailed
+				statuses[i].Error = status.Message
+			} else if status.NumFilesTransferred >= numSent-len(status.FailedFiles) {
+				// every file not listed as failed was transferred
+				statuses[i].State = FileStateTransferred
+			}
+		}
+	}
+	return statuses
+}
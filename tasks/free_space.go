@@ -0,0 +1,91 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+)
+
+// returns the space available (in bytes) on the filesystem holding the given
+// directory (replaceable for testing)
+var availableSpace = diskFreeSpace
+
+// indicates that free space can't be determined on this platform
+var errFreeSpaceUnsupported = errors.New("free space can't be determined on this platform")
+
+// checks that the directories to which a transfer of the files requested by
+// the given specification (from the given source database) to the given
+// destination databases writes files have room for them, leaving the
+// configured minimum of free space, and returns an InsufficientSpaceError if
+// they don't
+func checkFreeSpace(source databases.Database, spec Specification, destinations []string) error {
+	if config.Service.MinFreeSpace <= 0 {
+		return nil
+	}
+	margin := uint64(config.Service.MinFreeSpace * 1024 * 1024 * 1024)
+
+	// the task's records and working files (e.g. manifests) are small, so
+	// their directories need only the margin, while the payload lands in the
+	// roots of local destination endpoints
+	_, size, err := databases.EstimateTransfer(spec.Source, source, spec.FileIds)
+	if err != nil {
+		return err
+	}
+	directories := []string{config.Service.DataDirectory, config.Service.ManifestDirectory}
+	payloads := []uint64{0, 0}
+	for _, destination := range destinations {
+		for _, endpointName := range databaseEndpoints(destination) {
+			endpoint := config.Endpoints[endpointName]
+			if endpoint.Provider == "local" && endpoint.Root != "" {
+				directories = append(directories, endpoint.Root)
+				payloads = append(payloads, size)
+			}
+		}
+	}
+
+	for i, directory := range directories {
+		if directory == "" {
+			continue
+		}
+		available, err := availableSpace(directory)
+		if err != nil {
+			if errors.Is(err, errFreeSpaceUnsupported) {
+				return nil
+			}
+			slog.Warn(fmt.Sprintf("Can't determine free space in %s: %s", directory, err.Error()))
+			continue
+		}
+		if required := payloads[i] + margin; available < required {
+			return InsufficientSpaceError{
+				Directory: directory,
+				Required:  required,
+				Available: available,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// limits on the labels attached to a transfer
+const (
+	maxLabels           = 16  // number of labels
+	maxLabelKeyLength   = 64  // length of a label key (bytes)
+	maxLabelValueLength = 256 // length of a label value (bytes)
+)
+
+// checks the given labels against the limits on their number and the lengths
+// of their keys and values
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return InvalidLabelError{
+			Message: fmt.Sprintf("too many labels: %d (limit is %d)", len(labels), maxLabels),
+		}
+	}
+	for key, value := range labels {
+		if key == "" {
+			return InvalidLabelError{Key: key, Message: "label keys must not be empty"}
+		}
+		if len(key) > maxLabelKeyLength {
+			return InvalidLabelError{
+				Key:     key,
+				Message: fmt.Sprintf("key is longer than %d bytes", maxLabelKeyLength),
+			}
+		}
+		if len(value) > maxLabelValueLength {
+			return InvalidLabelError{
+				Key:     key,
+				Message: fmt.Sprintf("value is longer than %d bytes", maxLabelValueLength),
+			}
+		}
+	}
+	return nil
+}
+
+// returns true if the task has all of the given labels with the given values
+func (task transferTask) hasLabels(labels map[string]string) bool {
+	for key, value := range labels {
+		if taskValue, found := task.Labels[key]; !found || taskValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// criteria for selecting transfers in a listing
+type transferQuery struct {
+	Orcid  string            // ORCID of the user or client that requested the transfers
+	Labels map[string]string // labels the transfers must have
+}
+
+// returns the IDs of the transfers (excluding sub-transfers) that match the
+// given query, in the order in which they were requested
+func matchingTasks(tasks map[uuid.UUID]transferTask, query transferQuery) []uuid.UUID {
+	matches := make([]transferTask, 0)
+	for _, task := range tasks {
+		if task.Parent.Valid {
+			continue
+		}
+		if task.User.Orcid != query.Orcid && task.Client.Orcid != query.Orcid {
+			continue
+		}
+		if task.hasLabels(query.Labels) {
+			matches = append(matches, task)
+		}
+	}
+	slices.SortFunc(matches, func(a, b transferTask) int {
+		return a.CreationTime.Compare(b.CreationTime)
+	})
+	taskIds := make([]uuid.UUID, len(matches))
+	for i, task := range matches {
+		taskIds[i] = task.Id
+	}
+	return taskIds
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/metrics"
+)
+
+// bucket upper bounds for lifecycle phase durations (seconds), ranging from
+// a second to a day
+var phaseDurationBuckets = []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 12 * 3600, 24 * 3600}
+
+// metrics describing the transfer tasks processed by DTS
+var (
+	transfersCreated = metrics.NewCounter("dts_transfers_created_total",
+		"Number of transfer tasks created")
+	transfersSucceeded = metrics.NewCounter("dts_transfers_succeeded_total",
+		"Number of transfer tasks that completed successfully")
+	transfersFailed = metrics.NewCounter("dts_transfers_failed_total",
+		"Number of transfer tasks that failed (including canceled tasks)")
+	transfersCanceled = metrics.NewCounter("dts_transfers_canceled_total",
+		"Number of transfer tasks for which cancellation was requested")
+	transfersActive = metrics.NewGauge("dts_transfers_active",
+		"Number of transfer tasks that have not yet completed")
+	bytesTransferred = metrics.NewCounter("dts_bytes_transferred_total",
+		"Number of bytes in payloads of successfully completed transfer tasks")
+	stagingDuration = metrics.NewHistogram("dts_staging_duration_seconds",
+		"Time spent by transfer tasks staging files", phaseDurationBuckets)
+	transferDuration = metrics.NewHistogram("dts_transfer_duration_seconds",
+		"Time spent by transfer tasks transferring files", phaseDurationBuckets)
+	finalizingDuration = metrics.NewHistogram("dts_finalizing_duration_seconds",
+		"Time spent by transfer tasks generating and transferring manifests", phaseDurationBuckets)
+)
+
+// updates metrics for a task whose status has changed from the given old
+// status, given the time it spent with that status
+func recordStatusChange(task transferTask, oldStatus TransferStatus, seconds float64) {
+	// the sub-transfers of a split task contribute only their payloads, since
+	// the task itself accounts for the transfer
+	if task.Parent.Valid {
+		if task.Status.Code == TransferStatusSucceeded {
+			bytesTransferred.Add(float64(task.payloadBytes()))
+		}
+		return
+	}
+	switch oldStatus.Code {
+	case TransferStatusStaging:
+		stagingDuration.Observe(seconds)
+	case TransferStatusActive, TransferStatusInactive:
+		transferDuration.Observe(seconds)
+	case TransferStatusFinalizing:
+		finalizingDuration.Observe(seconds)
+	}
+	switch task.Status.Code {
+	case TransferStatusSucceeded:
+		transfersSucceeded.Inc()
+		bytesTransferred.Add(float64(task.payloadBytes()))
+	case TransferStatusFailed:
+		transfersFailed.Inc()
+	}
+}
+
+// updates the number of active tasks from the given set of tasks
+func recordActiveTasks(tasks map[uuid.UUID]transferTask) {
+	numActive := 0
+	for _, task := range tasks {
+		if !task.Completed() && !task.Parent.Valid {
+			numActive++
+		}
+	}
+	transfersActive.Set(float64(numActive))
+}
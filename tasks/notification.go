@@ -0,0 +1,291 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+)
+
+// sends an email summarizing a completed task to the user who requested it,
+// if the user asked to be notified and an SMTP server is configured
+func notifyUser(task transferTask) {
+	if !task.NotifyByEmail {
+		return
+	}
+	if config.SMTP.Host == "" {
+		slog.Debug(fmt.Sprintf("Task %s: no SMTP server configured for notifications",
+			task.Id.String()))
+		return
+	}
+	if task.User.Email == "" {
+		slog.Debug(fmt.Sprintf("Task %s: no email address for notification",
+			task.Id.String()))
+		return
+	}
+	subject, body := notificationForTask(task)
+	err := sendEmail(task.User.Email, subject, body)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Task %s: sending notification: %s",
+			task.Id.String(), err.Error()))
+	} else {
+		slog.Info(fmt.Sprintf("Task %s: sent notification to %s",
+			task.Id.String(), task.User.Email))
+	}
+}
+
+// composes a subject and body for an email summarizing a completed task
+func notificationForTask(task transferTask) (string, string) {
+	var status string
+	if task.Status.Code == TransferStatusSucceeded {
+		status = "succeeded"
+	} else {
+		status = "failed"
+	}
+	subject := fmt.Sprintf("DTS transfer %s %s", task.Id.String(), status)
+
+	var body strings.Builder
+	body.WriteString(fmt.Sprintf("Your transfer from %s to %s %s.\r\n\r\n",
+		task.Source, task.destinationName(), status))
+	body.WriteString(fmt.Sprintf("Transfer ID: %s\r\n", task.Id.String()))
+	if task.Status.Message != "" {
+		body.WriteString(fmt.Sprintf("Message: %s\r\n", task.Status.Message))
+	}
+	body.WriteString(fmt.Sprintf("Files: %d\r\n", len(task.FileIds)))
+	body.WriteString(fmt.Sprintf("Bytes: %d\r\n", task.payloadBytes()))
+	if task.Status.Code == TransferStatusSucceeded {
+		body.WriteString(fmt.Sprintf("Manifest: %s (%s)\r\n",
+			task.destinationManifest(), task.Destination))
+	}
+	return subject, body.String()
+}
+
+// sends an email with the given subject and body to the given address using
+// the configured SMTP server
+func sendEmail(to, subject, body string) error {
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n"+
+		"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		config.SMTP.From, to, subject, time.Now().Format(time.RFC1123Z), body)
+	address := fmt.Sprintf("%s:%d", config.SMTP.Host, config.SMTP.Port)
+	var auth smtp.Auth
+	if config.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", config.SMTP.Username, config.SMTP.Password,
+			config.SMTP.Host)
+	}
+	return smtp.SendMail(address, auth, config.SMTP.From, []string{to}, []byte(message))
+}
+
+// the JSON payload POSTed to a task's callback URL when it completes
+type CallbackPayload struct {
+	// the ID of the completed transfer
+	Id uuid.UUID `json:"id"`
+	// "succeeded" or "failed"
+	Status string `json:"status"`
+	// a message describing the transfer's status (if any)
+	Message string `json:"message,omitempty"`
+	// the number of files in the transfer
+	NumFiles int `json:"num_files"`
+	// the number of bytes in the transfer
+	Bytes int `json:"bytes"`
+	// the path (relative to the DTS) from which the transfer's manifest can be
+	// fetched (successful transfers only)
+	ManifestURL string `json:"manifest_url,omitempty"`
+}
+
+// the name of the HTTP header holding the signature of a callback payload
+const CallbackSignatureHeader = "X-DTS-Signature"
+
+// the number of times a callback is attempted, and the interval between
+// attempts
+var callbackAttempts = 3
+var callbackRetryInterval = 5 * time.Second
+
+// if true, callbacks may be delivered to loopback, private, link-local, and
+// unspecified addresses, which are otherwise refused so that callbacks can't
+// reach hosts inside the service's network (set only in tests)
+var allowInternalCallbacks = false
+
+// returns true if the given IP address is internal to the service's host or
+// network, and therefore not an acceptable callback destination
+func isInternalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast()
+}
+
+// refuses connections to internal addresses for callbacks, checking the
+// address actually dialed (after DNS resolution) so that a host name can't be
+// rebound to an internal address after validation
+func checkCallbackAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid callback address: %s", address)
+	}
+	if isInternalAddress(ip) && !allowInternalCallbacks {
+		return fmt.Errorf("refusing callback to internal address %s", ip.String())
+	}
+	return nil
+}
+
+// checks that the given URL can be used as a callback, returning an
+// InvalidCallbackURLError if it can't
+func validateCallbackURL(callbackURL string) error {
+	if config.Service.CallbackSecret == "" {
+		return InvalidCallbackURLError{
+			URL:     callbackURL,
+			Message: "callbacks are not enabled on this service",
+		}
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return InvalidCallbackURLError{URL: callbackURL, Message: err.Error()}
+	}
+	if !slices.Contains(config.Service.CallbackSchemes, u.Scheme) {
+		return InvalidCallbackURLError{
+			URL: callbackURL,
+			Message: fmt.Sprintf("scheme must be one of %s",
+				strings.Join(config.Service.CallbackSchemes, ", ")),
+		}
+	}
+	if u.Host == "" {
+		return InvalidCallbackURLError{URL: callbackURL, Message: "no host given"}
+	}
+	// catch obviously internal hosts here (others are refused when dialed)
+	if !allowInternalCallbacks {
+		ip := net.ParseIP(u.Hostname())
+		if strings.EqualFold(u.Hostname(), "localhost") || (ip != nil && isInternalAddress(ip)) {
+			return InvalidCallbackURLError{
+				URL:     callbackURL,
+				Message: "host must not be a loopback, private, link-local, or unspecified address",
+			}
+		}
+	}
+	return nil
+}
+
+// returns the signature for the given callback payload, a hex-encoded
+// HMAC-SHA256 digest computed with the configured callback secret
+func callbackSignature(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(config.Service.CallbackSecret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// POSTs a signed summary of a completed task to its callback URL (if any),
+// retrying a few times if the callback can't be delivered
+func notifyCallback(task transferTask) {
+	if task.CallbackURL == "" {
+		return
+	}
+	payload, err := json.Marshal(callbackPayloadForTask(task))
+	if err != nil {
+		slog.Error(fmt.Sprintf("Task %s: encoding callback payload: %s",
+			task.Id.String(), err.Error()), task.logAttrs()...)
+		return
+	}
+	signature := callbackSignature(payload)
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err = postCallback(task.CallbackURL, payload, signature)
+		if err == nil {
+			slog.Info(fmt.Sprintf("Task %s: delivered callback to %s",
+				task.Id.String(), task.CallbackURL), task.logAttrs()...)
+			return
+		}
+		slog.Warn(fmt.Sprintf("Task %s: delivering callback (attempt %d of %d): %s",
+			task.Id.String(), attempt, callbackAttempts, err.Error()), task.logAttrs()...)
+		if attempt < callbackAttempts {
+			time.Sleep(callbackRetryInterval)
+		}
+	}
+	slog.Error(fmt.Sprintf("Task %s: giving up on callback to %s",
+		task.Id.String(), task.CallbackURL), task.logAttrs()...)
+}
+
+// composes the callback payload summarizing a completed task
+func callbackPayloadForTask(task transferTask) CallbackPayload {
+	payload := CallbackPayload{
+		Id:       task.Id,
+		Status:   "failed",
+		Message:  task.Status.Message,
+		NumFiles: len(task.FileIds),
+		Bytes:    task.payloadBytes(),
+	}
+	if task.Status.Code == TransferStatusSucceeded {
+		payload.Status = "succeeded"
+		payload.ManifestURL = fmt.Sprintf("/api/v1/transfers/%s/manifest", task.Id.String())
+	}
+	return payload
+}
+
+// POSTs the given payload with the given signature to the given URL
+func postCallback(callbackURL string, payload []byte, signature string) error {
+	request, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(CallbackSignatureHeader, signature)
+	client := http.Client{
+		Timeout: 30 * time.Second,
+		// don't use a proxy, and dial only external addresses
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 10 * time.Second,
+				Control: checkCallbackAddress,
+			}).DialContext,
+		},
+		// don't follow redirects, which could lead anywhere
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", response.StatusCode)
+	}
+	return nil
+}
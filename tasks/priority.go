@@ -0,0 +1,115 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+)
+
+// this "enum" type encodes the priority with which a transfer is dispatched:
+// transfers with higher priorities begin before those with lower ones when the
+// number of active transfers is limited
+type TransferPriority int
+
+const (
+	TransferPriorityLow    TransferPriority = -1
+	TransferPriorityNormal TransferPriority = 0 // the default
+	TransferPriorityHigh   TransferPriority = 1
+)
+
+// returns the transfer priority with the given name ("high", "normal", or
+// "low"), or normal priority if the name is empty
+func ParseTransferPriority(name string) (TransferPriority, error) {
+	switch name {
+	case "high":
+		return TransferPriorityHigh, nil
+	case "normal", "":
+		return TransferPriorityNormal, nil
+	case "low":
+		return TransferPriorityLow, nil
+	default:
+		return TransferPriorityNormal, InvalidPriorityError{Priority: name}
+	}
+}
+
+func (p TransferPriority) String() string {
+	switch p {
+	case TransferPriorityHigh:
+		return "high"
+	case TransferPriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// returns the IDs of the given tasks in the order in which they're updated:
+// higher-priority tasks first, and tasks of equal priority in the order in
+// which they were created
+func dispatchOrder(tasks map[uuid.UUID]transferTask) []uuid.UUID {
+	taskIds := make([]uuid.UUID, 0, len(tasks))
+	for taskId := range tasks {
+		taskIds = append(taskIds, taskId)
+	}
+	slices.SortFunc(taskIds, func(a, b uuid.UUID) int {
+		taskA, taskB := tasks[a], tasks[b]
+		if taskA.Priority != taskB.Priority {
+			return int(taskB.Priority - taskA.Priority)
+		}
+		return taskA.CreationTime.Compare(taskB.CreationTime)
+	})
+	return taskIds
+}
+
+// returns true if the given task is waiting to begin (it hasn't started and
+// isn't tracking sub-transfers that start on their own)
+func (task transferTask) awaitingDispatch() bool {
+	return len(task.Subtasks) == 0 && len(task.Children) == 0 && !task.Completed()
+}
+
+// the status message for a task waiting for an available transfer slot
+const pendingMessage = "pending: waiting for an available transfer slot"
+
+// returns true if the given task is waiting for an available transfer slot
+func (task transferTask) pending() bool {
+	return task.Status.Code == TransferStatusInactive && task.Status.Message == pendingMessage
+}
+
+// returns the number of the given tasks that have begun and not yet completed
+func numActiveTasks(tasks map[uuid.UUID]transferTask) int {
+	numActive := 0
+	for _, task := range tasks {
+		if len(task.Subtasks) > 0 && !task.Completed() {
+			numActive++
+		}
+	}
+	return numActive
+}
+
+// returns true if another task can begin, given the number of active tasks
+func canDispatch(numActive int) bool {
+	return config.Service.MaxConcurrentTransfers == 0 || numActive < config.Service.MaxConcurrentTransfers
+}
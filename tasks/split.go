@@ -0,0 +1,131 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// splits the given new task into sub-transfers that each transfer at most
+// maxFiles files, returning the parent task (which tracks its sub-transfers)
+// and the sub-transfers themselves
+func splitTask(task transferTask, maxFiles int) (transferTask, []transferTask) {
+	children := make([]transferTask, 0)
+	for start := 0; start < len(task.FileIds); start += maxFiles {
+		end := min(start+maxFiles, len(task.FileIds))
+		child := task
+		child.Id = uuid.New()
+		child.Parent = uuid.NullUUID{UUID: task.Id, Valid: true}
+		child.FileIds = task.FileIds[start:end]
+		child.Children = nil
+		child.NotifyByEmail = false // the user hears about the parent only
+		child.CallbackURL = ""
+		children = append(children, child)
+		task.Children = append(task.Children, child.Id)
+	}
+	return task, children
+}
+
+// fans the given new task out into one sub-transfer for each of its
+// destinations, returning the parent task (which tracks its sub-transfers) and
+// the sub-transfers themselves
+func fanOutTask(task transferTask) (transferTask, []transferTask) {
+	children := make([]transferTask, 0, len(task.Destinations))
+	for _, destination := range task.Destinations {
+		child := task
+		child.Id = uuid.New()
+		child.Parent = uuid.NullUUID{UUID: task.Id, Valid: true}
+		child.Destination = destination
+		child.Destinations = nil
+		child.Children = nil
+		child.NotifyByEmail = false // the user hears about the parent only
+		child.CallbackURL = ""
+		children = append(children, child)
+		task.Children = append(task.Children, child.Id)
+	}
+	return task, children
+}
+
+// updates the status of a split task from those of its sub-transfers,
+// canceling any remaining sub-transfers if one of them fails
+func (task *transferTask) updateFromChildren(tasks map[uuid.UUID]transferTask) {
+	var status TransferStatus
+	var numUnknown, numStaging, numFinalizing, numSucceeded int
+	var failure string
+	for _, childId := range task.Children {
+		child, found := tasks[childId]
+		if !found {
+			failure = fmt.Sprintf("sub-transfer %s not found", childId.String())
+			continue
+		}
+		status.NumFiles += child.Status.NumFiles
+		status.NumFilesTransferred += child.Status.NumFilesTransferred
+		status.NumFilesSkipped += child.Status.NumFilesSkipped
+		switch child.Status.Code {
+		case TransferStatusUnknown:
+			numUnknown++
+		case TransferStatusStaging:
+			numStaging++
+		case TransferStatusFinalizing:
+			numFinalizing++
+		case TransferStatusSucceeded:
+			numSucceeded++
+		case TransferStatusFailed:
+			if failure == "" {
+				failure = fmt.Sprintf("sub-transfer %s failed", childId.String())
+				if child.Status.Message != "" {
+					failure += ": " + child.Status.Message
+				}
+			}
+		}
+	}
+
+	numChildren := len(task.Children)
+	if failure != "" {
+		status.Code = TransferStatusFailed
+		status.Message = failure
+		for _, childId := range task.Children {
+			if child, found := tasks[childId]; found && !child.Completed() {
+				cancelChildren(tasks, child)
+				child.Cancel()
+				tasks[childId] = child
+			}
+		}
+	} else if numSucceeded == numChildren {
+		status.Code = TransferStatusSucceeded
+	} else if numUnknown == numChildren {
+		status.Code = TransferStatusUnknown
+	} else if numUnknown+numStaging == numChildren {
+		status.Code = TransferStatusStaging
+	} else if numFinalizing+numSucceeded == numChildren {
+		status.Code = TransferStatusFinalizing
+	} else {
+		status.Code = TransferStatusActive
+	}
+	task.Status = status
+	if task.Completed() {
+		task.CompletionTime = time.Now()
+	}
+}
@@ -24,7 +24,10 @@ package tasks
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -39,6 +42,7 @@ import (
 // It holds multiple (possibly null) UUIDs corresponding to different
 // states in the file transfer lifecycle
 type transferSubtask struct {
+	TaskId              uuid.UUID               // ID of the task to which the subtask belongs
 	Destination         string                  // name of destination database (in config)
 	DestinationEndpoint string                  // name of destination database (in config)
 	DestinationFolder   string                  // folder path to which files are transferred
@@ -50,6 +54,12 @@ type transferSubtask struct {
 	Transfer            uuid.NullUUID           // file transfer UUID (if any)
 	TransferStatus      TransferStatus          // status of file transfer operation
 	Client              auth.Client             // info about client used for transfer
+	EndpointOptions     TransferOptions         // options for source endpoint transfer (if any)
+	Retries             int                     // number of times staging or transfer has been retried
+	SkipExisting        bool                    // set if files already at the destination are skipped
+	IncludePrivateData  bool                    // set if the source stages private data to which the user is entitled
+	SkippedFiles        []string                // IDs of files skipped because they're already at the destination
+	PollTime            time.Time               // time at which staging or transfer status was last checked
 }
 
 func (subtask *transferSubtask) start() error {
@@ -68,10 +78,11 @@ func (subtask *transferSubtask) start() error {
 	} else {
 		// tell the source DB to stage the files, stash the task, and return
 		// its new ID
-		source, err := databases.NewDatabase(subtask.Client.Orcid, subtask.Source)
+		source, err := taskDatabase(subtask.TaskId, subtask.Client.Orcid, subtask.Source)
 		if err != nil {
 			return err
 		}
+		source = databases.WithPrivateData(source, subtask.IncludePrivateData)
 		fileIds := make([]string, len(subtask.Resources))
 		for i, resource := range subtask.Resources {
 			fileIds[i] = resource.Id
@@ -96,17 +107,43 @@ func (subtask *transferSubtask) start() error {
 func (subtask *transferSubtask) update() error {
 	var err error
 	if subtask.Staging.Valid { // we're staging
-		err = subtask.checkStaging()
+		if pollDue(subtask.PollTime, config.Service.StagingPollInterval) {
+			subtask.PollTime = time.Now()
+			err = subtask.checkStaging()
+		}
 	} else if subtask.Transfer.Valid { // we're transferring
-		err = subtask.checkTransfer()
+		if pollDue(subtask.PollTime, config.Service.TransferPollInterval) {
+			subtask.PollTime = time.Now()
+			err = subtask.checkTransfer()
+		}
 	}
 	return err
 }
 
+// returns true if the subtask's staging request or file transfer has failed
+func (subtask transferSubtask) failed() bool {
+	return subtask.StagingStatus == databases.StagingStatusFailed ||
+		subtask.TransferStatus.Code == TransferStatusFailed
+}
+
+// retries a failed subtask from its last completed phase: failed staging is
+// requested again, and a failed transfer of staged files is resubmitted
+// without restaging them (files already at the destination are verified and
+// overwritten, or skipped if the subtask skips existing files, so this is safe)
+func (subtask *transferSubtask) retry() error {
+	subtask.Retries++
+	if subtask.StagingStatus == databases.StagingStatusFailed {
+		subtask.Staging = uuid.NullUUID{}
+		subtask.StagingStatus = databases.StagingStatusUnknown
+		return subtask.start()
+	}
+	return subtask.beginTransfer()
+}
+
 // checks whether files for a subtask are finished staging and, if so,
 // initiates the transfer process
 func (subtask *transferSubtask) checkStaging() error {
-	source, err := databases.NewDatabase(subtask.Client.Orcid, subtask.Source)
+	source, err := taskDatabase(subtask.TaskId, subtask.Client.Orcid, subtask.Source)
 	if err != nil {
 		return err
 	}
@@ -115,6 +152,16 @@ func (subtask *transferSubtask) checkStaging() error {
 	if err != nil {
 		return err
 	}
+	if subtask.StagingStatus == databases.StagingStatusSucceeded ||
+		subtask.StagingStatus == databases.StagingStatusFailed {
+		// staging changes the files' status, so their cached descriptors are
+		// out of date
+		fileIds := make([]string, len(subtask.Resources))
+		for i, resource := range subtask.Resources {
+			fileIds[i] = resource.Id
+		}
+		databases.InvalidateCachedResources(subtask.Source, fileIds)
+	}
 
 	if subtask.StagingStatus == databases.StagingStatusSucceeded { // staged!
 		if config.Service.DoubleCheckStaging {
@@ -149,6 +196,10 @@ func (subtask *transferSubtask) checkTransfer() error {
 	if err != nil {
 		return err
 	}
+	// files already at the destination count toward the transfer but weren't
+	// sent to the endpoint
+	subtask.TransferStatus.NumFiles += len(subtask.SkippedFiles)
+	subtask.TransferStatus.NumFilesSkipped += len(subtask.SkippedFiles)
 	if subtask.TransferStatus.Code == TransferStatusSucceeded ||
 		subtask.TransferStatus.Code == TransferStatusFailed { // transfer finished
 		subtask.Transfer = uuid.NullUUID{}
@@ -156,9 +207,22 @@ func (subtask *transferSubtask) checkTransfer() error {
 	return nil
 }
 
-// issues a cancellation request to the endpoint associated with the subtask
+// issues a cancellation request to the database or endpoint associated with
+// the subtask, depending on where it is in its lifecycle
 func (subtask *transferSubtask) cancel() error {
-	if subtask.Transfer.Valid { // we're transferring
+	if subtask.Staging.Valid { // we're staging
+		// ask the source database to discard the staging request
+		source, err := taskDatabase(subtask.TaskId, subtask.Client.Orcid, subtask.Source)
+		if err != nil {
+			return err
+		}
+		err = source.CancelStaging(subtask.Staging.UUID)
+		if err != nil {
+			return err
+		}
+		subtask.Staging = uuid.NullUUID{}
+		return nil
+	} else if subtask.Transfer.Valid { // we're transferring
 		// fetch the source endpoint
 		endpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
 		if err != nil {
@@ -185,26 +249,90 @@ func (subtask *transferSubtask) checkCancellation() error {
 
 	// at any other point in the lifecycle, terminate the task
 	subtask.TransferStatus.Code = TransferStatusFailed
-	subtask.TransferStatus.Message = "Task canceled at user request"
+	subtask.TransferStatus.Message = canceledMessage
 	return nil
 }
 
+// returns the path (relative to the destination endpoint's root) to which the
+// given resource's file is transferred
+func (subtask transferSubtask) destinationPath(resource DataResource) string {
+	if config.Service.ManifestFormat == "bagit" { // payload goes into the bag
+		return filepath.Join(subtask.DestinationFolder, bagPayloadDirectory, resource.Path)
+	}
+	return filepath.Join(subtask.DestinationFolder, resource.Path)
+}
+
+// returns the subtask's resources with the MD5 checksums of their transferred
+// files recomputed at the destination where its endpoint can compute them, and
+// any that disagree with the checksums reported by the source flagged
+func (subtask transferSubtask) resourcesWithRecomputedHashes() []DataResource {
+	resources := slices.Clone(subtask.Resources)
+	endpoint, err := endpoints.NewEndpoint(subtask.DestinationEndpoint)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Can't recompute hashes at %s: %s",
+			subtask.DestinationEndpoint, err.Error()))
+		return resources
+	}
+	hashable, ok := endpoint.(endpoints.HashableEndpoint)
+	if !ok {
+		return resources
+	}
+	for i, resource := range resources {
+		sourceHash := resource.HashFor("md5")
+		if sourceHash == "" {
+			continue
+		}
+		hash, err := hashable.MD5Sum(subtask.destinationPath(resource))
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Can't recompute hash for %s: %s", resource.Id, err.Error()))
+			continue
+		}
+		if hash != sourceHash {
+			slog.Warn(fmt.Sprintf("Hash for %s reported by %s (%s) doesn't match that of its "+
+				"transferred file (%s)", resource.Id, subtask.Source, sourceHash, hash))
+			resources[i].SourceHash = resource.Hash
+			resources[i].Hash = hash
+			resources[i].Hashes = maps.Clone(resource.Hashes)
+			if resources[i].Hashes == nil {
+				resources[i].Hashes = make(map[string]string)
+			}
+			resources[i].Hashes["md5"] = hash
+			resources[i].HashMismatch = true
+		}
+	}
+	return resources
+}
+
+// returns the IDs of those of the subtask's resources whose files are already
+// present at its destination, if its destination endpoint can report them
+func (subtask transferSubtask) existingFiles(destination endpoints.Endpoint) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	incremental, ok := destination.(endpoints.IncrementalEndpoint)
+	if !ok {
+		slog.Debug(fmt.Sprintf("Endpoint %s can't report existing files, so none are skipped",
+			subtask.DestinationEndpoint))
+		return existing, nil
+	}
+	// look for the resources where they're transferred
+	resources := make([]DataResource, len(subtask.Resources))
+	for i, resource := range subtask.Resources {
+		resources[i] = resource
+		resources[i].Path = subtask.destinationPath(resource)
+	}
+	fileIds, err := incremental.ExistingFiles(resources)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileId := range fileIds {
+		existing[fileId] = true
+	}
+	return existing, nil
+}
+
 // initiates a file transfer on a set of staged files
 func (subtask *transferSubtask) beginTransfer() error {
 	slog.Debug(fmt.Sprintf("Transferring %d file(s) from %s to %s",
 		len(subtask.Resources), subtask.SourceEndpoint, subtask.DestinationEndpoint))
-	// assemble a list of file transfers
-	fileXfers := make([]FileTransfer, len(subtask.Resources))
-	for i, resource := range subtask.Resources {
-		destinationPath := filepath.Join(subtask.DestinationFolder, resource.Path)
-		fileXfers[i] = FileTransfer{
-			SourcePath:      resource.Path,
-			DestinationPath: destinationPath,
-			Hash:            resource.Hash,
-		}
-	}
-
-	// initiate the transfer
 	sourceEndpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
 	if err != nil {
 		return err
@@ -213,7 +341,46 @@ func (subtask *transferSubtask) beginTransfer() error {
 	if err != nil {
 		return err
 	}
-	transferId, err := sourceEndpoint.Transfer(destinationEndpoint, fileXfers)
+
+	// skip files already present at the destination, if requested
+	existing := make(map[string]bool)
+	if subtask.SkipExisting {
+		existing, err = subtask.existingFiles(destinationEndpoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	// assemble a list of file transfers
+	fileXfers := make([]FileTransfer, 0, len(subtask.Resources))
+	subtask.SkippedFiles = nil
+	for _, resource := range subtask.Resources {
+		if existing[resource.Id] {
+			subtask.SkippedFiles = append(subtask.SkippedFiles, resource.Id)
+			continue
+		}
+		fileXfers = append(fileXfers, FileTransfer{
+			SourcePath:      resource.Path,
+			DestinationPath: subtask.destinationPath(resource),
+			Hash:            resource.Hash,
+		})
+	}
+	if len(fileXfers) == 0 { // nothing to do!
+		slog.Debug(fmt.Sprintf("All %d file(s) are already present at %s",
+			len(subtask.Resources), subtask.DestinationEndpoint))
+		subtask.Transfer = uuid.NullUUID{}
+		subtask.TransferStatus = TransferStatus{
+			Code:            TransferStatusSucceeded,
+			NumFiles:        len(subtask.Resources),
+			NumFilesSkipped: len(subtask.SkippedFiles),
+		}
+		subtask.Staging = uuid.NullUUID{}
+		return nil
+	}
+
+	// initiate the transfer
+	transferId, err := endpoints.TransferWithOptions(sourceEndpoint, destinationEndpoint,
+		fileXfers, subtask.EndpointOptions)
 	if err != nil {
 		return err
 	}
@@ -222,8 +389,9 @@ func (subtask *transferSubtask) beginTransfer() error {
 		Valid: true,
 	}
 	subtask.TransferStatus = TransferStatus{
-		Code:     TransferStatusActive,
-		NumFiles: len(subtask.Resources),
+		Code:            TransferStatusActive,
+		NumFiles:        len(subtask.Resources),
+		NumFilesSkipped: len(subtask.SkippedFiles),
 	}
 	subtask.Staging = uuid.NullUUID{}
 	return nil
@@ -22,16 +22,22 @@
 package tasks
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/endpoints"
 )
@@ -40,22 +46,70 @@ import (
 // a source database to a destination database. A transferTask can have one or
 // more subtasks, depending on how many transfer endpoints are involved.
 type transferTask struct {
-	Canceled          bool              // set if a cancellation request has been made
-	CompletionTime    time.Time         // time at which the transfer completed
-	Description       string            // Markdown description of the task
-	Destination       string            // name of destination database (in config)
-	DestinationFolder string            // folder path to which files are transferred
-	FileIds           []string          // IDs of all files being transferred
-	Id                uuid.UUID         // task identifier
-	Instructions      json.RawMessage   // machine-readable task processing instructions
-	Manifest          uuid.NullUUID     // manifest generation UUID (if any)
-	ManifestFile      string            // name of locally-created manifest file
-	PayloadSize       float64           // Size of payload (gigabytes)
-	Source            string            // name of source database (in config)
-	Status            TransferStatus    // status of file transfer operation
-	Subtasks          []transferSubtask // list of constituent file transfer subtasks
-	Client            auth.Client       // info about the DTS client used for this task
-	User              auth.User         // info about user requesting transfer
+	BasedOn            uuid.UUID         // ID of the transfer on which this one is based (if any)
+	CallbackURL        string            // URL POSTed to on completion (if any)
+	Canceled           bool              // set if a cancellation request has been made
+	Children           []uuid.UUID       // IDs of sub-transfers of a split task (if any)
+	CompletionTime     time.Time         // time at which the transfer completed
+	CreationTime       time.Time         // time at which the transfer was requested
+	DedupeByHash       bool              // set if files with identical content are transferred once
+	Description        string            // Markdown description of the task
+	IncludePrivateData bool              // set if the source includes private data to which the user is entitled
+	Destination        string            // name of destination database (in config)
+	Destinations       []string          // names of destination databases of a fanned-out task (if any)
+	DestinationFolder  string            // folder path to which files are transferred
+	EndpointOptions    TransferOptions   // options for source endpoint transfers (if any)
+	FileIds            []string          // IDs of all files being transferred
+	Id                 uuid.UUID         // task identifier
+	Instructions       json.RawMessage   // machine-readable task processing instructions
+	Labels             map[string]string // free-form labels for organizing and filtering transfers
+	ManifestMetadata   *ManifestMetadata // metadata overriding the configured manifest defaults (if any)
+	Manifest           uuid.NullUUID     // manifest generation UUID (if any)
+	ManifestContent    json.RawMessage   // JSON manifest generated for the transfer (if any)
+	NotifyByEmail      bool              // set if the user is emailed on completion
+	Notified           bool              // set once the user has been notified of completion
+	ManifestFile       string            // name of locally-created manifest file
+	Parent             uuid.NullUUID     // ID of the split task of a sub-transfer (if any)
+	PayloadSize        float64           // Size of payload (gigabytes)
+	PollTime           time.Time         // time at which manifest transfer status was last checked
+	RequestId          string            // ID of the service request that created the task (if any)
+	SkipExisting       bool              // set if files already at the destination are skipped
+	Priority           TransferPriority  // priority with which the task begins
+	Source             string            // name of source database (in config)
+	Status             TransferStatus    // status of file transfer operation
+	StatusTime         time.Time         // time at which the status code last changed
+	Subtasks           []transferSubtask // list of constituent file transfer subtasks
+	Client             auth.Client       // info about the DTS client used for this task
+	User               auth.User         // info about user requesting transfer
+}
+
+// returns attributes that correlate the task's log entries with the service
+// request that created it
+func (task transferTask) logAttrs() []any {
+	if task.RequestId == "" {
+		return nil
+	}
+	return []any{"request_id", task.RequestId}
+}
+
+// returns the name of the task's destination database, or a comma-separated
+// list of names for a task fanned out to several destinations
+func (task transferTask) destinationName() string {
+	if len(task.Destinations) > 0 {
+		return strings.Join(task.Destinations, ", ")
+	}
+	return task.Destination
+}
+
+// returns the total size of the files in the task's payload (in bytes)
+func (task transferTask) payloadBytes() int {
+	var size int
+	for _, subtask := range task.Subtasks {
+		for _, resource := range subtask.Resources {
+			size += resource.Bytes
+		}
+	}
+	return size
 }
 
 // computes the size of a payload for a transfer task (in Gigabytes)
@@ -67,19 +121,64 @@ func payloadSize(resources []DataResource) float64 {
 	return float64(size) / float64(1024*1024*1024)
 }
 
+// checks the given resources for embargoes that are in effect at the given
+// time, returning an EmbargoedResourceError for the first embargoed resource
+// if the service rejects such resources, or logging a warning for each one
+// otherwise
+func (task transferTask) checkEmbargoes(resources []DataResource, now time.Time) error {
+	today := now.Format(time.DateOnly)
+	for _, resource := range resources {
+		if resource.EmbargoUntil == "" || resource.EmbargoUntil <= today {
+			continue
+		}
+		if config.Service.EmbargoPolicy == "reject" {
+			return EmbargoedResourceError{
+				ResourceId: resource.Id,
+				Until:      resource.EmbargoUntil,
+			}
+		}
+		slog.Warn(fmt.Sprintf("Task %s: transferring resource %s under embargo until %s",
+			task.Id.String(), resource.Id, resource.EmbargoUntil), task.logAttrs()...)
+	}
+	return nil
+}
+
 // starts a task going, initiating staging if needed
 func (task *transferTask) start() error {
-	source, err := databases.NewDatabase(task.Client.Orcid, task.Source)
+	source, err := taskDatabase(task.Id, task.Client.Orcid, task.Source)
 	if err != nil {
 		return err
 	}
+	source = databases.WithPrivateData(source, task.IncludePrivateData)
 
 	// resolve resource data using file IDs
-	resources, err := source.Resources(task.FileIds)
+	resources, err := databases.CachedResources(task.Source, source, task.FileIds)
 	if err != nil {
 		return err
 	}
 
+	// make sure the resources are well-formed before we move any files
+	err = databases.ValidateResources(task.Source, resources)
+	if err != nil {
+		return err
+	}
+
+	// make sure we're not moving any files before their release dates
+	err = task.checkEmbargoes(resources, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// transfer files with identical content only once, if requested
+	if task.DedupeByHash {
+		numResources := len(resources)
+		resources = dedupeByHash(resources)
+		if len(resources) < numResources {
+			slog.Info(fmt.Sprintf("Task %s: %d file(s) with duplicate content recorded as aliases",
+				task.Id.String(), numResources-len(resources)), task.logAttrs()...)
+		}
+	}
+
 	// if the database stores its files in more than one location, check that each
 	// resource is associated with a valid endpoint
 	if len(config.Databases[task.Source].Endpoints) > 1 {
@@ -106,7 +205,7 @@ func (task *transferTask) start() error {
 
 	// make sure the size of the payload doesn't exceed our specified limit
 	task.PayloadSize = payloadSize(resources) // (in GB)
-	if task.PayloadSize > config.Service.MaxPayloadSize {
+	if task.PayloadSize > config.Service.MaxPayloadSize && !exemptFromLimits(task.Client.Orcid) {
 		return &PayloadTooLargeError{Size: task.PayloadSize}
 	}
 
@@ -115,7 +214,7 @@ func (task *transferTask) start() error {
 	destinationEndpoint := config.Databases[task.Destination].Endpoint
 
 	// construct a destination folder name
-	destination, err := databases.NewDatabase(task.Client.Orcid, task.Destination)
+	destination, err := taskDatabase(task.Id, task.Client.Orcid, task.Destination)
 	if err != nil {
 		return err
 	}
@@ -123,35 +222,29 @@ func (task *transferTask) start() error {
 	if err != nil {
 		return err
 	}
-	task.DestinationFolder = filepath.Join(username, "dts-"+task.Id.String())
-
-	// assemble distinct endpoints and create a subtask for each
-	distinctEndpoints := make(map[string]interface{})
-	for _, resource := range resources {
-		if _, found := distinctEndpoints[resource.Endpoint]; !found {
-			distinctEndpoints[resource.Endpoint] = struct{}{}
-		}
+	if task.Parent.Valid { // sub-transfers share their parent's folder
+		task.DestinationFolder = filepath.Join(username, "dts-"+task.Parent.UUID.String(),
+			"dts-"+task.Id.String())
+	} else {
+		task.DestinationFolder = filepath.Join(username, "dts-"+task.Id.String())
 	}
-	task.Subtasks = make([]transferSubtask, 0)
-	for sourceEndpoint := range distinctEndpoints {
-		// pick out the files corresponding to the source endpoint
-		// NOTE: this is slow, but preserves file ID ordering
-		resourcesForEndpoint := make([]DataResource, 0)
-		for _, resource := range resources {
-			if resource.Endpoint == sourceEndpoint {
-				resourcesForEndpoint = append(resourcesForEndpoint, resource)
-			}
-		}
 
-		// set up a subtask for the endpoint
+	// group the resources by endpoint and create a subtask for each group
+	sourceEndpoints, resourcesForEndpoint := resourcesByEndpoint(resources)
+	task.Subtasks = make([]transferSubtask, 0)
+	for _, sourceEndpoint := range sourceEndpoints {
 		task.Subtasks = append(task.Subtasks, transferSubtask{
+			TaskId:              task.Id,
 			Destination:         task.Destination,
 			DestinationEndpoint: destinationEndpoint,
 			DestinationFolder:   task.DestinationFolder,
-			Resources:           resourcesForEndpoint,
+			Resources:           resourcesForEndpoint[sourceEndpoint],
 			Source:              task.Source,
 			SourceEndpoint:      sourceEndpoint,
 			Client:              task.Client,
+			EndpointOptions:     task.EndpointOptions,
+			SkipExisting:        task.SkipExisting,
+			IncludePrivateData:  task.IncludePrivateData,
 		})
 	}
 
@@ -168,20 +261,78 @@ func (task *transferTask) start() error {
 	return err
 }
 
+// collapses resources with identical hashes and sizes into the first of them,
+// recording the IDs of the others as its aliases (resources without hashes
+// are left alone)
+func dedupeByHash(resources []DataResource) []DataResource {
+	type content struct {
+		Hash  string
+		Bytes int
+	}
+	indexForContent := make(map[content]int)
+	deduped := make([]DataResource, 0, len(resources))
+	for _, resource := range resources {
+		if resource.Hash != "" {
+			key := content{Hash: resource.Hash, Bytes: resource.Bytes}
+			if i, found := indexForContent[key]; found {
+				deduped[i].Aliases = append(deduped[i].Aliases, resource.Id)
+				continue
+			}
+			indexForContent[key] = len(deduped)
+		}
+		deduped = append(deduped, resource)
+	}
+	return deduped
+}
+
+// groups the given resources by the names of their endpoints, returning the
+// distinct endpoint names (in order of first appearance) and a map of each
+// name to its resources (in their original order)
+func resourcesByEndpoint(resources []DataResource) ([]string, map[string][]DataResource) {
+	endpointNames := make([]string, 0)
+	resourcesForEndpoint := make(map[string][]DataResource)
+	for _, resource := range resources {
+		if _, found := resourcesForEndpoint[resource.Endpoint]; !found {
+			endpointNames = append(endpointNames, resource.Endpoint)
+		}
+		resourcesForEndpoint[resource.Endpoint] = append(resourcesForEndpoint[resource.Endpoint], resource)
+	}
+	return endpointNames, resourcesForEndpoint
+}
+
+// the status message for a task canceled at the user's request
+const canceledMessage = "Task canceled at user request"
+
 // updates the state of a task, setting its status as necessary
 func (task *transferTask) Update() error {
 	var err error
 	if len(task.Subtasks) == 0 { // new task!
+		if task.Canceled { // canceled before it began, so don't begin
+			task.Status.Code = TransferStatusFailed
+			task.Status.Message = canceledMessage
+			task.CompletionTime = time.Now()
+			return nil
+		}
 		err = task.start()
 	} else if task.Canceled { // cancellation requested
+		subtasksFinished := true
 		for i := range task.Subtasks {
 			err = task.Subtasks[i].checkCancellation()
+			code := task.Subtasks[i].TransferStatus.Code
+			if code != TransferStatusSucceeded && code != TransferStatusFailed {
+				subtasksFinished = false
+			}
 		}
-		if task.Completed() {
+		if subtasksFinished { // the task ends once its subtasks have stopped
+			task.Status.Code = TransferStatusFailed
+			task.Status.Message = canceledMessage
 			task.CompletionTime = time.Now()
 		}
 	} else if task.Manifest.Valid { // we're generating/sending a manifest
-		err = task.checkManifest()
+		if pollDue(task.PollTime, config.Service.FinalizePollInterval) {
+			task.PollTime = time.Now()
+			err = task.checkManifest()
+		}
 	} else { // update subtasks
 		// track subtask failures
 		var subtaskFailed bool
@@ -196,6 +347,22 @@ func (task *transferTask) Update() error {
 				return err
 			}
 
+			// retry failed staging or transfers (if allowed) instead of failing
+			if task.Subtasks[i].failed() &&
+				task.Subtasks[i].Retries < config.Service.MaxTransferRetries {
+				phase := "transfer"
+				if task.Subtasks[i].StagingStatus == databases.StagingStatusFailed {
+					phase = "staging"
+				}
+				slog.Warn(fmt.Sprintf("Task %s: retrying failed %s (attempt %d of %d)",
+					task.Id.String(), phase, task.Subtasks[i].Retries+1,
+					config.Service.MaxTransferRetries), task.logAttrs()...)
+				err = task.Subtasks[i].retry()
+				if err != nil {
+					return err
+				}
+			}
+
 			if task.Subtasks[i].StagingStatus == databases.StagingStatusFailed {
 				subtaskFailed = true
 				failedSubtaskStatus.Code = TransferStatusUnknown
@@ -216,6 +383,9 @@ func (task *transferTask) Update() error {
 			// overwrite only the error code and message fields
 			task.Status.Code = failedSubtaskStatus.Code
 			task.Status.Message = failedSubtaskStatus.Message
+			if task.Completed() {
+				task.CompletionTime = time.Now()
+			}
 			task.Cancel()
 		} else {
 			// accumulate statistics
@@ -227,7 +397,7 @@ func (task *transferTask) Update() error {
 				task.Status.NumFiles += subtask.TransferStatus.NumFiles
 				if subtask.Staging.Valid {
 					subtaskStaging = true
-				} else if subtask.Transfer.Valid {
+				} else {
 					task.Status.NumFilesTransferred += subtask.TransferStatus.NumFilesTransferred
 					task.Status.NumFilesSkipped += subtask.TransferStatus.NumFilesSkipped
 				}
@@ -242,36 +412,20 @@ func (task *transferTask) Update() error {
 				return err
 			}
 
-			// generate a manifest for the transfer
+			// generate a manifest for the transfer and write it to disk
 			manifest := task.createManifest()
-
-			// write the manifest to disk and begin transferring it to the
-			// destination endpoint
-			var manifestBytes []byte
-			manifestBytes, err = json.Marshal(manifest)
+			task.ManifestContent, err = json.Marshal(manifest)
 			if err != nil {
 				return fmt.Errorf("marshalling manifest content: %s", err.Error())
 			}
-			task.ManifestFile = filepath.Join(config.Service.ManifestDirectory, fmt.Sprintf("manifest-%s.json", task.Id.String()))
-			manifestFile, err := os.Create(task.ManifestFile)
-			if err != nil {
-				return fmt.Errorf("creating manifest file: %s", err.Error())
-			}
-			_, err = manifestFile.Write(manifestBytes)
-			if err != nil {
-				return fmt.Errorf("writing manifest file content: %s", err.Error())
+			var fileXfers []FileTransfer
+			if config.Service.ManifestFormat == "bagit" {
+				fileXfers, err = task.writeBagManifest(manifest)
+			} else {
+				fileXfers, err = task.writeJsonManifest(manifest)
 			}
-			err = manifestFile.Close()
 			if err != nil {
-				return fmt.Errorf("closing manifest file: %s", err.Error())
-			}
-
-			// construct the source/destination file manifest paths
-			fileXfers := []FileTransfer{
-				{
-					SourcePath:      task.ManifestFile,
-					DestinationPath: filepath.Join(task.DestinationFolder, "manifest.json"),
-				},
+				return err
 			}
 
 			// begin transferring the manifest
@@ -283,7 +437,7 @@ func (task *transferTask) Update() error {
 			}
 			task.Manifest.UUID, err = localEndpoint.Transfer(destinationEndpoint, fileXfers)
 			if err != nil {
-				return fmt.Errorf("transferring manifest file: %s", err.Error())
+				return fmt.Errorf("transferring manifest file: %w", err)
 			}
 
 			task.Status.Code = TransferStatusFinalizing
@@ -323,6 +477,31 @@ func (task transferTask) Completed() bool {
 	}
 }
 
+// returns the specification from which the task was created
+func (task transferTask) Specification() Specification {
+	return Specification{
+		Description:        task.Description,
+		Destination:        task.Destination,
+		Destinations:       task.Destinations,
+		Instructions:       task.Instructions,
+		FileIds:            task.FileIds,
+		Source:             task.Source,
+		NotifyByEmail:      task.NotifyByEmail,
+		Client:             task.Client,
+		User:               task.User,
+		EndpointOptions:    task.EndpointOptions,
+		RequestId:          task.RequestId,
+		CallbackURL:        task.CallbackURL,
+		SkipExisting:       task.SkipExisting,
+		DedupeByHash:       task.DedupeByHash,
+		IncludePrivateData: task.IncludePrivateData,
+		BasedOn:            task.BasedOn,
+		Priority:           task.Priority,
+		Labels:             task.Labels,
+		ManifestMetadata:   task.ManifestMetadata,
+	}
+}
+
 // creates a DataPackage that serves as the transfer manifest
 func (task *transferTask) createManifest() DataPackage {
 	numResources := 0
@@ -332,7 +511,11 @@ func (task *transferTask) createManifest() DataPackage {
 	resources := make([]DataResource, numResources)
 	n := 0
 	for _, subtask := range task.Subtasks {
-		copy(resources[n:], subtask.Resources)
+		if config.Service.RecomputeHashes {
+			copy(resources[n:], subtask.resourcesWithRecomputedHashes())
+		} else {
+			copy(resources[n:], subtask.Resources)
+		}
 		n += len(subtask.Resources)
 	}
 
@@ -355,10 +538,221 @@ func (task *transferTask) createManifest() DataPackage {
 	}
 	copy(manifest.Resources, resources)
 	copy(manifest.Instructions, task.Instructions)
+	addManifestMetadata(&manifest, task.ManifestMetadata)
+
+	// record the endpoint from which each resource was transferred if requested
+	if config.Service.ManifestEndpointMetadata {
+		for i, resource := range manifest.Resources {
+			manifest.Resources[i].SourceEndpoint = endpointMetadata(resource.Endpoint)
+		}
+	}
+
+	// record collection metadata at the package level if requested
+	if config.Service.ManifestCollectionMetadata {
+		hoistCollectionMetadata(&manifest)
+	}
 
 	return manifest
 }
 
+// adds the metadata given with a transfer request (if any) to the given
+// manifest, falling back on the defaults in the DTS config file for anything
+// not given (keywords from both are included)
+func addManifestMetadata(manifest *DataPackage, requested *ManifestMetadata) {
+	if requested == nil {
+		requested = &ManifestMetadata{}
+	}
+
+	for _, keywords := range [][]string{config.Manifest.Keywords, requested.Keywords} {
+		for _, keyword := range keywords {
+			if !slices.Contains(manifest.Keywords, keyword) {
+				manifest.Keywords = append(manifest.Keywords, keyword)
+			}
+		}
+	}
+
+	if len(requested.Licenses) > 0 {
+		manifest.Licenses = slices.Clone(requested.Licenses)
+	} else {
+		for _, license := range config.Manifest.Licenses {
+			manifest.Licenses = append(manifest.Licenses, DataLicense{
+				Name:  license.Name,
+				Path:  license.Path,
+				Title: license.Title,
+			})
+		}
+	}
+
+	if requested.Publisher != nil {
+		publisher := *requested.Publisher
+		publisher.Role = "publisher"
+		manifest.Contributors = append(manifest.Contributors, publisher)
+	} else if config.Manifest.Publisher != nil {
+		manifest.Contributors = append(manifest.Contributors, Contributor{
+			Title:        config.Manifest.Publisher.Title,
+			Email:        config.Manifest.Publisher.Email,
+			Path:         config.Manifest.Publisher.Path,
+			Organization: config.Manifest.Publisher.Title,
+			Role:         "publisher",
+		})
+	}
+
+	if len(requested.Funding) > 0 {
+		manifest.Funding = slices.Clone(requested.Funding)
+	} else {
+		for _, funding := range config.Manifest.Funding {
+			manifest.Funding = append(manifest.Funding, credit.FundingReference{
+				GrantId:    funding.GrantId,
+				GrantTitle: funding.GrantTitle,
+				GrantUrl:   funding.GrantUrl,
+				Funder: credit.Organization{
+					OrganizationId:   funding.FunderId,
+					OrganizationName: funding.FunderName,
+				},
+			})
+		}
+	}
+}
+
+// if all resources in the given manifest share the same collection-level credit
+// metadata (e.g. that of an NMDC study), moves it from the resources into the
+// package descriptor so it appears only once
+func hoistCollectionMetadata(manifest *DataPackage) {
+	if len(manifest.Resources) == 0 {
+		return
+	}
+	collection := manifest.Resources[0].Credit
+	if len(collection.Titles) == 0 && len(collection.Descriptions) == 0 &&
+		len(collection.RelatedIdentifiers) == 0 {
+		return
+	}
+	for _, resource := range manifest.Resources[1:] {
+		if !reflect.DeepEqual(resource.Credit.Titles, collection.Titles) ||
+			!reflect.DeepEqual(resource.Credit.Descriptions, collection.Descriptions) ||
+			!reflect.DeepEqual(resource.Credit.RelatedIdentifiers, collection.RelatedIdentifiers) {
+			return
+		}
+	}
+
+	// the primary title names the package, and any alternative titles are
+	// recorded as keywords
+	for i, title := range collection.Titles {
+		if i == 0 {
+			manifest.Title = title.Title
+		} else {
+			manifest.Keywords = append(manifest.Keywords, title.Title)
+		}
+	}
+	if manifest.Description == "" && len(collection.Descriptions) > 0 {
+		manifest.Description = collection.Descriptions[0].DescriptionText
+	}
+	manifest.RelatedIdentifiers = collection.RelatedIdentifiers
+
+	for i := range manifest.Resources {
+		manifest.Resources[i].Credit.Titles = nil
+		manifest.Resources[i].Credit.Descriptions = nil
+		manifest.Resources[i].Credit.RelatedIdentifiers = nil
+	}
+}
+
+// returns manifest metadata for the endpoint with the given name
+func endpointMetadata(endpointName string) *DataEndpoint {
+	epConfig := config.Endpoints[endpointName]
+	metadata := DataEndpoint{
+		Name:     endpointName,
+		Title:    epConfig.Name,
+		Provider: epConfig.Provider,
+	}
+	if epConfig.Id != uuid.Nil {
+		metadata.Id = epConfig.Id.String()
+	}
+	return &metadata
+}
+
+// returns the name of the Frictionless manifest file written to a transfer's
+// destination folder, which is gzip-compressed if so configured
+func manifestFileName() string {
+	if config.Service.CompressManifest {
+		return "manifest.json.gz"
+	}
+	return "manifest.json"
+}
+
+// returns the path (relative to the destination endpoint's root) of the task's
+// manifest in its destination folder: its Frictionless manifest, or its bag's
+// payload manifest if BagIt manifests are written
+func (task transferTask) destinationManifest() string {
+	if config.Service.ManifestFormat == "bagit" {
+		return filepath.Join(task.DestinationFolder, bagManifestFileName)
+	}
+	return filepath.Join(task.DestinationFolder, manifestFileName())
+}
+
+// writes the given manifest to a Frictionless data package file, returning the
+// file transfer that sends it to the task's destination folder
+func (task *transferTask) writeJsonManifest(manifest DataPackage) ([]FileTransfer, error) {
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling manifest content: %s", err.Error())
+	}
+	workingDir, err := task.createWorkingDirectory()
+	if err != nil {
+		return nil, err
+	}
+	task.ManifestFile = filepath.Join(workingDir, manifestFileName())
+	manifestFile, err := os.Create(task.ManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("creating manifest file: %s", err.Error())
+	}
+	if config.Service.CompressManifest {
+		gzipWriter := gzip.NewWriter(manifestFile)
+		_, err = gzipWriter.Write(manifestBytes)
+		if err == nil {
+			err = gzipWriter.Close()
+		}
+	} else {
+		_, err = manifestFile.Write(manifestBytes)
+	}
+	if err != nil {
+		manifestFile.Close()
+		return nil, fmt.Errorf("writing manifest file content: %s", err.Error())
+	}
+	err = manifestFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("closing manifest file: %s", err.Error())
+	}
+
+	// construct the source/destination file manifest paths
+	return []FileTransfer{
+		{
+			SourcePath:      task.ManifestFile,
+			DestinationPath: filepath.Join(task.DestinationFolder, manifestFileName()),
+		},
+	}, nil
+}
+
+// writes the tag files for a BagIt bag describing the given manifest,
+// returning the file transfers that send them to the task's destination folder
+func (task *transferTask) writeBagManifest(manifest DataPackage) ([]FileTransfer, error) {
+	workingDir, err := task.createWorkingDirectory()
+	if err != nil {
+		return nil, err
+	}
+	task.ManifestFile = filepath.Join(workingDir, "bag")
+	tagFiles, err := writeBagTagFiles(task.ManifestFile, manifest)
+	if err != nil {
+		return nil, err
+	}
+	fileXfers := make([]FileTransfer, len(tagFiles))
+	for i, tagFile := range tagFiles {
+		fileXfers[i] = FileTransfer{
+			SourcePath:      filepath.Join(task.ManifestFile, tagFile),
+			DestinationPath: filepath.Join(task.DestinationFolder, tagFile),
+		}
+	}
+	return fileXfers, nil
+}
+
 // checks whether the file manifest for a task has been generated and, if so,
 // marks the task as completed
 func (task *transferTask) checkManifest() error {
@@ -373,8 +767,11 @@ func (task *transferTask) checkManifest() error {
 	}
 	if xferStatus.Code == TransferStatusSucceeded ||
 		xferStatus.Code == TransferStatusFailed { // manifest transferred
+		if xferStatus.Code == TransferStatusSucceeded {
+			task.finalize()
+		}
 		task.Manifest = uuid.NullUUID{}
-		os.Remove(task.ManifestFile)
+		task.removeWorkingDirectory()
 		task.ManifestFile = ""
 		task.Status.Code = xferStatus.Code
 		task.Status.Message = ""
@@ -382,3 +779,44 @@ func (task *transferTask) checkManifest() error {
 	}
 	return nil
 }
+
+// notifies the task's destination database (if it needs to know) that the
+// task's files and manifest have arrived; failures are logged but don't affect
+// the status of the task, whose files have already been transferred
+func (task *transferTask) finalize() {
+	destination, err := taskDatabase(task.Id, task.Client.Orcid, task.Destination)
+	if err == nil {
+		if finalizable, ok := destination.(databases.FinalizableDatabase); ok {
+			err = finalizable.Finalize(task.DestinationFolder, task.destinationManifest())
+		}
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Task %s: finalizing transfer to %s: %s",
+			task.Id.String(), task.Destination, err.Error()), task.logAttrs()...)
+	}
+}
+
+// returns the directory in which files generated for the task (e.g. its
+// manifest) are written, which keeps them apart from those of other tasks
+func (task transferTask) workingDirectory() string {
+	return filepath.Join(config.Service.ManifestDirectory, task.Id.String())
+}
+
+// creates the task's working directory if it doesn't exist, returning its path
+func (task transferTask) createWorkingDirectory() (string, error) {
+	workingDir := task.workingDirectory()
+	err := os.MkdirAll(workingDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("creating working directory: %s", err.Error())
+	}
+	return workingDir, nil
+}
+
+// removes the task's working directory and everything in it
+func (task transferTask) removeWorkingDirectory() {
+	err := os.RemoveAll(task.workingDirectory())
+	if err != nil {
+		slog.Error(fmt.Sprintf("Task %s: removing working directory: %s",
+			task.Id.String(), err.Error()))
+	}
+}
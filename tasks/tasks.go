@@ -23,6 +23,7 @@ package tasks
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"errors"
@@ -31,18 +32,26 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/ena"
+	globusdb "github.com/kbase/dts/databases/globus"
 	"github.com/kbase/dts/databases/jdp"
 	"github.com/kbase/dts/databases/kbase"
+	localdb "github.com/kbase/dts/databases/local"
 	"github.com/kbase/dts/databases/nmdc"
+	"github.com/kbase/dts/databases/osf"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/endpoints/http"
 	"github.com/kbase/dts/endpoints/local"
 	"github.com/kbase/dts/frictionless"
 )
@@ -50,10 +59,13 @@ import (
 // useful type aliases
 type Contributor = frictionless.Contributor
 type Database = databases.Database
+type DataEndpoint = frictionless.DataEndpoint
+type DataLicense = frictionless.DataLicense
 type DataPackage = frictionless.DataPackage
 type DataResource = frictionless.DataResource
 type Endpoint = endpoints.Endpoint
 type FileTransfer = endpoints.FileTransfer
+type TransferOptions = endpoints.TransferOptions
 type TransferStatus = endpoints.TransferStatus
 
 // useful constants
@@ -79,6 +91,10 @@ func Start() error {
 	if firstCall {
 		endpoints.RegisterEndpointProvider("globus", globus.NewEndpoint)
 		endpoints.RegisterEndpointProvider("local", local.NewEndpoint)
+		endpoints.RegisterEndpointProvider("http", http.NewEndpoint)
+		if _, found := config.Databases["ena"]; found {
+			databases.RegisterDatabase("ena", ena.NewDatabase)
+		}
 		if _, found := config.Databases["jdp"]; found {
 			databases.RegisterDatabase("jdp", jdp.NewDatabase)
 		}
@@ -88,6 +104,17 @@ func Start() error {
 		if _, found := config.Databases["nmdc"]; found {
 			databases.RegisterDatabase("nmdc", nmdc.NewDatabase)
 		}
+		if _, found := config.Databases["osf"]; found {
+			databases.RegisterDatabase("osf", osf.NewDatabase)
+		}
+		for dbName, dbConfig := range config.Databases {
+			switch dbConfig.Provider {
+			case "globus":
+				databases.RegisterDatabase(dbName, globusdb.DatabaseConstructor(dbName))
+			case "local":
+				databases.RegisterDatabase(dbName, localdb.DatabaseConstructor(dbName))
+			}
+		}
 		firstCall = false
 	}
 
@@ -107,29 +134,51 @@ func Start() error {
 		return err
 	}
 
+	// warn about (but tolerate) any other endpoints we can't reach, since they
+	// may recover
+	for _, err := range refreshHealth().Endpoints {
+		if err != nil {
+			slog.Warn(err.Error())
+		}
+	}
+
 	// allocate channels
 	taskChannels = channelsType{
-		CreateTask:       make(chan transferTask, 32),
-		CancelTask:       make(chan uuid.UUID, 32),
-		GetTaskStatus:    make(chan uuid.UUID, 32),
-		ReturnTaskId:     make(chan uuid.UUID, 32),
-		ReturnTaskStatus: make(chan TransferStatus, 32),
-		Error:            make(chan error, 32),
-		Poll:             make(chan struct{}),
-		Stop:             make(chan struct{}),
+		CreateTask:         make(chan transferTask, 32),
+		CancelTask:         make(chan uuid.UUID, 32),
+		PurgeTask:          make(chan uuid.UUID, 32),
+		ReturnPurgeResult:  make(chan error, 32),
+		GetTaskStatus:      make(chan uuid.UUID, 32),
+		GetTaskSpec:        make(chan uuid.UUID, 32),
+		ReturnTaskId:       make(chan uuid.UUID, 32),
+		ReturnTaskStatus:   make(chan TransferStatus, 32),
+		ReturnTaskSpec:     make(chan Specification, 32),
+		GetTaskManifest:    make(chan uuid.UUID, 32),
+		ReturnTaskManifest: make(chan json.RawMessage, 32),
+		GetTaskChildren:    make(chan uuid.UUID, 32),
+		ReturnTaskChildren: make(chan []uuid.UUID, 32),
+		GetTaskFiles:       make(chan uuid.UUID, 32),
+		ReturnTaskFiles:    make(chan []FileStatus, 32),
+		GetTaskList:        make(chan transferQuery, 32),
+		ReturnTaskList:     make(chan []uuid.UUID, 32),
+		Drain:              make(chan context.Context),
+		Error:              make(chan error, 32),
+		Poll:               make(chan struct{}),
+		Stop:               make(chan struct{}),
 	}
 
 	// start processing tasks
 	go processTasks()
 
 	// start the polling heartbeat
-	slog.Info(fmt.Sprintf("Task statuses are updated every %d ms",
-		config.Service.PollInterval))
-	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
-	go heartbeat(pollInterval, taskChannels.Poll)
+	slog.Info(fmt.Sprintf("Task statuses are updated every %d ms (staging: %d ms, transfer: %d ms, finalization: %d ms)",
+		config.Service.PollInterval, config.Service.StagingPollInterval,
+		config.Service.TransferPollInterval, config.Service.FinalizePollInterval))
+	go heartbeat(heartbeatInterval(), taskChannels.Poll)
 
 	// okay, we're running now
 	running = true
+	draining = false
 
 	return nil
 }
@@ -148,6 +197,20 @@ func Stop() error {
 	return err
 }
 
+// Prepares to stop processing tasks by refusing new ones and waiting for file
+// transfers performed within the service to finish (or for the given context
+// to be done), recording the progress of all tasks as their transfers finish.
+// Transfers handled by external services (e.g. Globus) are unaffected. Call
+// Stop() afterward to save the tasks so they can be resumed on restart.
+func Drain(ctx context.Context) error {
+	if !running {
+		return NotRunningError{}
+	}
+	draining = true
+	taskChannels.Drain <- ctx
+	return <-taskChannels.Error
+}
+
 // Returns true if tasks are currently being processed, false if not.
 func Running() bool {
 	return running
@@ -160,6 +223,9 @@ type Specification struct {
 	// the name of destination database to which files are transferred (as
 	// specified in the DTS config file)
 	Destination string
+	// the names of several destination databases, to each of which the files
+	// are transferred by a separate sub-transfer (used in place of Destination)
+	Destinations []string
 	// machine-readable instructions for processing the payload at its destination
 	Instructions json.RawMessage
 	// an array of identifiers for files to be transferred from Source to
@@ -168,10 +234,59 @@ type Specification struct {
 	// the name of source database from which files are transferred (as specified
 	// in the DTS config file)
 	Source string
+	// set if the user should be notified by email when the task completes
+	// (requires an SMTP server in the DTS config file)
+	NotifyByEmail bool
 	// information about the client accessing the DTS
 	Client auth.Client
 	// information about the user requesting the task
 	User auth.User
+	// provider-specific options passed to the source endpoint(s) for this
+	// transfer, overriding their defaults (optional)
+	EndpointOptions TransferOptions
+	// the ID of the service request that created the task (optional), which
+	// is recorded in the task's log entries for correlation
+	RequestId string
+	// a URL to which a signed summary of the task is POSTed when it completes
+	// (optional, requires a callback secret in the DTS config file)
+	CallbackURL string
+	// set if files already present at the destination (with matching sizes and
+	// checksums) should be skipped instead of transferred again
+	SkipExisting bool
+	// set if files with identical hashes and sizes should be transferred only
+	// once, with the IDs of the duplicates recorded in the manifest as aliases
+	DedupeByHash bool
+	// set if the source database should include private data to which the
+	// user is entitled (where it has any) when describing and staging files
+	IncludePrivateData bool
+	// if set, the ID of a completed transfer from the same source on which
+	// this transfer is based: files it moved with unchanged hashes and sizes
+	// are left out
+	BasedOn uuid.UUID
+	// the priority with which the task begins relative to other waiting tasks
+	// (normal by default)
+	Priority TransferPriority
+	// free-form labels (e.g. project or run names) attached to the task for
+	// organizing and filtering transfers (optional)
+	Labels map[string]string
+	// metadata included in the task's manifest, overriding the defaults in the
+	// DTS config file (optional)
+	ManifestMetadata *ManifestMetadata
+}
+
+// Metadata given with a transfer request for inclusion in its manifest. Each
+// of these fields takes precedence over the corresponding default in the DTS
+// config file, except for keywords, which are added to the defaults.
+type ManifestMetadata struct {
+	// keywords added to the manifest
+	Keywords []string
+	// licenses under which the transferred data are managed
+	Licenses []DataLicense
+	// the organization publishing the transferred data (its role is set to
+	// "publisher")
+	Publisher *Contributor
+	// acknowledgments of funding for the transferred data
+	Funding []credit.FundingReference
 }
 
 // Creates a new transfer task associated with the user with the specified Orcid
@@ -181,31 +296,131 @@ type Specification struct {
 func Create(spec Specification) (uuid.UUID, error) {
 	var taskId uuid.UUID
 
+	// are we shutting down?
+	if draining {
+		return taskId, DrainingError{}
+	}
+
 	// have we requested files to be transferred?
 	if len(spec.FileIds) == 0 {
 		return taskId, NoFilesRequestedError{}
 	}
 
+	// are the given labels (if any) within our limits?
+	if err := validateLabels(spec.Labels); err != nil {
+		return taskId, err
+	}
+
+	// can we contact the given callback URL (if any)?
+	if spec.CallbackURL != "" {
+		err := validateCallbackURL(spec.CallbackURL)
+		if err != nil {
+			return taskId, err
+		}
+	}
+
+	// were we given a sensible set of destinations?
+	destinations, err := destinationNames(spec)
+	if err != nil {
+		return taskId, err
+	}
+	if len(destinations) == 1 { // a single destination needs no sub-transfers
+		spec.Destination, spec.Destinations = destinations[0], nil
+	}
+
+	// are these pairs of databases permitted?
+	for _, destination := range destinations {
+		if !transferAllowed(spec.Source, destination) {
+			return taskId, TransferNotAllowedError{
+				Source:      spec.Source,
+				Destination: destination,
+			}
+		}
+	}
+
 	// verify that we can fetch the task's source and destination databases
 	// without incident
-	_, err := databases.NewDatabase(spec.Client.Orcid, spec.Source)
+	source, err := databases.NewDatabase(spec.Client.Orcid, spec.Source)
 	if err != nil {
 		return taskId, err
 	}
-	_, err = databases.NewDatabase(spec.Client.Orcid, spec.Destination)
+	source = databases.WithPrivateData(source, spec.IncludePrivateData)
+	for _, destination := range destinations {
+		_, err = databases.NewDatabase(spec.Client.Orcid, destination)
+		if err != nil {
+			return taskId, err
+		}
+	}
+
+	// make sure the source's endpoints accept any given transfer options
+	if len(spec.EndpointOptions) > 0 {
+		for _, endpointName := range databaseEndpoints(spec.Source) {
+			err = endpoints.ValidateTransferOptions(endpointName, spec.EndpointOptions)
+			if err != nil {
+				return taskId, err
+			}
+		}
+	}
+
+	// if required, make sure all data is encrypted in transit
+	if config.Service.RequireEncryption {
+		for _, dbName := range append([]string{spec.Source}, destinations...) {
+			err = checkEncryption(dbName)
+			if err != nil {
+				return taskId, err
+			}
+		}
+	}
+
+	// leave out files that haven't changed since the base transfer (if any)
+	if spec.BasedOn != uuid.Nil {
+		spec.FileIds, err = changedFiles(source, spec)
+		if err != nil {
+			return taskId, err
+		}
+	}
+
+	// make sure the requested files don't exceed our limits
+	if !exemptFromLimits(spec.Client.Orcid) {
+		err = checkTransferLimits(source, spec)
+		if err != nil {
+			return taskId, err
+		}
+	}
+
+	// make sure there's room for the requested files wherever they're written
+	err = checkFreeSpace(source, spec, destinations)
+	if err != nil {
+		return taskId, err
+	}
+
+	// make sure the source database recognizes all of the requested files
+	err = checkFilesExist(source, spec)
 	if err != nil {
 		return taskId, err
 	}
 
 	// create a new task and send it along for processing
 	taskChannels.CreateTask <- transferTask{
-		Client:       spec.Client,
-		User:         spec.User,
-		Source:       spec.Source,
-		Destination:  spec.Destination,
-		FileIds:      spec.FileIds,
-		Description:  spec.Description,
-		Instructions: spec.Instructions,
+		Client:             spec.Client,
+		User:               spec.User,
+		Source:             spec.Source,
+		Destination:        spec.Destination,
+		Destinations:       spec.Destinations,
+		FileIds:            spec.FileIds,
+		Description:        spec.Description,
+		Instructions:       spec.Instructions,
+		NotifyByEmail:      spec.NotifyByEmail,
+		EndpointOptions:    spec.EndpointOptions,
+		RequestId:          spec.RequestId,
+		CallbackURL:        spec.CallbackURL,
+		SkipExisting:       spec.SkipExisting,
+		DedupeByHash:       spec.DedupeByHash,
+		IncludePrivateData: spec.IncludePrivateData,
+		BasedOn:            spec.BasedOn,
+		Priority:           spec.Priority,
+		Labels:             spec.Labels,
+		ManifestMetadata:   spec.ManifestMetadata,
 	}
 	select {
 	case taskId = <-taskChannels.ReturnTaskId:
@@ -214,6 +429,205 @@ func Create(spec Specification) (uuid.UUID, error) {
 	return taskId, err
 }
 
+// returns the names of the destination databases for the given specification,
+// or an InvalidDestinationsError if they're specified inconsistently
+func destinationNames(spec Specification) ([]string, error) {
+	if len(spec.Destinations) == 0 {
+		return []string{spec.Destination}, nil
+	}
+	if spec.Destination != "" {
+		return nil, InvalidDestinationsError{
+			Message: "a single destination and a list of destinations can't both be given",
+		}
+	}
+	for i, destination := range spec.Destinations {
+		if slices.Contains(spec.Destinations[:i], destination) {
+			return nil, InvalidDestinationsError{
+				Message: fmt.Sprintf("%s is given more than once", destination),
+			}
+		}
+	}
+	return spec.Destinations, nil
+}
+
+// returns true if transfers requested by the client with the given ORCID are
+// exempt from the service's limits on the number of files and payload size
+func exemptFromLimits(orcid string) bool {
+	return config.Service.ExemptAdminsFromLimits && slices.Contains(config.Service.Admins, orcid)
+}
+
+// checks the files requested by the given specification against the service's
+// limits on the number of files and payload size of a transfer, resolving
+// their descriptors in the given source database
+func checkTransferLimits(source databases.Database, spec Specification) error {
+	maxFiles := config.Service.MaxFilesPerRequest
+	if maxFiles > 0 && len(spec.FileIds) > maxFiles {
+		return &TooManyFilesError{Count: len(spec.FileIds)}
+	}
+	_, bytes, err := databases.EstimateTransfer(spec.Source, source, spec.FileIds)
+	if err != nil {
+		return err
+	}
+	size := float64(bytes) / float64(1024*1024*1024) // (in GB)
+	if size > config.Service.MaxPayloadSize {
+		return &PayloadTooLargeError{Size: size}
+	}
+	return nil
+}
+
+// the number of file IDs whose descriptors are resolved at once when checking
+// that a transfer's requested files exist
+const fileCheckBatchSize = 1000
+
+// checks that the source database recognizes all of the files requested by
+// the given specification, resolving their descriptors in batches and
+// returning a FilesNotFoundError listing any that it doesn't recognize
+func checkFilesExist(source databases.Database, spec Specification) error {
+	var notFound []string
+	for start := 0; start < len(spec.FileIds); start += fileCheckBatchSize {
+		batch := slices.Clone(spec.FileIds[start:min(start+fileCheckBatchSize, len(spec.FileIds))])
+		for len(batch) > 0 {
+			resources, err := databases.CachedResources(spec.Source, source, batch)
+			if err != nil {
+				// some databases report only the first file they can't find,
+				// so we note it and check the rest of the batch
+				var resourceErr databases.ResourceNotFoundError
+				if errors.As(err, &resourceErr) && slices.Contains(batch, resourceErr.ResourceId) {
+					notFound = append(notFound, resourceErr.ResourceId)
+					batch = slices.DeleteFunc(batch, func(id string) bool {
+						return id == resourceErr.ResourceId
+					})
+					continue
+				}
+				return err
+			}
+			found := make(map[string]bool)
+			for _, resource := range resources {
+				found[resource.Id] = true
+			}
+			for _, fileId := range batch {
+				if !found[fileId] {
+					notFound = append(notFound, fileId)
+				}
+			}
+			break
+		}
+	}
+	if len(notFound) > 0 {
+		return FilesNotFoundError{Database: spec.Source, FileIds: notFound}
+	}
+	return nil
+}
+
+// returns the names of the endpoints configured for the database with the
+// given name
+func databaseEndpoints(dbName string) []string {
+	return config.Databases[dbName].EndpointNames()
+}
+
+// returns the database with the given name, accessed by the client with the
+// given ORCID on behalf of the task with the given ID, which it sends as a
+// correlation ID with its requests (if it can)
+func taskDatabase(taskId uuid.UUID, orcid, dbName string) (databases.Database, error) {
+	db, err := databases.NewDatabase(orcid, dbName)
+	if err != nil {
+		return nil, err
+	}
+	return databases.Correlated(db, taskId.String()), nil
+}
+
+// This type reports the health of the endpoints and databases in the DTS
+// configuration, mapping their names to errors describing any problems (nil
+// for healthy endpoints and databases).
+type Health struct {
+	Endpoints map[string]error
+	Databases map[string]error
+}
+
+// the interval for which a health check's results are reused, so that frequent
+// health queries don't contact (and authenticate with) every endpoint
+var healthCacheTTL = 30 * time.Second
+
+var cachedHealth Health
+var cachedHealthTime time.Time
+var cachedHealthMutex sync.Mutex
+
+// checks the health of all configured endpoints and databases. A database is
+// healthy if all of its endpoints are healthy. Results are reused for
+// healthCacheTTL after each check.
+func CheckHealth() Health {
+	cachedHealthMutex.Lock()
+	defer cachedHealthMutex.Unlock()
+	if cachedHealthTime.IsZero() || time.Since(cachedHealthTime) >= healthCacheTTL {
+		cachedHealth = checkHealth()
+		cachedHealthTime = time.Now()
+	}
+	return cachedHealth
+}
+
+// checks the health of all configured endpoints and databases, replacing any
+// cached results
+func refreshHealth() Health {
+	cachedHealthMutex.Lock()
+	defer cachedHealthMutex.Unlock()
+	cachedHealth = checkHealth()
+	cachedHealthTime = time.Now()
+	return cachedHealth
+}
+
+func checkHealth() Health {
+	health := Health{
+		Endpoints: make(map[string]error),
+		Databases: make(map[string]error),
+	}
+	for endpointName := range config.Endpoints {
+		health.Endpoints[endpointName] = endpoints.CheckHealth(endpointName)
+	}
+	for dbName := range config.Databases {
+		endpointNames := databaseEndpoints(dbName)
+		slices.Sort(endpointNames)
+		health.Databases[dbName] = nil
+		for _, endpointName := range endpointNames {
+			err, checked := health.Endpoints[endpointName]
+			if !checked {
+				err = endpoints.CheckHealth(endpointName)
+			}
+			if err != nil {
+				health.Databases[dbName] = err
+				break
+			}
+		}
+	}
+	return health
+}
+
+// returns true if transfers from the given source database to the given
+// destination database are permitted (all transfers are permitted if no
+// allowed transfers are configured)
+func transferAllowed(source, destination string) bool {
+	if config.AllowedTransfers == nil {
+		return true
+	}
+	for _, allowed := range config.AllowedTransfers {
+		if allowed.Permits(source, destination) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns an error if any endpoint for the database with the given name moves
+// data over unencrypted connections
+func checkEncryption(dbName string) error {
+	for _, endpointName := range databaseEndpoints(dbName) {
+		err := endpoints.CheckEncryption(endpointName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Given a task UUID, returns its transfer status (or a non-nil error
 // indicating any issues encountered).
 func Status(taskId uuid.UUID) (TransferStatus, error) {
@@ -227,6 +641,74 @@ func Status(taskId uuid.UUID) (TransferStatus, error) {
 	return status, err
 }
 
+// Given a task UUID, returns the specification with which it was created (or
+// a non-nil error indicating any issues encountered). The specification is
+// persisted along with the task, so it's available for as long as the task's
+// record is kept.
+func SpecificationForTask(taskId uuid.UUID) (Specification, error) {
+	var spec Specification
+	var err error
+	taskChannels.GetTaskSpec <- taskId
+	select {
+	case spec = <-taskChannels.ReturnTaskSpec:
+	case err = <-taskChannels.Error:
+	}
+	return spec, err
+}
+
+// Given a task UUID, returns the JSON manifest generated for the completed
+// transfer (or a non-nil error indicating any issues encountered). The manifest
+// is kept for as long as the task's record is kept.
+func Manifest(taskId uuid.UUID) (json.RawMessage, error) {
+	var manifest json.RawMessage
+	var err error
+	taskChannels.GetTaskManifest <- taskId
+	select {
+	case manifest = <-taskChannels.ReturnTaskManifest:
+	case err = <-taskChannels.Error:
+	}
+	return manifest, err
+}
+
+// Given a task UUID, returns the UUIDs of the sub-transfers into which the task
+// was split (or a non-nil error indicating any issues encountered). A task that
+// wasn't split has no sub-transfers.
+func SubTransfers(taskId uuid.UUID) ([]uuid.UUID, error) {
+	var children []uuid.UUID
+	var err error
+	taskChannels.GetTaskChildren <- taskId
+	select {
+	case children = <-taskChannels.ReturnTaskChildren:
+	case err = <-taskChannels.Error:
+	}
+	return children, err
+}
+
+// Given a task UUID, returns the statuses of the individual files in the
+// transfer (or a non-nil error indicating any issues encountered).
+func FileStatuses(taskId uuid.UUID) ([]FileStatus, error) {
+	var statuses []FileStatus
+	var err error
+	taskChannels.GetTaskFiles <- taskId
+	select {
+	case statuses = <-taskChannels.ReturnTaskFiles:
+	case err = <-taskChannels.Error:
+	}
+	return statuses, err
+}
+
+// Returns the UUIDs of the transfers requested by the user or client with the
+// given ORCID that have all of the given labels (any transfers if no labels
+// are given), in the order in which they were requested. Sub-transfers of
+// split transfers are not included.
+func List(orcid string, labels map[string]string) ([]uuid.UUID, error) {
+	if !running {
+		return nil, NotRunningError{}
+	}
+	taskChannels.GetTaskList <- transferQuery{Orcid: orcid, Labels: labels}
+	return <-taskChannels.ReturnTaskList, nil
+}
+
 // Requests that the task with the given UUID be canceled. Clients should check
 // the status of the task separately.
 func Cancel(taskId uuid.UUID) error {
@@ -239,6 +721,15 @@ func Cancel(taskId uuid.UUID) error {
 	return err
 }
 
+// Immediately removes the record of the task with the given UUID (and those
+// of its sub-transfers) along with its manifest, regardless of how long ago it
+// completed. A task still in progress is canceled first, discarding any
+// staging requests or transfers it has made.
+func Purge(taskId uuid.UUID) error {
+	taskChannels.PurgeTask <- taskId
+	return <-taskChannels.ReturnPurgeResult
+}
+
 //-----------
 // Internals
 //-----------
@@ -246,6 +737,7 @@ func Cancel(taskId uuid.UUID) error {
 // global variables for managing tasks
 var firstCall = true            // indicates first call to Start()
 var running bool                // true if tasks are processing, false if not
+var draining bool               // true if new tasks are refused pending shutdown
 var taskChannels channelsType   // channels used for processing tasks
 var stopHeartbeat chan struct{} // send a pulse to this channel to halt polling
 
@@ -275,6 +767,83 @@ func createOrLoadTasks(dataFile string) map[uuid.UUID]transferTask {
 	return tasks
 }
 
+// returns true if the given error indicates a transient condition after which
+// a task update can be retried, false otherwise
+func isRetryable(err error) bool {
+	var timeout databases.TimeoutError
+	var timeoutPtr *databases.TimeoutError
+	var transient endpoints.TransientError
+	return errors.As(err, &timeout) || errors.As(err, &timeoutPtr) ||
+		errors.As(err, &transient)
+}
+
+// marks as failed any incomplete tasks restored from a previous session that
+// are older than the configured maximum age, so they aren't resumed
+func failStaleTasks(tasks map[uuid.UUID]transferTask) {
+	if config.Service.ResumeMaxAge == 0 {
+		return
+	}
+	maxAge := time.Duration(config.Service.ResumeMaxAge) * time.Second
+	for taskId, task := range tasks {
+		if task.Completed() {
+			continue
+		}
+		// tasks saved before creation times were recorded use their status times
+		created := task.CreationTime
+		if created.IsZero() {
+			created = task.StatusTime
+		}
+		if time.Since(created) > maxAge {
+			slog.Info(fmt.Sprintf("Task %s: not resuming stale transfer (requested %s)",
+				taskId.String(), created.Format(time.RFC3339)))
+			task.Status.Code = TransferStatusFailed
+			task.Status.Message = "stale on restart: transfer exceeded the maximum age for resumption"
+			task.CompletionTime = time.Now()
+			task.StatusTime = task.CompletionTime
+
+			// clean up any staging requests or transfers in progress
+			if err := task.Cancel(); err != nil {
+				slog.Error(fmt.Sprintf("Task %s: %s", taskId.String(), err.Error()))
+			}
+			tasks[taskId] = task
+		}
+	}
+}
+
+// checks the staging requests of incomplete tasks restored from a previous
+// session, so that staging that finished while the service was down advances
+// to transfer immediately, and staging requests that the source database no
+// longer knows about are made again
+func resumeStaging(tasks map[uuid.UUID]transferTask) {
+	for taskId, task := range tasks {
+		if task.Completed() || task.Canceled {
+			continue
+		}
+		for i := range task.Subtasks {
+			subtask := &task.Subtasks[i]
+			if !subtask.Staging.Valid {
+				continue
+			}
+			subtask.PollTime = time.Now()
+			err := subtask.checkStaging()
+			if err == nil && subtask.StagingStatus == databases.StagingStatusUnknown {
+				slog.Warn(fmt.Sprintf("Task %s: staging request %s was lost; staging again",
+					taskId.String(), subtask.Staging.UUID.String()), task.logAttrs()...)
+				subtask.Staging = uuid.NullUUID{}
+				err = subtask.start()
+			}
+			if err != nil {
+				slog.Error(fmt.Sprintf("Task %s: resuming staging: %s", taskId.String(), err.Error()),
+					task.logAttrs()...)
+			} else if subtask.Transfer.Valid {
+				slog.Info(fmt.Sprintf("Task %s: staging finished while the service was down",
+					taskId.String()), task.logAttrs()...)
+			}
+		}
+		tasks[taskId] = task
+	}
+}
+
 // saves a map of task IDs to tasks to the given file
 func saveTasks(tasks map[uuid.UUID]transferTask, dataFile string) error {
 	if len(tasks) > 0 {
@@ -313,14 +882,27 @@ func saveTasks(tasks map[uuid.UUID]transferTask, dataFile string) error {
 // this type holds various channels used by the task manager to communicate
 // with its worker goroutine
 type channelsType struct {
-	CreateTask       chan transferTask   // used by client to request task creation
-	CancelTask       chan uuid.UUID      // used by client to request task cancellation
-	GetTaskStatus    chan uuid.UUID      // used by client to request task status
-	ReturnTaskId     chan uuid.UUID      // returns task ID to client
-	ReturnTaskStatus chan TransferStatus // returns task status to client
-	Error            chan error          // returns error to client
-	Poll             chan struct{}       // carries heartbeat signal for task updates
-	Stop             chan struct{}       // used by client to stop task management
+	CreateTask         chan transferTask    // used by client to request task creation
+	CancelTask         chan uuid.UUID       // used by client to request task cancellation
+	PurgeTask          chan uuid.UUID       // used by client to request that a task's record be purged
+	ReturnPurgeResult  chan error           // returns the result of a purge (nil on success) to client
+	GetTaskStatus      chan uuid.UUID       // used by client to request task status
+	GetTaskSpec        chan uuid.UUID       // used by client to request task specification
+	ReturnTaskId       chan uuid.UUID       // returns task ID to client
+	ReturnTaskStatus   chan TransferStatus  // returns task status to client
+	ReturnTaskSpec     chan Specification   // returns task specification to client
+	GetTaskManifest    chan uuid.UUID       // used by client to request task manifest
+	ReturnTaskManifest chan json.RawMessage // returns task manifest to client
+	GetTaskChildren    chan uuid.UUID       // used by client to request task's sub-transfers
+	ReturnTaskChildren chan []uuid.UUID     // returns task's sub-transfers to client
+	GetTaskFiles       chan uuid.UUID       // used by client to request task's file statuses
+	ReturnTaskFiles    chan []FileStatus    // returns task's file statuses to client
+	GetTaskList        chan transferQuery   // used by client to request a listing of tasks
+	ReturnTaskList     chan []uuid.UUID     // returns a listing of tasks to client
+	Drain              chan context.Context // used by client to request that transfers be drained
+	Error              chan error           // returns error to client
+	Poll               chan struct{}        // carries heartbeat signal for task updates
+	Stop               chan struct{}        // used by client to stop task management
 }
 
 // this function runs in its own goroutine, using the given local endpoint
@@ -331,12 +913,28 @@ func processTasks() {
 	dataStore := filepath.Join(config.Service.DataDirectory, "dts.gob")
 	tasks := createOrLoadTasks(dataStore)
 
+	failStaleTasks(tasks)
+	resumeStaging(tasks)
+
 	// parse the task channels into directional types as needed
 	var createTaskChan <-chan transferTask = taskChannels.CreateTask
 	var cancelTaskChan <-chan uuid.UUID = taskChannels.CancelTask
+	var purgeTaskChan <-chan uuid.UUID = taskChannels.PurgeTask
+	var returnPurgeResultChan chan<- error = taskChannels.ReturnPurgeResult
 	var getTaskStatusChan <-chan uuid.UUID = taskChannels.GetTaskStatus
 	var returnTaskIdChan chan<- uuid.UUID = taskChannels.ReturnTaskId
 	var returnTaskStatusChan chan<- TransferStatus = taskChannels.ReturnTaskStatus
+	var getTaskSpecChan <-chan uuid.UUID = taskChannels.GetTaskSpec
+	var returnTaskSpecChan chan<- Specification = taskChannels.ReturnTaskSpec
+	var getTaskManifestChan <-chan uuid.UUID = taskChannels.GetTaskManifest
+	var returnTaskManifestChan chan<- json.RawMessage = taskChannels.ReturnTaskManifest
+	var getTaskChildrenChan <-chan uuid.UUID = taskChannels.GetTaskChildren
+	var returnTaskChildrenChan chan<- []uuid.UUID = taskChannels.ReturnTaskChildren
+	var getTaskFilesChan <-chan uuid.UUID = taskChannels.GetTaskFiles
+	var returnTaskFilesChan chan<- []FileStatus = taskChannels.ReturnTaskFiles
+	var getTaskListChan <-chan transferQuery = taskChannels.GetTaskList
+	var returnTaskListChan chan<- []uuid.UUID = taskChannels.ReturnTaskList
+	var drainChan <-chan context.Context = taskChannels.Drain
 	var errorChan chan<- error = taskChannels.Error
 	var pollChan <-chan struct{} = taskChannels.Poll
 	var stopChan <-chan struct{} = taskChannels.Stop
@@ -349,10 +947,30 @@ func processTasks() {
 		select {
 		case newTask := <-createTaskChan: // Create() called
 			newTask.Id = uuid.New()
+			newTask.CreationTime = time.Now()
+			newTask.StatusTime = newTask.CreationTime
+			if len(newTask.Destinations) > 0 { // one sub-transfer per destination
+				var children []transferTask
+				newTask, children = fanOutTask(newTask)
+				for _, child := range children {
+					tasks[child.Id] = splitNewTask(tasks, child)
+				}
+			} else {
+				newTask = splitNewTask(tasks, newTask)
+			}
 			tasks[newTask.Id] = newTask
 			returnTaskIdChan <- newTask.Id
+			transfersCreated.Inc()
+			recordActiveTasks(tasks)
 			slog.Info(fmt.Sprintf("Created new transfer task %s (%d file(s) requested)",
-				newTask.Id.String(), len(newTask.FileIds)))
+				newTask.Id.String(), len(newTask.FileIds)), newTask.logAttrs()...)
+			if len(newTask.Destinations) > 0 {
+				slog.Info(fmt.Sprintf("Task %s: fanned out into %d sub-transfers (one per destination)",
+					newTask.Id.String(), len(newTask.Children)))
+			} else if len(newTask.Children) > 0 {
+				slog.Info(fmt.Sprintf("Task %s: split into %d sub-transfers",
+					newTask.Id.String(), len(newTask.Children)))
+			}
 			// FIXME: this can be removed when we remove the user -> client ORCID fallback
 			if newTask.User.Orcid == newTask.Client.Orcid {
 				slog.Debug(fmt.Sprintf("Task %s: No user ORCID specified, using client ORCID", newTask.Id.String()))
@@ -360,18 +978,34 @@ func processTasks() {
 		case taskId := <-cancelTaskChan: // Cancel() called
 			if task, found := tasks[taskId]; found {
 				slog.Info(fmt.Sprintf("Task %s: received cancellation request", taskId.String()))
+				if !task.Completed() { // only unfinished transfers count
+					transfersCanceled.Inc()
+				}
+				cancelChildren(tasks, task)
 				err := task.Cancel()
 				if err != nil {
 					task.Status.Code = TransferStatusUnknown
 					task.Status.Message = fmt.Sprintf("error in cancellation: %s", err.Error())
 					task.CompletionTime = time.Now()
 					slog.Error(fmt.Sprintf("Task %s: %s", task.Id.String(), task.Status.Message))
-					tasks[task.Id] = task
 				}
+				tasks[task.Id] = task
 			} else {
 				err := NotFoundError{Id: taskId}
 				errorChan <- err
 			}
+		case taskId := <-purgeTaskChan: // Purge() called
+			if task, found := tasks[taskId]; found {
+				purgeChildren(tasks, task)
+				purgeTask(task)
+				delete(tasks, taskId)
+				recordActiveTasks(tasks)
+				slog.Info(fmt.Sprintf("Task %s: purged transfer record on request", taskId.String()),
+					task.logAttrs()...)
+				returnPurgeResultChan <- nil
+			} else {
+				returnPurgeResultChan <- NotFoundError{Id: taskId}
+			}
 		case taskId := <-getTaskStatusChan: // Status() called
 			if task, found := tasks[taskId]; found {
 				returnTaskStatusChan <- task.Status
@@ -379,47 +1013,54 @@ func processTasks() {
 				err := NotFoundError{Id: taskId}
 				errorChan <- err
 			}
-		case <-pollChan: // time to move things along
-			for taskId, task := range tasks {
-				if !task.Completed() {
-					oldStatus := task.Status
-					err := task.Update()
-					if err != nil {
-						// We log task update errors but do not propagate them. All
-						// task errors result in a failed status.
-						task.Status.Code = TransferStatusFailed
-						task.Status.Message = err.Error()
-						task.CompletionTime = time.Now()
-						slog.Error(fmt.Sprintf("Task %s: %s", task.Id.String(), err.Error()))
-					}
-					if task.Status.Code != oldStatus.Code {
-						switch task.Status.Code {
-						case TransferStatusStaging:
-							slog.Info(fmt.Sprintf("Task %s: staging %d file(s) (%g GB)",
-								task.Id.String(), len(task.FileIds), task.PayloadSize))
-						case TransferStatusActive:
-							slog.Info(fmt.Sprintf("Task %s: beginning transfer (%d file(s), %g GB)",
-								task.Id.String(), len(task.FileIds), task.PayloadSize))
-						case TransferStatusInactive:
-							slog.Info(fmt.Sprintf("Task %s: suspended transfer", task.Id.String()))
-						case TransferStatusFinalizing:
-							slog.Info(fmt.Sprintf("Task %s: finalizing transfer", task.Id.String()))
-						case TransferStatusSucceeded:
-							slog.Info(fmt.Sprintf("Task %s: completed successfully", task.Id.String()))
-						case TransferStatusFailed:
-							slog.Info(fmt.Sprintf("Task %s: failed", task.Id.String()))
-						}
-					}
+		case taskId := <-getTaskSpecChan: // SpecificationForTask() called
+			if task, found := tasks[taskId]; found {
+				returnTaskSpecChan <- task.Specification()
+			} else {
+				err := NotFoundError{Id: taskId}
+				errorChan <- err
+			}
+		case taskId := <-getTaskManifestChan: // Manifest() called
+			if task, found := tasks[taskId]; found {
+				if task.Status.Code == TransferStatusSucceeded && len(task.ManifestContent) > 0 {
+					returnTaskManifestChan <- task.ManifestContent
+				} else {
+					err := ManifestNotFoundError{Id: taskId}
+					errorChan <- err
 				}
-
-				// if the task completed a long enough time go, delete its entry
-				if task.Age() > deleteAfter {
-					slog.Debug(fmt.Sprintf("Task %s: purging transfer record", task.Id.String()))
-					delete(tasks, taskId)
-				} else { // update its entry
-					tasks[taskId] = task
+			} else {
+				err := NotFoundError{Id: taskId}
+				errorChan <- err
+			}
+		case taskId := <-getTaskChildrenChan: // SubTransfers() called
+			if task, found := tasks[taskId]; found {
+				returnTaskChildrenChan <- task.Children
+			} else {
+				err := NotFoundError{Id: taskId}
+				errorChan <- err
+			}
+		case taskId := <-getTaskFilesChan: // FileStatuses() called
+			if task, found := tasks[taskId]; found {
+				returnTaskFilesChan <- fileStatuses(tasks, task)
+			} else {
+				err := NotFoundError{Id: taskId}
+				errorChan <- err
+			}
+		case query := <-getTaskListChan: // List() called
+			returnTaskListChan <- matchingTasks(tasks, query)
+		case <-pollChan: // time to move things along
+			updateTasks(tasks, deleteAfter)
+		case ctx := <-drainChan: // Drain() called
+			// finishing a transfer can start another (e.g. that of a manifest),
+			// so we keep updating tasks until their statuses settle
+			var err error
+			for {
+				err = endpoints.Drain(ctx)
+				if err != nil || !updateTasks(tasks, deleteAfter) {
+					break
 				}
 			}
+			errorChan <- err
 		case <-stopChan: // Stop() called
 			err := saveTasks(tasks, dataStore) // don't forget to save our state!
 			errorChan <- err
@@ -428,6 +1069,183 @@ func processTasks() {
 	}
 }
 
+// updates all incomplete tasks, purging the records of tasks that completed
+// long enough ago, and returns true if the status of any task changed
+func updateTasks(tasks map[uuid.UUID]transferTask, deleteAfter time.Duration) bool {
+	changed := false
+	numActive := numActiveTasks(tasks)
+	for _, taskId := range dispatchOrder(tasks) {
+		task := tasks[taskId]
+		if task.awaitingDispatch() && !task.Canceled {
+			if !canDispatch(numActive) { // wait for an active task to finish
+				if !task.pending() {
+					task.Status = TransferStatus{
+						Code:    TransferStatusInactive,
+						Message: pendingMessage,
+					}
+					task.StatusTime = time.Now()
+					tasks[taskId] = task
+					changed = true
+					slog.Info(fmt.Sprintf("Task %s: waiting for an available transfer slot",
+						task.Id.String()), task.logAttrs()...)
+				}
+				continue
+			}
+			if task.pending() { // begin as if newly requested
+				task.Status = TransferStatus{}
+			}
+			numActive++
+		}
+		if !task.Completed() {
+			oldStatus := task.Status
+			var err error
+			if len(task.Children) > 0 { // split task
+				task.updateFromChildren(tasks)
+			} else {
+				err = task.Update()
+			}
+			if isRetryable(err) {
+				// transient errors (e.g. database timeouts or briefly
+				// unavailable endpoints) leave the task as it is, to be
+				// updated again at the next poll
+				slog.Warn(fmt.Sprintf("Task %s: %s (will retry)", task.Id.String(), err.Error()),
+					task.logAttrs()...)
+			} else if err != nil {
+				// We log task update errors but do not propagate them. All
+				// other task errors result in a failed status.
+				task.Status.Code = TransferStatusFailed
+				task.Status.Message = err.Error()
+				task.CompletionTime = time.Now()
+				slog.Error(fmt.Sprintf("Task %s: %s", task.Id.String(), err.Error()),
+					task.logAttrs()...)
+
+				// clean up any staging requests or transfers in progress
+				task.Cancel()
+			}
+			if task.Status.Code != oldStatus.Code {
+				changed = true
+				now := time.Now()
+				recordStatusChange(task, oldStatus, now.Sub(task.StatusTime).Seconds())
+				task.StatusTime = now
+				switch task.Status.Code {
+				case TransferStatusStaging:
+					slog.Info(fmt.Sprintf("Task %s: staging %d file(s) (%g GB)",
+						task.Id.String(), len(task.FileIds), task.PayloadSize), task.logAttrs()...)
+				case TransferStatusActive:
+					slog.Info(fmt.Sprintf("Task %s: beginning transfer (%d file(s), %g GB)",
+						task.Id.String(), len(task.FileIds), task.PayloadSize), task.logAttrs()...)
+				case TransferStatusInactive:
+					slog.Info(fmt.Sprintf("Task %s: suspended transfer", task.Id.String()),
+						task.logAttrs()...)
+				case TransferStatusFinalizing:
+					slog.Info(fmt.Sprintf("Task %s: finalizing transfer", task.Id.String()),
+						task.logAttrs()...)
+				case TransferStatusSucceeded:
+					slog.Info(fmt.Sprintf("Task %s: completed successfully", task.Id.String()),
+						task.logAttrs()...)
+				case TransferStatusFailed:
+					slog.Info(fmt.Sprintf("Task %s: failed", task.Id.String()), task.logAttrs()...)
+				}
+				if task.Completed() { // clean up after the task
+					task.removeWorkingDirectory()
+					if !task.Notified { // notify the user only once
+						task.Notified = true
+						go notifyUser(task)
+						go notifyCallback(task)
+					}
+				}
+			}
+		}
+
+		// if the task completed a long enough time go, delete its entry
+		if task.Age() > deleteAfter {
+			slog.Debug(fmt.Sprintf("Task %s: purging transfer record", task.Id.String()))
+			delete(tasks, taskId)
+		} else { // update its entry
+			tasks[taskId] = task
+		}
+	}
+	recordActiveTasks(tasks)
+	return changed
+}
+
+// splits the given new task into sub-transfers (adding them to the given set of
+// tasks) if it has more files than a single transfer may carry, returning the
+// (possibly split) task
+func splitNewTask(tasks map[uuid.UUID]transferTask, task transferTask) transferTask {
+	maxFiles := config.Service.MaxFilesPerTransfer
+	if maxFiles > 0 && len(task.FileIds) > maxFiles { // split it up
+		var children []transferTask
+		task, children = splitTask(task, maxFiles)
+		for _, child := range children {
+			tasks[child.Id] = child
+		}
+	}
+	return task
+}
+
+// cancels the sub-transfers of the given task (and theirs, and so on)
+func cancelChildren(tasks map[uuid.UUID]transferTask, task transferTask) {
+	for _, childId := range task.Children {
+		if child, found := tasks[childId]; found {
+			cancelChildren(tasks, child)
+			child.Cancel()
+			tasks[childId] = child
+		}
+	}
+}
+
+// purges the sub-transfers of the given task (and theirs, and so on) from the
+// given set of tasks
+func purgeChildren(tasks map[uuid.UUID]transferTask, task transferTask) {
+	for _, childId := range task.Children {
+		if child, found := tasks[childId]; found {
+			purgeChildren(tasks, child)
+			purgeTask(child)
+			delete(tasks, childId)
+		}
+	}
+}
+
+// cancels the given task if it's in progress and removes its working
+// directory (and thus its manifest) in preparation for purging its record
+func purgeTask(task transferTask) {
+	if !task.Completed() {
+		err := task.Cancel()
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Task %s: error in cancellation: %s", task.Id.String(),
+				err.Error()), task.logAttrs()...)
+		}
+	}
+	task.removeWorkingDirectory()
+}
+
+// returns the interval at which the polling heartbeat sends its signal: the
+// shortest of the intervals at which the phases of a transfer are checked
+func heartbeatInterval() time.Duration {
+	interval := config.Service.PollInterval
+	for _, phaseInterval := range []int{
+		config.Service.StagingPollInterval,
+		config.Service.TransferPollInterval,
+		config.Service.FinalizePollInterval,
+	} {
+		if phaseInterval > 0 && phaseInterval < interval {
+			interval = phaseInterval
+		}
+	}
+	return time.Duration(interval) * time.Millisecond
+}
+
+// returns true if a transfer phase last checked at the given time is due to be
+// checked again, given the interval (in milliseconds) at which that phase is
+// polled
+func pollDue(lastPolled time.Time, interval int) bool {
+	// heartbeats arrive at slightly irregular times, so we allow half a
+	// heartbeat of slack to keep from skipping a poll that's just barely due
+	slack := heartbeatInterval() / 2
+	return time.Since(lastPolled) >= time.Duration(interval)*time.Millisecond-slack
+}
+
 // this function sends a regular pulse on its poll channel until the global
 // variable running is found to be false
 func heartbeat(pollInterval time.Duration, pollChan chan<- struct{}) {
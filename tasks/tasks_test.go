@@ -25,9 +25,27 @@
 package tasks
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,7 +54,11 @@ import (
 
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/dtstest"
+	"github.com/kbase/dts/endpoints"
+	httpendpoint "github.com/kbase/dts/endpoints/http"
 )
 
 // runs all tests serially
@@ -45,7 +67,1083 @@ func TestRunner(t *testing.T) {
 	tester.TestStartAndStop()
 	tester.TestCreateTask()
 	tester.TestCancelTask()
+	tester.TestPurgeTask()
+	tester.TestTaskSpecification()
+	tester.TestListTransfers()
+	tester.TestCancelStaging()
+	tester.TestEmailNotification()
+	tester.TestCallbackNotification()
+	tester.TestInvalidResource()
+	tester.TestUnsupportedEndpointOptions()
+	tester.TestDrainBeforeStop()
+	tester.TestSplitTask()
+	tester.TestMultipleDestinations()
+	tester.TestDedupeByHash()
+	tester.TestBasedOnTransfer()
+	tester.TestTransferLimits()
+	tester.TestTransferPriority()
+	tester.TestMaxConcurrentTransfers()
+	tester.TestFileStatuses()
+	tester.TestWorkingDirectories()
+	tester.TestRequestIdLogging()
+	tester.TestTransferRetries()
 	tester.TestStopAndRestart()
+	tester.TestStaleTasksOnRestart()
+}
+
+// tests the structure of a BagIt bag generated for a two-file transfer
+func TestWriteBagTagFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest := DataPackage{
+		Name:        "manifest",
+		Description: "# Transfer\nTwo files",
+		Resources:   []DataResource{testResources["file1"], testResources["file2"]},
+		Contributors: []Contributor{
+			{
+				Title:        "Joe-bob",
+				Email:        "joe-bob@example.com",
+				Organization: "Joe-bob's Bait Shop",
+			},
+		},
+	}
+	bagDir := filepath.Join(TESTING_DIR, "bag")
+	tagFiles, err := writeBagTagFiles(bagDir, manifest)
+	assert.Nil(err)
+	assert.Equal([]string{"bagit.txt", "bag-info.txt", "manifest-md5.txt"}, tagFiles)
+
+	declaration, err := os.ReadFile(filepath.Join(bagDir, "bagit.txt"))
+	assert.Nil(err)
+	assert.Equal("BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n", string(declaration))
+
+	payloadManifest, err := os.ReadFile(filepath.Join(bagDir, "manifest-md5.txt"))
+	assert.Nil(err)
+	assert.Equal("d91f97974d06563cab48d4d43a17e08a  data/dir1/file1.dat\n"+
+		"d91f9e974d0e563cab48d4d43a17e08a  data/dir2/file2.dat\n", string(payloadManifest))
+
+	info, err := os.ReadFile(filepath.Join(bagDir, "bag-info.txt"))
+	assert.Nil(err)
+	assert.Contains(string(info), "Payload-Oxum: 3072.2\n")
+	assert.Contains(string(info), "Source-Organization: Joe-bob's Bait Shop\n")
+	assert.Contains(string(info), "External-Description: # Transfer\n  Two files\n")
+
+	// a SHA-256 payload manifest is added if every resource has a SHA-256 hash
+	manifest.Resources[0].Hashes = map[string]string{"sha256": "aaaa"}
+	manifest.Resources[1].Hashes = map[string]string{"sha256": "bbbb"}
+	tagFiles, err = writeBagTagFiles(bagDir, manifest)
+	assert.Nil(err)
+	assert.Contains(tagFiles, "manifest-sha256.txt")
+	sha256Manifest, err := os.ReadFile(filepath.Join(bagDir, "manifest-sha256.txt"))
+	assert.Nil(err)
+	assert.Equal("aaaa  data/dir1/file1.dat\nbbbb  data/dir2/file2.dat\n", string(sha256Manifest))
+
+	// resources without MD5 checksums can't be bagged
+	manifest.Resources[0].Hash = "sha256:abcdef"
+	manifest.Resources[0].Hashes = nil
+	_, err = writeBagTagFiles(bagDir, manifest)
+	assert.NotNil(err)
+}
+
+// checks that a manifest carries every hash recorded for its resources
+func TestManifestHashes(t *testing.T) {
+	assert := assert.New(t)
+
+	resource := testResources["file1"]
+	resource.Hashes = map[string]string{
+		"md5":    resource.Hash,
+		"sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+	task := transferTask{
+		Subtasks: []transferSubtask{
+			{
+				Resources: []DataResource{resource},
+			},
+		},
+	}
+	manifest := task.createManifest()
+	assert.Equal(resource.Hash, manifest.Resources[0].Hash)
+	assert.Equal(resource.Hashes, manifest.Resources[0].Hashes)
+
+	data, err := json.Marshal(manifest)
+	assert.Nil(err)
+	assert.Contains(string(data), `"hashes":{"md5":"`+resource.Hash+`","sha256":"9f86d081`)
+}
+
+// tests the recording of source endpoint metadata in a transfer manifest
+func TestManifestEndpointMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	resource := testResources["file1"]
+	resource.Endpoint = "source-endpoint"
+	task := transferTask{
+		Subtasks: []transferSubtask{
+			{
+				Resources: []DataResource{resource},
+			},
+		},
+	}
+
+	// by default, no endpoint metadata is recorded
+	manifest := task.createManifest()
+	assert.Nil(manifest.Resources[0].SourceEndpoint)
+
+	config.Service.ManifestEndpointMetadata = true
+	defer func() { config.Service.ManifestEndpointMetadata = false }()
+	manifest = task.createManifest()
+	assert.Equal(&DataEndpoint{
+		Name:     "source-endpoint",
+		Title:    "Endpoint 1",
+		Provider: "test",
+		Id:       "26d61236-39f6-4742-a374-8ec709347f2f",
+	}, manifest.Resources[0].SourceEndpoint)
+
+	// the metadata appears in the manifest's JSON
+	data, err := json.Marshal(manifest)
+	assert.Nil(err)
+	assert.Contains(string(data),
+		`"source_endpoint":{"name":"source-endpoint","title":"Endpoint 1","provider":"test",`)
+}
+
+// checks that a Frictionless manifest is gzip-compressed when requested, and
+// that the task keeps its uncompressed content
+func TestWriteCompressedJsonManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	config.Service.CompressManifest = true
+	defer func() { config.Service.CompressManifest = false }()
+
+	task := transferTask{
+		Id:                uuid.New(),
+		DestinationFolder: "dts-transfer",
+	}
+	defer task.removeWorkingDirectory()
+	manifest := DataPackage{
+		Name:      "manifest",
+		Resources: []DataResource{testResources["file1"], testResources["file2"]},
+	}
+	fileXfers, err := task.writeJsonManifest(manifest)
+	assert.Nil(err)
+	assert.Equal(1, len(fileXfers))
+	assert.Equal(filepath.Join("dts-transfer", "manifest.json.gz"), fileXfers[0].DestinationPath)
+	assert.Equal("manifest.json.gz", filepath.Base(task.ManifestFile))
+
+	// the manifest file decompresses to the manifest's JSON
+	manifestFile, err := os.Open(task.ManifestFile)
+	assert.Nil(err)
+	defer manifestFile.Close()
+	gzipReader, err := gzip.NewReader(manifestFile)
+	assert.Nil(err)
+	var written DataPackage
+	err = json.NewDecoder(gzipReader).Decode(&written)
+	assert.Nil(err)
+	assert.Equal("manifest", written.Name)
+	assert.Equal(2, len(written.Resources))
+}
+
+// checks that a manifest records hashes recomputed at the destination when
+// requested, flagging those that disagree with their sources
+func TestManifestRecomputedHashes(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Source:              "test-source",
+		DestinationEndpoint: "destination-endpoint",
+		DestinationFolder:   "dts-transfer",
+		Resources:           []DataResource{testResources["file1"], testResources["file2"]},
+	}
+	task := transferTask{Subtasks: []transferSubtask{subtask}}
+
+	// the destination computes the right hash for file1 but not for file2
+	endpoint, err := endpoints.NewEndpoint("destination-endpoint")
+	assert.Nil(err)
+	destination := endpoint.(*dtstest.Endpoint)
+	correctHash := "0123456789abcdef0123456789abcdef"
+	destination.Options.Checksums = map[string]string{
+		"dts-transfer/dir1/file1.dat": testResources["file1"].Hash,
+		"dts-transfer/dir2/file2.dat": correctHash,
+	}
+	defer func() { destination.Options.Checksums = nil }()
+
+	// by default, source hashes are trusted
+	manifest := task.createManifest()
+	assert.Equal(testResources["file2"].Hash, manifest.Resources[1].Hash)
+	assert.False(manifest.Resources[1].HashMismatch)
+
+	config.Service.RecomputeHashes = true
+	defer func() { config.Service.RecomputeHashes = false }()
+	manifest = task.createManifest()
+	assert.Equal(testResources["file1"], manifest.Resources[0])
+	assert.Equal(correctHash, manifest.Resources[1].Hash)
+	assert.Equal(correctHash, manifest.Resources[1].HashFor("md5"))
+	assert.Equal(testResources["file2"].Hash, manifest.Resources[1].SourceHash)
+	assert.True(manifest.Resources[1].HashMismatch)
+
+	// the subtask's own resources are untouched
+	assert.Equal(testResources["file2"], task.Subtasks[0].Resources[1])
+}
+
+// checks that transfers are rejected when the directories to which they write
+// files would be left with less than the configured free space
+func TestCheckFreeSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	source, err := databases.NewDatabase("1234-5678-9012-3456", "test-source")
+	assert.Nil(err)
+	spec := Specification{ // 3072 bytes
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	}
+	destinations := []string{"test-destination"}
+
+	// simulate filesystems with 1 MiB free
+	var checked []string
+	availableSpace = func(directory string) (uint64, error) {
+		checked = append(checked, directory)
+		return 1024 * 1024, nil
+	}
+	defer func() { availableSpace = diskFreeSpace }()
+
+	// nothing is checked unless a minimum is configured
+	assert.Nil(checkFreeSpace(source, spec, destinations))
+	assert.Empty(checked)
+
+	// the data and manifest directories need only the minimum
+	config.Service.MinFreeSpace = 1023.0 / (1024 * 1024) // 1023 KiB
+	defer func() { config.Service.MinFreeSpace = 0 }()
+	assert.Nil(checkFreeSpace(source, spec, destinations))
+	assert.Equal([]string{config.Service.DataDirectory, config.Service.ManifestDirectory}, checked)
+
+	// the payload must also fit on a local destination endpoint
+	endpoint := config.Endpoints["destination-endpoint"]
+	defer func() { config.Endpoints["destination-endpoint"] = endpoint }()
+	localEndpoint := endpoint
+	localEndpoint.Provider = "local"
+	config.Endpoints["destination-endpoint"] = localEndpoint
+	err = checkFreeSpace(source, spec, destinations)
+	assert.Equal(InsufficientSpaceError{
+		Directory: endpoint.Root,
+		Required:  3072 + 1023*1024,
+		Available: 1024 * 1024,
+	}, err)
+	spec.FileIds = []string{"file1"}
+	assert.Nil(checkFreeSpace(source, spec, destinations))
+
+	// free space can be determined on this platform
+	available, err := diskFreeSpace(TESTING_DIR)
+	if !errors.Is(err, errFreeSpaceUnsupported) {
+		assert.Nil(err)
+		assert.Positive(available)
+	}
+}
+
+// checks that resources with identical hashes and sizes are collapsed into
+// the first of them, which records the others as aliases
+func TestDedupeResourcesByHash(t *testing.T) {
+	assert := assert.New(t)
+
+	alias := testResources["file1"]
+	alias.Id, alias.Path = "file1-alias", "dir3/file1.dat"
+	resized := testResources["file1"]
+	resized.Id, resized.Bytes = "file1-resized", 4096
+	unhashed1 := testResources["file2"]
+	unhashed1.Hash = ""
+	unhashed2 := unhashed1
+	unhashed2.Id = "file2-unhashed"
+	resources := []DataResource{testResources["file1"], unhashed1, alias, resized, unhashed2}
+
+	deduped := dedupeByHash(resources)
+	assert.Equal(4, len(deduped))
+	assert.Equal("file1", deduped[0].Id)
+	assert.Equal([]string{"file1-alias"}, deduped[0].Aliases)
+	assert.Equal("file2", deduped[1].Id)
+	assert.Equal("file1-resized", deduped[2].Id)
+	assert.Equal("file2-unhashed", deduped[3].Id)
+	for _, resource := range deduped[1:] {
+		assert.Nil(resource.Aliases)
+	}
+	assert.Nil(resources[0].Aliases) // the originals are left alone
+}
+
+// checks that a subtask skipping existing files transfers nothing when its
+// files are already at its destination
+func TestSkipExistingFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Source:              "test-source",
+		SourceEndpoint:      "source-endpoint",
+		DestinationEndpoint: "destination-endpoint",
+		DestinationFolder:   "dts-skip-existing",
+		Resources:           []DataResource{testResources["file1"], testResources["file2"]},
+		SkipExisting:        true,
+	}
+	sourceEndpoint, err := endpoints.NewEndpoint("source-endpoint")
+	assert.Nil(err)
+
+	// the first transfer copies every file
+	err = subtask.beginTransfer()
+	assert.Nil(err)
+	assert.True(subtask.Transfer.Valid)
+	for subtask.Transfer.Valid {
+		time.Sleep(endpointOptions.TransferDuration)
+		err = subtask.checkTransfer()
+		assert.Nil(err)
+	}
+	assert.Equal(TransferStatusSucceeded, subtask.TransferStatus.Code)
+	assert.Equal(2, subtask.TransferStatus.NumFiles)
+	assert.Equal(2, subtask.TransferStatus.NumFilesTransferred)
+	assert.Equal(0, subtask.TransferStatus.NumFilesSkipped)
+	xfers, err := sourceEndpoint.Transfers()
+	assert.Nil(err)
+	numXfers := len(xfers)
+
+	// the second transfer copies nothing
+	err = subtask.beginTransfer()
+	assert.Nil(err)
+	assert.False(subtask.Transfer.Valid)
+	assert.Equal(TransferStatusSucceeded, subtask.TransferStatus.Code)
+	assert.Equal(2, subtask.TransferStatus.NumFiles)
+	assert.Equal(0, subtask.TransferStatus.NumFilesTransferred)
+	assert.Equal(2, subtask.TransferStatus.NumFilesSkipped)
+	xfers, err = sourceEndpoint.Transfers()
+	assert.Nil(err)
+	assert.Equal(numXfers, len(xfers))
+
+	// without skipping, the files are copied again
+	subtask.SkipExisting = false
+	err = subtask.beginTransfer()
+	assert.Nil(err)
+	assert.True(subtask.Transfer.Valid)
+	assert.Equal(0, subtask.TransferStatus.NumFilesSkipped)
+}
+
+// checks that staging restored from a previous session advances to transfer
+// if it finished in the meantime, and is requested again if it was lost
+func TestResumeStaging(t *testing.T) {
+	assert := assert.New(t)
+
+	client := auth.Client{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"}
+	source, err := databases.NewDatabase(client.Orcid, "test-source")
+	assert.Nil(err)
+	stagedId, err := source.StageFiles([]string{"file1"})
+	assert.Nil(err)
+	time.Sleep(endpointOptions.StagingDuration)
+
+	newTask := func(stagingId uuid.UUID) transferTask {
+		return transferTask{
+			Id: uuid.New(),
+			Subtasks: []transferSubtask{{
+				Client:              client,
+				Source:              "test-source",
+				SourceEndpoint:      "source-endpoint",
+				DestinationEndpoint: "destination-endpoint",
+				DestinationFolder:   "dts-resume-staging",
+				Resources:           []DataResource{testResources["file1"]},
+				Staging:             uuid.NullUUID{UUID: stagingId, Valid: true},
+			}},
+		}
+	}
+	staged, lost := newTask(stagedId), newTask(uuid.New())
+	lostId := lost.Subtasks[0].Staging.UUID
+	tasks := map[uuid.UUID]transferTask{staged.Id: staged, lost.Id: lost}
+	resumeStaging(tasks)
+
+	// finished staging moves right along to transfer
+	subtask := tasks[staged.Id].Subtasks[0]
+	assert.False(subtask.Staging.Valid)
+	assert.True(subtask.Transfer.Valid)
+
+	// lost staging is started over
+	subtask = tasks[lost.Id].Subtasks[0]
+	assert.NotEqual(lostId, subtask.Staging.UUID)
+	assert.True(subtask.Staging.Valid || subtask.Transfer.Valid)
+}
+
+// a destination database that records (or fails) finalizations
+type finalizableDatabase struct {
+	*dtstest.Database
+	Folders, Manifests []string
+	Fail               bool
+}
+
+func (db *finalizableDatabase) Finalize(folder, manifest string) error {
+	if db.Fail {
+		return fmt.Errorf("staging service unavailable")
+	}
+	db.Folders = append(db.Folders, folder)
+	db.Manifests = append(db.Manifests, manifest)
+	return nil
+}
+
+// checks that destination databases that need to know about transferred files
+// are notified, and that failed notifications are logged
+func TestFinalize(t *testing.T) {
+	assert := assert.New(t)
+
+	destination := &finalizableDatabase{Database: &dtstest.Database{}}
+	err := databases.RegisterDatabase("finalizable-destination",
+		func(orcid string) (databases.Database, error) {
+			return destination, nil
+		})
+	assert.Nil(err)
+
+	var logs logBuffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	task := transferTask{
+		Id:                uuid.New(),
+		Client:            auth.Client{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"},
+		Destination:       "finalizable-destination",
+		DestinationFolder: filepath.Join("joe-bob", "dts-finalize"),
+	}
+	task.finalize()
+	assert.Equal([]string{task.DestinationFolder}, destination.Folders)
+	assert.Equal([]string{filepath.Join(task.DestinationFolder, manifestFileName())},
+		destination.Manifests)
+	assert.Equal("", logs.String())
+
+	// for BagIt manifests, the database is given the bag's payload manifest
+	defaultFormat := config.Service.ManifestFormat
+	config.Service.ManifestFormat = "bagit"
+	task.finalize()
+	config.Service.ManifestFormat = defaultFormat
+	assert.Equal(filepath.Join(task.DestinationFolder, "manifest-md5.txt"), destination.Manifests[1])
+
+	// other destinations aren't notified
+	task.Destination = "test-destination"
+	task.finalize()
+	assert.Equal("", logs.String())
+
+	// a failed notification is logged
+	destination.Fail = true
+	task.Destination = "finalizable-destination"
+	task.finalize()
+	assert.Contains(logs.String(), "staging service unavailable")
+	assert.Equal(2, len(destination.Folders))
+}
+
+// checks that files unknown to a transfer's source database are reported
+// before the transfer is created
+func TestCheckFilesExist(t *testing.T) {
+	assert := assert.New(t)
+
+	source, err := databases.NewDatabase("1234-5678-9012-3456", "test-source")
+	assert.Nil(err)
+	spec := Specification{
+		Source:  "test-source",
+		FileIds: []string{"file1", "file2"},
+	}
+	assert.Nil(checkFilesExist(source, spec))
+
+	spec.FileIds = []string{"file1", "nonexistent1", "file3", "nonexistent2"}
+	err = checkFilesExist(source, spec)
+	assert.Equal(FilesNotFoundError{
+		Database: "test-source",
+		FileIds:  []string{"nonexistent1", "nonexistent2"},
+	}, err)
+	assert.Contains(err.Error(), "nonexistent1, nonexistent2")
+
+	// large requests are checked in batches
+	spec.FileIds = []string{"file1"}
+	for i := range fileCheckBatchSize + 1 {
+		spec.FileIds = append(spec.FileIds, fmt.Sprintf("nonexistent%d", i))
+	}
+	var notFound FilesNotFoundError
+	assert.ErrorAs(checkFilesExist(source, spec), &notFound)
+	assert.Len(notFound.FileIds, fileCheckBatchSize+1)
+}
+
+// checks the limits on the number and size of transfer labels
+func TestValidateLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(validateLabels(nil))
+	assert.Nil(validateLabels(map[string]string{"project": "soil-survey", "run": ""}))
+
+	var labelErr InvalidLabelError
+	err := validateLabels(map[string]string{"": "value"})
+	assert.ErrorAs(err, &labelErr)
+	err = validateLabels(map[string]string{strings.Repeat("k", maxLabelKeyLength+1): "value"})
+	assert.ErrorAs(err, &labelErr)
+	err = validateLabels(map[string]string{"key": strings.Repeat("v", maxLabelValueLength+1)})
+	assert.ErrorAs(err, &labelErr)
+	assert.Equal("key", labelErr.Key)
+
+	tooMany := make(map[string]string)
+	for i := range maxLabels + 1 {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+	err = validateLabels(tooMany)
+	assert.ErrorAs(err, &labelErr)
+}
+
+// checks the selection of transfers by requesting user and labels
+func TestMatchingTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	user := auth.User{Orcid: "1234-5678-9012-3456"}
+	soil := transferTask{Id: uuid.New(), User: user, CreationTime: now,
+		Labels: map[string]string{"project": "soil-survey", "run": "1"}}
+	marine := transferTask{Id: uuid.New(), User: user, CreationTime: now.Add(time.Second),
+		Labels: map[string]string{"project": "marine", "run": "1"}}
+	child := transferTask{Id: uuid.New(), User: user, CreationTime: now,
+		Labels: soil.Labels, Parent: uuid.NullUUID{UUID: soil.Id, Valid: true}}
+	other := transferTask{Id: uuid.New(), User: auth.User{Orcid: "0000-0000-0000-0000"},
+		CreationTime: now, Labels: soil.Labels}
+	tasks := map[uuid.UUID]transferTask{
+		soil.Id: soil, marine.Id: marine, child.Id: child, other.Id: other,
+	}
+
+	query := transferQuery{Orcid: user.Orcid}
+	assert.Equal([]uuid.UUID{soil.Id, marine.Id}, matchingTasks(tasks, query))
+	query.Labels = map[string]string{"run": "1"}
+	assert.Equal([]uuid.UUID{soil.Id, marine.Id}, matchingTasks(tasks, query))
+	query.Labels = map[string]string{"project": "marine", "run": "1"}
+	assert.Equal([]uuid.UUID{marine.Id}, matchingTasks(tasks, query))
+	query.Labels = map[string]string{"project": "marine", "run": "2"}
+	assert.Empty(matchingTasks(tasks, query))
+}
+
+// checks the states a subtask reports for its individual files
+func TestSubtaskFileStatuses(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Resources: []DataResource{testResources["file1"], testResources["file2"],
+			testResources["file3"]},
+		SkippedFiles: []string{"file1"},
+		TransferStatus: TransferStatus{
+			Code:    TransferStatusFailed,
+			Message: "endpoint unreachable",
+		},
+	}
+	states := func() []FileState {
+		var states []FileState
+		for _, status := range subtask.fileStatuses() {
+			states = append(states, status.State)
+		}
+		return states
+	}
+
+	// without a report of individual failures, every file sent failed
+	assert.Equal([]FileState{FileStateSkipped, FileStateFailed, FileStateFailed}, states())
+	assert.Equal("endpoint unreachable", subtask.fileStatuses()[1].Error)
+
+	// otherwise, only the reported files failed, and the rest were transferred
+	// if the endpoint says so
+	subtask.TransferStatus.FailedFiles = map[string]string{"dir3/file3.dat": "disk full"}
+	assert.Equal([]FileState{FileStateSkipped, FileStatePending, FileStateFailed}, states())
+	assert.Equal("disk full", subtask.fileStatuses()[2].Error)
+	subtask.TransferStatus.NumFilesTransferred = 1
+	assert.Equal([]FileState{FileStateSkipped, FileStateTransferred, FileStateFailed}, states())
+
+	// files in a transfer that's still in progress are pending
+	subtask.Transfer = uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	assert.Equal([]FileState{FileStateSkipped, FileStatePending, FileStatePending}, states())
+}
+
+// checks that staging is polled at the staging interval and file transfers at
+// the transfer interval
+func TestPhasePollIntervals(t *testing.T) {
+	assert := assert.New(t)
+
+	stagingInterval := config.Service.StagingPollInterval
+	transferInterval := config.Service.TransferPollInterval
+	defer func() {
+		config.Service.StagingPollInterval = stagingInterval
+		config.Service.TransferPollInterval = transferInterval
+	}()
+
+	// the subtasks refer to a nonexistent database and endpoint, so any
+	// status check fails, telling us that a poll occurred
+	lastPolled := time.Now().Add(-time.Second)
+	stager := transferSubtask{
+		Source:   "nonexistent-database",
+		Staging:  uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		PollTime: lastPolled,
+	}
+	mover := transferSubtask{
+		SourceEndpoint: "nonexistent-endpoint",
+		Transfer:       uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		PollTime:       lastPolled,
+	}
+
+	// staging is checked only once the staging interval has elapsed
+	config.Service.StagingPollInterval = int(time.Hour / time.Millisecond)
+	config.Service.TransferPollInterval = 10
+	assert.Nil(stager.update(), "Staging was checked before its poll interval elapsed")
+	assert.Equal(lastPolled, stager.PollTime)
+	assert.NotNil(mover.update(), "Transfer wasn't checked after its poll interval elapsed")
+	assert.NotEqual(lastPolled, mover.PollTime)
+
+	// and so are file transfers
+	mover.PollTime = lastPolled
+	config.Service.StagingPollInterval = 10
+	config.Service.TransferPollInterval = int(time.Hour / time.Millisecond)
+	assert.Nil(mover.update(), "Transfer was checked before its poll interval elapsed")
+	assert.Equal(lastPolled, mover.PollTime)
+	assert.NotNil(stager.update(), "Staging wasn't checked after its poll interval elapsed")
+	assert.NotEqual(lastPolled, stager.PollTime)
+
+	// a subtask that has never been polled is polled right away
+	mover.PollTime = time.Time{}
+	assert.NotNil(mover.update(), "Transfer wasn't checked on its first poll")
+
+	// the heartbeat keeps pace with the most frequently polled phase
+	assert.Equal(10*time.Millisecond, heartbeatInterval())
+}
+
+// checks that manifests include the configured metadata, and that metadata
+// given with a transfer request takes precedence
+func TestManifestMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	defaultManifest := config.Manifest
+	defer func() { config.Manifest = defaultManifest }()
+	err := config.Init([]byte(strings.ReplaceAll(tasksConfig, "TESTING_DIR", TESTING_DIR) +
+		"manifest:\n" +
+		"  keywords: [kbase, manifest]\n" +
+		"  licenses:\n    - name: CC0-1.0\n" +
+		"  publisher:\n    title: Example Laboratory\n    email: dts@example.com\n" +
+		"  funding:\n    - funder_name: Example Foundation\n      grant_id: EF-1234\n"))
+	assert.Nil(err)
+
+	user := auth.User{Name: "Joe-bob", Email: "joe-bob@example.com", Organization: "Example U"}
+	task := transferTask{
+		User: user,
+		Subtasks: []transferSubtask{
+			{Resources: []DataResource{testResources["file1"]}},
+		},
+	}
+
+	// the configured defaults
+	manifest := task.createManifest()
+	assert.Equal([]string{"dts", "manifest", "kbase"}, manifest.Keywords)
+	assert.Equal([]DataLicense{{Name: "CC0-1.0"}}, manifest.Licenses)
+	assert.Equal([]Contributor{
+		{Title: "Joe-bob", Email: "joe-bob@example.com", Role: "author", Organization: "Example U"},
+		{Title: "Example Laboratory", Email: "dts@example.com", Role: "publisher",
+			Organization: "Example Laboratory"},
+	}, manifest.Contributors)
+	assert.Equal([]credit.FundingReference{
+		{GrantId: "EF-1234", Funder: credit.Organization{OrganizationName: "Example Foundation"}},
+	}, manifest.Funding)
+
+	// request-level metadata takes precedence (and adds keywords)
+	task.ManifestMetadata = &ManifestMetadata{
+		Keywords:  []string{"soil", "kbase"},
+		Licenses:  []DataLicense{{Name: "CC-BY-4.0"}},
+		Publisher: &Contributor{Title: "Soil Institute", Organization: "Soil Institute"},
+	}
+	manifest = task.createManifest()
+	assert.Equal([]string{"dts", "manifest", "kbase", "soil"}, manifest.Keywords)
+	assert.Equal([]DataLicense{{Name: "CC-BY-4.0"}}, manifest.Licenses)
+	assert.Equal(2, len(manifest.Contributors))
+	assert.Equal(Contributor{Title: "Soil Institute", Organization: "Soil Institute",
+		Role: "publisher"}, manifest.Contributors[1])
+	assert.Equal("Example Foundation", manifest.Funding[0].Funder.OrganizationName)
+
+	// the metadata survives a trip through JSON
+	data, err := json.Marshal(manifest)
+	assert.Nil(err)
+	var unmarshaled DataPackage
+	err = json.Unmarshal(data, &unmarshaled)
+	assert.Nil(err)
+	assert.Equal(manifest.Funding, unmarshaled.Funding)
+	assert.Equal(manifest.Licenses, unmarshaled.Licenses)
+}
+
+// checks that study-level credit metadata shared by all resources in a manifest
+// appears once in the package descriptor when requested
+func TestManifestCollectionMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	study := credit.CreditMetadata{
+		Titles: []credit.Title{
+			{Title: "A Study of Soil Microbes"},
+			{Title: "Soil Study"},
+		},
+		Descriptions: []credit.Description{
+			{DescriptionText: "We studied microbes in soil.", DescriptionType: "abstract"},
+		},
+		RelatedIdentifiers: []credit.PermanentID{
+			{Id: "nmdc:sty-11-abc", Description: "NMDC study", RelationshipType: "IsPartOf"},
+			{Id: "10.1234/soil", Description: "Dataset DOI", RelationshipType: "IsCitedBy"},
+		},
+		ResourceType: "dataset",
+	}
+	resources := []DataResource{testResources["file1"], testResources["file2"]}
+	for i := range resources {
+		resources[i].Credit = study
+	}
+	task := transferTask{
+		Subtasks: []transferSubtask{
+			{
+				Resources: resources,
+			},
+		},
+	}
+
+	// by default, each resource carries the study metadata
+	manifest := task.createManifest()
+	assert.Equal("", manifest.Title)
+	assert.Nil(manifest.RelatedIdentifiers)
+	for _, resource := range manifest.Resources {
+		assert.Equal(study.Titles, resource.Credit.Titles)
+	}
+
+	config.Service.ManifestCollectionMetadata = true
+	defer func() { config.Service.ManifestCollectionMetadata = false }()
+	manifest = task.createManifest()
+	assert.Equal("A Study of Soil Microbes", manifest.Title)
+	assert.Equal("We studied microbes in soil.", manifest.Description)
+	assert.Contains(manifest.Keywords, "Soil Study")
+	assert.Equal(study.RelatedIdentifiers, manifest.RelatedIdentifiers)
+	for _, resource := range manifest.Resources {
+		assert.Nil(resource.Credit.Titles)
+		assert.Nil(resource.Credit.Descriptions)
+		assert.Nil(resource.Credit.RelatedIdentifiers)
+		assert.Equal("dataset", resource.Credit.ResourceType)
+	}
+
+	// the study metadata appears exactly once in the manifest's JSON
+	data, err := json.Marshal(manifest)
+	assert.Nil(err)
+	assert.Equal(1, strings.Count(string(data), "nmdc:sty-11-abc"))
+	assert.Equal(1, strings.Count(string(data), "We studied microbes in soil."))
+
+	// resources drawn from different studies are left alone
+	task.Subtasks[0].Resources[1].Credit.Titles = []credit.Title{{Title: "Another Study"}}
+	manifest = task.createManifest()
+	assert.Equal("", manifest.Title)
+	assert.Nil(manifest.RelatedIdentifiers)
+	assert.Equal(study.Titles, manifest.Resources[0].Credit.Titles)
+}
+
+// checks that resources from a database with several endpoints are split into
+// one group per endpoint
+func TestResourcesByEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	resources := []DataResource{
+		{Id: "nmdc:dobj-1", Path: "a.fna", Endpoint: "globus-nmdc-nersc"},
+		{Id: "nmdc:dobj-2", Path: "b.tsv", Endpoint: "globus-nmdc-emsl"},
+		{Id: "nmdc:dobj-3", Path: "c.fna", Endpoint: "globus-nmdc-nersc"},
+	}
+	names, groups := resourcesByEndpoint(resources)
+	assert.Equal([]string{"globus-nmdc-nersc", "globus-nmdc-emsl"}, names)
+	assert.Equal(2, len(groups))
+	assert.Equal([]DataResource{resources[0], resources[2]}, groups["globus-nmdc-nersc"])
+	assert.Equal([]DataResource{resources[1]}, groups["globus-nmdc-emsl"])
+}
+
+// checks that the status of a split task combines those of its sub-transfers
+func TestUpdateFromChildren(t *testing.T) {
+	assert := assert.New(t)
+
+	parent := transferTask{Id: uuid.New()}
+	children := []transferTask{
+		{Id: uuid.New(), Status: TransferStatus{Code: TransferStatusSucceeded, NumFiles: 2, NumFilesTransferred: 2}},
+		{Id: uuid.New(), Status: TransferStatus{Code: TransferStatusActive, NumFiles: 2, NumFilesTransferred: 1}},
+		{Id: uuid.New(), Status: TransferStatus{Code: TransferStatusStaging, NumFiles: 1}},
+	}
+	tasks := make(map[uuid.UUID]transferTask)
+	for _, child := range children {
+		parent.Children = append(parent.Children, child.Id)
+		tasks[child.Id] = child
+	}
+
+	parent.updateFromChildren(tasks)
+	assert.Equal(TransferStatusActive, parent.Status.Code)
+	assert.Equal(5, parent.Status.NumFiles)
+	assert.Equal(3, parent.Status.NumFilesTransferred)
+
+	// a split task is finalizing until all of its sub-transfers succeed
+	for _, child := range children[1:] {
+		child.Status = TransferStatus{Code: TransferStatusFinalizing}
+		tasks[child.Id] = child
+	}
+	parent.updateFromChildren(tasks)
+	assert.Equal(TransferStatusFinalizing, parent.Status.Code)
+	assert.False(parent.Completed())
+
+	// if one sub-transfer fails, the task fails and the others are canceled
+	failed := tasks[children[1].Id]
+	failed.Status = TransferStatus{Code: TransferStatusFailed, Message: "oops"}
+	tasks[failed.Id] = failed
+	parent.updateFromChildren(tasks)
+	assert.Equal(TransferStatusFailed, parent.Status.Code)
+	assert.Contains(parent.Status.Message, failed.Id.String())
+	assert.True(parent.Completed())
+	assert.True(tasks[children[2].Id].Canceled)
+}
+
+// checks that a task with several destinations fans out into one sub-transfer
+// per destination, and that canceling the task cancels all of them
+func TestFanOutTask(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id:            uuid.New(),
+		Source:        "test-source",
+		Destinations:  []string{"test-destination", "test-destination2"},
+		FileIds:       []string{"file1", "file2"},
+		NotifyByEmail: true,
+		CallbackURL:   "https://example.com/callback",
+	}
+	parent, children := fanOutTask(task)
+	assert.Equal(2, len(children))
+	assert.Equal(2, len(parent.Children))
+	for i, child := range children {
+		assert.Equal(parent.Children[i], child.Id)
+		assert.Equal(uuid.NullUUID{UUID: parent.Id, Valid: true}, child.Parent)
+		assert.Equal(task.Destinations[i], child.Destination)
+		assert.Nil(child.Destinations)
+		assert.Equal(task.FileIds, child.FileIds)
+		assert.False(child.NotifyByEmail)
+		assert.Empty(child.CallbackURL)
+	}
+	assert.Equal("test-destination, test-destination2", parent.destinationName())
+
+	// canceling reaches sub-transfers of sub-transfers
+	tasks := map[uuid.UUID]transferTask{parent.Id: parent}
+	for _, child := range children {
+		grandchild := transferTask{Id: uuid.New(), Parent: uuid.NullUUID{UUID: child.Id, Valid: true}}
+		child.Children = []uuid.UUID{grandchild.Id}
+		tasks[child.Id] = child
+		tasks[grandchild.Id] = grandchild
+	}
+	cancelChildren(tasks, parent)
+	for _, task := range tasks {
+		assert.Equal(task.Id != parent.Id, task.Canceled)
+	}
+}
+
+// checks the validation of a specification's destinations
+func TestDestinationNames(t *testing.T) {
+	assert := assert.New(t)
+
+	destinations, err := destinationNames(Specification{Destination: "test-destination"})
+	assert.Nil(err)
+	assert.Equal([]string{"test-destination"}, destinations)
+
+	destinations, err = destinationNames(Specification{
+		Destinations: []string{"test-destination", "test-destination2"},
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"test-destination", "test-destination2"}, destinations)
+
+	_, err = destinationNames(Specification{
+		Destination:  "test-destination",
+		Destinations: []string{"test-destination2"},
+	})
+	assert.IsType(InvalidDestinationsError{}, err)
+
+	_, err = destinationNames(Specification{
+		Destinations: []string{"test-destination", "test-destination2", "test-destination"},
+	})
+	assert.IsType(InvalidDestinationsError{}, err)
+	assert.Contains(err.Error(), "test-destination is given more than once")
+}
+
+// checks that a high-priority task requested after several low-priority ones
+// is dispatched first, and that tasks of equal priority are dispatched in the
+// order in which they were created
+func TestDispatchOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	lows := []transferTask{
+		{Id: uuid.New(), Priority: TransferPriorityLow, CreationTime: now},
+		{Id: uuid.New(), Priority: TransferPriorityLow, CreationTime: now.Add(time.Second)},
+		{Id: uuid.New(), Priority: TransferPriorityLow, CreationTime: now.Add(2 * time.Second)},
+	}
+	normal := transferTask{Id: uuid.New(), CreationTime: now.Add(3 * time.Second)}
+	high := transferTask{Id: uuid.New(), Priority: TransferPriorityHigh, CreationTime: now.Add(4 * time.Second)}
+	tasks := map[uuid.UUID]transferTask{normal.Id: normal, high.Id: high}
+	for _, low := range lows {
+		tasks[low.Id] = low
+	}
+
+	assert.Equal([]uuid.UUID{high.Id, normal.Id, lows[0].Id, lows[1].Id, lows[2].Id},
+		dispatchOrder(tasks))
+}
+
+// checks that databases whose endpoints use unencrypted connections are
+// rejected when encryption in transit is required
+func TestCheckEncryption(t *testing.T) {
+	assert := assert.New(t)
+	endpoints.RegisterEndpointProvider("http", httpendpoint.NewEndpoint)
+
+	secureEndpoint := config.Endpoints["source-endpoint"]
+	secureEndpoint.Provider = "http"
+	secureEndpoint.Root = "https://example.com/files"
+	config.Endpoints["https-endpoint"] = secureEndpoint
+	insecureEndpoint := secureEndpoint
+	insecureEndpoint.Root = "http://example.com/files"
+	config.Endpoints["http-endpoint"] = insecureEndpoint
+
+	secureDb := config.Databases["source"]
+	secureDb.Endpoint = "https-endpoint"
+	config.Databases["https-source"] = secureDb
+	insecureDb := secureDb
+	insecureDb.Endpoint = "http-endpoint"
+	config.Databases["http-source"] = insecureDb
+	defer func() {
+		delete(config.Endpoints, "https-endpoint")
+		delete(config.Endpoints, "http-endpoint")
+		delete(config.Databases, "https-source")
+		delete(config.Databases, "http-source")
+	}()
+
+	assert.Nil(checkEncryption("https-source"))
+	assert.Equal(endpoints.UnencryptedEndpointError{Name: "http-endpoint"},
+		checkEncryption("http-source"))
+
+	// endpoints that don't report on their transport are accepted
+	assert.Nil(checkEncryption("source"))
+}
+
+// checks the enforcement of allowed source/destination database pairs
+func TestTransferAllowed(t *testing.T) {
+	assert := assert.New(t)
+
+	// with no allowed transfers configured, anything goes
+	assert.True(transferAllowed("jdp", "kbase"))
+	assert.True(transferAllowed("kbase", "public-s3"))
+
+	config.AllowedTransfers = []config.AllowedTransfer{
+		{Source: "jdp", Destination: "kbase"},
+		{Source: "*", Destination: "archive"},
+		{Source: "nmdc", Destination: "*-s3"},
+	}
+	defer func() { config.AllowedTransfers = nil }()
+
+	// explicitly allowed pairs
+	assert.True(transferAllowed("jdp", "kbase"))
+
+	// pairs matching wildcards
+	assert.True(transferAllowed("jdp", "archive"))
+	assert.True(transferAllowed("nmdc", "archive"))
+	assert.True(transferAllowed("nmdc", "public-s3"))
+
+	// everything else is denied
+	assert.False(transferAllowed("kbase", "jdp"))
+	assert.False(transferAllowed("jdp", "public-s3"))
+	assert.False(transferAllowed("nmdc", "kbase"))
+
+	// denied transfers are rejected before they're created
+	_, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1"},
+	})
+	assert.Equal(TransferNotAllowedError{Source: "test-source", Destination: "test-destination"}, err)
+
+	// an empty list of allowed transfers denies everything
+	config.AllowedTransfers = []config.AllowedTransfer{}
+	assert.False(transferAllowed("jdp", "kbase"))
+}
+
+// checks that resources are rejected (or, if configured, merely flagged)
+// only while they're under embargo
+func TestCheckEmbargoes(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2030, 1, 15, 12, 0, 0, 0, time.UTC)
+	task := transferTask{Id: uuid.New()}
+
+	released := DataResource{Id: "file1", EmbargoUntil: "2030-01-15"}
+	embargoed := DataResource{Id: "file2", EmbargoUntil: "2030-01-16"}
+	unembargoed := DataResource{Id: "file3"}
+
+	assert.Nil(task.checkEmbargoes([]DataResource{released, unembargoed}, now))
+	err := task.checkEmbargoes([]DataResource{released, embargoed, unembargoed}, now)
+	assert.Equal(EmbargoedResourceError{ResourceId: "file2", Until: "2030-01-16"}, err)
+
+	config.Service.EmbargoPolicy = "warn"
+	defer func() { config.Service.EmbargoPolicy = "reject" }()
+	assert.Nil(task.checkEmbargoes([]DataResource{released, embargoed, unembargoed}, now))
+}
+
+// checks that callbacks to internal addresses are refused, whether they're
+// given literally or reached by dialing a host name
+func TestInternalCallbacksRefused(t *testing.T) {
+	assert := assert.New(t)
+
+	config.Service.CallbackSecret = "sekrit"
+	config.Service.CallbackSchemes = []string{"http", "https"}
+	defer func() {
+		config.Service.CallbackSecret = ""
+		config.Service.CallbackSchemes = []string{"https"}
+	}()
+
+	for _, callbackURL := range []string{
+		"https://127.0.0.1/callback",
+		"https://localhost:8443/callback",
+		"https://[::1]/callback",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.1/callback",
+		"https://0.0.0.0/callback",
+	} {
+		assert.IsType(InvalidCallbackURLError{}, validateCallbackURL(callbackURL), callbackURL)
+	}
+	assert.Nil(validateCallbackURL("https://example.com/callback"))
+
+	// a loopback server is never contacted
+	numRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+	}))
+	defer server.Close()
+	err := postCallback(server.URL+"/callback", []byte("{}"), "sha256=00")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "refusing callback to internal address")
+	assert.Equal(0, numRequests)
+}
+
+// checks that database timeouts and transient endpoint errors (and only those)
+// are considered retryable
+func TestIsRetryable(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isRetryable(databases.TimeoutError{Database: "jdp"}))
+	assert.True(isRetryable(&databases.TimeoutError{Database: "jdp"}))
+	assert.True(isRetryable(fmt.Errorf("staging: %w", databases.TimeoutError{Database: "jdp"})))
+	assert.True(isRetryable(fmt.Errorf("transferring manifest file: %w",
+		endpoints.TransientError{Name: "globus", Err: fmt.Errorf("Bad Gateway")})))
+	assert.False(isRetryable(databases.UnavailableError{Database: "jdp"}))
+	assert.False(isRetryable(fmt.Errorf("oops")))
+	assert.False(isRetryable(nil))
+}
+
+// checks that health checks are reused until they expire
+func TestCachedHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	defaultTTL := healthCacheTTL
+	defer func() { healthCacheTTL = defaultTTL }()
+	healthCacheTTL = time.Hour
+
+	health := refreshHealth()
+	assert.Len(health.Endpoints, len(config.Endpoints))
+
+	// a check within the TTL returns the cached results
+	cachedHealthMutex.Lock()
+	cachedHealth = Health{Endpoints: map[string]error{"stale": nil}}
+	cachedHealthMutex.Unlock()
+	assert.Contains(CheckHealth().Endpoints, "stale")
+
+	// an expired check contacts the endpoints again
+	healthCacheTTL = 0
+	health = CheckHealth()
+	assert.NotContains(health.Endpoints, "stale")
+	assert.Len(health.Endpoints, len(config.Endpoints))
 }
 
 // This runs setup, runs all tests, and does breakdown.
@@ -57,68 +1155,1168 @@ func TestMain(m *testing.M) {
 	os.Exit(status)
 }
 
-// this function gets called at the begіnning of a test session
-func setup() {
-	dtstest.EnableDebugLogging()
+// this function gets called at the begіnning of a test session
+func setup() {
+	dtstest.EnableDebugLogging()
+
+	log.Print("Creating testing directory...\n")
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+	os.Chdir(TESTING_DIR)
+
+	// read in the config file with SOURCE_ROOT and DESTINATION_ROOT replaced
+	myConfig := strings.ReplaceAll(tasksConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.Init([]byte(myConfig))
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+
+	// register test databases/endpoints referred to in config file
+	dtstest.RegisterTestFixturesFromConfig(endpointOptions, testResources)
+
+	// Create the data and manifest directories
+	os.Mkdir(config.Service.DataDirectory, 0755)
+	os.Mkdir(config.Service.ManifestDirectory, 0755)
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	if TESTING_DIR != "" {
+		log.Printf("Deleting testing directory %s...\n", TESTING_DIR)
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// To run the tests serially, we attach them to a SerialTests type and
+// have them run by a a single test runner.
+type SerialTests struct{ Test *testing.T }
+
+func (t *SerialTests) TestStartAndStop() {
+	assert := assert.New(t.Test)
+
+	assert.False(Running())
+	err := Start()
+	assert.Nil(err)
+	assert.True(Running())
+	err = Stop()
+	assert.Nil(err)
+	assert.False(Running())
+}
+
+func (t *SerialTests) TestCreateTask() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	deleteAfter := time.Duration(config.Service.DeleteAfter) * time.Second
+	numCreated := transfersCreated.Value()
+	numSucceeded := transfersSucceeded.Value()
+	numBytes := bytesTransferred.Value()
+
+	// queue up a transfer task between two phony databases
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+	assert.True(taskId != uuid.UUID{})
+
+	// the initial status of the task should be Unknown
+	status, err := Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusUnknown, status.Code)
+
+	// no manifest is available until the transfer has completed
+	_, err = Manifest(taskId)
+	assert.NotNil(err)
+	assert.IsType(ManifestNotFoundError{}, err)
+
+	// make sure the status switches to staging or active
+	time.Sleep(pause + pollInterval)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.True(status.Code == TransferStatusStaging || status.Code == TransferStatusActive)
+
+	// wait for the staging to complete and then check its status
+	// again (should be actively transferring)
+	time.Sleep(pause + endpointOptions.StagingDuration)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusActive, status.Code)
+
+	// wait again for the transfer to complete and then check its status
+	// (should be finalizing or have successfully completed)
+	time.Sleep(pause + endpointOptions.TransferDuration)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.True(status.Code == TransferStatusFinalizing || status.Code == TransferStatusSucceeded)
+
+	// if the transfer was finalizing, check once more for completion
+	if status.Code != TransferStatusSucceeded {
+		time.Sleep(pause + endpointOptions.TransferDuration)
+		status, err = Status(taskId)
+		assert.Nil(err)
+		assert.Equal(TransferStatusSucceeded, status.Code)
+	}
+
+	// check our metrics
+	assert.Equal(numCreated+1, transfersCreated.Value())
+	assert.Equal(numSucceeded+1, transfersSucceeded.Value())
+	assert.Equal(numBytes+3072, bytesTransferred.Value())
+
+	// the completed transfer's manifest describes both files
+	manifestContent, err := Manifest(taskId)
+	assert.Nil(err)
+	var manifest DataPackage
+	err = json.Unmarshal(manifestContent, &manifest)
+	assert.Nil(err)
+	assert.Equal(2, len(manifest.Resources))
+
+	// canceling a completed transfer doesn't count as a cancellation
+	numCanceled := transfersCanceled.Value()
+	err = Cancel(taskId)
+	assert.Nil(err)
+	_, err = Status(taskId) // handled after the cancellation
+	assert.Nil(err)
+	assert.Equal(numCanceled, transfersCanceled.Value())
+
+	// now wait for the task to age out and make sure it's not found
+	time.Sleep(pause + deleteAfter)
+	status, err = Status(taskId)
+	assert.NotNil(err)
+	_, err = Manifest(taskId)
+	assert.IsType(NotFoundError{}, err)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestCancelTask() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+
+	// queue up a transfer task between two phony databases
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+	assert.True(taskId != uuid.UUID{})
+
+	// get things going and make sure we can check its status
+	time.Sleep(pause + pollInterval)
+	_, err = Status(taskId)
+	assert.Nil(err)
+
+	// cancel the thing
+	err = Cancel(taskId)
+	assert.Nil(err)
+
+	// wait for the task to complete, which it does by failing
+	status, err := Status(taskId)
+	for {
+		if status.Code == TransferStatusSucceeded ||
+			status.Code == TransferStatusFailed {
+			break
+		}
+		time.Sleep(pause + pollInterval)
+		status, err = Status(taskId)
+		assert.Nil(err)
+	}
+	assert.Equal(TransferStatusFailed, status.Code)
+
+	// a task canceled before it begins never begins
+	taskId, err = Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+	err = Cancel(taskId)
+	assert.Nil(err)
+	time.Sleep(pause + pollInterval)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusFailed, status.Code)
+	assert.Equal("Task canceled at user request", status.Message)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestPurgeTask() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	spec := Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	}
+
+	// purge a task that's still in progress
+	taskId, err := Create(spec)
+	assert.Nil(err)
+	time.Sleep(pause + pollInterval)
+	err = Purge(taskId)
+	assert.Nil(err)
+	_, err = Status(taskId)
+	assert.Equal(NotFoundError{Id: taskId}, err)
+	err = Purge(taskId)
+	assert.Equal(NotFoundError{Id: taskId}, err)
+
+	// purge a completed task, well before its record would be deleted
+	taskId, err = Create(spec)
+	assert.Nil(err)
+	status, err := Status(taskId)
+	for err == nil && !(status.Code == TransferStatusSucceeded ||
+		status.Code == TransferStatusFailed) {
+		time.Sleep(pause + pollInterval)
+		status, err = Status(taskId)
+	}
+	assert.Nil(err)
+	assert.Equal(TransferStatusSucceeded, status.Code)
+	_, err = Manifest(taskId)
+	assert.Nil(err)
+	err = Purge(taskId)
+	assert.Nil(err)
+	_, err = Manifest(taskId)
+	assert.Equal(NotFoundError{Id: taskId}, err)
+	_, err = os.Stat(filepath.Join(config.Service.ManifestDirectory, taskId.String()))
+	assert.True(os.IsNotExist(err))
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestTaskSpecification() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	// queue up a transfer task with a description
+	description := "# Transfer\n* type: assembly\n"
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		Description: description,
+	})
+	assert.Nil(err)
+
+	spec, err := SpecificationForTask(taskId)
+	assert.Nil(err)
+	assert.Equal(description, spec.Description)
+	assert.Equal("test-source", spec.Source)
+	assert.Equal("test-destination", spec.Destination)
+	assert.Equal([]string{"file1", "file2"}, spec.FileIds)
+
+	// the description should survive a restart
+	err = Stop()
+	assert.Nil(err)
+	err = Start()
+	assert.Nil(err)
+	spec, err = SpecificationForTask(taskId)
+	assert.Nil(err)
+	assert.Equal(description, spec.Description)
+
+	// nonexistent tasks have no specification
+	_, err = SpecificationForTask(uuid.New())
+	assert.NotNil(err)
+
+	err = Cancel(taskId)
+	assert.Nil(err)
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestListTransfers() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	// queue up labeled transfers, distinguished from those of other tests by
+	// a run label
+	run := uuid.NewString()
+	client := auth.Client{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"}
+	create := func(project string) uuid.UUID {
+		taskId, err := Create(Specification{
+			Client:      client,
+			User:        auth.User{Name: "Joe-bob", Orcid: client.Orcid},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     []string{"file1"},
+			Labels:      map[string]string{"project": project, "run": run},
+		})
+		assert.Nil(err)
+		return taskId
+	}
+	soilId := create("soil-survey")
+	marineId := create("marine")
+
+	taskIds, err := List(client.Orcid, map[string]string{"run": run})
+	assert.Nil(err)
+	assert.Equal([]uuid.UUID{soilId, marineId}, taskIds)
+	taskIds, err = List(client.Orcid, map[string]string{"run": run, "project": "marine"})
+	assert.Nil(err)
+	assert.Equal([]uuid.UUID{marineId}, taskIds)
+	taskIds, err = List("0000-0000-0000-0000", map[string]string{"run": run})
+	assert.Nil(err)
+	assert.Empty(taskIds)
+
+	// labels are part of a task's specification
+	spec, err := SpecificationForTask(soilId)
+	assert.Nil(err)
+	assert.Equal("soil-survey", spec.Labels["project"])
+
+	// invalid labels are rejected
+	_, err = Create(Specification{
+		Client:      client,
+		User:        auth.User{Name: "Joe-bob", Orcid: client.Orcid},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1"},
+		Labels:      map[string]string{"": "unkeyed"},
+	})
+	assert.IsType(InvalidLabelError{}, err)
+
+	for _, taskId := range []uuid.UUID{soilId, marineId} {
+		err = Cancel(taskId)
+		assert.Nil(err)
+	}
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestCancelStaging() {
+	assert := assert.New(t.Test)
+
+	client := auth.Client{
+		Name:  "Joe-bob",
+		Orcid: "1234-5678-9012-3456",
+	}
+	source, err := databases.NewDatabase(client.Orcid, "test-source")
+	assert.Nil(err)
+	testSource := source.(*dtstest.Database)
+
+	// set up a task with a subtask that's staging files
+	stagingId, err := source.StageFiles([]string{"file1", "file2"})
+	assert.Nil(err)
+	assert.Contains(testSource.Staging, stagingId)
+	task := transferTask{
+		Client:      client,
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		Subtasks: []transferSubtask{
+			{
+				Source: "test-source",
+				Staging: uuid.NullUUID{
+					UUID:  stagingId,
+					Valid: true,
+				},
+				StagingStatus: databases.StagingStatusActive,
+				Client:        client,
+			},
+		},
+	}
+
+	// a failed or canceled task should discard its staging request immediately
+	err = task.Cancel()
+	assert.Nil(err)
+	assert.NotContains(testSource.Staging, stagingId)
+	assert.False(task.Subtasks[0].Staging.Valid)
+
+	err = task.Update()
+	assert.Nil(err)
+	assert.Equal(TransferStatusFailed, task.Subtasks[0].TransferStatus.Code)
+}
+
+func (t *SerialTests) TestEmailNotification() {
+	assert := assert.New(t.Test)
+
+	// start a stub SMTP server and point our configuration at it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	messages := make(chan string, 1)
+	go runStubSMTPServer(listener, messages)
+	config.SMTP.Host = "127.0.0.1"
+	config.SMTP.Port = listener.Addr().(*net.TCPAddr).Port
+	config.SMTP.From = "dts@example.com"
+	defer func() { config.SMTP.Host = "" }()
+
+	err = Start()
+	assert.Nil(err)
+
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Email: "joe-bob@example.com",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:        "test-source",
+		Destination:   "test-destination",
+		FileIds:       []string{"file1", "file2"},
+		NotifyByEmail: true,
+	})
+	assert.Nil(err)
+
+	// wait for the notification
+	select {
+	case message := <-messages:
+		assert.Contains(message, "To: joe-bob@example.com\r\n")
+		assert.Contains(message, fmt.Sprintf("Subject: DTS transfer %s succeeded\r\n", taskId.String()))
+		assert.Contains(message, "Files: 2\r\n")
+		assert.Contains(message, "Bytes: 3072\r\n")
+		assert.Contains(message, "manifest.json")
+	case <-time.After(5 * time.Second):
+		assert.Fail("No email notification was sent")
+	}
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestCallbackNotification() {
+	assert := assert.New(t.Test)
+
+	// start a callback server that fails its first request (to exercise
+	// retries) and accepts the next, reporting its payload and signature
+	type callback struct {
+		Payload   []byte
+		Signature string
+	}
+	callbacks := make(chan callback, 1)
+	numRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		if numRequests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		payload, _ := io.ReadAll(r.Body)
+		callbacks <- callback{
+			Payload:   payload,
+			Signature: r.Header.Get(CallbackSignatureHeader),
+		}
+	}))
+	defer server.Close()
+
+	spec := Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		CallbackURL: server.URL + "/callback",
+	}
+
+	err := Start()
+	assert.Nil(err)
+
+	// callbacks are refused unless they're enabled with a secret and use a
+	// permitted scheme
+	_, err = Create(spec)
+	assert.IsType(InvalidCallbackURLError{}, err)
+	config.Service.CallbackSecret = "sekrit"
+	defer func() { config.Service.CallbackSecret = "" }()
+	_, err = Create(spec)
+	assert.IsType(InvalidCallbackURLError{}, err)
+	config.Service.CallbackSchemes = []string{"http", "https"}
+	defer func() { config.Service.CallbackSchemes = []string{"https"} }()
+	retryInterval := callbackRetryInterval
+	callbackRetryInterval = 10 * time.Millisecond
+	defer func() { callbackRetryInterval = retryInterval }()
+	allowInternalCallbacks = true // the callback server is on localhost
+	defer func() { allowInternalCallbacks = false }()
+
+	taskId, err := Create(spec)
+	assert.Nil(err)
+
+	// wait for the callback and check its payload and signature
+	select {
+	case cb := <-callbacks:
+		var payload CallbackPayload
+		err = json.Unmarshal(cb.Payload, &payload)
+		assert.Nil(err)
+		assert.Equal(CallbackPayload{
+			Id:          taskId,
+			Status:      "succeeded",
+			NumFiles:    2,
+			Bytes:       3072,
+			ManifestURL: fmt.Sprintf("/api/v1/transfers/%s/manifest", taskId.String()),
+		}, payload)
+		mac := hmac.New(sha256.New, []byte("sekrit"))
+		mac.Write(cb.Payload)
+		assert.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), cb.Signature)
+	case <-time.After(5 * time.Second):
+		assert.Fail("No callback was delivered")
+	}
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestInvalidResource() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+
+	// request a transfer that includes a malformed resource
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "malformed"},
+	})
+	assert.Nil(err)
+
+	// the task should fail as soon as it starts, before any files are moved
+	time.Sleep(pause + pollInterval)
+	status, err := Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusFailed, status.Code)
+	assert.Contains(status.Message, "malformed")
+	assert.Contains(status.Message, "no path")
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+// checks that transfer options unsupported by the source endpoint are rejected
+func (t *SerialTests) TestUnsupportedEndpointOptions() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	// the test endpoint accepts no transfer options
+	_, err = Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:          "test-source",
+		Destination:     "test-destination",
+		FileIds:         []string{"file1"},
+		EndpointOptions: TransferOptions{"encrypt_data": true},
+	})
+	assert.NotNil(err)
+	assert.IsType(endpoints.InvalidTransferOptionError{}, err)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+// checks that transfers in progress are drained before the task manager stops,
+// so that their files aren't transferred again on restart
+func (t *SerialTests) TestDrainBeforeStop() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+	spec := Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	}
+	taskId, err := Create(spec)
+	assert.Nil(err)
+
+	// wait for the files to start transferring
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	time.Sleep(pause + pollInterval + endpointOptions.StagingDuration)
+	status, err := Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusActive, status.Code)
+	sourceEndpoint, err := endpoints.NewEndpoint("source-endpoint")
+	assert.Nil(err)
+	xfers, err := sourceEndpoint.Transfers()
+	assert.Nil(err)
+	numXfers := len(xfers)
+
+	// drain the transfer, which should complete (with its manifest)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*endpointOptions.TransferDuration)
+	defer cancel()
+	err = Drain(ctx)
+	assert.Nil(err)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusSucceeded, status.Code)
+
+	// no new transfers are accepted while draining
+	_, err = Create(spec)
+	assert.IsType(DrainingError{}, err)
+
+	err = Stop()
+	assert.Nil(err)
+
+	// restart and make sure the completed task isn't transferred again
+	err = Start()
+	assert.Nil(err)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusSucceeded, status.Code)
+	time.Sleep(pause + 2*pollInterval)
+	xfers, err = sourceEndpoint.Transfers()
+	assert.Nil(err)
+	assert.Equal(numXfers, len(xfers))
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestTransferLimits() {
+	assert := assert.New(t.Test)
+
+	// allow at most two files and the payload of file1 and file2 (3 kiB)
+	maxPayloadSize := config.Service.MaxPayloadSize
+	config.Service.MaxFilesPerRequest = 2
+	config.Service.MaxPayloadSize = 3072.0 / (1024 * 1024 * 1024)
+	config.Service.Admins = []string{"0000-0000-0000-0000"}
+	defer func() {
+		config.Service.MaxFilesPerRequest = 0
+		config.Service.MaxPayloadSize = maxPayloadSize
+		config.Service.Admins = nil
+		config.Service.ExemptAdminsFromLimits = false
+	}()
+
+	err := Start()
+	assert.Nil(err)
+
+	spec := Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+	}
+
+	// a transfer at the limits is accepted
+	spec.FileIds = []string{"file1", "file2"}
+	_, err = Create(spec)
+	assert.Nil(err)
+
+	// a transfer of too many files is rejected
+	spec.FileIds = []string{"file1", "file2", "file3"}
+	_, err = Create(spec)
+	assert.IsType(&TooManyFilesError{}, err)
+
+	// a transfer whose payload is too large is rejected
+	spec.FileIds = []string{"file1", "file3"}
+	_, err = Create(spec)
+	assert.IsType(&PayloadTooLargeError{}, err)
+
+	// admins are subject to the limits unless they're exempt
+	spec.Client.Orcid = "0000-0000-0000-0000"
+	spec.FileIds = []string{"file1", "file2", "file3"}
+	_, err = Create(spec)
+	assert.IsType(&TooManyFilesError{}, err)
+
+	config.Service.ExemptAdminsFromLimits = true
+	taskId, err := Create(spec)
+	assert.Nil(err)
+
+	// the exempt transfer isn't rejected when it starts, either
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	time.Sleep(pause + pollInterval)
+	status, err := Status(taskId)
+	assert.Nil(err)
+	assert.NotEqual(TransferStatusFailed, status.Code)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestTransferPriority() {
+	assert := assert.New(t.Test)
+
+	config.Service.MaxConcurrentTransfers = 1
+	defer func() { config.Service.MaxConcurrentTransfers = 0 }()
+
+	err := Start()
+	assert.Nil(err)
+
+	spec := Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		Priority:    TransferPriorityLow,
+	}
+
+	// request several low-priority transfers, followed by a high-priority one
+	lowIds := make([]uuid.UUID, 3)
+	for i := range lowIds {
+		lowIds[i], err = Create(spec)
+		assert.Nil(err)
+	}
+	spec.Priority = TransferPriorityHigh
+	highId, err := Create(spec)
+	assert.Nil(err)
+
+	// the high-priority transfer begins before all but (at most) the first of
+	// the low-priority ones
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	status, err := Status(highId)
+	assert.Nil(err)
+	for status.Code == TransferStatusUnknown || status.Message == pendingMessage {
+		time.Sleep(pollInterval / 2)
+		status, err = Status(highId)
+		assert.Nil(err)
+	}
+	for _, lowId := range lowIds[1:] {
+		status, err = Status(lowId)
+		assert.Nil(err)
+		assert.Contains([]endpoints.TransferStatusCode{TransferStatusUnknown, TransferStatusInactive},
+			status.Code)
+	}
+
+	// the priority survives a restart
+	err = Stop()
+	assert.Nil(err)
+	err = Start()
+	assert.Nil(err)
+	highSpec, err := SpecificationForTask(highId)
+	assert.Nil(err)
+	assert.Equal(TransferPriorityHigh, highSpec.Priority)
+	lowSpec, err := SpecificationForTask(lowIds[2])
+	assert.Nil(err)
+	assert.Equal(TransferPriorityLow, lowSpec.Priority)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestMaxConcurrentTransfers() {
+	assert := assert.New(t.Test)
+
+	config.Service.MaxConcurrentTransfers = 2
+	defer func() { config.Service.MaxConcurrentTransfers = 0 }()
+
+	err := Start()
+	assert.Nil(err)
+
+	// request more transfers than can run at once
+	taskIds := make([]uuid.UUID, 4)
+	for i := range taskIds {
+		taskIds[i], err = Create(Specification{
+			Client: auth.Client{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			User: auth.User{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     []string{"file1", "file2"},
+		})
+		assert.Nil(err)
+	}
+
+	// no more than 2 transfers run at once, and the rest report that they're
+	// pending until they begin
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	sawPending := false
+	finalStatuses := make(map[uuid.UUID]TransferStatus)
+	for len(finalStatuses) < len(taskIds) {
+		numRunning := 0
+		for _, taskId := range taskIds {
+			if _, completed := finalStatuses[taskId]; completed {
+				continue
+			}
+			status, err := Status(taskId)
+			assert.Nil(err)
+			switch status.Code {
+			case TransferStatusStaging, TransferStatusActive, TransferStatusFinalizing:
+				numRunning++
+			case TransferStatusInactive:
+				assert.Equal(pendingMessage, status.Message)
+				sawPending = true
+			case TransferStatusSucceeded, TransferStatusFailed:
+				finalStatuses[taskId] = status
+			}
+		}
+		assert.LessOrEqual(numRunning, 2)
+		time.Sleep(pollInterval / 2)
+	}
+	assert.True(sawPending)
+
+	// every transfer eventually completes
+	for _, status := range finalStatuses {
+		assert.Equal(TransferStatusSucceeded, status.Code)
+	}
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestFileStatuses() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	// make one file in the next transfer from the source endpoint fail
+	sourceEndpoint, err := endpoints.NewEndpoint("source-endpoint")
+	assert.Nil(err)
+	sourceEndpoint.(*dtstest.Endpoint).Options.FailedFiles = map[string]string{
+		"dir2/file2.dat": "permission denied",
+	}
+	defer func() { sourceEndpoint.(*dtstest.Endpoint).Options.FailedFiles = nil }()
+
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+
+	// before the transfer begins, its files are pending
+	files, err := FileStatuses(taskId)
+	assert.Nil(err)
+	assert.Equal([]FileStatus{
+		{Id: "file1", State: FileStatePending},
+		{Id: "file2", State: FileStatePending},
+	}, files)
+
+	// wait for the transfer to fail
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	status, err := Status(taskId)
+	for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
+		time.Sleep(pause + pollInterval)
+		status, err = Status(taskId)
+		assert.Nil(err)
+	}
+	assert.Equal(TransferStatusFailed, status.Code)
+
+	// the failing file is reported along with its error
+	files, err = FileStatuses(taskId)
+	assert.Nil(err)
+	assert.Equal([]FileStatus{
+		{Id: "file1", Path: "dir1/file1.dat", State: FileStateTransferred},
+		{Id: "file2", Path: "dir2/file2.dat", State: FileStateFailed, Error: "permission denied"},
+	}, files)
+
+	_, err = FileStatuses(uuid.New())
+	assert.NotNil(err)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestSplitTask() {
+	assert := assert.New(t.Test)
+
+	config.Service.MaxFilesPerTransfer = 2
+	defer func() { config.Service.MaxFilesPerTransfer = 0 }()
+
+	err := Start()
+	assert.Nil(err)
+
+	// request a transfer that exceeds the maximum number of files
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2", "file3"},
+	})
+	assert.Nil(err)
+
+	// the transfer should be split into two sub-transfers
+	children, err := SubTransfers(taskId)
+	assert.Nil(err)
+	assert.Equal(2, len(children))
+
+	// wait for everything to finish
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	status, err := Status(taskId)
+	for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
+		time.Sleep(pause + pollInterval)
+		status, err = Status(taskId)
+		assert.Nil(err)
+	}
+
+	// the task's status combines those of its sub-transfers, each of which
+	// has its own manifest
+	assert.Equal(TransferStatusSucceeded, status.Code)
+	assert.Equal(3, status.NumFiles)
+	numResources := 0
+	for _, childId := range children {
+		childStatus, err := Status(childId)
+		assert.Nil(err)
+		assert.Equal(TransferStatusSucceeded, childStatus.Code)
+		manifestContent, err := Manifest(childId)
+		assert.Nil(err)
+		var manifest DataPackage
+		err = json.Unmarshal(manifestContent, &manifest)
+		assert.Nil(err)
+		numResources += len(manifest.Resources)
+	}
+	assert.Equal(3, numResources)
+
+	// tasks that aren't split have no sub-transfers
+	children, err = SubTransfers(children[0])
+	assert.Nil(err)
+	assert.Empty(children)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestMultipleDestinations() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	// request a transfer of the same files to two destinations
+	destinations := []string{"test-destination", "test-destination2"}
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:       "test-source",
+		Destinations: destinations,
+		FileIds:      []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+
+	// the transfer should fan out into one sub-transfer per destination
+	children, err := SubTransfers(taskId)
+	assert.Nil(err)
+	assert.Equal(2, len(children))
+	spec, err := SpecificationForTask(taskId)
+	assert.Nil(err)
+	assert.Equal(destinations, spec.Destinations)
 
-	log.Print("Creating testing directory...\n")
-	var err error
-	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
-	if err != nil {
-		log.Panicf("Couldn't create testing directory: %s", err)
+	// wait for everything to finish
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	status, err := Status(taskId)
+	for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
+		time.Sleep(pause + pollInterval)
+		status, err = Status(taskId)
+		assert.Nil(err)
 	}
-	os.Chdir(TESTING_DIR)
 
-	// read in the config file with SOURCE_ROOT and DESTINATION_ROOT replaced
-	myConfig := strings.ReplaceAll(tasksConfig, "TESTING_DIR", TESTING_DIR)
-	err = config.Init([]byte(myConfig))
-	if err != nil {
-		log.Panicf("Couldn't initialize configuration: %s", err)
+	// both destinations should have received all of the files
+	assert.Equal(TransferStatusSucceeded, status.Code)
+	assert.Equal(4, status.NumFiles)
+	for i, childId := range children {
+		childSpec, err := SpecificationForTask(childId)
+		assert.Nil(err)
+		assert.Equal(destinations[i], childSpec.Destination)
+		childStatus, err := Status(childId)
+		assert.Nil(err)
+		assert.Equal(TransferStatusSucceeded, childStatus.Code)
+		assert.Equal(2, childStatus.NumFilesTransferred)
+		manifestContent, err := Manifest(childId)
+		assert.Nil(err)
+		var manifest DataPackage
+		err = json.Unmarshal(manifestContent, &manifest)
+		assert.Nil(err)
+		assert.Equal(2, len(manifest.Resources))
 	}
 
-	// register test databases/endpoints referred to in config file
-	dtstest.RegisterTestFixturesFromConfig(endpointOptions, testResources)
-
-	// Create the data and manifest directories
-	os.Mkdir(config.Service.DataDirectory, 0755)
-	os.Mkdir(config.Service.ManifestDirectory, 0755)
-}
+	// a destination can't be given twice
+	_, err = Create(Specification{
+		Client:       auth.Client{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"},
+		Source:       "test-source",
+		Destinations: []string{"test-destination", "test-destination"},
+		FileIds:      []string{"file1"},
+	})
+	assert.IsType(InvalidDestinationsError{}, err)
 
-// this function gets called after all tests have been run
-func breakdown() {
-	if TESTING_DIR != "" {
-		log.Printf("Deleting testing directory %s...\n", TESTING_DIR)
-		os.RemoveAll(TESTING_DIR)
-	}
+	err = Stop()
+	assert.Nil(err)
 }
 
-// To run the tests serially, we attach them to a SerialTests type and
-// have them run by a a single test runner.
-type SerialTests struct{ Test *testing.T }
-
-func (t *SerialTests) TestStartAndStop() {
+func (t *SerialTests) TestDedupeByHash() {
 	assert := assert.New(t.Test)
 
-	assert.False(Running())
+	// expose file1 under a second ID
+	alias := testResources["file1"]
+	alias.Id, alias.Path = "file1-alias", "dir3/file1.dat"
+	testResources[alias.Id] = alias
+	defer delete(testResources, alias.Id)
+
 	err := Start()
 	assert.Nil(err)
-	assert.True(Running())
+
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:       "test-source",
+		Destination:  "test-destination",
+		FileIds:      []string{"file1", "file2", "file1-alias"},
+		DedupeByHash: true,
+	})
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	status, err := Status(taskId)
+	for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
+		time.Sleep(pause + pollInterval)
+		status, err = Status(taskId)
+		assert.Nil(err)
+	}
+
+	// the aliased file is transferred only once, and the manifest records
+	// its alias
+	assert.Equal(TransferStatusSucceeded, status.Code)
+	assert.Equal(2, status.NumFiles)
+	assert.Equal(2, status.NumFilesTransferred)
+	manifestContent, err := Manifest(taskId)
+	assert.Nil(err)
+	var manifest DataPackage
+	err = json.Unmarshal(manifestContent, &manifest)
+	assert.Nil(err)
+	assert.Equal(2, len(manifest.Resources))
+	assert.Equal("file1", manifest.Resources[0].Id)
+	assert.Equal([]string{"file1-alias"}, manifest.Resources[0].Aliases)
+	assert.Equal("file2", manifest.Resources[1].Id)
+	assert.Nil(manifest.Resources[1].Aliases)
+
 	err = Stop()
 	assert.Nil(err)
-	assert.False(Running())
 }
 
-func (t *SerialTests) TestCreateTask() {
+func (t *SerialTests) TestBasedOnTransfer() {
 	assert := assert.New(t.Test)
 
 	err := Start()
 	assert.Nil(err)
 
-	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
-	deleteAfter := time.Duration(config.Service.DeleteAfter) * time.Second
-
-	// queue up a transfer task between two phony databases
-	taskId, err := Create(Specification{
+	spec := Specification{
 		Client: auth.Client{
 			Name:  "Joe-bob",
 			Orcid: "1234-5678-9012-3456",
@@ -130,62 +2328,94 @@ func (t *SerialTests) TestCreateTask() {
 		Source:      "test-source",
 		Destination: "test-destination",
 		FileIds:     []string{"file1", "file2"},
-	})
-	assert.Nil(err)
-	assert.True(taskId != uuid.UUID{})
+	}
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	waitForTransfer := func(taskId uuid.UUID) TransferStatus {
+		status, err := Status(taskId)
+		for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
+			time.Sleep(pause + pollInterval)
+			status, err = Status(taskId)
+			assert.Nil(err)
+		}
+		return status
+	}
 
-	// the initial status of the task should be Unknown
-	status, err := Status(taskId)
-	assert.Nil(err)
-	assert.Equal(TransferStatusUnknown, status.Code)
+	// a transfer can't be based on one that doesn't exist
+	spec.BasedOn = uuid.New()
+	_, err = Create(spec)
+	assert.NotNil(err)
+	assert.IsType(InvalidBaseTransferError{}, err)
 
-	// make sure the status switches to staging or active
-	time.Sleep(pause + pollInterval)
-	status, err = Status(taskId)
+	// transfer the base files
+	spec.BasedOn = uuid.Nil
+	baseId, err := Create(spec)
 	assert.Nil(err)
-	assert.True(status.Code == TransferStatusStaging || status.Code == TransferStatusActive)
+	assert.Equal(TransferStatusSucceeded, waitForTransfer(baseId).Code)
 
-	// wait for the staging to complete and then check its status
-	// again (should be actively transferring)
-	time.Sleep(pause + endpointOptions.StagingDuration)
-	status, err = Status(taskId)
+	// nothing has changed yet
+	spec.BasedOn = baseId
+	_, err = Create(spec)
+	assert.NotNil(err)
+	assert.IsType(NoChangedFilesError{}, err)
+
+	// add a file to the source, and transfer only it
+	newFile := testResources["file3"]
+	newFile.Id, newFile.Path = "file4", "dir3/file4.dat"
+	testResources[newFile.Id] = newFile
+	defer delete(testResources, newFile.Id)
+	spec.FileIds = []string{"file1", "file2", "file4"}
+	taskId, err := Create(spec)
 	assert.Nil(err)
-	assert.Equal(TransferStatusActive, status.Code)
+	status := waitForTransfer(taskId)
+	assert.Equal(TransferStatusSucceeded, status.Code)
+	assert.Equal(1, status.NumFiles)
+	basedOnSpec, err := SpecificationForTask(taskId)
+	assert.Nil(err)
+	assert.Equal([]string{"file4"}, basedOnSpec.FileIds)
+	assert.Equal(baseId, basedOnSpec.BasedOn)
 
-	// wait again for the transfer to complete and then check its status
-	// (should be finalizing or have successfully completed)
-	time.Sleep(pause + endpointOptions.TransferDuration)
-	status, err = Status(taskId)
+	// once purged, the base transfer can't be used
+	err = Purge(baseId)
 	assert.Nil(err)
-	assert.True(status.Code == TransferStatusFinalizing || status.Code == TransferStatusSucceeded)
+	_, err = Create(spec)
+	assert.NotNil(err)
+	assert.IsType(InvalidBaseTransferError{}, err)
 
-	// if the transfer was finalizing, check once more for completion
-	if status.Code != TransferStatusSucceeded {
-		time.Sleep(pause + endpointOptions.TransferDuration)
-		status, err = Status(taskId)
+	// a transfer fanned out to several destinations, each of whose
+	// sub-transfers is split, can serve as a base
+	config.Service.MaxFilesPerTransfer = 1
+	defer func() { config.Service.MaxFilesPerTransfer = 0 }()
+	spec.BasedOn = uuid.Nil
+	spec.Destination = ""
+	spec.Destinations = []string{"test-destination", "test-destination2"}
+	spec.FileIds = []string{"file1", "file2"}
+	baseId, err = Create(spec)
+	assert.Nil(err)
+	children, err := SubTransfers(baseId)
+	assert.Nil(err)
+	for _, childId := range children {
+		grandchildren, err := SubTransfers(childId)
 		assert.Nil(err)
-		assert.Equal(TransferStatusSucceeded, status.Code)
+		assert.Equal(2, len(grandchildren))
 	}
-
-	// now wait for the task to age out and make sure it's not found
-	time.Sleep(pause + deleteAfter)
-	status, err = Status(taskId)
+	assert.Equal(TransferStatusSucceeded, waitForTransfer(baseId).Code)
+	spec.BasedOn = baseId
+	_, err = Create(spec)
 	assert.NotNil(err)
+	assert.IsType(NoChangedFilesError{}, err)
 
 	err = Stop()
 	assert.Nil(err)
 }
 
-func (t *SerialTests) TestCancelTask() {
+func (t *SerialTests) TestWorkingDirectories() {
 	assert := assert.New(t.Test)
 
 	err := Start()
 	assert.Nil(err)
 
-	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
-
-	// queue up a transfer task between two phony databases
-	taskId, err := Create(Specification{
+	// queue up two transfers at once
+	spec := Specification{
 		Client: auth.Client{
 			Name:  "Joe-bob",
 			Orcid: "1234-5678-9012-3456",
@@ -197,26 +2427,187 @@ func (t *SerialTests) TestCancelTask() {
 		Source:      "test-source",
 		Destination: "test-destination",
 		FileIds:     []string{"file1", "file2"},
-	})
+	}
+	taskIds := make([]uuid.UUID, 2)
+	for i := range taskIds {
+		taskIds[i], err = Create(spec)
+		assert.Nil(err)
+	}
+
+	// while each task is finalizing, its manifest lives in its own
+	// subdirectory of the manifest directory
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	sawManifest := make([]bool, len(taskIds))
+	completed := make([]bool, len(taskIds))
+	for slices.Contains(completed, false) {
+		for i, taskId := range taskIds {
+			status, err := Status(taskId)
+			assert.Nil(err)
+			switch status.Code {
+			case TransferStatusFinalizing:
+				workingDir := filepath.Join(config.Service.ManifestDirectory, taskId.String())
+				_, err := os.Stat(filepath.Join(workingDir, "manifest.json"))
+				sawManifest[i] = sawManifest[i] || err == nil
+			case TransferStatusSucceeded, TransferStatusFailed:
+				assert.Equal(TransferStatusSucceeded, status.Code)
+				completed[i] = true
+			}
+		}
+		time.Sleep(pollInterval / 2)
+	}
+	assert.Equal([]bool{true, true}, sawManifest)
+
+	// the subdirectories are removed once the tasks complete
+	for _, taskId := range taskIds {
+		_, err = os.Stat(filepath.Join(config.Service.ManifestDirectory, taskId.String()))
+		assert.True(os.IsNotExist(err))
+	}
+
+	err = Stop()
 	assert.Nil(err)
-	assert.True(taskId != uuid.UUID{})
+}
 
-	// get things going and make sure we can check its status
-	time.Sleep(pause + pollInterval)
-	_, err = Status(taskId)
+func (t *SerialTests) TestTransferRetries() {
+	assert := assert.New(t.Test)
+
+	// start a stub SMTP server to receive notifications
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	assert.Nil(err)
+	defer listener.Close()
+	messages := make(chan string, 2)
+	go runStubSMTPServer(listener, messages)
+	config.SMTP.Host = "127.0.0.1"
+	config.SMTP.Port = listener.Addr().(*net.TCPAddr).Port
+	config.SMTP.From = "dts@example.com"
+	config.Service.MaxTransferRetries = 1
+	defer func() {
+		config.SMTP.Host = ""
+		config.Service.MaxTransferRetries = 0
+	}()
 
-	// cancel the thing
-	err = Cancel(taskId)
+	err = Start()
 	assert.Nil(err)
 
-	// wait for the task to complete
-	status, err := Status(taskId)
-	for {
-		if status.Code == TransferStatusSucceeded ||
-			status.Code == TransferStatusFailed {
-			break
+	spec := Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Email: "joe-bob@example.com",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:        "test-source",
+		Destination:   "test-destination",
+		FileIds:       []string{"file1", "file2"},
+		NotifyByEmail: true,
+	}
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	waitForCompletion := func(taskId uuid.UUID) TransferStatus {
+		status, err := Status(taskId)
+		assert.Nil(err)
+		for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
+			time.Sleep(pause + pollInterval)
+			status, err = Status(taskId)
+			if !assert.Nil(err) {
+				break
+			}
+		}
+		return status
+	}
+	sourceEndpoint, err := endpoints.NewEndpoint("source-endpoint")
+	assert.Nil(err)
+
+	for _, numFailures := range []int{1, 2} {
+		// make the next file transfer(s) from the source endpoint fail
+		sourceEndpoint.(*dtstest.Endpoint).Options.FailedTransfers = numFailures
+
+		taskId, err := Create(spec)
+		assert.Nil(err)
+		status := waitForCompletion(taskId)
+
+		// a single failure is retried, after which the transfer succeeds, but
+		// a second failure exhausts our retries
+		outcome := "succeeded"
+		if numFailures > config.Service.MaxTransferRetries {
+			outcome = "failed"
+		}
+		if outcome == "succeeded" {
+			assert.Equal(TransferStatusSucceeded, status.Code)
+		} else {
+			assert.Equal(TransferStatusFailed, status.Code)
+		}
+
+		// either way, the user is notified exactly once
+		select {
+		case message := <-messages:
+			assert.Contains(message, fmt.Sprintf("Subject: DTS transfer %s %s\r\n",
+				taskId.String(), outcome))
+		case <-time.After(5 * time.Second):
+			assert.Fail("No email notification was sent")
 		}
+		select {
+		case <-messages:
+			assert.Fail("More than one email notification was sent")
+		case <-time.After(pause + 4*pollInterval):
+		}
+	}
+	sourceEndpoint.(*dtstest.Endpoint).Options.FailedTransfers = 0
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+// a buffer that safely captures log output written from several goroutines
+type logBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func (t *SerialTests) TestRequestIdLogging() {
+	assert := assert.New(t.Test)
+
+	// capture log output as JSON
+	var logs logBuffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	err := Start()
+	assert.Nil(err)
+
+	// create a task on behalf of a service request and let it finish
+	taskId, err := Create(Specification{
+		Client: auth.Client{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		RequestId:   "test-request-id",
+	})
+	assert.Nil(err)
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	status, err := Status(taskId)
+	for !(status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed) {
 		time.Sleep(pause + pollInterval)
 		status, err = Status(taskId)
 		assert.Nil(err)
@@ -224,6 +2615,19 @@ func (t *SerialTests) TestCancelTask() {
 
 	err = Stop()
 	assert.Nil(err)
+
+	// every log entry for the task records the ID of the originating request
+	numEntries := 0
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		err = json.Unmarshal([]byte(line), &entry)
+		assert.Nil(err)
+		if msg, _ := entry["msg"].(string); strings.Contains(msg, taskId.String()) {
+			assert.Equal("test-request-id", entry["request_id"], msg)
+			numEntries++
+		}
+	}
+	assert.Greater(numEntries, 1)
 }
 
 func (t *SerialTests) TestStopAndRestart() {
@@ -266,6 +2670,105 @@ func (t *SerialTests) TestStopAndRestart() {
 	assert.Nil(err)
 }
 
+// checks that restored tasks older than the configured maximum age are failed
+// instead of being resumed
+func (t *SerialTests) TestStaleTasksOnRestart() {
+	assert := assert.New(t.Test)
+
+	// persist an old task and a recent one
+	client := auth.Client{
+		Name:  "Joe-bob",
+		Orcid: "1234-5678-9012-3456",
+	}
+	oldTask := transferTask{
+		Id:           uuid.New(),
+		Client:       client,
+		Source:       "test-source",
+		Destination:  "test-destination",
+		FileIds:      []string{"file1"},
+		CreationTime: time.Now().Add(-2 * time.Hour),
+		Status:       TransferStatus{Code: TransferStatusStaging},
+	}
+	recentTask := oldTask
+	recentTask.Id = uuid.New()
+	recentTask.CreationTime = time.Now().Add(-time.Minute)
+	dataFile := filepath.Join(config.Service.DataDirectory, "dts.gob")
+	os.Remove(dataFile)
+	err := saveTasks(map[uuid.UUID]transferTask{
+		oldTask.Id:    oldTask,
+		recentTask.Id: recentTask,
+	}, dataFile)
+	assert.Nil(err)
+
+	// restart with a maximum age of 1 hour
+	config.Service.ResumeMaxAge = 3600
+	defer func() { config.Service.ResumeMaxAge = 0 }()
+	err = Start()
+	assert.Nil(err)
+
+	status, err := Status(oldTask.Id)
+	assert.Nil(err)
+	assert.Equal(TransferStatusFailed, status.Code)
+	assert.Contains(status.Message, "stale on restart")
+
+	status, err = Status(recentTask.Id)
+	assert.Nil(err)
+	assert.NotEqual(TransferStatusFailed, status.Code)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+// accepts connections on the given listener until it's closed, speaking just
+// enough SMTP to receive messages, which are sent to the given channel
+func runStubSMTPServer(listener net.Listener, messages chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		serveStubSMTPConnection(conn, messages)
+	}
+}
+
+// handles a single connection to the stub SMTP server
+func serveStubSMTPConnection(conn net.Conn, messages chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost stub SMTP server\r\n")
+	var message strings.Builder
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+				messages <- message.String()
+				fmt.Fprint(conn, "250 OK\r\n")
+			} else {
+				message.WriteString(line)
+			}
+			continue
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case command == "DATA":
+			inData = true
+			fmt.Fprint(conn, "354 End data with <CR><LF>.<CR><LF>\r\n")
+		case command == "QUIT":
+			fmt.Fprint(conn, "221 Bye\r\n")
+			return
+		default: // MAIL, RCPT, etc
+			fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
 // temporary testing directory
 var TESTING_DIR string
 
@@ -300,6 +2803,10 @@ databases:
     name: Destination Test Database
     organization: Fabulous Destinations, Inc.
     endpoint: destination-endpoint
+  test-destination2:
+    name: Second Destination Test Database
+    organization: Fabulous Destinations, Inc.
+    endpoint: destination-endpoint
 endpoints:
   local-endpoint:
     name: Local endpoint
@@ -343,4 +2850,11 @@ var testResources map[string]DataResource = map[string]DataResource{
 		Bytes:  4096,
 		Hash:   "e91f9e974d0e563cab48d4d43a17e08e",
 	},
+	"malformed": { // no path!
+		Id:     "malformed",
+		Name:   "malformed.dat",
+		Format: "text",
+		Bytes:  512,
+		Hash:   "f91f9e974d0e563cab48d4d43a17e08f",
+	},
 }